@@ -0,0 +1,138 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// aggregatedField is the field of a summary of aggregated logs, counting the
+// logs it stands for, see WithAggregation.
+const aggregatedField = "aggregated"
+
+// aggregation counts the logs with the same fingerprint in each window, see
+// WithAggregation.
+type aggregation struct {
+	window      time.Duration
+	fingerprint func(log []byte) string
+
+	mu sync.Mutex
+	// counts holds the logs with each fingerprint in the current window,
+	// after the first one, which is queued as usual.
+	counts map[string]*aggregate
+}
+
+type aggregate struct {
+	n    int
+	last string
+}
+
+// numbers matches the numbers in logs, which are left out of their default
+// fingerprint.
+var numbers = regexp.MustCompile(`[0-9]+`)
+
+// defaultFingerprint returns the level and message fields of a JSON log, or
+// the whole of any other log, with numbers replaced by "#", so that the logs
+// from the same line of code, such as "retry 3 of 5", have the same
+// fingerprint.
+func defaultFingerprint(log []byte) string {
+	var fields struct {
+		Level   json.RawMessage
+		Message json.RawMessage
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(log, &object); err == nil {
+		fields.Level = object[zerolog.LevelFieldName]
+		fields.Message = object[zerolog.MessageFieldName]
+	}
+	if fields.Message == nil {
+		return numbers.ReplaceAllString(string(log), "#")
+	}
+	return string(fields.Level) + " " + numbers.ReplaceAllString(string(fields.Message), "#")
+}
+
+// aggregated reports whether the log is counted in the summary of its
+// fingerprint, rather than queued, which it is unless it is the first with its
+// fingerprint in the window. Logs with an empty fingerprint are never
+// aggregated.
+func (c *CloudWatchWriter) aggregated(log []byte) bool {
+	a := &c.aggregation
+	fingerprint := a.fingerprint(log)
+	if fingerprint == "" {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	count, ok := a.counts[fingerprint]
+	if !ok {
+		a.counts[fingerprint] = &aggregate{}
+		return false
+	}
+	count.n++
+	count.last = string(log)
+	return true
+}
+
+// aggregate queues the summaries at the end of each window, until the writer
+// has stopped.
+func (c *CloudWatchWriter) aggregate() {
+	timer := c.clock.NewTimer(c.aggregation.window)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+			c.queueSummaries(c.ctx, true)
+			timer.Reset(c.aggregation.window)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// endAggregation queues the summaries of the current window, for Flush and
+// Close. The window carries on, so the next log with one of its fingerprints
+// is still counted.
+func (c *CloudWatchWriter) endAggregation() {
+	if c.aggregation.window == 0 {
+		return
+	}
+	c.queueSummaries(context.Background(), false)
+}
+
+// queueSummaries queues a summary for each fingerprint with logs which have
+// been counted rather than queued: the last of them, with the aggregated field
+// counting them. A log which isn't a JSON object is made the message field of
+// one. With endWindow, the next window starts.
+func (c *CloudWatchWriter) queueSummaries(ctx context.Context, endWindow bool) {
+	a := &c.aggregation
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	fingerprints := make([]string, 0, len(a.counts))
+	for fingerprint := range a.counts {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+
+	for _, fingerprint := range fingerprints {
+		count := a.counts[fingerprint]
+		if count.n == 0 {
+			continue
+		}
+		fields := []field{{name: aggregatedField, value: []byte(strconv.Itoa(count.n))}}
+		_ = c.writeSummary(ctx, addFields(count.last, fields, true))
+		count.n, count.last = 0, ""
+	}
+	if endWindow {
+		a.counts = make(map[string]*aggregate)
+	}
+}
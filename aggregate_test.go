@@ -0,0 +1,107 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterAggregation(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithAggregation(time.Minute, nil),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	for _, log := range []string{
+		`{"level":"error","message":"retry 1 of 5","time":1}`,
+		`{"level":"error","message":"retry 2 of 5","time":2}`,
+		`{"level":"info","message":"retry 2 of 5"}`,
+		`connection 1 refused`,
+		`{"level":"error","message":"retry 3 of 5","time":3}`,
+		`connection 2 refused`,
+	} {
+		_, err = cloudWatchWriter.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	assert.Equal(t, []string{
+		`{"level":"error","message":"retry 1 of 5","time":1}`,
+		`{"level":"info","message":"retry 2 of 5"}`,
+		`connection 1 refused`,
+		`{"aggregated":2,"level":"error","message":"retry 3 of 5","time":3}`,
+		`{"aggregated":1,"message":"connection 2 refused"}`,
+	}, client.Messages("logGroup", "logStream"))
+}
+
+func TestCloudWatchWriterAggregationWindow(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithAggregation(time.Minute, nil),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err = cloudWatchWriter.Write([]byte("failed"))
+		assert.NoError(t, err)
+	}
+
+	// At the end of the window the summary is queued, and the next log
+	// starts the next one
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(time.Minute)
+	for cloudWatchWriter.Stats().QueuedEvents < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	_, err = cloudWatchWriter.Write([]byte("failed"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.Equal(t, []string{"failed", `{"aggregated":2,"message":"failed"}`, "failed"}, client.Messages("logGroup", "logStream"))
+}
+
+func TestCloudWatchWriterAggregationFingerprint(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithAggregation(time.Minute, func(log []byte) string {
+			if string(log) == "keep" {
+				return ""
+			}
+			return "all"
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	for _, log := range []string{"a", "keep", "b", "keep", "c"} {
+		_, err = cloudWatchWriter.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	assert.Equal(t, []string{"a", "keep", "keep", `{"aggregated":2,"message":"c"}`}, client.Messages("logGroup", "logStream"))
+}
+
+func TestCloudWatchWriterAggregationInvalid(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithAggregation(-time.Second, nil),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,19 @@
+package cloudwatchwriter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ansiEscapes matches ANSI escape sequences: control sequences such as the
+// colours of console output, operating system commands such as window titles
+// and hyperlinks, and the other two byte sequences.
+var ansiEscapes = regexp.MustCompile("\x1b(?:\\[[0-?]*[ -/]*[@-~]|\\][^\x07\x1b]*(?:\x07|\x1b\\\\)|[@-Z\\\\-_])")
+
+// stripANSI removes the ANSI escape sequences from a message.
+func stripANSI(message string) string {
+	if !strings.Contains(message, "\x1b") {
+		return message
+	}
+	return ansiEscapes.ReplaceAllString(message, "")
+}
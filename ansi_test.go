@@ -0,0 +1,44 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterANSIStripping(t *testing.T) {
+	tests := []struct {
+		name    string
+		log     string
+		message string
+	}{
+		{"colours", "\x1b[1;31mERROR\x1b[0m failed", "ERROR failed"},
+		{"cursor", "50%\x1b[2K\x1b[1G100%", "50%100%"},
+		{"hyperlink", "see \x1b]8;;https://example.com\x07the docs\x1b]8;;\x1b\\", "see the docs"},
+		{"two bytes", "\x1bMup", "up"},
+		{"none", "plain log", "plain log"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &mockClient{}
+			cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+				cloudwatchwriter.WithANSIStripping(),
+			)
+			if err != nil {
+				t.Fatalf("NewWithClient: %v", err)
+			}
+			defer cloudWatchWriter.Close()
+
+			_, err = cloudWatchWriter.Write([]byte(test.log))
+			assert.NoError(t, err)
+			assert.NoError(t, cloudWatchWriter.Flush())
+
+			events := client.getLogEvents()
+			if assert.Len(t, events, 1) {
+				assert.Equal(t, test.message, *events[0].Message)
+			}
+		})
+	}
+}
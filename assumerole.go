@@ -0,0 +1,25 @@
+package cloudwatchwriter
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// NewAssumeRole is like New, but sends the logs with the credentials of the IAM
+// role roleARN, which are fetched with STS AssumeRole using cfg and refreshed
+// before they expire. This is for fleets sending their logs to a log group in
+// another account, such as a dedicated logging account, whose role they are
+// allowed to assume.
+func NewAssumeRole(cfg aws.Config, roleARN, logGroupName, logStreamName string, opts ...Option) (*CloudWatchWriter, error) {
+	if !arn.IsARN(roleARN) {
+		return nil, fmt.Errorf("invalid role ARN: %s", roleARN)
+	}
+
+	roleCfg := cfg.Copy()
+	roleCfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleARN))
+	return New(roleCfg, logGroupName, logStreamName, opts...)
+}
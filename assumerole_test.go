@@ -0,0 +1,14 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestNewAssumeRoleInvalidRoleARN(t *testing.T) {
+	_, err := cloudwatchwriter.NewAssumeRole(aws.Config{}, "logging-role", "logGroup", "logStream")
+	assert.Error(t, err)
+}
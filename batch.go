@@ -0,0 +1,63 @@
+package cloudwatchwriter
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// batch is a batch of log events to be sent with one PutLogEvents request,
+// which keeps track of how close it is to the limits AWS puts on a batch.
+type batch struct {
+	events []types.InputLogEvent
+	size   int
+	// start and end are the earliest and latest timestamps in the batch.
+	start, end int64
+}
+
+// fits reports whether the event can be added to the batch without it going
+// over the 1MB limit on batch size, or spanning more than 24 hours.
+func (b *batch) fits(event types.InputLogEvent) bool {
+	if len(b.events) == 0 {
+		return true
+	}
+	if b.size+len(*event.Message)+additionalBytesPerLogEvent > batchSizeLimit {
+		return false
+	}
+
+	timestamp := *event.Timestamp
+	return timestamp-b.start <= maxBatchSpan && b.end-timestamp <= maxBatchSpan
+}
+
+func (b *batch) add(event types.InputLogEvent) {
+	timestamp := *event.Timestamp
+	if len(b.events) == 0 || timestamp < b.start {
+		b.start = timestamp
+	}
+	if len(b.events) == 0 || timestamp > b.end {
+		b.end = timestamp
+	}
+
+	b.events = append(b.events, event)
+	b.size += len(*event.Message) + additionalBytesPerLogEvent
+}
+
+// full reports whether the batch has reached the 10k limit on the number of
+// log events.
+func (b *batch) full() bool {
+	return len(b.events) >= maxNumLogEvents
+}
+
+// take returns the events in the batch, in chronological order as AWS
+// requires, and empties it. Logs written at the same time by different
+// goroutines can be queued slightly out of order, events with the same
+// timestamp are kept in the order they were queued.
+func (b *batch) take() []types.InputLogEvent {
+	events := b.events
+	*b = batch{}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return *events[i].Timestamp < *events[j].Timestamp
+	})
+	return events
+}
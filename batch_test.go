@@ -0,0 +1,74 @@
+package cloudwatchwriter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func helperEvent(message string, timestamp time.Time) types.InputLogEvent {
+	return types.InputLogEvent{
+		Message:   aws.String(message),
+		Timestamp: aws.Int64(timestamp.UnixNano() / int64(time.Millisecond)),
+	}
+}
+
+func TestBatchSizeLimit(t *testing.T) {
+	var b batch
+	now := time.Now()
+
+	// An empty batch takes anything
+	message := strings.Repeat("a", batchSizeLimit/2-additionalBytesPerLogEvent)
+	assert.True(t, b.fits(helperEvent(message, now)))
+	b.add(helperEvent(message, now))
+	assert.True(t, b.fits(helperEvent(message, now)))
+	b.add(helperEvent(message, now))
+	assert.False(t, b.fits(helperEvent("a", now)))
+
+	assert.Equal(t, 2, len(b.take()))
+	assert.True(t, b.fits(helperEvent(message, now)))
+}
+
+func TestBatchNumEventsLimit(t *testing.T) {
+	var b batch
+	now := time.Now()
+
+	for i := 0; i < maxNumLogEvents; i++ {
+		assert.False(t, b.full())
+		b.add(helperEvent("a", now))
+	}
+	assert.True(t, b.full())
+}
+
+func TestBatchSpanLimit(t *testing.T) {
+	var b batch
+	now := time.Now()
+
+	b.add(helperEvent("a", now))
+	b.add(helperEvent("b", now.Add(-12*time.Hour)))
+	assert.True(t, b.fits(helperEvent("c", now.Add(12*time.Hour))))
+	assert.False(t, b.fits(helperEvent("c", now.Add(12*time.Hour+time.Millisecond))))
+	assert.True(t, b.fits(helperEvent("c", now.Add(-12*time.Hour))))
+	assert.False(t, b.fits(helperEvent("c", now.Add(-24*time.Hour-time.Millisecond))))
+}
+
+func TestBatchTakeSorted(t *testing.T) {
+	var b batch
+	now := time.Now()
+
+	b.add(helperEvent("b1", now))
+	b.add(helperEvent("a", now.Add(-time.Millisecond)))
+	b.add(helperEvent("b2", now))
+	b.add(helperEvent("c", now.Add(time.Millisecond)))
+	b.add(helperEvent("b3", now))
+
+	var messages []string
+	for _, event := range b.take() {
+		messages = append(messages, *event.Message)
+	}
+	assert.Equal(t, []string{"a", "b1", "b2", "b3", "c"}, messages)
+}
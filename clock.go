@@ -0,0 +1,43 @@
+package cloudwatchwriter
+
+import "time"
+
+// Clock tells the time and makes the timers the writer waits with, for the
+// batch interval, retries and the block timeout, see WithClock. It is also the
+// time of the logs written without a timestamp, and the time the age of logs
+// is measured from.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer made by a Clock, which behaves like a *time.Timer, with its
+// channel returned by C.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// SystemClock returns the Clock of the system, which is used by default.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
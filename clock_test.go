@@ -0,0 +1,58 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterClock(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}},
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := cloudwatchwritertest.NewClock(start)
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Hour, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Minute,
+			MaxBackoff:     time.Minute,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+
+	// Nothing is sent until the batch interval has passed on the clock
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(time.Hour - time.Second)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 0, client.numPutLogEventsCalls())
+
+	// The first attempt fails, and the retry is made once the backoff has
+	// passed on the clock
+	clock.Advance(time.Second)
+	assert.NoError(t, client.waitForPutLogEventsCalls(1, time.Second))
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	assert.Equal(t, 0, client.numLogs())
+	clock.Advance(time.Minute)
+	assert.NoError(t, client.waitForLogs(1, time.Second))
+
+	events := client.getLogEvents()
+	assert.Equal(t, start.UnixMilli(), *events[0].Timestamp)
+	assert.Equal(t, start.Add(time.Hour+time.Minute), cloudWatchWriter.Stats().LastSuccessTime)
+}
+
+func TestCloudWatchWriterNilClock(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, time.Hour, "logGroup", "logStream", cloudwatchwriter.WithClock(nil))
+	assert.Error(t, err)
+}
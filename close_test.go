@@ -0,0 +1,62 @@
+package cloudwatchwriter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterCloseWithContext(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, cloudWatchWriter.CloseWithContext(ctx))
+	assert.Equal(t, 1, client.numLogs())
+}
+
+func TestCloudWatchWriterCloseWithContextTimeout(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client)
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 1"}, exampleLog{Message: "Test message 2"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := cloudWatchWriter.CloseWithContext(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "undelivered logs: 3")
+	}
+	assert.Equal(t, 0, client.numLogs())
+
+	// Closing again is fine
+	cloudWatchWriter.Close()
+}
+
+func TestCloudWatchWriterCloseWithContextFailedBatch(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+
+	err = cloudWatchWriter.CloseWithContext(context.Background())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "undelivered logs: 1")
+	}
+}
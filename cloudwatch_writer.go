@@ -2,14 +2,22 @@ package cloudwatchwriter
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
 	"sync"
 	"time"
+	"unsafe"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
-	"github.com/pkg/errors"
-	"gopkg.in/oleiade/lane.v1"
+	"github.com/rs/zerolog"
 )
 
 const (
@@ -30,6 +38,17 @@ const (
 	// event, other than the length of the log message, see:
 	// https://docs.aws.amazon.com/AmazonCloudWatchLogs/latest/APIReference/API_PutLogEvents.html
 	additionalBytesPerLogEvent = 26
+	// errorsBufferSize is the number of errors the channel returned by Errors
+	// holds, any more are discarded until they have been received.
+	errorsBufferSize = 64
+	// maxBatchSpan is the maximum time between the earliest and the latest
+	// log events in a batch, in milliseconds, another AWS limitation, see:
+	// https://docs.aws.amazon.com/AmazonCloudWatchLogs/latest/APIReference/API_PutLogEvents.html
+	maxBatchSpan = int64(24 * time.Hour / time.Millisecond)
+	// maxEventAge and maxEventFuture are how far in the past and the future a
+	// log event can be for CloudWatch to accept it, see the same page.
+	maxEventAge    = 14 * 24 * time.Hour
+	maxEventFuture = 2 * time.Hour
 )
 
 // CloudWatchLogsClient represents the AWS cloudwatchlogs client that we need to talk to CloudWatch
@@ -43,45 +62,434 @@ type CloudWatchLogsClient interface {
 // CloudWatchWriter can be inserted into zerolog to send logs to CloudWatch.
 type CloudWatchWriter struct {
 	sync.RWMutex
-	client            CloudWatchLogsClient
-	batchInterval     time.Duration
-	queue             *lane.Queue
-	err               error
-	logGroupName      *string
-	logStreamName     *string
+	client        CloudWatchLogsClient
+	batchInterval time.Duration
+	// baseClient and opts are the client and options given to the
+	// constructor, before the middleware, which Stream creates its writers
+	// with.
+	baseClient CloudWatchLogsClient
+	opts       []Option
+	// streams are the writers returned by Stream which are still open, whose
+	// logs the writer sends with its own, parent is the writer a stream was
+	// returned by.
+	streams        []*CloudWatchWriter
+	streamsMu      sync.Mutex
+	parent         *CloudWatchWriter
+	retryPolicy    RetryPolicy
+	queue          *eventQueue
+	maxQueueEvents int
+	maxQueueBytes  int
+	overflowPolicy OverflowPolicy
+	// overflowPolicySet is set by WithOverflowPolicy, so that
+	// WithMaxBufferedBytes leaves its policy as it is.
+	overflowPolicySet bool
+	blockTimeout      time.Duration
+	minLevel          zerolog.Level
+	// flushLevel is the level from which logs are sent straight away, if
+	// flushOnLevel is set, see WithFlushLevel.
+	flushLevel     zerolog.Level
+	flushOnLevel   bool
+	filters        filters
+	sampling       sampling
+	repeats        repeats
+	aggregation    aggregation
+	rateLimit      rateLimit
+	redactions     []Redaction
+	transformers   []func(LogEvent) (LogEvent, bool)
+	oversizePolicy OversizePolicy
+	// invalidUTF8Policy is applied to logs which aren't valid UTF-8.
+	invalidUTF8Policy InvalidUTF8Policy
+	// splitLines makes each line of a log a separate one, see
+	// WithLineSplitting.
+	splitLines bool
+	// stripANSI removes ANSI escape sequences from logs.
+	stripANSI bool
+	// trimNewlines removes the newlines at the end of logs.
+	trimNewlines bool
+	// fatalsPending counts the fatal and panic logs which FlushHook has seen
+	// but which haven't been written to the writer yet.
+	fatalsPending int
+	// heartbeatInterval is how often a heartbeat log is written, see
+	// WithHeartbeat.
+	heartbeatInterval time.Duration
+	// sessionMarkers writes a log when the writer is created and another when
+	// it is closed, see WithSessionMarkers, sessionStarted is when the first
+	// was written.
+	sessionMarkers bool
+	sessionStarted time.Time
+	sessionStop    sync.Once
+	// summaryWriter, if not nil, writes the logs standing for several
+	// others instead of the queue, see writeSummary.
+	summaryWriter func(ctx context.Context, log string) error
+	// sequenceField, if not empty, is the field each log is numbered in, see
+	// WithSequenceNumbers, sequenceNumber is the last number given out.
+	sequenceField  string
+	sequenceNumber int64
+	// rejectedEventsHandler is called with the events of a batch which
+	// CloudWatch rejects.
+	rejectedEventsHandler func(RejectedEvents)
+	stats                 Stats
+	// latencies are the delivery latencies of the last log events sent.
+	latencies latencies
+	// throttleFactor multiplies the batch interval while CloudWatch is
+	// throttling the writer.
+	throttleFactor int
+	// retentionDays is the retention period set on a log group the writer
+	// creates, zero means logs never expire.
+	retentionDays int32
+	// logGroupTags are added to the log group, logGroupCreated is set if the
+	// writer created it, and so it already has them.
+	logGroupTags    map[string]string
+	logGroupCreated bool
+	// fallbackWriter, if not nil, is written the logs of batches which could
+	// not be sent.
+	fallbackWriter io.Writer
+	// sendHooks are called after each batch has been sent, or given up on.
+	sendHooks []func(BatchSend)
+	// expvarName, if not empty, is the name the writer's Stats are published
+	// under with expvar.
+	expvarName string
+	// spoolDir, if not empty, is the directory of the spool which holds the
+	// queued events on disk.
+	spoolDir string
+	// deadLetterHandler is called with the events of batches which could not
+	// be sent, and why.
+	deadLetterHandler func([]types.InputLogEvent, error)
+	// dropHandler, if not nil, is called with each log which is dropped.
+	dropHandler func([]byte, DropReason)
+	// errorHandler, if not nil, is called with the errors from sending logs,
+	// instead of them being returned by the next Write.
+	errorHandler func(error)
+	// writeNeverFails makes Write always succeed, and stops the errors from
+	// sending logs from being kept for Write to return.
+	writeNeverFails bool
+	// logger, if not nil, is told about what the writer is doing.
+	logger Logger
+	// middleware wraps the calls to the client.
+	middleware []Middleware
+	clock      Clock
+	// queueBudget, if not nil, limits the size of the logs queued by the
+	// writers of a Pool between them.
+	queueBudget *queueBudget
+	// limiter, if not nil, limits the rate of the batches sent, along with
+	// the other writers sharing it.
+	limiter *Limiter
+	// maxAge is how old a queued log event can be before it is dropped
+	// rather than sent, see WithMaxEventAge, zero means any age.
+	maxAge time.Duration
+	// firstSendJitter is the most the first batch is delayed by, at random,
+	// see WithFirstSendJitter.
+	firstSendJitter time.Duration
+	// numSenders is the number of goroutines sending the batches, see
+	// WithSenders, and senders, if not nil, hands the batches to them.
+	numSenders int
+	senders    *senders
+	// manualPump stops the writer from sending the logs in the background,
+	// they are sent by pump when Pump is called instead.
+	manualPump bool
+	pump       *pump
+	pumpMu     sync.Mutex
+	closePump  sync.Once
+	// manualStart stops the constructor from setting up the log stream and
+	// starting the queueMonitor, which Start does instead. lifecycle is held
+	// while the writer is started, stopped or closed.
+	manualStart   bool
+	lifecycle     sync.Mutex
+	setUp         bool
+	spoolReplayed bool
+	closeStarted  bool
+	// stopMonitor is closed to stop the queueMonitor, which closes
+	// monitorStopped once it has, they are nil while it isn't running.
+	stopMonitor    chan struct{}
+	monitorStopped chan struct{}
+	// errors receives the errors from sending logs, it is closed once the
+	// writer has stopped.
+	errors      chan error
+	closeErrors sync.Once
+	// logGroupClass is the class of a log group the writer creates, the
+	// default is the standard class.
+	logGroupClass types.LogGroupClass
+	err           error
+	logGroupName  *string
+	// logGroupARN is the ARN the log group was given by, if it was, see
+	// checkLogGroupARN.
+	logGroupARN *arn.ARN
+	// logStreamName is only changed by SetLogStream, which holds logStreamMu
+	// so that it switches streams one at a time.
+	logStreamName *string
+	logStreamMu   sync.Mutex
+	// logStreamTemplate is the log stream name given to the constructor,
+	// before expandName, which WithLogStreamRotation expands again for each
+	// log stream.
+	logStreamTemplate string
+	// logStreamOrderBy and logStreamDescending are the order findLogStream
+	// goes through the log streams in, see WithLogStreamLookupOrder.
+	logStreamOrderBy    types.OrderBy
+	logStreamDescending bool
+	// imdsClient, if not nil, looks up the EC2 instance ID for {instance_id},
+	// which is only looked up once.
+	imdsClient     *imds.Client
+	instanceID     string
+	instanceIDOnce sync.Once
+	// fields are added to each log, see addFields, with the ECS task
+	// metadata first if ecsFields is set.
+	fields       []field
+	wrapLogs     bool
+	ecsFields    bool
+	staticFields map[string]interface{}
+	// timestampField is the field of JSON logs with their timestamps, in
+	// timestampLayout, see WithTimestampField.
+	timestampField  string
+	timestampLayout string
+	ecsMetadata     *ecsMetadata
+	ecsMetadataOnce sync.Once
+	rotation        rotation
+	// logStreamEvents and logStreamBytes count what has been sent to the
+	// current log stream, for WithLogStreamSizeRotation.
+	logStreamEvents   int64
+	logStreamBytes    int64
 	nextSequenceToken *string
+	sequenceTokens    bool
 	closing           bool
 	done              chan struct{}
+	intervalChanged   chan struct{}
+	flushRequests     chan chan struct{}
+	// sendNow asks the queueMonitor to send the queued logs without waiting
+	// for the batch interval.
+	sendNow chan struct{}
+	// ctx is cancelled to abandon sending logs when closing takes too long.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// undelivered counts the logs which failed to be sent since the writer
+	// started closing, lastUndeliveredErr is why the last of them failed.
+	undelivered        int
+	lastUndeliveredErr error
 }
 
-// New returns a pointer to a CloudWatchWriter struct, or an error.
-func New(cfg aws.Config, logGroupName, logStreamName string) (*CloudWatchWriter, error) {
-	return NewWithClient(cloudwatchlogs.NewFromConfig(cfg), defaultBatchInterval, logGroupName, logStreamName)
+// New returns a pointer to a CloudWatchWriter struct, or an error. The
+// writer can be configured with any number of Options. The log group can be
+// given by its name or its ARN, which must be of a log group in the account and
+// region of the client, as only the name is sent to CloudWatch. The writer
+// checks that it is when it starts, and doesn't create a log group given by
+// its ARN. In the names of the log group and log stream,
+// {hostname} is replaced with the host name, {pid} with the process ID,
+// {date} with the current date in UTC, as 2006-01-02, {instance_id} with the
+// EC2 instance ID, see WithEC2Metadata, and {ecs_cluster}, {ecs_task_id} and
+// {ecs_container} with the ECS cluster, task ID and container name, see
+// WithECSMetadataFields. Those which can't be looked up are replaced with the
+// host name.
+func New(cfg aws.Config, logGroupName, logStreamName string, opts ...Option) (*CloudWatchWriter, error) {
+	return NewWithClient(cloudwatchlogs.NewFromConfig(cfg), defaultBatchInterval, logGroupName, logStreamName, opts...)
 }
 
 // NewWithClient returns a pointer to a CloudWatchWriter struct, or an error.
-func NewWithClient(client CloudWatchLogsClient, batchInterval time.Duration, logGroupName, logStreamName string) (*CloudWatchWriter, error) {
-	writer := &CloudWatchWriter{
-		client:        client,
-		queue:         lane.NewQueue(),
-		logGroupName:  aws.String(logGroupName),
-		logStreamName: aws.String(logStreamName),
-		done:          make(chan struct{}),
+// Options are applied after batchInterval, so WithBatchInterval takes
+// precedence over it.
+func NewWithClient(client CloudWatchLogsClient, batchInterval time.Duration, logGroupName, logStreamName string, opts ...Option) (*CloudWatchWriter, error) {
+	writer, err := newUnstartedWriter(client, batchInterval, logGroupName, logStreamName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if writer.manualPump && writer.manualStart {
+		return nil, errors.New("a manually pumped writer can't be started manually")
+	}
+	if err = writer.setupRotation(); err != nil {
+		return nil, err
+	}
+	if writer.spoolDir != "" {
+		if writer.manualPump {
+			return nil, errors.New("a manually pumped writer can't use a spool")
+		}
+		if writer.queue.spool, err = openSpool(writer.spoolDir); err != nil {
+			return nil, err
+		}
 	}
+	writer.pump = newPump(writer)
 
-	err := writer.SetBatchInterval(batchInterval)
+	if !writer.manualStart {
+		if err = writer.setup(writer.ctx); err != nil {
+			return nil, err
+		}
+		if !writer.manualPump {
+			writer.startMonitor()
+		}
+		if writer.queue.spool != nil {
+			writer.spoolReplayed = true
+			writer.replaySpool()
+		}
+	}
+	if writer.rotation.full != nil {
+		go writer.rotateLogStreams()
+	}
+	if writer.aggregation.window > 0 {
+		go writer.aggregate()
+	}
+	if writer.heartbeatInterval > 0 {
+		go writer.heartbeat()
+	}
+	if writer.sessionMarkers {
+		writer.writeSessionStart()
+	}
+	if writer.expvarName != "" {
+		writer.publishExpvar()
+	}
+
+	return writer, nil
+}
+
+// newWriter returns a writer with the options applied and checked, once it has
+// found or created the log stream, without starting to send the logs.
+func newWriter(client CloudWatchLogsClient, batchInterval time.Duration, logGroupName, logStreamName string, opts ...Option) (*CloudWatchWriter, error) {
+	writer, err := newUnstartedWriter(client, batchInterval, logGroupName, logStreamName, opts...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "set batch interval: %v", batchInterval)
+		return nil, err
 	}
+	if err = writer.setup(writer.ctx); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
 
-	logStream, err := writer.getOrCreateLogStream()
+// newUnstartedWriter returns a writer with the options applied and checked,
+// without making any requests to CloudWatch.
+func newUnstartedWriter(client CloudWatchLogsClient, batchInterval time.Duration, logGroupName, logStreamName string, opts ...Option) (*CloudWatchWriter, error) {
+	logGroupName, logGroupARN, err := parseLogGroupName(logGroupName)
 	if err != nil {
 		return nil, err
 	}
-	writer.setNextSequenceToken(logStream.UploadSequenceToken)
+	if _, ok := client.(logGroupDescriber); logGroupARN != nil && !ok {
+		return nil, errors.New("a log group ARN needs a client which implements DescribeLogGroups")
+	}
+
+	writer := &CloudWatchWriter{
+		client:            client,
+		batchInterval:     batchInterval,
+		baseClient:        client,
+		opts:              opts,
+		retryPolicy:       defaultRetryPolicy(),
+		minLevel:          zerolog.TraceLevel,
+		throttleFactor:    1,
+		logGroupARN:       logGroupARN,
+		logStreamTemplate: logStreamName,
+		done:              make(chan struct{}),
+		intervalChanged:   make(chan struct{}, 1),
+		flushRequests:     make(chan chan struct{}),
+		sendNow:           make(chan struct{}, 1),
+		errors:            make(chan error, errorsBufferSize),
+		clock:             systemClock{},
+	}
+	writer.ctx, writer.cancel = context.WithCancel(context.Background())
+
+	for _, opt := range opts {
+		opt(writer)
+	}
+	if len(writer.middleware) > 0 {
+		writer.client = newMiddlewareClient(writer.client, writer.middleware)
+	}
+	if writer.clock == nil {
+		return nil, errors.New("clock must not be nil")
+	}
+	now := writer.clock.Now()
+	writer.logGroupName = aws.String(writer.expandName(logGroupName, now))
+	writer.logStreamName = aws.String(writer.expandName(logStreamName, now))
+	if writer.ecsFields {
+		writer.fields = writer.ecsMetadataFields()
+	}
+	if len(writer.staticFields) > 0 {
+		fields, err := staticFields(writer.staticFields)
+		if err != nil {
+			return nil, err
+		}
+		writer.fields = append(writer.fields, fields...)
+		writer.wrapLogs = true
+	}
 
-	go writer.queueMonitor()
+	err = writer.SetBatchInterval(writer.batchInterval)
+	if err != nil {
+		return nil, fmt.Errorf("set batch interval: %v: %w", writer.batchInterval, err)
+	}
+
+	if err = writer.retryPolicy.validate(); err != nil {
+		return nil, err
+	}
 
+	if writer.maxQueueEvents < 0 || writer.maxQueueBytes < 0 {
+		return nil, errors.New("max queue size must not be negative")
+	}
+	if writer.repeats.window < 0 {
+		return nil, errors.New("duplicate suppression window must not be negative")
+	}
+	if writer.rateLimit.perSecond < 0 {
+		return nil, errors.New("rate limit must not be negative")
+	}
+	if writer.rateLimit.perSecond > 0 && writer.rateLimit.burst < 1 {
+		return nil, errors.New("rate limit burst must be at least 1")
+	}
+	writer.rateLimit.buckets = make(map[string]*bucket)
+	if writer.heartbeatInterval < 0 {
+		return nil, errors.New("heartbeat interval must not be negative")
+	}
+	if writer.aggregation.window < 0 {
+		return nil, errors.New("aggregation window must not be negative")
+	}
+	if writer.aggregation.fingerprint == nil {
+		writer.aggregation.fingerprint = defaultFingerprint
+	}
+	writer.aggregation.counts = make(map[string]*aggregate)
+	if writer.sampling.n < 0 {
+		return nil, errors.New("sampling rate must not be negative")
+	}
+	if writer.blockTimeout < 0 {
+		return nil, errors.New("block timeout must not be negative")
+	}
+	if writer.maxAge < 0 {
+		return nil, errors.New("max event age must not be negative")
+	}
+	if writer.firstSendJitter < 0 {
+		return nil, errors.New("first send jitter must not be negative")
+	}
+	if writer.numSenders < 0 {
+		return nil, errors.New("number of senders must not be negative")
+	}
+	switch writer.logStreamOrderBy {
+	case "", types.OrderByLogStreamName, types.OrderByLastEventTime:
+	default:
+		return nil, fmt.Errorf("invalid log stream lookup order: %s", writer.logStreamOrderBy)
+	}
+	if writer.senders == nil && writer.numSenders > 0 {
+		writer.senders = newSenders(writer.numSenders)
+	}
+	if writer.limiter != nil {
+		if err = writer.limiter.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if writer.retentionDays != 0 {
+		if !validRetentionDays(writer.retentionDays) {
+			return nil, fmt.Errorf("invalid retention days: %d", writer.retentionDays)
+		}
+		if _, ok := client.(retentionPolicyPutter); !ok {
+			return nil, errors.New("retention days need a client which implements PutRetentionPolicy")
+		}
+	}
+	if len(writer.logGroupTags) > 0 {
+		if _, ok := client.(logGroupDescriber); !ok {
+			return nil, errors.New("log group tags need a client which implements DescribeLogGroups")
+		}
+		if _, ok := client.(resourceTagger); !ok {
+			return nil, errors.New("log group tags need a client which implements TagResource")
+		}
+	}
+	if writer.expvarName != "" && expvar.Get(writer.expvarName) != nil {
+		return nil, fmt.Errorf("expvar already published: %s", writer.expvarName)
+	}
+	writer.queue = newEventQueue(writer.maxQueueEvents, writer.maxQueueBytes, writer.overflowPolicy, writer.blockTimeout, writer.clock)
+	writer.queue.budget = writer.queueBudget
+	if writer.dropHandler != nil {
+		writer.queue.onEvict = func(event types.InputLogEvent) {
+			writer.callDropHandler(aws.ToString(event.Message), DropReasonEvicted)
+		}
+	}
 	return writer, nil
 }
 
@@ -89,18 +497,61 @@ func NewWithClient(client CloudWatchLogsClient, batchInterval time.Duration, log
 // CloudWatch.
 func (c *CloudWatchWriter) SetBatchInterval(interval time.Duration) error {
 	if interval < minBatchInterval {
-		return errors.New("supplied batch interval is less than the minimum")
+		return ErrBatchIntervalTooSmall
 	}
 
 	c.setBatchInterval(interval)
 	return nil
 }
 
-func (c *CloudWatchWriter) setBatchInterval(interval time.Duration) {
+// SetLogStream switches the writer to sending the logs to another log stream in
+// the log group, such as when a daemon's configuration is reloaded. The logs
+// written so far are flushed to the current log stream first, and if that
+// fails SetLogStream returns the error without switching. It then finds or
+// creates the new log stream, returning the error if that fails, and only then
+// switches to it, so the logs written while it is in progress are sent to one
+// log stream or the other. The logs queued by a writer which isn't running,
+// see WithManualStart, are sent to the new log stream. The placeholders in the
+// name are replaced as they are by New.
+func (c *CloudWatchWriter) SetLogStream(name string) error {
+	c.logStreamMu.Lock()
+	defer c.logStreamMu.Unlock()
+
+	if c.isClosing() {
+		return ErrClosed
+	}
+	if c.expandName(name, c.clock.Now()) == aws.ToString(c.getLogStreamName()) {
+		return nil
+	}
+	if err := c.Flush(); err != nil && !errors.Is(err, ErrNotStarted) {
+		return err
+	}
+
+	logStreamName := aws.String(c.expandName(name, c.clock.Now()))
+	logStream, err := c.getOrCreateLogStream(c.ctx, logStreamName)
+	if err != nil {
+		return err
+	}
+
 	c.Lock()
 	defer c.Unlock()
 
+	c.logStreamName = logStreamName
+	c.nextSequenceToken = logStream.UploadSequenceToken
+	c.logStreamEvents, c.logStreamBytes = 0, 0
+	return nil
+}
+
+func (c *CloudWatchWriter) setBatchInterval(interval time.Duration) {
+	c.Lock()
 	c.batchInterval = interval
+	c.Unlock()
+
+	// Let the queueMonitor know, so it can reschedule the next batch
+	select {
+	case c.intervalChanged <- struct{}{}:
+	default:
+	}
 }
 
 func (c *CloudWatchWriter) getBatchInterval() time.Duration {
@@ -117,6 +568,33 @@ func (c *CloudWatchWriter) setErr(err error) {
 	c.err = err
 }
 
+// reportErr reports an error from sending logs on the errors channel, if it
+// isn't full, and to the error handler if there is one, otherwise by keeping it
+// for the next Write or Flush to return, unless Write never fails.
+func (c *CloudWatchWriter) reportErr(err error) {
+	select {
+	case c.errors <- err:
+	default:
+	}
+
+	switch {
+	case c.errorHandler != nil:
+		c.errorHandler(err)
+	case !c.writeNeverFails:
+		c.setErr(err)
+	}
+}
+
+// Errors returns a channel which receives the errors from sending logs to
+// CloudWatch, such as a batch which could not be sent after retrying, so that
+// they can be handled by another goroutine. The errors are still returned by
+// Write, unless there is an error handler. The channel holds up to 64 errors,
+// later ones are discarded until they have been received. It is closed when
+// the writer is closed.
+func (c *CloudWatchWriter) Errors() <-chan error {
+	return c.errors
+}
+
 func (c *CloudWatchWriter) getErr() error {
 	c.RLock()
 	defer c.RUnlock()
@@ -124,28 +602,153 @@ func (c *CloudWatchWriter) getErr() error {
 	return c.err
 }
 
-func (c *CloudWatchWriter) setNextSequenceToken(next *string) {
+// takeErr returns the last error from sending logs, which is then forgotten.
+func (c *CloudWatchWriter) takeErr() error {
 	c.Lock()
 	defer c.Unlock()
 
-	c.nextSequenceToken = next
+	err := c.err
+	c.err = nil
+	return err
+}
+
+// setNextSequenceToken sets the next sequence token for the log stream, unless
+// SetLogStream has switched to another one since.
+func (c *CloudWatchWriter) setNextSequenceToken(logStreamName, next *string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if logStreamName == c.logStreamName {
+		c.nextSequenceToken = next
+	}
+}
+
+func (c *CloudWatchWriter) getLogStreamName() *string {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.logStreamName
 }
 
 func (c *CloudWatchWriter) getNextSequenceToken() *string {
 	c.RLock()
 	defer c.RUnlock()
 
+	if !c.sequenceTokens {
+		return nil
+	}
 	return c.nextSequenceToken
 }
 
-// Write implements the io.Writer interface.
+// enableSequenceTokens switches the writer to sending sequence tokens, for
+// endpoints which still reject PutLogEvents calls without a valid one.
+func (c *CloudWatchWriter) enableSequenceTokens(logStreamName, next *string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.sequenceTokens = true
+	if logStreamName == c.logStreamName {
+		c.nextSequenceToken = next
+	}
+}
+
+// Write implements the io.Writer interface. It returns ErrQueueFull if the log
+// is dropped because the queue is full or, with the Reject oversize policy, a
+// *MessageTooLargeError, which matches ErrMessageTooLarge, because it is too
+// large. It never fails with WithWriteNeverFails.
 func (c *CloudWatchWriter) Write(log []byte) (int, error) {
-	event := &types.InputLogEvent{
-		Message: aws.String(string(log)),
-		// Timestamp has to be in milliseconds since the epoch
-		Timestamp: aws.Int64(time.Now().UTC().UnixNano() / int64(time.Millisecond)),
+	return c.WriteContext(context.Background(), log)
+}
+
+// WriteContext is like Write, but with the Block overflow policy it gives up
+// waiting for space in the queue when ctx is done, returning ctx.Err().
+func (c *CloudWatchWriter) WriteContext(ctx context.Context, log []byte) (int, error) {
+	if c.splitLines {
+		return writeLines(log, func(line []byte) error {
+			_, err := c.writeLevel(ctx, c.levelOf(line), line, "", nil)
+			return err
+		})
+	}
+	return c.writeLevel(ctx, c.levelOf(log), log, "", nil)
+}
+
+// WriteString implements the io.StringWriter interface, writing the log like
+// Write, for callers which already have it as a string, such as
+// io.WriteString, without the copy of it which converting it to a []byte and
+// back would take, unless the log is passed to functions of the caller's, such
+// as filters, see WithAllowFilters.
+func (c *CloudWatchWriter) WriteString(log string) (int, error) {
+	b := stringBytes(log)
+	if c.splitLines {
+		// The lines are copied, as Write does
+		return c.Write(b)
+	}
+	return c.writeLevel(context.Background(), c.levelOf(b), b, log, nil)
+}
+
+// writeLevel writes a log at the level, which is zerolog.NoLevel if it isn't
+// known, unless it is filtered out, see WithDenyFilters, or sampled out, see
+// WithSampling, then sends it straight away if its level is high enough, see
+// WithFlushLevel. The log's events are added to the delivery, if there is one,
+// see WriteAsync. The text, if not empty, is the log as a string, which its
+// events share, see WriteString.
+func (c *CloudWatchWriter) writeLevel(ctx context.Context, level zerolog.Level, log []byte, text string, delivery *Delivery) (int, error) {
+	level = c.fatalLevel(level, log)
+	if ok, err := c.screen(ctx, level, log, text); !ok {
+		delivery.fail(err)
+		return c.writeResult(log, err)
+	}
+	_, err := c.write(ctx, log, text, delivery)
+	c.flushAtLevel(level)
+	return c.writeResult(log, err)
+}
+
+// screen decides whether a log at the level is written, before its events
+// are made, for every kind of writer: it isn't if it is filtered out, see
+// WithDenyFilters, sampled out, see WithSampling, counted as a copy of the
+// last log, see WithDuplicateSuppression, or in a summary, see
+// WithAggregation, or if it is over the rate limit, see WithRateLimit, in
+// which case it is dropped and the error is ErrRateLimited. The text, if not
+// empty, is the log as a string, see WriteString.
+func (c *CloudWatchWriter) screen(ctx context.Context, level zerolog.Level, log []byte, text string) (bool, error) {
+	if text != "" && c.callsBackWithLog() {
+		// The log is a view of the text, see stringBytes, which the
+		// caller's functions could modify or keep
+		log = []byte(text)
+	}
+	if c.filteredOut(log) || c.sampledOut(level, log) {
+		return false, nil
+	}
+	if c.repeats.window > 0 && c.suppressRepeat(ctx, log) {
+		return false, nil
+	}
+	if c.aggregation.window > 0 && c.aggregated(log) {
+		return false, nil
+	}
+	if c.rateLimited(log) {
+		c.dropped(c.redact(logString(log, text)), ErrRateLimited)
+		return false, ErrRateLimited
+	}
+	return true, nil
+}
+
+// writeResult returns what writing the log returns, given the error from
+// writing it, which isn't returned with WithWriteNeverFails.
+func (c *CloudWatchWriter) writeResult(log []byte, err error) (int, error) {
+	if err != nil && !c.writeNeverFails {
+		return 0, err
+	}
+	return len(log), nil
+}
+
+func (c *CloudWatchWriter) write(ctx context.Context, log []byte, text string, delivery *Delivery) (int, error) {
+	if err := c.enqueueDelivery(ctx, log, text, delivery); err != nil {
+		return 0, err
+	}
+	if delivery != nil {
+		// The delivery reports what happens to the log instead
+		return len(log), nil
 	}
-	c.queue.Enqueue(event)
 
 	// report last sending error
 	lastErr := c.getErr()
@@ -156,93 +759,403 @@ func (c *CloudWatchWriter) Write(log []byte) (int, error) {
 	return len(log), nil
 }
 
-func (c *CloudWatchWriter) queueMonitor() {
-	var batch []types.InputLogEvent
-	batchSize := 0
-	nextSendTime := time.Now().Add(c.getBatchInterval())
+// enqueueLog queues the log events for a log written now.
+func (c *CloudWatchWriter) enqueueLog(ctx context.Context, log string) error {
+	return c.enqueueDelivery(ctx, nil, log, nil)
+}
+
+// writeSummary writes a log standing for several others, see
+// WithDuplicateSuppression and WithAggregation, with the summaryWriter of a
+// SyncWriter or LambdaWriter, or else by queueing it.
+func (c *CloudWatchWriter) writeSummary(ctx context.Context, log string) error {
+	if c.summaryWriter != nil {
+		return c.summaryWriter(ctx, log)
+	}
+	return c.enqueueLog(ctx, log)
+}
+
+// enqueueDelivery is like enqueueLog, for the log or, if it isn't empty, the
+// text, adding the events to the delivery, if there is one, and failing it if
+// they can't all be queued.
+func (c *CloudWatchWriter) enqueueDelivery(ctx context.Context, log []byte, text string, delivery *Delivery) error {
+	buf, err := c.logEvents(log, text)
+	if err != nil {
+		delivery.fail(err)
+		return err
+	}
+	defer buf.release()
+
+	events := buf.events
+	delivery.add(len(events))
+	for i, event := range events {
+		if err := c.queue.enqueue(ctx, event, delivery); err != nil {
+			c.dropped(*event.Message, err)
+			for range events[i:] {
+				delivery.eventDone(err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// logEvents returns the log events for a log written now, after applying the
+// oversize policy, which can make it several events or drop it, in a buffer
+// from eventBuffers which the caller releases once it is done with them. The
+// log is converted to a string once, which the events share, unless the text,
+// the log as a string already, isn't empty.
+func (c *CloudWatchWriter) logEvents(log []byte, text string) (*eventBuffer, error) {
+	buf := eventBuffers.Get().(*eventBuffer)
+	events, err := c.appendInputLogEvents(buf.events, LogEvent{Message: logString(log, text)}, c.clock.Now())
+	if err != nil {
+		buf.release()
+		return nil, err
+	}
+	buf.events = events
+	return buf, nil
+}
+
+// logString returns the text if it isn't empty, or else the log converted to
+// a string.
+func logString(log []byte, text string) string {
+	if text != "" {
+		return text
+	}
+	return string(log)
+}
+
+// stringBytes returns a view of the string's bytes, without copying them,
+// which must not be modified, and so must not be passed to the caller's
+// functions, see callsBackWithLog.
+func stringBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+// callsBackWithLog reports whether writing a log passes it to functions of
+// the caller's: the filters and the aggregation fingerprint.
+func (c *CloudWatchWriter) callsBackWithLog() bool {
+	return len(c.filters.allow) > 0 || len(c.filters.deny) > 0 || c.aggregation.window > 0
+}
+
+// queueMonitor moves the queued logs into batches, sending each batch when it
+// is full or when the batch interval has elapsed since the last batch was sent.
+// It sleeps until logs are queued, the writer is closed or the next batch is
+// due.
+func (c *CloudWatchWriter) queueMonitor(stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	// Stopping waits for the batches being sent
+	defer c.waitForSends()
+
+	p := c.pump
+	// The first batch is due as if the last one had been sent at a random
+	// time in the jitter, so that many writers started at once don't send
+	// their batches at the same time
+	var jitter time.Duration
+	if c.firstSendJitter > 0 {
+		jitter = time.Duration(rand.Int63n(int64(c.firstSendJitter) + 1))
+	}
+	lastSendTime := c.clock.Now().Add(jitter)
+	timer := c.clock.NewTimer(c.getEffectiveBatchInterval() + jitter)
+	defer timer.Stop()
+
+	send := func() {
+		p.send()
+		c.pumpStreams()
+		lastSendTime = c.clock.Now()
+		resetTimer(timer, c.getEffectiveBatchInterval())
+	}
 
 	for {
-		if time.Now().After(nextSendTime) {
-			c.sendBatch(batch, 0)
-			batch = nil
-			batchSize = 0
-			nextSendTime.Add(c.getBatchInterval())
-		}
-
-		item := c.queue.Dequeue()
-		if item == nil {
-			// Empty queue, means no logs to process
-			if c.isClosing() {
-				c.sendBatch(batch, 0)
-				// At this point we've processed all the logs and can safely
-				// close.
-				close(c.done)
+		p.drain(send)
+
+		// Empty queue, means no logs to process
+		if c.isClosing() {
+			p.send()
+			c.pumpStreams()
+			c.waitForSends()
+			// At this point we've processed all the logs and can safely
+			// close.
+			close(c.done)
+			return
+		}
+
+		select {
+		case <-c.queue.notify:
+		case <-timer.C():
+			send()
+		case <-c.intervalChanged:
+			resetTimer(timer, lastSendTime.Add(c.getEffectiveBatchInterval()).Sub(c.clock.Now()))
+		case <-c.sendNow:
+			p.drain(send)
+			send()
+		case flushed := <-c.flushRequests:
+			p.drain(send)
+			send()
+			c.waitForSends()
+			close(flushed)
+		case <-stop:
+			// The logs are sent anyway once the writer is closing
+			if !c.isClosing() {
 				return
 			}
-			time.Sleep(time.Millisecond)
-			continue
+			stop = nil
 		}
+	}
+}
 
-		logEvent, ok := item.(*types.InputLogEvent)
-		if !ok || logEvent.Message == nil {
-			// This should not happen!
-			continue
+// resetTimer resets a timer which may have fired without its channel having
+// been drained.
+func resetTimer(timer Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C():
+		default:
 		}
+	}
+	timer.Reset(d)
+}
+
+// sendBatch sends the batch to CloudWatch, retrying according to the retry
+// policy. The error from the last attempt is returned, and reported by the
+// next Write, or passed to the error handler.
+// Throttled attempts are retried for as long as it takes, and they increase
+// the batch interval until requests succeed again. They are counted apart
+// from the other attempts, so they neither use up the maximum number of
+// attempts nor lengthen the backoff after the other errors.
+func (c *CloudWatchWriter) sendBatch(batch []types.InputLogEvent) error {
+	if len(batch) == 0 {
+		return nil
+	}
 
-		messageSize := len(*logEvent.Message) + additionalBytesPerLogEvent
-		// Send the batch before adding the next message, if the message would
-		// push it over the 1MB limit on batch size.
-		if batchSize+messageSize > batchSizeLimit {
-			c.sendBatch(batch, 0)
-			batch = nil
-			batchSize = 0
-			nextSendTime = time.Now().Add(c.getBatchInterval())
+	start := c.clock.Now()
+	attempt, throttles := 1, 0
+	for {
+		var latency time.Duration
+		err := c.ctx.Err()
+		if err == nil {
+			err = c.waitForLimiter(batch)
+		}
+		if err == nil {
+			requestStart := c.clock.Now()
+			err = c.putLogEvents(batch, 0)
+			latency = c.clock.Now().Sub(requestStart)
+		}
+		if err == nil {
+			c.unthrottled()
+			c.sent(len(batch))
+			c.sentToLogStream(batch)
+			c.callSendHooks(batch, attempt+throttles, start, latency, nil)
+			return nil
 		}
 
-		batch = append(batch, *logEvent)
-		batchSize += messageSize
+		throttled := isThrottle(err)
+		if throttled {
+			c.throttled()
+		}
+		retry := c.ctx.Err() == nil && (throttled || (attempt < c.retryPolicy.MaxAttempts && c.retryPolicy.isRetryable(err)))
+		c.sendFailed(err, len(batch), retry)
+		if !retry {
+			c.logf("giving up on sending %d logs after %d attempts: %v", len(batch), attempt+throttles, err)
+			c.reportErr(err)
+			c.addUndelivered(len(batch), err)
+			c.writeFallback(batch)
+			c.droppedEvents(batch, DropReasonUndelivered)
+			if c.deadLetterHandler != nil {
+				c.deadLetterHandler(batch, err)
+			}
+			c.callSendHooks(batch, attempt+throttles, start, latency, err)
+			return err
+		}
 
-		if len(batch) >= maxNumLogEvents {
-			c.sendBatch(batch, 0)
-			batch = nil
-			batchSize = 0
-			nextSendTime = time.Now().Add(c.getBatchInterval())
+		requests := attempt + throttles
+		var backoff time.Duration
+		if throttled {
+			throttles++
+			backoff = c.retryPolicy.backoff(throttles)
+		} else {
+			backoff = c.retryPolicy.backoff(attempt)
+			attempt++
+		}
+		c.logf("retrying sending %d logs in %v after attempt %d failed: %v", len(batch), backoff, requests, err)
+		timer := c.clock.NewTimer(backoff)
+		select {
+		case <-timer.C():
+		case <-c.ctx.Done():
+			timer.Stop()
 		}
 	}
 }
 
-// Only allow 1 retry of an invalid sequence token.
-func (c *CloudWatchWriter) sendBatch(batch []types.InputLogEvent, retryNum int) {
-	if retryNum > 1 || len(batch) == 0 {
+// writeFallback writes the logs of a batch which could not be sent to the
+// fallback writer, if there is one, one per line. It is only called by the
+// goroutine sending the batches, so the writes don't overlap.
+func (c *CloudWatchWriter) writeFallback(batch []types.InputLogEvent) {
+	if c.fallbackWriter == nil {
 		return
 	}
+	for _, event := range batch {
+		message := aws.ToString(event.Message)
+		if !strings.HasSuffix(message, "\n") {
+			message += "\n"
+		}
+		// There's nowhere left to report an error to
+		_, _ = io.WriteString(c.fallbackWriter, message)
+	}
+}
+
+// addUndelivered records logs which failed to be sent, if the writer is
+// closing, in order for CloseWithContext to report them.
+func (c *CloudWatchWriter) addUndelivered(numLogs int, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.closing {
+		c.undelivered += numLogs
+		c.lastUndeliveredErr = err
+	}
+}
 
+// Only allow 1 retry of an invalid sequence token. Receiving one when sequence
+// tokens are disabled means the endpoint still requires them, so they are
+// enabled from then on. A ResourceNotFoundException means that the log group or
+// log stream has been deleted, so they are created again before the 1 retry. A
+// DataAlreadyAcceptedException means that a previous attempt at sending the
+// batch succeeded, so it's not an error.
+func (c *CloudWatchWriter) putLogEvents(batch []types.InputLogEvent, retryNum int) error {
+	logStreamName := c.getLogStreamName()
 	input := &cloudwatchlogs.PutLogEventsInput{
 		LogEvents:     batch,
 		LogGroupName:  c.logGroupName,
-		LogStreamName: c.logStreamName,
+		LogStreamName: logStreamName,
 		SequenceToken: c.getNextSequenceToken(),
 	}
 
-	output, err := c.client.PutLogEvents(context.TODO(), input)
+	output, err := c.client.PutLogEvents(c.ctx, input)
 	if err != nil {
 		var ist *types.InvalidSequenceTokenException
-		if errors.As(err, &ist) {
-			c.setNextSequenceToken(ist.ExpectedSequenceToken)
-			c.sendBatch(batch, retryNum+1)
-			return
+		if errors.As(err, &ist) && retryNum < 1 {
+			c.logf("sending sequence tokens from now on, after an InvalidSequenceTokenException")
+			c.enableSequenceTokens(logStreamName, ist.ExpectedSequenceToken)
+			return c.putLogEvents(batch, retryNum+1)
 		}
-		c.setErr(err)
-		return
+		var rnf *types.ResourceNotFoundException
+		if errors.As(err, &rnf) && retryNum < 1 {
+			c.logf("log group %s or log stream %s not found, creating it again", *c.logGroupName, *logStreamName)
+			logStream, err := c.getOrCreateLogStream(c.ctx, logStreamName)
+			if err != nil {
+				return err
+			}
+			c.setNextSequenceToken(logStreamName, logStream.UploadSequenceToken)
+			return c.putLogEvents(batch, retryNum+1)
+		}
+		var daa *types.DataAlreadyAcceptedException
+		if errors.As(err, &daa) {
+			if daa.ExpectedSequenceToken != nil {
+				c.setNextSequenceToken(logStreamName, daa.ExpectedSequenceToken)
+			}
+			return nil
+		}
+		return err
 	}
-	c.setNextSequenceToken(output.NextSequenceToken)
+	c.setNextSequenceToken(logStreamName, output.NextSequenceToken)
+	c.handleRejectedEvents(batch, output.RejectedLogEventsInfo)
+	return nil
+}
+
+// Flush sends the logs which have been written so far, without waiting for the
+// batch interval, and blocks until they have been sent. Like Write, it returns
+// the last error from sending logs to CloudWatch which has not been reported
+// yet, unless the errors go to an error handler.
+func (c *CloudWatchWriter) Flush() error {
+	if c.manualPump {
+		return c.Pump()
+	}
+	c.endRepeats()
+	c.endAggregation()
+	if err := c.flush(); err != nil {
+		return err
+	}
+	return c.takeErr()
+}
+
+// flush is like Flush, without returning the error from sending logs, for a
+// writer which isn't pumped manually.
+func (c *CloudWatchWriter) flush() error {
+	flushed := make(chan struct{})
+	stopped := c.getMonitorStopped()
+	if stopped == nil {
+		select {
+		case <-c.done:
+			return nil
+		default:
+			return ErrNotStarted
+		}
+	}
+	select {
+	case c.flushRequests <- flushed:
+		<-flushed
+	case <-c.done:
+	case <-stopped:
+		select {
+		case <-c.done:
+		default:
+			return ErrNotStarted
+		}
+	}
+	return nil
 }
 
 // Close blocks until the writer has completed writing the logs to CloudWatch.
 func (c *CloudWatchWriter) Close() {
+	_ = c.CloseWithContext(context.Background())
+}
+
+// CloseWithContext is like Close, but when ctx is done it abandons sending the
+// logs which are still queued, cancelling any request to CloudWatch in
+// progress, and returns once the writer has stopped. It returns an error if
+// any logs could not be delivered during the close, including how many. The
+// writer's streams, see Stream, are closed along with it.
+func (c *CloudWatchWriter) CloseWithContext(ctx context.Context) error {
+	if c.sessionMarkers {
+		c.writeSessionStop()
+	}
+	c.endRepeats()
+	c.endAggregation()
 	c.setClosing()
-	// block until the done channel is closed
-	<-c.done
+	// Stream holds streamsMu while checking that the writer isn't closing, so
+	// no more streams are added after this
+	streamsErr := c.closeStreams(ctx)
+	if c.parent != nil {
+		c.parent.removeStream(c)
+	}
+	c.queue.close()
+	if c.manualPump {
+		c.closePump.Do(func() { go c.pumpRemaining() })
+	} else {
+		c.startClosing(ctx)
+	}
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		c.cancel()
+		<-c.done
+	}
+	c.cancel()
+	// Nothing sends errors once the writer has stopped
+	c.closeErrors.Do(func() { close(c.errors) })
+
+	if err := c.queue.closeSpool(); err != nil {
+		return err
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.undelivered > 0 {
+		return fmt.Errorf("undelivered logs: %d: %w", c.undelivered, c.lastUndeliveredErr)
+	}
+	return streamsErr
 }
 
 func (c *CloudWatchWriter) isClosing() bool {
@@ -259,43 +1172,80 @@ func (c *CloudWatchWriter) setClosing() {
 	c.closing = true
 }
 
-// getOrCreateLogStream gets info on the log stream for the log group and log
-// stream we're interested in -- primarily for the purpose of finding the value
-// of the next sequence token. If the log group doesn't exist, then we create
-// it, if the log stream doesn't exist, then we create it.
-func (c *CloudWatchWriter) getOrCreateLogStream() (*types.LogStream, error) {
-	// Get the log streams that match our log group name and log stream
-	output, err := c.client.DescribeLogStreams(context.TODO(), &cloudwatchlogs.DescribeLogStreamsInput{
-		LogGroupName:        c.logGroupName,
-		LogStreamNamePrefix: c.logStreamName,
-	})
-	if err != nil || output == nil {
+// getOrCreateLogStream gets info on the named log stream in our log group --
+// the next sequence token is only used when sequence tokens are enabled. If
+// the log group doesn't exist, then we create it, if the log stream doesn't
+// exist, then we create it. Another process creating either of them at the
+// same time is not an error.
+func (c *CloudWatchWriter) getOrCreateLogStream(ctx context.Context, logStreamName *string) (*types.LogStream, error) {
+	logStream, err := c.findLogStream(ctx, logStreamName)
+	if err != nil {
 		var rnf *types.ResourceNotFoundException
 		if errors.As(err, &rnf) {
-			_, err = c.client.CreateLogGroup(context.TODO(), &cloudwatchlogs.CreateLogGroupInput{
-				LogGroupName: c.logGroupName,
-			})
-			if err != nil {
-				return nil, errors.Wrap(err, "cloudwatchlog.Client.CreateLogGroup")
+			if err = c.createLogGroup(ctx); err != nil {
+				return nil, err
 			}
-			return c.getOrCreateLogStream()
+			return c.getOrCreateLogStream(ctx, logStreamName)
 		}
-		return nil, errors.Wrap(err, "cloudwatchlogs.Client.DescribeLogStreams")
+		return nil, fmt.Errorf("cloudwatchlogs.Client.DescribeLogStreams: %w", err)
 	}
-
-	if len(output.LogStreams) > 0 {
-		return &output.LogStreams[0], nil
+	if logStream != nil {
+		return logStream, nil
 	}
 
 	// No matching log stream, so we need to create it
-	_, err = c.client.CreateLogStream(context.TODO(), &cloudwatchlogs.CreateLogStreamInput{
+	c.logf("creating log stream %s", *logStreamName)
+	_, err = c.client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
 		LogGroupName:  c.logGroupName,
-		LogStreamName: c.logStreamName,
+		LogStreamName: logStreamName,
 	})
-	if err != nil {
-		return nil, errors.Wrap(err, "cloudwatchlogs.Client.CreateLogStream")
+	if err != nil && !isAlreadyExists(err) {
+		return nil, wrapCreateErr(err, "cloudwatchlogs.Client.CreateLogStream")
 	}
 
 	// We can just return an empty log stream as the initial sequence token would be nil anyway.
 	return &types.LogStream{}, nil
 }
+
+// findLogStream goes through the pages of log streams whose names start with
+// the log stream name, as the prefix also matches longer names, and returns
+// the one with exactly that name, or nil if there isn't one. When they are
+// ordered by last event time, which CloudWatch doesn't accept a prefix with,
+// it goes through all of the log streams.
+func (c *CloudWatchWriter) findLogStream(ctx context.Context, logStreamName *string) (*types.LogStream, error) {
+	input := &cloudwatchlogs.DescribeLogStreamsInput{
+		LogGroupName: c.logGroupName,
+		OrderBy:      types.OrderByLogStreamName,
+		Descending:   aws.Bool(c.logStreamDescending),
+	}
+	if c.logStreamOrderBy == types.OrderByLastEventTime {
+		input.OrderBy = types.OrderByLastEventTime
+	} else {
+		input.LogStreamNamePrefix = logStreamName
+	}
+	for {
+		output, err := c.client.DescribeLogStreams(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if output == nil {
+			return nil, nil
+		}
+
+		for i := range output.LogStreams {
+			if aws.ToString(output.LogStreams[i].LogStreamName) == aws.ToString(logStreamName) {
+				return &output.LogStreams[i], nil
+			}
+		}
+
+		if aws.ToString(output.NextToken) == "" || aws.ToString(output.NextToken) == aws.ToString(input.NextToken) {
+			return nil, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func isAlreadyExists(err error) bool {
+	var rae *types.ResourceAlreadyExistsException
+	return errors.As(err, &rae)
+}
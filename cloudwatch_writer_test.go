@@ -3,8 +3,11 @@ package cloudwatchwriter_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -12,7 +15,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
-	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/tracmo/cloudwatchwriter"
 )
@@ -22,10 +24,38 @@ const sequenceToken = "next-sequence-token"
 type mockClient struct {
 	sync.RWMutex
 	putLogEventsShouldError bool
-	logEvents               []types.InputLogEvent
-	logGroupName            *string
-	logStreamName           *string
-	expectedSequenceToken   *string
+	// putLogEventsErrors are returned, in order, by the next calls to
+	// PutLogEvents.
+	putLogEventsErrors []error
+	putLogEventsCalls  int
+	// rejectedLogEventsInfo is returned by PutLogEvents.
+	rejectedLogEventsInfo *types.RejectedLogEventsInfo
+	// putLogEventsGate, if not nil, makes PutLogEvents wait to receive from
+	// it before doing anything.
+	putLogEventsGate chan struct{}
+	logEvents        []types.InputLogEvent
+	logGroupName     *string
+	logStreamName    *string
+	// otherLogStreams are the names of other log streams in the log group,
+	// which DescribeLogStreams returns before logStreamName.
+	otherLogStreams []string
+	// describeLogStreamsPageSize, if not zero, is the number of log streams
+	// DescribeLogStreams returns in each page.
+	describeLogStreamsPageSize int
+	createLogStreamCalls       int
+	// createLogGroupErr, if not nil, is returned by CreateLogGroup.
+	createLogGroupErr error
+	// retentionInDays is the retention period set with PutRetentionPolicy.
+	retentionInDays *int32
+	// logGroupTags are the tags the log group was created with or tagged with
+	// by TagResource.
+	logGroupTags  map[string]string
+	logGroupClass types.LogGroupClass
+	// requireSequenceToken makes the mock behave like CloudWatch did before
+	// sequence tokens were made optional.
+	requireSequenceToken  bool
+	expectedSequenceToken *string
+	sequenceTokensSent    int
 }
 
 func (c *mockClient) DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
@@ -33,10 +63,17 @@ func (c *mockClient) DescribeLogStreams(ctx context.Context, params *cloudwatchl
 	defer c.RUnlock()
 
 	if c.logGroupName == nil {
-		return nil, errors.Wrap(&types.ResourceNotFoundException{}, "blah")
+		return nil, fmt.Errorf("blah: %w", &types.ResourceNotFoundException{})
 	}
 
 	var streams []types.LogStream
+	for _, name := range c.otherLogStreams {
+		if strings.HasPrefix(name, aws.ToString(params.LogStreamNamePrefix)) {
+			streams = append(streams, types.LogStream{
+				LogStreamName: aws.String(name),
+			})
+		}
+	}
 	if c.logStreamName != nil {
 		streams = append(streams, types.LogStream{
 			LogStreamName:       c.logStreamName,
@@ -44,8 +81,24 @@ func (c *mockClient) DescribeLogStreams(ctx context.Context, params *cloudwatchl
 		})
 	}
 
+	var nextToken *string
+	if c.describeLogStreamsPageSize > 0 {
+		start := 0
+		if params.NextToken != nil {
+			start, _ = strconv.Atoi(*params.NextToken)
+		}
+		end := start + c.describeLogStreamsPageSize
+		if end < len(streams) {
+			nextToken = aws.String(strconv.Itoa(end))
+		} else {
+			end = len(streams)
+		}
+		streams = streams[start:end]
+	}
+
 	return &cloudwatchlogs.DescribeLogStreamsOutput{
 		LogStreams: streams,
+		NextToken:  nextToken,
 	}, nil
 }
 
@@ -53,7 +106,12 @@ func (c *mockClient) CreateLogGroup(ctx context.Context, input *cloudwatchlogs.C
 	c.Lock()
 	defer c.Unlock()
 
+	if c.createLogGroupErr != nil {
+		return nil, c.createLogGroupErr
+	}
 	c.logGroupName = input.LogGroupName
+	c.logGroupTags = input.Tags
+	c.logGroupClass = input.LogGroupClass
 	return nil, nil
 }
 
@@ -61,41 +119,173 @@ func (c *mockClient) CreateLogStream(ctx context.Context, input *cloudwatchlogs.
 	c.Lock()
 	defer c.Unlock()
 
+	c.createLogStreamCalls++
 	c.logStreamName = input.LogStreamName
 	return nil, nil
 }
 
+func (c *mockClient) PutRetentionPolicy(ctx context.Context, input *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.retentionInDays = input.RetentionInDays
+	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
+}
+
+func (c *mockClient) DescribeLogGroups(ctx context.Context, input *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	var groups []types.LogGroup
+	if c.logGroupName != nil && strings.HasPrefix(*c.logGroupName, aws.ToString(input.LogGroupNamePrefix)) {
+		groups = append(groups, types.LogGroup{
+			LogGroupName:    c.logGroupName,
+			LogGroupArn:     aws.String("arn:aws:logs:eu-west-2:123456789012:log-group:" + *c.logGroupName),
+			RetentionInDays: c.retentionInDays,
+		})
+	}
+	return &cloudwatchlogs.DescribeLogGroupsOutput{
+		LogGroups: groups,
+	}, nil
+}
+
+func (c *mockClient) TagResource(ctx context.Context, input *cloudwatchlogs.TagResourceInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagResourceOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.logGroupName == nil || aws.ToString(input.ResourceArn) != "arn:aws:logs:eu-west-2:123456789012:log-group:"+*c.logGroupName {
+		return nil, &types.ResourceNotFoundException{}
+	}
+	if c.logGroupTags == nil {
+		c.logGroupTags = make(map[string]string)
+	}
+	for k, v := range input.Tags {
+		c.logGroupTags[k] = v
+	}
+	return &cloudwatchlogs.TagResourceOutput{}, nil
+}
+
 func (c *mockClient) PutLogEvents(ctx context.Context, putLogEvents *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	c.Lock()
+	c.putLogEventsCalls++
+	gate := c.putLogEventsGate
+	c.Unlock()
+	if gate != nil {
+		select {
+		case <-gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
 	c.Lock()
 	defer c.Unlock()
 	if c.putLogEventsShouldError {
 		return nil, errors.New("should error")
 	}
+	if len(c.putLogEventsErrors) > 0 {
+		err := c.putLogEventsErrors[0]
+		c.putLogEventsErrors = c.putLogEventsErrors[1:]
+		return nil, err
+	}
 
 	if putLogEvents == nil {
 		return nil, errors.New("received nil *cloudwatchlogs.PutLogEventsInput")
 	}
 
+	if c.logGroupName == nil || c.logStreamName == nil {
+		return nil, &types.ResourceNotFoundException{
+			Message: aws.String("The specified log stream does not exist."),
+		}
+	}
+
+	for i := 1; i < len(putLogEvents.LogEvents); i++ {
+		if *putLogEvents.LogEvents[i].Timestamp < *putLogEvents.LogEvents[i-1].Timestamp {
+			return nil, &types.InvalidParameterException{
+				Message: aws.String("Log events in a single PutLogEvents request must be in chronological order."),
+			}
+		}
+	}
+
+	if putLogEvents.SequenceToken != nil {
+		c.sequenceTokensSent++
+	}
+
 	// At the first PutLogEvents c.expectedSequenceToken should be nil, as we
 	// set it in this call. If it is not nil then we can compare the received
 	// sequence token and the expected one.
-	if c.expectedSequenceToken != nil {
-		if putLogEvents.SequenceToken == nil || *putLogEvents.SequenceToken != *c.expectedSequenceToken {
-			return nil, &types.InvalidSequenceTokenException{
-				ExpectedSequenceToken: c.expectedSequenceToken,
+	if c.requireSequenceToken {
+		if c.expectedSequenceToken != nil {
+			if putLogEvents.SequenceToken == nil || *putLogEvents.SequenceToken != *c.expectedSequenceToken {
+				return nil, &types.InvalidSequenceTokenException{
+					ExpectedSequenceToken: c.expectedSequenceToken,
+				}
 			}
+		} else {
+			c.expectedSequenceToken = aws.String(sequenceToken)
 		}
-	} else {
-		c.expectedSequenceToken = aws.String(sequenceToken)
 	}
 
 	c.logEvents = append(c.logEvents, putLogEvents.LogEvents...)
 	output := &cloudwatchlogs.PutLogEventsOutput{
-		NextSequenceToken: c.expectedSequenceToken,
+		NextSequenceToken:     c.expectedSequenceToken,
+		RejectedLogEventsInfo: c.rejectedLogEventsInfo,
 	}
 	return output, nil
 }
 
+// deleteLogGroup simulates the log group, and so the log stream, being deleted
+// while the writer is running.
+func (c *mockClient) deleteLogGroup() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.logGroupName = nil
+	c.logStreamName = nil
+}
+
+func (c *mockClient) getLogGroupName() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	return aws.ToString(c.logGroupName)
+}
+
+func (c *mockClient) getLogStreamName() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	return aws.ToString(c.logStreamName)
+}
+
+func (c *mockClient) numCreateLogStreamCalls() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.createLogStreamCalls
+}
+
+func (c *mockClient) getRetentionInDays() *int32 {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.retentionInDays
+}
+
+func (c *mockClient) getLogGroupTags() map[string]string {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.logGroupTags
+}
+
+func (c *mockClient) getLogGroupClass() types.LogGroupClass {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.logGroupClass
+}
+
 func (c *mockClient) getLogEvents() []types.InputLogEvent {
 	c.RLock()
 	defer c.RUnlock()
@@ -106,6 +296,31 @@ func (c *mockClient) getLogEvents() []types.InputLogEvent {
 	return logEvents
 }
 
+func (c *mockClient) numPutLogEventsCalls() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.putLogEventsCalls
+}
+
+func (c *mockClient) waitForPutLogEventsCalls(numberOfCalls int, timeout time.Duration) error {
+	endTime := time.Now().Add(timeout)
+	for c.numPutLogEventsCalls() < numberOfCalls {
+		if time.Now().After(endTime) {
+			return errors.New("ran out of time waiting for PutLogEvents calls")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+func (c *mockClient) numSequenceTokensSent() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.sequenceTokensSent
+}
+
 func (c *mockClient) setExpectedSequenceToken(token *string) {
 	c.Lock()
 	defer c.Unlock()
@@ -160,7 +375,7 @@ func (l *logsContainer) getLogEvents() ([]types.InputLogEvent, error) {
 	for _, log := range l.logs {
 		message, err := json.Marshal(log)
 		if err != nil {
-			return nil, errors.Wrap(err, "json.Marshal")
+			return nil, fmt.Errorf("json.Marshal: %w", err)
 		}
 
 		logEvents = append(logEvents, types.InputLogEvent{
@@ -420,6 +635,33 @@ func TestCloudWatchWriterParallel(t *testing.T) {
 	assertEqualLogMessages(t, expectedLogs, client.getLogEvents())
 }
 
+func TestCloudWatchWriterInterleavedWriters(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	// Goroutines writing at the same time can queue logs out of chronological
+	// order, which the mock client rejects like CloudWatch does.
+	numWriters, numLogs := 50, 200
+	var wg sync.WaitGroup
+	for i := 0; i < numWriters; i++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for j := 0; j < numLogs; j++ {
+				helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: fmt.Sprintf("writer %d, message %d", writer, j)})
+			}
+		}(i)
+	}
+	wg.Wait()
+	cloudWatchWriter.Close()
+
+	assert.Equal(t, numWriters*numLogs, client.numLogs())
+}
+
 func TestCloudWatchWriterClose(t *testing.T) {
 	client := &mockClient{}
 
@@ -484,9 +726,11 @@ func TestCloudWatchWriterReportError(t *testing.T) {
 
 func TestCloudWatchWriterReceiveInvalidSequenceTokenException(t *testing.T) {
 	// Setup
-	client := &mockClient{}
+	client := &mockClient{
+		requireSequenceToken: true,
+	}
 
-	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream", cloudwatchwriter.WithSequenceTokens())
 	if err != nil {
 		t.Fatalf("NewWithClient: %v", err)
 	}
@@ -523,3 +767,162 @@ func TestCloudWatchWriterReceiveInvalidSequenceTokenException(t *testing.T) {
 	}
 	assertEqualLogMessages(t, expectedLogs, client.getLogEvents())
 }
+
+func TestCloudWatchWriterNoSequenceTokens(t *testing.T) {
+	client := &mockClient{}
+
+	// Two writers sending to the same log stream, like two processes would
+	writer1, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	writer2, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		helperWriteLogs(t, writer1, exampleLog{Message: fmt.Sprintf("writer 1, message %d", i)})
+		helperWriteLogs(t, writer2, exampleLog{Message: fmt.Sprintf("writer 2, message %d", i)})
+		time.Sleep(201 * time.Millisecond)
+	}
+	writer1.Close()
+	writer2.Close()
+
+	assert.Equal(t, 6, client.numLogs())
+	assert.Equal(t, 0, client.numSequenceTokensSent())
+}
+
+func TestCloudWatchWriterDetectSequenceTokensRequired(t *testing.T) {
+	client := &mockClient{
+		requireSequenceToken: true,
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 1"})
+	if err = client.waitForLogs(1, 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	// The mock now expects a sequence token, which the writer has to pick up
+	// from the InvalidSequenceTokenException
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 2"})
+	cloudWatchWriter.Close()
+
+	assert.Equal(t, 2, client.numLogs())
+	assert.Equal(t, 1, client.numSequenceTokensSent())
+}
+
+func TestCloudWatchWriterLogStreamDeleted(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 1"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	// The writer creates the log group and log stream again and carries on
+	client.deleteLogGroup()
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 2"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	assert.Equal(t, 2, client.numLogs())
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 3"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 3, client.numLogs())
+}
+
+func TestCloudWatchWriterLogStreamPrefix(t *testing.T) {
+	// "logStream-1" also matches the prefix "logStream", but isn't the log
+	// stream we're interested in
+	client := &mockClient{
+		logGroupName:    aws.String("logGroup"),
+		otherLogStreams: []string{"logStream-1"},
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	assert.Equal(t, "logStream", client.getLogStreamName())
+}
+
+func TestCloudWatchWriterLogStreamOnLaterPage(t *testing.T) {
+	client := &mockClient{
+		logGroupName:               aws.String("logGroup"),
+		logStreamName:              aws.String("logStream"),
+		otherLogStreams:            []string{"logStream-1", "logStream-2", "logStream-3", "logStream-4", "logStream-5"},
+		describeLogStreamsPageSize: 2,
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	assert.Equal(t, 0, client.numCreateLogStreamCalls())
+}
+
+func TestCloudWatchWriterWriteString(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithDenyFilters(cloudwatchwriter.FieldEquals("level", "debug")),
+		cloudwatchwriter.WithManualPump(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	var _ io.StringWriter = cloudWatchWriter
+	n, err := io.WriteString(cloudWatchWriter, `{"level":"info","message":"kept"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, 33, n)
+	_, err = cloudWatchWriter.WriteString(`{"level":"debug","message":"filtered out"}`)
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	events := client.getLogEvents()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, `{"level":"info","message":"kept"}`, *events[0].Message)
+	}
+}
+
+func TestCloudWatchWriterWriteStringFilterModifiesLog(t *testing.T) {
+	client := &mockClient{}
+	var kept []byte
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithDenyFilters(func(log []byte) bool {
+			kept = log
+			log[0] = 'X'
+			return false
+		}),
+		cloudwatchwriter.WithManualPump(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// The filter is given a copy of the string, which it can modify and keep
+	_, err = cloudWatchWriter.WriteString("hello")
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.Equal(t, "Xello", string(kept))
+	events := client.getLogEvents()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "hello", *events[0].Message)
+	}
+}
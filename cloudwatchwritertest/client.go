@@ -0,0 +1,562 @@
+// Package cloudwatchwritertest provides an in-memory CloudWatch Logs, for
+// testing programs which send their logs with cloudwatchwriter without making
+// requests to AWS.
+package cloudwatchwritertest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
+)
+
+// The limits CloudWatch Logs puts on PutLogEvents.
+const (
+	maxBatchEvents             = 10000
+	maxBatchSize               = 1048576
+	maxEventSize               = 262144
+	additionalBytesPerLogEvent = 26
+	maxBatchSpan               = 24 * time.Hour
+	maxEventAge                = 14 * 24 * time.Hour
+	maxEventFuture             = 2 * time.Hour
+	// describePageSize is the number of log streams or log groups returned in
+	// each page.
+	describePageSize = 50
+)
+
+// Event is a log event received by the Client.
+type Event struct {
+	Message   string
+	Timestamp time.Time
+}
+
+// LogGroup describes a log group of the Client.
+type LogGroup struct {
+	Name string
+	// RetentionInDays is zero if the logs never expire.
+	RetentionInDays int32
+	Tags            map[string]string
+	Class           types.LogGroupClass
+	// Streams are the names of the log streams in the log group, in order.
+	Streams []string
+}
+
+type logGroup struct {
+	LogGroup
+	streams map[string]*logStream
+}
+
+type logStream struct {
+	events []Event
+	// sequence is the number of batches received, which makes the next
+	// sequence token.
+	sequence int
+}
+
+func (s *logStream) sequenceToken() *string {
+	if s.sequence == 0 {
+		return nil
+	}
+	return aws.String(strconv.Itoa(s.sequence))
+}
+
+// Client is an in-memory CloudWatch Logs, implementing
+// cloudwatchwriter.CloudWatchLogsClient, so that it can be given to
+// cloudwatchwriter.NewWithClient. It keeps the log events it receives, which
+// can then be checked with Events or WaitForEvents, and checks the requests
+// like CloudWatch does, returning the same errors, and rejecting log events
+// which are too old or too new. Errors can be simulated with Throttle and
+// FailSequenceTokens. It is safe for concurrent use.
+type Client struct {
+	mu                    sync.Mutex
+	groups                map[string]*logGroup
+	requireSequenceTokens bool
+	throttles             int
+	sequenceTokenFailures int
+	putLogEventsCalls     int
+	// received is closed, and replaced, whenever log events are received,
+	// to wake up WaitForEvents.
+	received chan struct{}
+}
+
+// NewClient returns a Client without any log groups.
+func NewClient() *Client {
+	return &Client{
+		groups:   make(map[string]*logGroup),
+		received: make(chan struct{}),
+	}
+}
+
+// RequireSequenceTokens makes the Client behave like CloudWatch did before
+// sequence tokens were made optional, failing PutLogEvents with an
+// InvalidSequenceTokenException unless it has the log stream's sequence token.
+func (c *Client) RequireSequenceTokens() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requireSequenceTokens = true
+}
+
+// Throttle makes the next n calls to PutLogEvents fail with a
+// ThrottlingException, as CloudWatch does when it receives too many requests.
+func (c *Client) Throttle(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.throttles = n
+}
+
+// FailSequenceTokens makes the next n calls to PutLogEvents fail with an
+// InvalidSequenceTokenException, as if another writer had sent logs to the log
+// stream first.
+func (c *Client) FailSequenceTokens(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sequenceTokenFailures = n
+}
+
+// Events returns the log events received for the log stream, in the order they
+// were received, which is none if the log stream doesn't exist.
+func (c *Client) Events(logGroupName, logStreamName string) []Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.events(logGroupName, logStreamName)
+}
+
+func (c *Client) events(logGroupName, logStreamName string) []Event {
+	group, ok := c.groups[logGroupName]
+	if !ok {
+		return nil
+	}
+	stream, ok := group.streams[logStreamName]
+	if !ok {
+		return nil
+	}
+	return append([]Event(nil), stream.events...)
+}
+
+// Messages returns the messages of the log events received for the log stream,
+// like Events.
+func (c *Client) Messages(logGroupName, logStreamName string) []string {
+	events := c.Events(logGroupName, logStreamName)
+	messages := make([]string, len(events))
+	for i, event := range events {
+		messages[i] = event.Message
+	}
+	return messages
+}
+
+// WaitForEvents waits until at least n log events have been received for the
+// log stream, and returns them, or returns an error with the events received
+// so far after the timeout.
+func (c *Client) WaitForEvents(logGroupName, logStreamName string, n int, timeout time.Duration) ([]Event, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		events := c.events(logGroupName, logStreamName)
+		received := c.received
+		c.mu.Unlock()
+
+		if len(events) >= n {
+			return events, nil
+		}
+		select {
+		case <-received:
+		case <-timer.C:
+			return events, fmt.Errorf("received %d log events for %s/%s after %v, waiting for %d", len(events), logGroupName, logStreamName, timeout, n)
+		}
+	}
+}
+
+// LogGroup returns the log group, and whether it exists.
+func (c *Client) LogGroup(logGroupName string) (LogGroup, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.groups[logGroupName]
+	if !ok {
+		return LogGroup{}, false
+	}
+	description := group.LogGroup
+	description.Tags = make(map[string]string, len(group.Tags))
+	for k, v := range group.Tags {
+		description.Tags[k] = v
+	}
+	for name := range group.streams {
+		description.Streams = append(description.Streams, name)
+	}
+	sort.Strings(description.Streams)
+	return description, true
+}
+
+// PutLogEventsCalls returns the number of calls to PutLogEvents, including
+// those which failed.
+func (c *Client) PutLogEventsCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.putLogEventsCalls
+}
+
+func logGroupArn(name string) string {
+	return "arn:aws:logs:us-east-1:123456789012:log-group:" + name
+}
+
+func notFound(message string) error {
+	return &types.ResourceNotFoundException{Message: aws.String(message)}
+}
+
+func invalidParameter(format string, args ...interface{}) error {
+	return &types.InvalidParameterException{Message: aws.String(fmt.Sprintf(format, args...))}
+}
+
+// CreateLogGroup implements cloudwatchwriter.CloudWatchLogsClient.
+func (c *Client) CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := aws.ToString(params.LogGroupName)
+	if name == "" {
+		return nil, invalidParameter("log group name is required")
+	}
+	if _, ok := c.groups[name]; ok {
+		return nil, &types.ResourceAlreadyExistsException{Message: aws.String("The specified log group already exists")}
+	}
+
+	group := &logGroup{
+		LogGroup: LogGroup{
+			Name:  name,
+			Tags:  make(map[string]string),
+			Class: params.LogGroupClass,
+		},
+		streams: make(map[string]*logStream),
+	}
+	if group.Class == "" {
+		group.Class = types.LogGroupClassStandard
+	}
+	for k, v := range params.Tags {
+		group.Tags[k] = v
+	}
+	c.groups[name] = group
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+// CreateLogStream implements cloudwatchwriter.CloudWatchLogsClient.
+func (c *Client) CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.groups[aws.ToString(params.LogGroupName)]
+	if !ok {
+		return nil, notFound("The specified log group does not exist.")
+	}
+	name := aws.ToString(params.LogStreamName)
+	if name == "" {
+		return nil, invalidParameter("log stream name is required")
+	}
+	if _, ok := group.streams[name]; ok {
+		return nil, &types.ResourceAlreadyExistsException{Message: aws.String("The specified log stream already exists")}
+	}
+	group.streams[name] = &logStream{}
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
+
+// DeleteLogGroup deletes the log group and its log streams, as happens when
+// someone deletes them while logs are being sent.
+func (c *Client) DeleteLogGroup(ctx context.Context, params *cloudwatchlogs.DeleteLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DeleteLogGroupOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := aws.ToString(params.LogGroupName)
+	if _, ok := c.groups[name]; !ok {
+		return nil, notFound("The specified log group does not exist.")
+	}
+	delete(c.groups, name)
+	return &cloudwatchlogs.DeleteLogGroupOutput{}, nil
+}
+
+// DeleteLogStream deletes the log stream, like DeleteLogGroup.
+func (c *Client) DeleteLogStream(ctx context.Context, params *cloudwatchlogs.DeleteLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DeleteLogStreamOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.groups[aws.ToString(params.LogGroupName)]
+	if !ok {
+		return nil, notFound("The specified log group does not exist.")
+	}
+	name := aws.ToString(params.LogStreamName)
+	if _, ok := group.streams[name]; !ok {
+		return nil, notFound("The specified log stream does not exist.")
+	}
+	delete(group.streams, name)
+	return &cloudwatchlogs.DeleteLogStreamOutput{}, nil
+}
+
+// DescribeLogGroups returns the log groups whose names start with the prefix,
+// for cloudwatchwriter.WithLogGroupTags.
+func (c *Client) DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var names []string
+	for name := range c.groups {
+		if strings.HasPrefix(name, aws.ToString(params.LogGroupNamePrefix)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	names, nextToken, err := page(names, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &cloudwatchlogs.DescribeLogGroupsOutput{NextToken: nextToken}
+	for _, name := range names {
+		group := c.groups[name]
+		logGroup := types.LogGroup{
+			LogGroupName:  aws.String(name),
+			LogGroupArn:   aws.String(logGroupArn(name)),
+			Arn:           aws.String(logGroupArn(name) + ":*"),
+			LogGroupClass: group.Class,
+		}
+		if group.RetentionInDays != 0 {
+			logGroup.RetentionInDays = aws.Int32(group.RetentionInDays)
+		}
+		output.LogGroups = append(output.LogGroups, logGroup)
+	}
+	return output, nil
+}
+
+// DescribeLogStreams implements cloudwatchwriter.CloudWatchLogsClient. The log
+// streams are always ordered by name, but like CloudWatch it doesn't accept a
+// prefix when ordering by last event time.
+func (c *Client) DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	if params.OrderBy == types.OrderByLastEventTime && params.LogStreamNamePrefix != nil {
+		return nil, invalidParameter("cannot order by LastEventTime with a logStreamNamePrefix")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.groups[aws.ToString(params.LogGroupName)]
+	if !ok {
+		return nil, notFound("The specified log group does not exist.")
+	}
+
+	var names []string
+	for name := range group.streams {
+		if strings.HasPrefix(name, aws.ToString(params.LogStreamNamePrefix)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if aws.ToBool(params.Descending) {
+		sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	}
+	names, nextToken, err := page(names, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &cloudwatchlogs.DescribeLogStreamsOutput{NextToken: nextToken}
+	for _, name := range names {
+		output.LogStreams = append(output.LogStreams, types.LogStream{
+			LogStreamName:       aws.String(name),
+			UploadSequenceToken: group.streams[name].sequenceToken(),
+		})
+	}
+	return output, nil
+}
+
+// page returns the page of names starting at the token, and the token of the
+// next page, if there is one.
+func page(names []string, token *string) ([]string, *string, error) {
+	start := 0
+	if token != nil {
+		var err error
+		if start, err = strconv.Atoi(*token); err != nil || start < 0 || start > len(names) {
+			return nil, nil, invalidParameter("invalid next token %q", *token)
+		}
+	}
+	if end := start + describePageSize; end < len(names) {
+		return names[start:end], aws.String(strconv.Itoa(end)), nil
+	}
+	return names[start:], nil, nil
+}
+
+// PutRetentionPolicy sets the retention period of a log group, for
+// cloudwatchwriter.WithRetentionDays.
+func (c *Client) PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.groups[aws.ToString(params.LogGroupName)]
+	if !ok {
+		return nil, notFound("The specified log group does not exist.")
+	}
+	if aws.ToInt32(params.RetentionInDays) <= 0 {
+		return nil, invalidParameter("retention in days must be positive")
+	}
+	group.RetentionInDays = aws.ToInt32(params.RetentionInDays)
+	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
+}
+
+// TagResource adds tags to a log group, for cloudwatchwriter.WithLogGroupTags.
+func (c *Client) TagResource(ctx context.Context, params *cloudwatchlogs.TagResourceInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagResourceOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, group := range c.groups {
+		if logGroupArn(name) == aws.ToString(params.ResourceArn) {
+			for k, v := range params.Tags {
+				group.Tags[k] = v
+			}
+			return &cloudwatchlogs.TagResourceOutput{}, nil
+		}
+	}
+	return nil, notFound("The specified resource does not exist.")
+}
+
+// PutLogEvents implements cloudwatchwriter.CloudWatchLogsClient.
+func (c *Client) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.putLogEventsCalls++
+	if c.throttles > 0 {
+		c.throttles--
+		return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	}
+
+	group, ok := c.groups[aws.ToString(params.LogGroupName)]
+	if !ok {
+		return nil, notFound("The specified log group does not exist.")
+	}
+	stream, ok := group.streams[aws.ToString(params.LogStreamName)]
+	if !ok {
+		return nil, notFound("The specified log stream does not exist.")
+	}
+
+	if c.sequenceTokenFailures > 0 {
+		c.sequenceTokenFailures--
+		stream.sequence++
+		return nil, &types.InvalidSequenceTokenException{
+			Message:               aws.String("The given sequenceToken is invalid."),
+			ExpectedSequenceToken: stream.sequenceToken(),
+		}
+	}
+	if c.requireSequenceTokens && aws.ToString(params.SequenceToken) != aws.ToString(stream.sequenceToken()) {
+		return nil, &types.InvalidSequenceTokenException{
+			Message:               aws.String("The given sequenceToken is invalid."),
+			ExpectedSequenceToken: stream.sequenceToken(),
+		}
+	}
+
+	if err := checkBatch(params.LogEvents); err != nil {
+		return nil, err
+	}
+
+	info := rejectedEvents(params.LogEvents, group.RetentionInDays)
+	for i, event := range params.LogEvents {
+		if info != nil && (i < int(aws.ToInt32(info.TooOldLogEventEndIndex)) ||
+			i < int(aws.ToInt32(info.ExpiredLogEventEndIndex)) ||
+			(info.TooNewLogEventStartIndex != nil && i >= int(*info.TooNewLogEventStartIndex))) {
+			continue
+		}
+		stream.events = append(stream.events, Event{
+			Message:   aws.ToString(event.Message),
+			Timestamp: time.UnixMilli(aws.ToInt64(event.Timestamp)),
+		})
+	}
+	stream.sequence++
+	close(c.received)
+	c.received = make(chan struct{})
+
+	return &cloudwatchlogs.PutLogEventsOutput{
+		NextSequenceToken:     stream.sequenceToken(),
+		RejectedLogEventsInfo: info,
+	}, nil
+}
+
+// checkBatch returns the error CloudWatch returns for a batch it doesn't
+// accept at all.
+func checkBatch(events []types.InputLogEvent) error {
+	if len(events) == 0 {
+		return invalidParameter("at least 1 log event is required")
+	}
+	if len(events) > maxBatchEvents {
+		return invalidParameter("%d log events is more than the maximum of %d", len(events), maxBatchEvents)
+	}
+
+	size := 0
+	for i, event := range events {
+		if event.Message == nil || event.Timestamp == nil {
+			return invalidParameter("log event %d must have a message and a timestamp", i)
+		}
+		eventSize := len(*event.Message) + additionalBytesPerLogEvent
+		if eventSize > maxEventSize {
+			return invalidParameter("log event %d is larger than the maximum of %d bytes", i, maxEventSize)
+		}
+		size += eventSize
+		if i > 0 && *event.Timestamp < *events[i-1].Timestamp {
+			return invalidParameter("Log events in a single PutLogEvents request must be in chronological order.")
+		}
+	}
+	if size > maxBatchSize {
+		return invalidParameter("the batch of %d bytes is larger than the maximum of %d bytes", size, maxBatchSize)
+	}
+	if span := time.Duration(*events[len(events)-1].Timestamp-*events[0].Timestamp) * time.Millisecond; span > maxBatchSpan {
+		return invalidParameter("the log events in a batch must not span more than 24 hours")
+	}
+	return nil
+}
+
+// rejectedEvents returns which of the events, in chronological order,
+// CloudWatch rejects, or nil if it accepts all of them.
+func rejectedEvents(events []types.InputLogEvent, retentionInDays int32) *types.RejectedLogEventsInfo {
+	now := time.Now()
+	tooOld := now.Add(-maxEventAge).UnixMilli()
+	expired := int64(0)
+	if retentionInDays != 0 {
+		expired = now.Add(-time.Duration(retentionInDays) * 24 * time.Hour).UnixMilli()
+	}
+	tooNew := now.Add(maxEventFuture).UnixMilli()
+
+	var info types.RejectedLogEventsInfo
+	rejected := false
+	for i, event := range events {
+		timestamp := *event.Timestamp
+		if timestamp < tooOld {
+			info.TooOldLogEventEndIndex = aws.Int32(int32(i + 1))
+			rejected = true
+		}
+		if timestamp < expired {
+			info.ExpiredLogEventEndIndex = aws.Int32(int32(i + 1))
+			rejected = true
+		}
+		if timestamp > tooNew && info.TooNewLogEventStartIndex == nil {
+			info.TooNewLogEventStartIndex = aws.Int32(int32(i))
+			rejected = true
+		}
+	}
+	if !rejected {
+		return nil
+	}
+	return &info
+}
@@ -0,0 +1,199 @@
+package cloudwatchwritertest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+var fastRetries = cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     time.Millisecond,
+})
+
+func newWriter(t *testing.T, client cloudwatchwriter.CloudWatchLogsClient, opts ...cloudwatchwriter.Option) *cloudwatchwriter.CloudWatchWriter {
+	t.Helper()
+	writer, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "group", "stream", append([]cloudwatchwriter.Option{fastRetries}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(writer.Close)
+	return writer
+}
+
+func TestClient(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	writer := newWriter(t, client,
+		cloudwatchwriter.WithRetentionDays(7),
+		cloudwatchwriter.WithLogGroupTags(map[string]string{"team": "a"}),
+	)
+
+	_, err := writer.Write([]byte("first"))
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("second"))
+	require.NoError(t, err)
+
+	events, err := client.WaitForEvents("group", "stream", 2, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "first", events[0].Message)
+	assert.WithinDuration(t, time.Now(), events[1].Timestamp, time.Second)
+	assert.Equal(t, []string{"first", "second"}, client.Messages("group", "stream"))
+	assert.Equal(t, 1, client.PutLogEventsCalls())
+
+	group, ok := client.LogGroup("group")
+	require.True(t, ok)
+	assert.Equal(t, cloudwatchwritertest.LogGroup{
+		Name:            "group",
+		RetentionInDays: 7,
+		Tags:            map[string]string{"team": "a"},
+		Class:           types.LogGroupClassStandard,
+		Streams:         []string{"stream"},
+	}, group)
+}
+
+func TestClientWaitForEventsTimeout(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+
+	events, err := client.WaitForEvents("group", "stream", 1, 10*time.Millisecond)
+	assert.Error(t, err)
+	assert.Empty(t, events)
+}
+
+func TestClientThrottle(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	client.Throttle(2)
+	writer := newWriter(t, client)
+
+	_, err := writer.Write([]byte("log"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+
+	assert.Equal(t, []string{"log"}, client.Messages("group", "stream"))
+	assert.Equal(t, 3, client.PutLogEventsCalls())
+	assert.Equal(t, int64(2), writer.Stats().Throttles)
+}
+
+func TestClientSequenceTokens(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	client.RequireSequenceTokens()
+	writer := newWriter(t, client)
+
+	_, err := writer.Write([]byte("first"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+
+	// Another writer sending logs to the log stream makes the sequence token
+	// wrong, and the writer starts sending them
+	client.FailSequenceTokens(1)
+	_, err = writer.Write([]byte("second"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+	_, err = writer.Write([]byte("third"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+
+	assert.Equal(t, []string{"first", "second", "third"}, client.Messages("group", "stream"))
+}
+
+func TestClientRejectedEvents(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	writer := newWriter(t, client)
+
+	now := time.Now()
+	require.NoError(t, writer.WriteEvents([]cloudwatchwriter.LogEvent{
+		{Message: "too old", Timestamp: now.Add(-15 * 24 * time.Hour)},
+		{Message: "accepted", Timestamp: now},
+	}))
+	require.NoError(t, writer.WriteEvents([]cloudwatchwriter.LogEvent{
+		{Message: "too new", Timestamp: now.Add(3 * time.Hour)},
+	}))
+	require.NoError(t, writer.Flush())
+
+	assert.Equal(t, []string{"accepted"}, client.Messages("group", "stream"))
+	stats := writer.Stats()
+	assert.Equal(t, int64(1), stats.RejectedTooOld)
+	assert.Equal(t, int64(1), stats.RejectedTooNew)
+}
+
+func TestClientDeleteLogGroup(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	writer := newWriter(t, client)
+
+	_, err := client.DeleteLogGroup(context.Background(), &cloudwatchlogs.DeleteLogGroupInput{LogGroupName: aws.String("group")})
+	require.NoError(t, err)
+	_, ok := client.LogGroup("group")
+	assert.False(t, ok)
+
+	// The writer creates the log group and log stream again
+	_, err = writer.Write([]byte("log"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+	assert.Equal(t, []string{"log"}, client.Messages("group", "stream"))
+}
+
+func TestClientInvalidBatches(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	ctx := context.Background()
+	_, err := client.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{LogGroupName: aws.String("group")})
+	require.NoError(t, err)
+
+	put := func(events ...types.InputLogEvent) error {
+		_, err := client.PutLogEvents(ctx, &cloudwatchlogs.PutLogEventsInput{
+			LogGroupName:  aws.String("group"),
+			LogStreamName: aws.String("stream"),
+			LogEvents:     events,
+		})
+		return err
+	}
+	event := func(timestamp time.Time) types.InputLogEvent {
+		return types.InputLogEvent{Message: aws.String("log"), Timestamp: aws.Int64(timestamp.UnixMilli())}
+	}
+
+	var rnf *types.ResourceNotFoundException
+	assert.True(t, errors.As(put(event(time.Now())), &rnf), "missing log stream")
+
+	_, err = client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{LogGroupName: aws.String("group"), LogStreamName: aws.String("stream")})
+	require.NoError(t, err)
+	var rae *types.ResourceAlreadyExistsException
+	_, err = client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{LogGroupName: aws.String("group"), LogStreamName: aws.String("stream")})
+	assert.True(t, errors.As(err, &rae))
+
+	now := time.Now()
+	var ipe *types.InvalidParameterException
+	assert.True(t, errors.As(put(), &ipe), "no events")
+	assert.True(t, errors.As(put(event(now), event(now.Add(-time.Second))), &ipe), "out of order")
+	assert.True(t, errors.As(put(event(now.Add(-25*time.Hour)), event(now)), &ipe), "span")
+	assert.NoError(t, put(event(now)))
+}
+
+func TestClientDescribeLogStreamsPages(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	ctx := context.Background()
+	_, err := client.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{LogGroupName: aws.String("group")})
+	require.NoError(t, err)
+	for i := 0; i < 60; i++ {
+		_, err = client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
+			LogGroupName:  aws.String("group"),
+			LogStreamName: aws.String("stream-" + time.Duration(i).String()),
+		})
+		require.NoError(t, err)
+	}
+
+	// The writer finds its log stream among them rather than creating it
+	writer := newWriter(t, client)
+	_, err = writer.Write([]byte("log"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+
+	group, _ := client.LogGroup("group")
+	assert.Len(t, group.Streams, 61)
+	assert.Len(t, client.Messages("group", "stream"), 1)
+}
@@ -0,0 +1,166 @@
+package cloudwatchwritertest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+// Clock is a cloudwatchwriter.Clock whose time only moves on when Advance is
+// called, for cloudwatchwriter.WithClock, so that a test controls when
+// batches are sent and retried, and can simulate hours of them in no time. It
+// is safe for concurrent use.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+	// changed is closed, and replaced, whenever a timer is started, to
+	// wake up WaitForTimers.
+	changed chan struct{}
+}
+
+// NewClock returns a Clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{
+		now:     now,
+		changed: make(chan struct{}),
+	}
+}
+
+// Now implements cloudwatchwriter.Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTimer implements cloudwatchwriter.Clock.
+func (c *Clock) NewTimer(d time.Duration) cloudwatchwriter.Timer {
+	t := &timer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the time on by d, firing the timers which are due by then, in
+// order. A writer reacts to its timers firing on its own goroutine, so the
+// test still has to wait for it to have sent a batch, such as with
+// Client.WaitForEvents.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	for _, t := range c.timers {
+		if !t.when.After(c.now) {
+			t.fire()
+		}
+	}
+	c.removeStopped()
+}
+
+// Timers returns the number of timers which have been started and have not
+// fired or been stopped yet.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// WaitForTimers waits until at least n timers have been started and have not
+// fired or been stopped, such as for a writer to be waiting for the batch
+// interval or a retry, before calling Advance. It returns an error after the
+// timeout, which is real time.
+func (c *Clock) WaitForTimers(n int, timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		c.mu.Lock()
+		timers := len(c.timers)
+		changed := c.changed
+		c.mu.Unlock()
+
+		if timers >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return fmt.Errorf("%d timers after %v, waiting for %d", timers, timeout, n)
+		}
+	}
+}
+
+func (c *Clock) removeStopped() {
+	active := c.timers[:0]
+	for _, t := range c.timers {
+		if t.active {
+			active = append(active, t)
+		}
+	}
+	for i := len(active); i < len(c.timers); i++ {
+		c.timers[i] = nil
+	}
+	c.timers = active
+}
+
+// timer is a cloudwatchwriter.Timer of a Clock.
+type timer struct {
+	clock  *Clock
+	c      chan time.Time
+	when   time.Time
+	active bool
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+// fire sends the time on the channel, as a *time.Timer does, unless the last
+// time hasn't been received yet. The clock must be locked.
+func (t *timer) fire() {
+	t.active = false
+	select {
+	case t.c <- t.clock.now:
+	default:
+	}
+}
+
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	wasActive := t.active
+	t.active = false
+	t.clock.removeStopped()
+	return wasActive
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wasActive := t.active
+	t.when = c.now.Add(d)
+	if d <= 0 {
+		t.fire()
+		c.removeStopped()
+		return wasActive
+	}
+	if !wasActive {
+		c.timers = append(c.timers, t)
+	}
+	t.active = true
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return wasActive
+}
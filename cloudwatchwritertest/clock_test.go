@@ -0,0 +1,58 @@
+package cloudwatchwritertest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func fired(timer interface{ C() <-chan time.Time }) (time.Time, bool) {
+	select {
+	case t := <-timer.C():
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := cloudwatchwritertest.NewClock(start)
+	assert.Equal(t, start, clock.Now())
+
+	timer := clock.NewTimer(time.Second)
+	assert.Equal(t, 1, clock.Timers())
+	clock.Advance(500 * time.Millisecond)
+	_, ok := fired(timer)
+	assert.False(t, ok)
+
+	clock.Advance(time.Second)
+	firedAt, ok := fired(timer)
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(1500*time.Millisecond), firedAt)
+	assert.Equal(t, 0, clock.Timers())
+	assert.False(t, timer.Stop())
+
+	assert.False(t, timer.Reset(time.Minute))
+	assert.True(t, timer.Stop())
+	clock.Advance(time.Hour)
+	_, ok = fired(timer)
+	assert.False(t, ok)
+
+	// A timer which is due straight away fires straight away
+	_, ok = fired(clock.NewTimer(0))
+	assert.True(t, ok)
+}
+
+func TestClockWaitForTimers(t *testing.T) {
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	assert.Error(t, clock.WaitForTimers(1, 10*time.Millisecond))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		clock.NewTimer(time.Second)
+	}()
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+}
@@ -0,0 +1,260 @@
+package cloudwatchwritertest
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+// Fault is what a FaultClient does to a PutLogEvents call.
+type Fault struct {
+	// Latency delays the call, or the error, unless the context is done
+	// first.
+	Latency time.Duration
+	// Err is returned instead of making the call, see ThrottlingError and
+	// ServerError.
+	Err error
+	// RejectTooOld and RejectTooNew are the number of log events at the start
+	// and at the end of the batch which are rejected, as CloudWatch does for
+	// log events more than 14 days old or 2 hours in the future. The other
+	// log events are sent.
+	RejectTooOld int
+	RejectTooNew int
+}
+
+// Call is a PutLogEvents call, for a Schedule to decide whether to inject a
+// fault into it.
+type Call struct {
+	// Number is the number of the call, starting at 1.
+	Number int
+	// Elapsed is the time since the FaultClient was created.
+	Elapsed time.Duration
+}
+
+// Schedule decides whether a fault is injected into a call.
+type Schedule func(call Call) bool
+
+// Always injects the fault into every call.
+func Always() Schedule {
+	return func(Call) bool {
+		return true
+	}
+}
+
+// Calls injects the fault into the calls with those numbers.
+func Calls(numbers ...int) Schedule {
+	return func(call Call) bool {
+		for _, number := range numbers {
+			if call.Number == number {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// CallRange injects the fault into the calls from number from to number to,
+// inclusive.
+func CallRange(from, to int) Schedule {
+	return func(call Call) bool {
+		return call.Number >= from && call.Number <= to
+	}
+}
+
+// Every injects the fault into every nth call.
+func Every(n int) Schedule {
+	return func(call Call) bool {
+		return n > 0 && call.Number%n == 0
+	}
+}
+
+// Window injects the fault into the calls made between from and to after the
+// FaultClient was created, such as to simulate an outage.
+func Window(from, to time.Duration) Schedule {
+	return func(call Call) bool {
+		return call.Elapsed >= from && call.Elapsed < to
+	}
+}
+
+// Probability injects the fault into calls at random, with the probability p,
+// from the source of random numbers seeded with seed, so that a test fails the
+// same way each time it runs.
+func Probability(p float64, seed int64) Schedule {
+	var mu sync.Mutex
+	random := rand.New(rand.NewSource(seed))
+	return func(Call) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return random.Float64() < p
+	}
+}
+
+// ThrottlingError returns the error CloudWatch returns when it throttles a
+// request.
+func ThrottlingError() error {
+	return &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+}
+
+// ServerError returns the error the AWS SDK returns for a response with the
+// HTTP status code, such as 500 or 503.
+func ServerError(statusCode int) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: statusCode}},
+			Err: &smithy.GenericAPIError{
+				Code:    "ServiceUnavailableException",
+				Message: http.StatusText(statusCode),
+				Fault:   smithy.FaultServer,
+			},
+		},
+		RequestID: "00000000-0000-0000-0000-000000000000",
+	}
+}
+
+type rule struct {
+	schedule Schedule
+	fault    Fault
+}
+
+// FaultClient wraps a CloudWatchLogsClient, injecting faults into the
+// PutLogEvents calls on schedules, to test how a program behaves when
+// CloudWatch is slow, throttles it or fails. The other calls are passed
+// through. It is safe for concurrent use.
+type FaultClient struct {
+	cloudwatchwriter.CloudWatchLogsClient
+	start    time.Time
+	mu       sync.Mutex
+	rules    []rule
+	calls    int
+	injected int
+}
+
+// NewFaultClient returns a FaultClient wrapping client, usually a Client,
+// without any faults yet.
+func NewFaultClient(client cloudwatchwriter.CloudWatchLogsClient) *FaultClient {
+	return &FaultClient{
+		CloudWatchLogsClient: client,
+		start:                time.Now(),
+	}
+}
+
+// Inject adds a fault on a schedule. The faults are tried in the order they
+// were added, and only the first one whose schedule matches a call is
+// injected into it.
+func (c *FaultClient) Inject(schedule Schedule, fault Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rules = append(c.rules, rule{schedule: schedule, fault: fault})
+}
+
+// Clear removes the faults, such as to simulate CloudWatch recovering.
+func (c *FaultClient) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rules = nil
+}
+
+// Calls returns the number of PutLogEvents calls, and the number of them which
+// had a fault injected.
+func (c *FaultClient) Calls() (calls, injected int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls, c.injected
+}
+
+// PutRetentionPolicy passes the call on to the wrapped client, for
+// cloudwatchwriter.WithRetentionDays, returning an error if it has none.
+func (c *FaultClient) PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	return putRetentionPolicy(ctx, c.CloudWatchLogsClient, params, optFns...)
+}
+
+// DescribeLogGroups passes the call on to the wrapped client, for
+// cloudwatchwriter.WithLogGroupTags, returning an error if it has none.
+func (c *FaultClient) DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	return describeLogGroups(ctx, c.CloudWatchLogsClient, params, optFns...)
+}
+
+// TagResource passes the call on to the wrapped client, for
+// cloudwatchwriter.WithLogGroupTags, returning an error if it has none.
+func (c *FaultClient) TagResource(ctx context.Context, params *cloudwatchlogs.TagResourceInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagResourceOutput, error) {
+	return tagResource(ctx, c.CloudWatchLogsClient, params, optFns...)
+}
+
+// PutLogEvents implements cloudwatchwriter.CloudWatchLogsClient, injecting the
+// fault scheduled for the call, if there is one.
+func (c *FaultClient) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	fault, ok := c.fault()
+	if !ok {
+		return c.CloudWatchLogsClient.PutLogEvents(ctx, params, optFns...)
+	}
+
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+	if fault.Err != nil {
+		return nil, fault.Err
+	}
+	if fault.RejectTooOld == 0 && fault.RejectTooNew == 0 {
+		return c.CloudWatchLogsClient.PutLogEvents(ctx, params, optFns...)
+	}
+
+	n := len(params.LogEvents)
+	tooOld := min(fault.RejectTooOld, n)
+	tooNew := max(n-fault.RejectTooNew, tooOld)
+	info := &types.RejectedLogEventsInfo{}
+	if tooOld > 0 {
+		info.TooOldLogEventEndIndex = aws.Int32(int32(tooOld))
+	}
+	if tooNew < n {
+		info.TooNewLogEventStartIndex = aws.Int32(int32(tooNew))
+	}
+	if tooOld == tooNew {
+		// Every log event is rejected, so nothing is sent
+		return &cloudwatchlogs.PutLogEventsOutput{RejectedLogEventsInfo: info}, nil
+	}
+
+	accepted := *params
+	accepted.LogEvents = params.LogEvents[tooOld:tooNew]
+	output, err := c.CloudWatchLogsClient.PutLogEvents(ctx, &accepted, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	output.RejectedLogEventsInfo = info
+	return output, nil
+}
+
+// fault returns the fault to inject into the next call, if there is one.
+func (c *FaultClient) fault() (Fault, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls++
+	call := Call{Number: c.calls, Elapsed: time.Since(c.start)}
+	for _, rule := range c.rules {
+		if rule.schedule(call) {
+			c.injected++
+			return rule.fault, true
+		}
+	}
+	return Fault{}, false
+}
@@ -0,0 +1,140 @@
+package cloudwatchwritertest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func writeAndFlush(t *testing.T, writer *cloudwatchwriter.CloudWatchWriter, log string) error {
+	t.Helper()
+	_, err := writer.Write([]byte(log))
+	require.NoError(t, err)
+	return writer.Flush()
+}
+
+func TestFaultClientServerErrors(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	faulty := cloudwatchwritertest.NewFaultClient(client)
+	faulty.Inject(cloudwatchwritertest.CallRange(1, 2), cloudwatchwritertest.Fault{Err: cloudwatchwritertest.ServerError(503)})
+	writer := newWriter(t, faulty)
+
+	// The errors are retried, like the AWS SDK does
+	require.NoError(t, writeAndFlush(t, writer, "log"))
+	assert.Equal(t, []string{"log"}, client.Messages("group", "stream"))
+	calls, injected := faulty.Calls()
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 2, injected)
+	assert.Equal(t, int64(2), writer.Stats().Retries)
+}
+
+func TestFaultClientThrottling(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	faulty := cloudwatchwritertest.NewFaultClient(client)
+	faulty.Inject(cloudwatchwritertest.Calls(1), cloudwatchwritertest.Fault{Err: cloudwatchwritertest.ThrottlingError()})
+	writer := newWriter(t, faulty)
+
+	require.NoError(t, writeAndFlush(t, writer, "log"))
+	assert.Equal(t, []string{"log"}, client.Messages("group", "stream"))
+	assert.Equal(t, int64(1), writer.Stats().Throttles)
+}
+
+func TestFaultClientOutage(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	faulty := cloudwatchwritertest.NewFaultClient(client)
+	faulty.Inject(cloudwatchwritertest.Window(0, time.Hour), cloudwatchwritertest.Fault{Err: cloudwatchwritertest.ServerError(500)})
+	writer := newWriter(t, faulty)
+
+	assert.Error(t, writeAndFlush(t, writer, "lost"))
+	calls, _ := faulty.Calls()
+	assert.Equal(t, 3, calls)
+
+	faulty.Clear()
+	require.NoError(t, writeAndFlush(t, writer, "sent"))
+	assert.Equal(t, []string{"sent"}, client.Messages("group", "stream"))
+}
+
+func TestFaultClientLatency(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	faulty := cloudwatchwritertest.NewFaultClient(client)
+	faulty.Inject(cloudwatchwritertest.Always(), cloudwatchwritertest.Fault{Latency: 50 * time.Millisecond})
+	writer := newWriter(t, faulty)
+
+	start := time.Now()
+	require.NoError(t, writeAndFlush(t, writer, "log"))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, []string{"log"}, client.Messages("group", "stream"))
+
+	// The latency is cut short when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := faulty.PutLogEvents(ctx, &cloudwatchlogs.PutLogEventsInput{
+		LogGroupName:  aws.String("group"),
+		LogStreamName: aws.String("stream"),
+	})
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestFaultClientRejectedEvents(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	faulty := cloudwatchwritertest.NewFaultClient(client)
+	faulty.Inject(cloudwatchwritertest.Every(1), cloudwatchwritertest.Fault{RejectTooOld: 1, RejectTooNew: 1})
+	writer := newWriter(t, faulty)
+
+	require.NoError(t, writer.WriteEvents([]cloudwatchwriter.LogEvent{
+		{Message: "first"}, {Message: "second"}, {Message: "third"},
+	}))
+	require.NoError(t, writer.Flush())
+
+	assert.Equal(t, []string{"second"}, client.Messages("group", "stream"))
+	stats := writer.Stats()
+	assert.Equal(t, int64(1), stats.RejectedTooOld)
+	assert.Equal(t, int64(1), stats.RejectedTooNew)
+
+	// When every log event is rejected, nothing is sent
+	require.NoError(t, writeAndFlush(t, writer, "rejected"))
+	assert.Equal(t, []string{"second"}, client.Messages("group", "stream"))
+	assert.Equal(t, int64(2), writer.Stats().RejectedTooOld)
+}
+
+func TestFaultClientProbability(t *testing.T) {
+	pick := func() []bool {
+		schedule := cloudwatchwritertest.Probability(0.5, 1)
+		var picked []bool
+		for i := 1; i <= 20; i++ {
+			picked = append(picked, schedule(cloudwatchwritertest.Call{Number: i}))
+		}
+		return picked
+	}
+
+	first := pick()
+	assert.Equal(t, first, pick(), "the same seed picks the same calls")
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+}
+
+func TestFaultClientFirstMatchingFault(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	faulty := cloudwatchwritertest.NewFaultClient(client)
+	faulty.Inject(cloudwatchwritertest.Calls(2), cloudwatchwritertest.Fault{})
+	faulty.Inject(cloudwatchwritertest.Every(2), cloudwatchwritertest.Fault{Err: cloudwatchwritertest.ServerError(500)})
+	writer := newWriter(t, faulty, cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}))
+
+	for _, log := range []string{"1", "2", "3", "4"} {
+		err := writeAndFlush(t, writer, log)
+		if log == "4" {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, client.Messages("group", "stream"))
+}
@@ -0,0 +1,55 @@
+package cloudwatchwritertest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+// retentionPolicyPutter is implemented by clients which can set the retention
+// period of a log group, which cloudwatchwriter.WithRetentionDays needs.
+type retentionPolicyPutter interface {
+	PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error)
+}
+
+// logGroupDescriber and resourceTagger are implemented by clients which can
+// look up and tag log groups, which cloudwatchwriter.WithLogGroupTags needs.
+type logGroupDescriber interface {
+	DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error)
+}
+
+type resourceTagger interface {
+	TagResource(ctx context.Context, params *cloudwatchlogs.TagResourceInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagResourceOutput, error)
+}
+
+// putRetentionPolicy passes the call on to a wrapped client, returning an
+// error if it doesn't implement PutRetentionPolicy.
+func putRetentionPolicy(ctx context.Context, client cloudwatchwriter.CloudWatchLogsClient, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	putter, ok := client.(retentionPolicyPutter)
+	if !ok {
+		return nil, fmt.Errorf("PutRetentionPolicy: not implemented by %T", client)
+	}
+	return putter.PutRetentionPolicy(ctx, params, optFns...)
+}
+
+// describeLogGroups passes the call on to a wrapped client, returning an error
+// if it doesn't implement DescribeLogGroups.
+func describeLogGroups(ctx context.Context, client cloudwatchwriter.CloudWatchLogsClient, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	describer, ok := client.(logGroupDescriber)
+	if !ok {
+		return nil, fmt.Errorf("DescribeLogGroups: not implemented by %T", client)
+	}
+	return describer.DescribeLogGroups(ctx, params, optFns...)
+}
+
+// tagResource passes the call on to a wrapped client, returning an error if it
+// doesn't implement TagResource.
+func tagResource(ctx context.Context, client cloudwatchwriter.CloudWatchLogsClient, params *cloudwatchlogs.TagResourceInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagResourceOutput, error) {
+	tagger, ok := client.(resourceTagger)
+	if !ok {
+		return nil, fmt.Errorf("TagResource: not implemented by %T", client)
+	}
+	return tagger.TagResource(ctx, params, optFns...)
+}
@@ -0,0 +1,386 @@
+package cloudwatchwritertest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+// ErrUnexpectedCall is returned by a Replayer for a call which is not the next
+// one in the recording, or when there are no calls left in it.
+var ErrUnexpectedCall = errors.New("call doesn't match the recording")
+
+// interaction is a call recorded by a Recorder, one per line of the file.
+type interaction struct {
+	Operation string          `json:"operation"`
+	Input     json.RawMessage `json:"input"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     *recordedError  `json:"error,omitempty"`
+}
+
+// recordedError is enough of an error to return the same kind of error when it
+// is replayed.
+type recordedError struct {
+	Code                  string  `json:"code,omitempty"`
+	Message               string  `json:"message"`
+	StatusCode            int     `json:"status_code,omitempty"`
+	ExpectedSequenceToken *string `json:"expected_sequence_token,omitempty"`
+}
+
+func newRecordedError(err error) *recordedError {
+	recorded := &recordedError{Message: err.Error()}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		recorded.Code = apiErr.ErrorCode()
+		recorded.Message = apiErr.ErrorMessage()
+	}
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		recorded.StatusCode = statusErr.HTTPStatusCode()
+	}
+	var ist *types.InvalidSequenceTokenException
+	var daa *types.DataAlreadyAcceptedException
+	switch {
+	case errors.As(err, &ist):
+		recorded.ExpectedSequenceToken = ist.ExpectedSequenceToken
+	case errors.As(err, &daa):
+		recorded.ExpectedSequenceToken = daa.ExpectedSequenceToken
+	}
+	return recorded
+}
+
+// err returns an error like the one which was recorded, of the same type for
+// the CloudWatch Logs errors which cloudwatchwriter handles.
+func (e *recordedError) err() error {
+	message := &e.Message
+	var err error
+	switch e.Code {
+	case "":
+		switch e.Message {
+		case context.Canceled.Error():
+			return context.Canceled
+		case context.DeadlineExceeded.Error():
+			return context.DeadlineExceeded
+		}
+		return errors.New(e.Message)
+	case "ResourceNotFoundException":
+		err = &types.ResourceNotFoundException{Message: message}
+	case "ResourceAlreadyExistsException":
+		err = &types.ResourceAlreadyExistsException{Message: message}
+	case "InvalidParameterException":
+		err = &types.InvalidParameterException{Message: message}
+	case "InvalidSequenceTokenException":
+		err = &types.InvalidSequenceTokenException{Message: message, ExpectedSequenceToken: e.ExpectedSequenceToken}
+	case "DataAlreadyAcceptedException":
+		err = &types.DataAlreadyAcceptedException{Message: message, ExpectedSequenceToken: e.ExpectedSequenceToken}
+	case "ServiceUnavailableException":
+		err = &types.ServiceUnavailableException{Message: message}
+	default:
+		err = &smithy.GenericAPIError{Code: e.Code, Message: e.Message}
+	}
+	if e.StatusCode != 0 {
+		responseErr := ServerError(e.StatusCode).(*awshttp.ResponseError)
+		responseErr.Err = err
+		err = responseErr
+	}
+	return err
+}
+
+// Recorder wraps a CloudWatchLogsClient, usually the real one, writing every
+// call, with its input and its output or error, to a file which a Replayer can
+// replay in tests. It is safe for concurrent use.
+type Recorder struct {
+	client cloudwatchwriter.CloudWatchLogsClient
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	err    error
+}
+
+// NewRecorder returns a Recorder wrapping client, writing the calls to the file
+// at path, which is replaced if it exists.
+func NewRecorder(client cloudwatchwriter.CloudWatchLogsClient, path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create recording: %w", err)
+	}
+	return &Recorder{
+		client: client,
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}, nil
+}
+
+// Close finishes writing the recording, returning the first error from writing
+// it.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.writer.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+func (r *Recorder) record(operation string, input, output interface{}, err error) {
+	line, marshalErr := marshalInteraction(operation, input, output, err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if marshalErr == nil {
+		_, marshalErr = r.writer.Write(append(line, '\n'))
+	}
+	if marshalErr != nil && r.err == nil {
+		r.err = marshalErr
+	}
+}
+
+// marshalInteraction returns the line of the recording for a call.
+func marshalInteraction(operation string, input, output interface{}, err error) ([]byte, error) {
+	call := interaction{Operation: operation}
+	var marshalErr error
+	if call.Input, marshalErr = json.Marshal(input); marshalErr != nil {
+		return nil, marshalErr
+	}
+	if err != nil {
+		call.Error = newRecordedError(err)
+	} else if call.Output, marshalErr = json.Marshal(output); marshalErr != nil {
+		return nil, marshalErr
+	}
+	return json.Marshal(call)
+}
+
+// CreateLogGroup implements cloudwatchwriter.CloudWatchLogsClient.
+func (r *Recorder) CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	output, err := r.client.CreateLogGroup(ctx, params, optFns...)
+	r.record("CreateLogGroup", params, output, err)
+	return output, err
+}
+
+// CreateLogStream implements cloudwatchwriter.CloudWatchLogsClient.
+func (r *Recorder) CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	output, err := r.client.CreateLogStream(ctx, params, optFns...)
+	r.record("CreateLogStream", params, output, err)
+	return output, err
+}
+
+// DescribeLogGroups records a call to the wrapped client, for
+// cloudwatchwriter.WithLogGroupTags, returning an error if it has none.
+func (r *Recorder) DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	output, err := describeLogGroups(ctx, r.client, params, optFns...)
+	r.record("DescribeLogGroups", params, output, err)
+	return output, err
+}
+
+// DescribeLogStreams implements cloudwatchwriter.CloudWatchLogsClient.
+func (r *Recorder) DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	output, err := r.client.DescribeLogStreams(ctx, params, optFns...)
+	r.record("DescribeLogStreams", params, output, err)
+	return output, err
+}
+
+// PutLogEvents implements cloudwatchwriter.CloudWatchLogsClient.
+func (r *Recorder) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	output, err := r.client.PutLogEvents(ctx, params, optFns...)
+	r.record("PutLogEvents", params, output, err)
+	return output, err
+}
+
+// PutRetentionPolicy records a call to the wrapped client, for
+// cloudwatchwriter.WithRetentionDays, returning an error if it has none.
+func (r *Recorder) PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	output, err := putRetentionPolicy(ctx, r.client, params, optFns...)
+	r.record("PutRetentionPolicy", params, output, err)
+	return output, err
+}
+
+// TagResource records a call to the wrapped client, for
+// cloudwatchwriter.WithLogGroupTags, returning an error if it has none.
+func (r *Recorder) TagResource(ctx context.Context, params *cloudwatchlogs.TagResourceInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagResourceOutput, error) {
+	output, err := tagResource(ctx, r.client, params, optFns...)
+	r.record("TagResource", params, output, err)
+	return output, err
+}
+
+// Replayer is a CloudWatchLogsClient which replays a recording made by a
+// Recorder, so that a test behaves the same as when it was recorded, without
+// AWS. Each call must be the next one in the recording, with the same
+// operation and input, apart from the timestamps of the log events, otherwise
+// it returns ErrUnexpectedCall. As the calls are replayed in order, the test
+// should make the same calls in the same order as when it was recorded, by
+// flushing the writer rather than relying on the batch interval. It is safe
+// for concurrent use.
+type Replayer struct {
+	mu    sync.Mutex
+	calls []interaction
+	next  int
+}
+
+// NewReplayer returns a Replayer replaying the recording in the file at path.
+func NewReplayer(path string) (*Replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open recording: %w", err)
+	}
+	defer file.Close()
+
+	r := &Replayer{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 10*maxBatchSize)
+	for scanner.Scan() {
+		var call interaction
+		if err = json.Unmarshal(scanner.Bytes(), &call); err != nil {
+			return nil, fmt.Errorf("read recording %s, call %d: %w", path, len(r.calls)+1, err)
+		}
+		r.calls = append(r.calls, call)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read recording %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Remaining returns the number of calls in the recording which haven't been
+// replayed yet, which should be none at the end of a test.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.calls) - r.next
+}
+
+// replay checks that the call is the next one in the recording, and sets
+// output to the recorded output, or returns the recorded error.
+func (r *Replayer) replay(operation string, input, output interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next == len(r.calls) {
+		return fmt.Errorf("%w: %s after the %d recorded calls", ErrUnexpectedCall, operation, len(r.calls))
+	}
+	call := r.calls[r.next]
+	if call.Operation != operation {
+		return fmt.Errorf("%w: call %d is %s, but %s was recorded", ErrUnexpectedCall, r.next+1, operation, call.Operation)
+	}
+
+	// The recorded input is decoded into the same type, so that they are
+	// compared the same way
+	recorded := reflect.New(reflect.TypeOf(input).Elem()).Interface()
+	if err := json.Unmarshal(call.Input, recorded); err != nil {
+		return fmt.Errorf("call %d: %w", r.next+1, err)
+	}
+	want, err := matchKey(recorded)
+	if err != nil {
+		return err
+	}
+	got, err := matchKey(input)
+	if err != nil {
+		return err
+	}
+	if string(got) != string(want) {
+		return fmt.Errorf("%w: call %d is %s with %s, but %s was recorded", ErrUnexpectedCall, r.next+1, operation, got, want)
+	}
+	r.next++
+
+	if call.Error != nil {
+		return call.Error.err()
+	}
+	if len(call.Output) == 0 {
+		return nil
+	}
+	return json.Unmarshal(call.Output, output)
+}
+
+// matchKey returns the JSON used to compare the input of a call with the
+// recorded one, which leaves out the timestamps of log events, as those change
+// each time a test runs.
+func matchKey(input interface{}) ([]byte, error) {
+	if params, ok := input.(*cloudwatchlogs.PutLogEventsInput); ok {
+		withoutTimestamps := *params
+		withoutTimestamps.LogEvents = make([]types.InputLogEvent, len(params.LogEvents))
+		for i, event := range params.LogEvents {
+			withoutTimestamps.LogEvents[i] = types.InputLogEvent{Message: event.Message}
+		}
+		input = &withoutTimestamps
+	}
+	return json.Marshal(input)
+}
+
+// CreateLogGroup implements cloudwatchwriter.CloudWatchLogsClient.
+func (r *Replayer) CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	output := &cloudwatchlogs.CreateLogGroupOutput{}
+	if err := r.replay("CreateLogGroup", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// CreateLogStream implements cloudwatchwriter.CloudWatchLogsClient.
+func (r *Replayer) CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	output := &cloudwatchlogs.CreateLogStreamOutput{}
+	if err := r.replay("CreateLogStream", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeLogGroups replays a call, for cloudwatchwriter.WithLogGroupTags.
+func (r *Replayer) DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	output := &cloudwatchlogs.DescribeLogGroupsOutput{}
+	if err := r.replay("DescribeLogGroups", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DescribeLogStreams implements cloudwatchwriter.CloudWatchLogsClient.
+func (r *Replayer) DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	output := &cloudwatchlogs.DescribeLogStreamsOutput{}
+	if err := r.replay("DescribeLogStreams", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// PutLogEvents implements cloudwatchwriter.CloudWatchLogsClient.
+func (r *Replayer) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	output := &cloudwatchlogs.PutLogEventsOutput{}
+	if err := r.replay("PutLogEvents", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// PutRetentionPolicy replays a call, for cloudwatchwriter.WithRetentionDays.
+func (r *Replayer) PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	output := &cloudwatchlogs.PutRetentionPolicyOutput{}
+	if err := r.replay("PutRetentionPolicy", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// TagResource replays a call, for cloudwatchwriter.WithLogGroupTags.
+func (r *Replayer) TagResource(ctx context.Context, params *cloudwatchlogs.TagResourceInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagResourceOutput, error) {
+	output := &cloudwatchlogs.TagResourceOutput{}
+	if err := r.replay("TagResource", params, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,62 @@
+package cloudwatchwritertest_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+	run := func(client cloudwatchwriter.CloudWatchLogsClient) *cloudwatchwriter.CloudWatchWriter {
+		writer := newWriter(t, client, cloudwatchwriter.WithRetentionDays(7))
+		require.NoError(t, writeAndFlush(t, writer, "first"))
+		require.NoError(t, writeAndFlush(t, writer, "second"))
+		writer.Close()
+		return writer
+	}
+
+	// The recording includes an error, which is retried
+	client := cloudwatchwritertest.NewClient()
+	faulty := cloudwatchwritertest.NewFaultClient(client)
+	faulty.Inject(cloudwatchwritertest.Calls(2), cloudwatchwritertest.Fault{Err: cloudwatchwritertest.ServerError(503)})
+	recorder, err := cloudwatchwritertest.NewRecorder(faulty, path)
+	require.NoError(t, err)
+	recorded := run(recorder)
+	require.NoError(t, recorder.Close())
+	assert.Equal(t, []string{"first", "second"}, client.Messages("group", "stream"))
+
+	replayer, err := cloudwatchwritertest.NewReplayer(path)
+	require.NoError(t, err)
+	replayed := run(replayer)
+	assert.Equal(t, 0, replayer.Remaining())
+	assert.Equal(t, recorded.Stats().Retries, replayed.Stats().Retries)
+	assert.Equal(t, int64(1), replayed.Stats().Retries)
+}
+
+func TestReplayUnexpectedCall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+	recorder, err := cloudwatchwritertest.NewRecorder(cloudwatchwritertest.NewClient(), path)
+	require.NoError(t, err)
+	writer := newWriter(t, recorder)
+	require.NoError(t, writeAndFlush(t, writer, "recorded"))
+	writer.Close()
+	require.NoError(t, recorder.Close())
+
+	replayer, err := cloudwatchwritertest.NewReplayer(path)
+	require.NoError(t, err)
+	writer = newWriter(t, replayer)
+	err = writeAndFlush(t, writer, "different")
+	assert.True(t, errors.Is(err, cloudwatchwritertest.ErrUnexpectedCall), "%v", err)
+	assert.Equal(t, 1, replayer.Remaining())
+}
+
+func TestNewReplayerMissingFile(t *testing.T) {
+	_, err := cloudwatchwritertest.NewReplayer(filepath.Join(t.TempDir(), "missing.jsonl"))
+	assert.Error(t, err)
+}
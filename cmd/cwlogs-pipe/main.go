@@ -0,0 +1,152 @@
+// Command cwlogs-pipe sends the lines it reads from standard input to a
+// CloudWatch Logs log stream, one log event per line:
+//
+//	app | cwlogs-pipe --group my-group --stream my-stream
+//
+// The AWS credentials and region are found the same way as by the AWS CLI,
+// and the log group and log stream are created if they don't exist. Each line
+// gets the time it was read as its timestamp, unless --timestamp-layout is
+// given, in which case lines which start with a timestamp in that layout get
+// that timestamp instead.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+// closeTimeout is how long to wait for the remaining logs to be sent, once
+// standard input has been read or the program has been told to stop.
+const closeTimeout = 30 * time.Second
+
+// layouts are the names which can be given for common timestamp layouts.
+var layouts = map[string]string{
+	"rfc3339":  time.RFC3339Nano,
+	"ansic":    time.ANSIC,
+	"datetime": time.DateTime,
+	"kitchen":  time.Kitchen,
+	"stamp":    time.StampMilli,
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("cwlogs-pipe: ")
+
+	group := flag.String("group", "", "the log group to send the logs to (required)")
+	stream := flag.String("stream", "", "the log stream to send the logs to (required)")
+	region := flag.String("region", "", "the AWS region, instead of the default one")
+	batchInterval := flag.Duration("batch-interval", 5*time.Second, "the maximum time between batches of logs, at least 200ms")
+	timestampLayout := flag.String("timestamp-layout", "", "the Go time layout, or one of rfc3339, ansic, datetime, kitchen or stamp, of timestamps at the start of the lines")
+	tee := flag.Bool("tee", false, "copy standard input to standard output too")
+	flag.Parse()
+
+	if *group == "" || *stream == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if layout, ok := layouts[*timestampLayout]; ok {
+		*timestampLayout = layout
+	}
+
+	if err := run(*group, *stream, *region, *batchInterval, *timestampLayout, *tee); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(group, stream, region string, batchInterval time.Duration, timestampLayout string, tee bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("load AWS config: %w", err)
+	}
+
+	writer, err := cloudwatchwriter.New(cfg, group, stream,
+		cloudwatchwriter.WithBatchInterval(batchInterval),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.Block),
+		cloudwatchwriter.WithMaxQueueSize(100000, 0),
+		cloudwatchwriter.WithErrorHandler(func(err error) {
+			log.Printf("sending logs: %v", err)
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if tee {
+		in = io.TeeReader(os.Stdin, os.Stdout)
+	}
+	read := make(chan error, 1)
+	go func() {
+		read <- pipe(in, writer, timestampLayout)
+	}()
+
+	select {
+	case err = <-read:
+	case <-ctx.Done():
+	}
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return errors.Join(err, writer.CloseWithContext(closeCtx))
+}
+
+// pipe writes each line read from r to the writer, until the end of r.
+func pipe(r io.Reader, writer *cloudwatchwriter.CloudWatchWriter, timestampLayout string) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			if writeErr := writer.WriteEvents([]cloudwatchwriter.LogEvent{lineEvent(line, timestampLayout)}); writeErr != nil {
+				log.Printf("writing log: %v", writeErr)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read standard input: %w", err)
+		}
+	}
+}
+
+// lineEvent returns the log event for a line. If the line starts with a
+// timestamp in the layout, the event has that timestamp, otherwise it is left
+// to be the time it is written.
+func lineEvent(line, layout string) cloudwatchwriter.LogEvent {
+	event := cloudwatchwriter.LogEvent{Message: line}
+	if layout == "" {
+		return event
+	}
+
+	// The timestamp is as many words as there are in the layout
+	words := strings.Count(layout, " ") + 1
+	fields := strings.SplitN(line, " ", words+1)
+	if len(fields) < words {
+		return event
+	}
+	timestamp, err := time.Parse(layout, strings.Join(fields[:words], " "))
+	if err == nil {
+		event.Timestamp = timestamp
+	}
+	return event
+}
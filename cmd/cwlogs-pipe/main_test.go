@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineEvent(t *testing.T) {
+	timestamp := time.Date(2021, 8, 18, 12, 30, 0, 0, time.UTC)
+
+	event := lineEvent("2021-08-18T12:30:00Z something happened", time.RFC3339Nano)
+	assert.Equal(t, "2021-08-18T12:30:00Z something happened", event.Message)
+	assert.True(t, timestamp.Equal(event.Timestamp))
+
+	// A layout with spaces in it
+	event = lineEvent("2021-08-18 12:30:00 something happened", time.DateTime)
+	assert.True(t, timestamp.Equal(event.Timestamp))
+
+	// A line without a timestamp is left to be given the time it is written
+	event = lineEvent("something happened", time.RFC3339Nano)
+	assert.True(t, event.Timestamp.IsZero())
+
+	event = lineEvent("2021-08-18T12:30:00Z", "")
+	assert.True(t, event.Timestamp.IsZero())
+}
@@ -0,0 +1,106 @@
+// Command cwlogs-tail follows log files and sends the lines appended to them
+// to a CloudWatch Logs log stream, one log event per line:
+//
+//	cwlogs-tail --group my-group --stream my-stream --checkpoint /var/lib/cwlogs-tail.json /var/log/app.log
+//
+// The AWS credentials and region are found the same way as by the AWS CLI,
+// and the log group and log stream are created if they don't exist. Rotated
+// and truncated files are followed, and with --checkpoint, it carries on from
+// where it stopped when it is restarted.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cwtail"
+)
+
+// closeTimeout is how long to wait for the remaining logs to be sent, once the
+// program has been told to stop.
+const closeTimeout = 30 * time.Second
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("cwlogs-tail: ")
+
+	group := flag.String("group", "", "the log group to send the logs to (required)")
+	stream := flag.String("stream", "", "the log stream to send the logs to (required)")
+	region := flag.String("region", "", "the AWS region, instead of the default one")
+	batchInterval := flag.Duration("batch-interval", 5*time.Second, "the maximum time between batches of logs, at least 200ms")
+	pollInterval := flag.Duration("poll-interval", time.Second, "how often to check the files for new lines")
+	checkpoint := flag.String("checkpoint", "", "the file to save how far the files have been sent in")
+	fromStart := flag.Bool("from-start", false, "send the lines already in the files which are not in the checkpoint")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: cwlogs-tail --group group --stream stream [flags] file...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *group == "" || *stream == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := []cwtail.Option{
+		cwtail.WithPollInterval(*pollInterval),
+		cwtail.WithErrorHandler(func(err error) {
+			log.Print(err)
+		}),
+	}
+	if *checkpoint != "" {
+		opts = append(opts, cwtail.WithCheckpoint(*checkpoint))
+	}
+	if *fromStart {
+		opts = append(opts, cwtail.WithFromStart())
+	}
+
+	if err := run(*group, *stream, *region, *batchInterval, flag.Args(), opts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(group, stream, region string, batchInterval time.Duration, paths []string, opts []cwtail.Option) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var configOpts []func(*config.LoadOptions) error
+	if region != "" {
+		configOpts = append(configOpts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, configOpts...)
+	if err != nil {
+		return fmt.Errorf("load AWS config: %w", err)
+	}
+
+	writer, err := cloudwatchwriter.New(cfg, group, stream,
+		cloudwatchwriter.WithBatchInterval(batchInterval),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.Block),
+		cloudwatchwriter.WithMaxQueueSize(100000, 0),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Without an error handler on the writer, the errors from sending the
+	// logs are returned by Write and Flush, so the tailer logs them and
+	// doesn't save a checkpoint past logs which failed to be sent
+	tailer, err := cwtail.New(writer, paths, opts...)
+	if err != nil {
+		return err
+	}
+	err = tailer.Run(ctx)
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return errors.Join(err, writer.CloseWithContext(closeCtx))
+}
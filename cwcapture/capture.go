@@ -0,0 +1,194 @@
+// Package cwcapture forwards what the process writes to its standard output
+// and standard error to writers such as a cloudwatchwriter.CloudWatchWriter,
+// including the output of C libraries and of child processes which inherit
+// them, while still writing it to where it went before.
+package cwcapture
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// maxLineSize is the length at which a line which hasn't ended yet is
+	// sent anyway, as CloudWatch doesn't accept longer log events.
+	maxLineSize = 256 * 1024
+	readSize    = 32 * 1024
+)
+
+// Capture replaces the file descriptors of the standard output and standard
+// error with pipes, writing each line written to them to a writer, one Write
+// per line without the line ending. Everything written to them is also
+// written to the original file descriptors, unless WithoutEcho is given.
+type Capture struct {
+	streams      []*stream
+	echo         bool
+	errorHandler func(error)
+	forwarding   sync.WaitGroup
+	closeOnce    sync.Once
+	// closeOriginals closes the copies of the original file descriptors,
+	// once they are no longer restored or echoed to.
+	closeOriginals sync.Once
+}
+
+// stream is the standard output or standard error being captured.
+type stream struct {
+	fd     int
+	writer io.Writer
+	// original is a copy of the file descriptor from before it was
+	// captured, which is restored by Close, and closed once forwarding
+	// has stopped.
+	original *os.File
+	// r and w are the ends of the pipe which the file descriptor has been
+	// replaced with.
+	r, w *os.File
+}
+
+// Option configures a Capture, see Start.
+type Option func(*Capture)
+
+// WithoutEcho stops the output from also being written to the original
+// standard output and standard error, so that it is only sent to the writers.
+func WithoutEcho() Option {
+	return func(c *Capture) {
+		c.echo = false
+	}
+}
+
+// WithErrorHandler sets a function which is called with the errors from
+// writing the output, which don't stop the Capture. By default they are
+// ignored.
+func WithErrorHandler(handler func(error)) Option {
+	return func(c *Capture) {
+		c.errorHandler = handler
+	}
+}
+
+// Start starts capturing the standard output, writing its lines to stdout,
+// and the standard error, writing its lines to stderr, which can be the same
+// writer. A nil writer leaves that file descriptor alone. The writers mustn't
+// write to the standard output or standard error themselves, such as with the
+// internal logging of a CloudWatchWriter, or they would capture their own
+// output. Capturing isn't supported on Windows.
+func Start(stdout, stderr io.Writer, opts ...Option) (*Capture, error) {
+	c := &Capture{echo: true}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	for _, s := range []*stream{{fd: 1, writer: stdout}, {fd: 2, writer: stderr}} {
+		if s.writer == nil {
+			continue
+		}
+		if err := s.capture(); err != nil {
+			c.restore()
+			for _, s := range c.streams {
+				s.r.Close()
+				s.original.Close()
+			}
+			return nil, err
+		}
+		c.streams = append(c.streams, s)
+	}
+	for _, s := range c.streams {
+		c.forwarding.Add(1)
+		go c.forward(s)
+	}
+	return c, nil
+}
+
+// forward writes what is written to the stream, until its pipe is closed.
+func (c *Capture) forward(s *stream) {
+	defer c.forwarding.Done()
+	defer s.r.Close()
+
+	buf := make([]byte, readSize)
+	var partial []byte
+	for {
+		n, err := s.r.Read(buf)
+		if c.echo && n > 0 {
+			_, _ = s.original.Write(buf[:n])
+		}
+		partial = append(partial, buf[:n]...)
+
+		for {
+			i := bytes.IndexByte(partial, '\n')
+			if i < 0 {
+				break
+			}
+			c.write(s, partial[:i])
+			partial = partial[i+1:]
+		}
+		if len(partial) >= maxLineSize || err != nil && len(partial) > 0 {
+			c.write(s, partial)
+			partial = nil
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// write writes the line, without its line ending.
+func (c *Capture) write(s *stream, line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 {
+		return
+	}
+	// The writer may keep the line, so it mustn't share the buffer
+	if _, err := s.writer.Write(append([]byte(nil), line...)); err != nil && c.errorHandler != nil {
+		c.errorHandler(err)
+	}
+}
+
+// Close blocks until the output written so far has been written to the
+// writers, see CloseWithContext.
+func (c *Capture) Close() {
+	_ = c.CloseWithContext(context.Background())
+}
+
+// CloseWithContext restores the standard output and standard error, and
+// blocks until the output written to them before has been written to the
+// writers. Child processes which inherited them keep writing to the pipes
+// until they exit, so when ctx is done first, their output is no longer
+// forwarded and ctx.Err() is returned. The writers aren't flushed or closed.
+func (c *Capture) CloseWithContext(ctx context.Context) error {
+	c.closeOnce.Do(c.restore)
+
+	done := make(chan struct{})
+	go func() {
+		c.forwarding.Wait()
+		c.closeOriginals.Do(func() {
+			for _, s := range c.streams {
+				s.original.Close()
+			}
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, s := range c.streams {
+			s.r.Close()
+		}
+		<-done
+		return ctx.Err()
+	}
+}
+
+// restore puts back the original file descriptors, and closes the ends of the
+// pipes which the Capture writes to, so that forwarding stops once everything
+// else holding them has closed them too.
+func (c *Capture) restore() {
+	for _, s := range c.streams {
+		if err := s.restore(); err != nil && c.errorHandler != nil {
+			c.errorHandler(err)
+		}
+		s.w.Close()
+	}
+}
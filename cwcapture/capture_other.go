@@ -0,0 +1,15 @@
+//go:build !unix
+
+package cwcapture
+
+import "errors"
+
+var errUnsupported = errors.New("capturing the standard output and standard error is not supported on this platform")
+
+func (s *stream) capture() error {
+	return errUnsupported
+}
+
+func (s *stream) restore() error {
+	return errUnsupported
+}
@@ -0,0 +1,85 @@
+//go:build unix
+
+package cwcapture_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tracmo/cloudwatchwriter/cwcapture"
+	"golang.org/x/sys/unix"
+)
+
+type recordingWriter struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (w *recordingWriter) Write(log []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lines = append(w.lines, string(log))
+	return len(log), nil
+}
+
+func (w *recordingWriter) Lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([]string(nil), w.lines...)
+}
+
+func TestCapture(t *testing.T) {
+	stdout := &recordingWriter{}
+	stderr := &recordingWriter{}
+	capture, err := cwcapture.Start(stdout, stderr, cwcapture.WithoutEcho())
+	require.NoError(t, err)
+
+	fmt.Fprint(os.Stdout, "first line\r\nsecond ")
+	fmt.Fprintln(os.Stdout, "line")
+	fmt.Fprintln(os.Stderr, "error line")
+	// Written to the file descriptor directly, like a C library would
+	_, err = unix.Write(1, []byte("raw line\n"))
+	assert.NoError(t, err)
+	cmd := exec.Command("sh", "-c", "echo child line; echo child error >&2; printf 'no newline'")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	assert.NoError(t, cmd.Run())
+	capture.Close()
+
+	assert.Equal(t, []string{"first line", "second line", "raw line", "child line", "no newline"}, stdout.Lines())
+	assert.Equal(t, []string{"error line", "child error"}, stderr.Lines())
+}
+
+func TestCaptureEcho(t *testing.T) {
+	// The original standard error is a pipe, to see what is echoed to it
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	original, err := unix.Dup(2)
+	require.NoError(t, err)
+	require.NoError(t, unix.Dup2(int(w.Fd()), 2))
+	defer func() {
+		assert.NoError(t, unix.Dup2(original, 2))
+		unix.Close(original)
+	}()
+
+	stderr := &recordingWriter{}
+	capture, err := cwcapture.Start(nil, stderr)
+	require.NoError(t, err)
+	fmt.Fprintln(os.Stderr, "echoed")
+	capture.Close()
+	w.Close()
+
+	assert.Equal(t, []string{"echoed"}, stderr.Lines())
+	echoed := make([]byte, 100)
+	n, err := r.Read(echoed)
+	assert.NoError(t, err)
+	assert.Equal(t, "echoed\n", string(echoed[:n]))
+}
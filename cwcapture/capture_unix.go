@@ -0,0 +1,42 @@
+//go:build unix
+
+package cwcapture
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// capture replaces the stream's file descriptor with the write end of a pipe,
+// keeping a copy of the original.
+func (s *stream) capture() error {
+	original, err := unix.Dup(s.fd)
+	if err != nil {
+		return fmt.Errorf("copying file descriptor %d: %w", s.fd, err)
+	}
+	unix.CloseOnExec(original)
+	s.original = os.NewFile(uintptr(original), fmt.Sprintf("original file descriptor %d", s.fd))
+
+	s.r, s.w, err = os.Pipe()
+	if err != nil {
+		s.original.Close()
+		return err
+	}
+	if err = unix.Dup2(int(s.w.Fd()), s.fd); err != nil {
+		s.original.Close()
+		s.r.Close()
+		s.w.Close()
+		return fmt.Errorf("replacing file descriptor %d: %w", s.fd, err)
+	}
+	return nil
+}
+
+// restore puts back the stream's original file descriptor.
+func (s *stream) restore() error {
+	if err := unix.Dup2(int(s.original.Fd()), s.fd); err != nil {
+		return fmt.Errorf("restoring file descriptor %d: %w", s.fd, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Package cwotel traces the batches of logs a cloudwatchwriter.CloudWatchWriter
+// sends to CloudWatch with OpenTelemetry, see https://opentelemetry.io.
+//
+// It is a separate module so that OpenTelemetry is not a dependency of the
+// cloudwatchwriter module.
+package cwotel
+
+import (
+	"context"
+
+	"github.com/tracmo/cloudwatchwriter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/tracmo/cloudwatchwriter/cwotel"
+	spanName            = "cloudwatchwriter.PutLogEvents"
+)
+
+// WithTracing makes the writer record a span for each batch it sends to
+// CloudWatch, from the first attempt until the batch was sent or given up on,
+// with the number of log events, their total size and the number of retries as
+// attributes. Batches are sent in the background, so the spans are the roots
+// of their traces.
+func WithTracing(provider trace.TracerProvider) cloudwatchwriter.Option {
+	tracer := provider.Tracer(instrumentationName)
+
+	return cloudwatchwriter.WithSendHook(func(send cloudwatchwriter.BatchSend) {
+		_, span := tracer.Start(context.Background(), spanName,
+			trace.WithNewRoot(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithTimestamp(send.Start),
+			trace.WithAttributes(
+				attribute.Int("cloudwatchwriter.batch.events", send.Events),
+				attribute.Int("cloudwatchwriter.batch.bytes", send.Bytes),
+				attribute.Int("cloudwatchwriter.batch.retries", send.Attempts-1),
+			),
+		)
+		if send.Err != nil {
+			span.RecordError(send.Err)
+			span.SetStatus(codes.Error, send.Err.Error())
+		}
+		span.End(trace.WithTimestamp(send.Start.Add(send.Duration)))
+	})
+}
@@ -0,0 +1,78 @@
+package cwotel_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cwotel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type mockClient struct {
+	sync.Mutex
+	messages []string
+}
+
+func (c *mockClient) DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	return &cloudwatchlogs.DescribeLogStreamsOutput{
+		LogStreams: []types.LogStream{{LogStreamName: params.LogStreamNamePrefix}},
+	}, nil
+}
+
+func (c *mockClient) CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (c *mockClient) CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
+
+func (c *mockClient) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, event := range params.LogEvents {
+		c.messages = append(c.messages, *event.Message)
+	}
+	return &cloudwatchlogs.PutLogEventsOutput{}, nil
+}
+
+func TestWithTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 5*time.Second, "logGroup", "logStream",
+		cwotel.WithTracing(provider),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	for _, message := range []string{"first", "second"} {
+		_, err = cloudWatchWriter.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "cloudwatchwriter.PutLogEvents", spans[0].Name())
+		assert.Equal(t, codes.Unset, spans[0].Status().Code)
+		assert.ElementsMatch(t, []attribute.KeyValue{
+			attribute.Int("cloudwatchwriter.batch.events", 2),
+			attribute.Int("cloudwatchwriter.batch.bytes", len("first")+len("second")),
+			attribute.Int("cloudwatchwriter.batch.retries", 0),
+		}, spans[0].Attributes())
+		assert.False(t, spans[0].EndTime().Before(spans[0].StartTime()))
+	}
+}
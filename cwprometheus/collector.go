@@ -0,0 +1,164 @@
+// Package cwprometheus exports the metrics of a cloudwatchwriter.CloudWatchWriter
+// to Prometheus, see https://prometheus.io.
+//
+// It is a separate module so that the Prometheus client is not a dependency of
+// the cloudwatchwriter module.
+package cwprometheus
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+const namespace = "cloudwatchwriter"
+
+// Collector is a prometheus.Collector exporting the metrics of a
+// CloudWatchWriter: the queue depth, the logs and batches sent, retries,
+// throttling, the logs dropped, rejected, sampled out and filtered out, and
+// histograms of batch sizes and send latency. Pass the Option to the writer's constructor to
+// connect them.
+type Collector struct {
+	mu     sync.RWMutex
+	writer *cloudwatchwriter.CloudWatchWriter
+
+	queuedEvents  *prometheus.Desc
+	queuedBytes   *prometheus.Desc
+	eventsSent    *prometheus.Desc
+	batchesSent   *prometheus.Desc
+	retries       *prometheus.Desc
+	throttles     *prometheus.Desc
+	droppedEvents *prometheus.Desc
+	rejected      *prometheus.Desc
+	sampledOut    *prometheus.Desc
+	filteredOut   *prometheus.Desc
+	skippedEmpty  *prometheus.Desc
+
+	batchEvents  prometheus.Histogram
+	batchBytes   prometheus.Histogram
+	sendDuration *prometheus.HistogramVec
+}
+
+// NewCollector returns a Collector, the labels are added to all of its
+// metrics, to tell apart the writers when there is more than one.
+func NewCollector(labels prometheus.Labels) *Collector {
+	desc := func(name, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, variableLabels, labels)
+	}
+
+	return &Collector{
+		queuedEvents:  desc("queued_events", "Number of logs waiting to be sent."),
+		queuedBytes:   desc("queued_bytes", "Total size of the logs waiting to be sent."),
+		eventsSent:    desc("events_sent_total", "Number of logs accepted by CloudWatch."),
+		batchesSent:   desc("batches_sent_total", "Number of batches of logs accepted by CloudWatch."),
+		retries:       desc("retries_total", "Number of requests to CloudWatch which were retried."),
+		throttles:     desc("throttles_total", "Number of requests to CloudWatch which were throttled."),
+		droppedEvents: desc("events_dropped_total", "Number of logs discarded by the writer.", "reason"),
+		rejected:      desc("events_rejected_total", "Number of logs rejected by CloudWatch.", "reason"),
+		sampledOut:    desc("events_sampled_out_total", "Number of logs discarded by sampling."),
+		filteredOut:   desc("events_filtered_out_total", "Number of logs discarded by filters and transformers."),
+		skippedEmpty:  desc("events_skipped_empty_total", "Number of empty logs which were not sent."),
+
+		batchEvents: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "batch_events",
+			Help:        "Number of logs in each batch sent to CloudWatch.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(1, 4, 8),
+		}),
+		batchBytes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "batch_bytes",
+			Help:        "Total size of the logs in each batch sent to CloudWatch.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(256, 4, 8),
+		}),
+		sendDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "send_duration_seconds",
+			Help:        "Time taken to send each batch to CloudWatch, including retries.",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}, []string{"result"}),
+	}
+}
+
+// Option connects the Collector to the writer being created.
+func (c *Collector) Option() cloudwatchwriter.Option {
+	return func(writer *cloudwatchwriter.CloudWatchWriter) {
+		c.mu.Lock()
+		c.writer = writer
+		c.mu.Unlock()
+
+		cloudwatchwriter.WithSendHook(c.observe)(writer)
+	}
+}
+
+func (c *Collector) observe(send cloudwatchwriter.BatchSend) {
+	result := "success"
+	if send.Err != nil {
+		result = "failure"
+	}
+	c.batchEvents.Observe(float64(send.Events))
+	c.batchBytes.Observe(float64(send.Bytes))
+	c.sendDuration.WithLabelValues(result).Observe(send.Duration.Seconds())
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queuedEvents
+	ch <- c.queuedBytes
+	ch <- c.eventsSent
+	ch <- c.batchesSent
+	ch <- c.retries
+	ch <- c.throttles
+	ch <- c.droppedEvents
+	ch <- c.rejected
+	ch <- c.sampledOut
+	ch <- c.filteredOut
+	ch <- c.skippedEmpty
+	c.batchEvents.Describe(ch)
+	c.batchBytes.Describe(ch)
+	c.sendDuration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	writer := c.writer
+	c.mu.RUnlock()
+
+	if writer != nil {
+		stats := writer.Stats()
+		gauge := func(desc *prometheus.Desc, value float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+		}
+		counter := func(desc *prometheus.Desc, value int64, labelValues ...string) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value), labelValues...)
+		}
+
+		gauge(c.queuedEvents, float64(stats.QueuedEvents))
+		gauge(c.queuedBytes, float64(stats.QueuedBytes))
+		counter(c.eventsSent, stats.EventsSent)
+		counter(c.batchesSent, stats.BatchesSent)
+		counter(c.retries, stats.Retries)
+		counter(c.throttles, stats.Throttles)
+		counter(c.droppedEvents, stats.DroppedQueueFull, "queue_full")
+		counter(c.droppedEvents, stats.DroppedOldest, "oldest")
+		counter(c.droppedEvents, stats.DroppedTooLarge, "too_large")
+		counter(c.droppedEvents, stats.DroppedUndelivered, "undelivered")
+		counter(c.droppedEvents, stats.DroppedRateLimited, "rate_limited")
+		counter(c.droppedEvents, stats.DroppedTooOld, "too_old")
+		counter(c.rejected, stats.RejectedTooOld, "too_old")
+		counter(c.rejected, stats.RejectedExpired, "expired")
+		counter(c.rejected, stats.RejectedTooNew, "too_new")
+		counter(c.sampledOut, stats.SampledOut)
+		counter(c.filteredOut, stats.FilteredOut)
+		counter(c.skippedEmpty, stats.SkippedEmpty)
+	}
+
+	c.batchEvents.Collect(ch)
+	c.batchBytes.Collect(ch)
+	c.sendDuration.Collect(ch)
+}
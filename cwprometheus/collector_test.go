@@ -0,0 +1,94 @@
+package cwprometheus_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cwprometheus"
+)
+
+type mockClient struct {
+	sync.Mutex
+	messages []string
+}
+
+func (c *mockClient) DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	return &cloudwatchlogs.DescribeLogStreamsOutput{
+		LogStreams: []types.LogStream{{LogStreamName: params.LogStreamNamePrefix}},
+	}, nil
+}
+
+func (c *mockClient) CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (c *mockClient) CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
+
+func (c *mockClient) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, event := range params.LogEvents {
+		c.messages = append(c.messages, *event.Message)
+	}
+	return &cloudwatchlogs.PutLogEventsOutput{}, nil
+}
+
+func TestCollector(t *testing.T) {
+	collector := cwprometheus.NewCollector(prometheus.Labels{"log_group": "logGroup"})
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 5*time.Second, "logGroup", "logStream",
+		collector.Option(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	for _, message := range []string{"first", "second"} {
+		_, err = cloudWatchWriter.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+
+	expected := `
+# HELP cloudwatchwriter_events_sent_total Number of logs accepted by CloudWatch.
+# TYPE cloudwatchwriter_events_sent_total counter
+cloudwatchwriter_events_sent_total{log_group="logGroup"} 2
+# HELP cloudwatchwriter_queued_events Number of logs waiting to be sent.
+# TYPE cloudwatchwriter_queued_events gauge
+cloudwatchwriter_queued_events{log_group="logGroup"} 0
+# HELP cloudwatchwriter_batch_bytes Total size of the logs in each batch sent to CloudWatch.
+# TYPE cloudwatchwriter_batch_bytes histogram
+cloudwatchwriter_batch_bytes_bucket{log_group="logGroup",le="256"} 1
+cloudwatchwriter_batch_bytes_bucket{log_group="logGroup",le="1024"} 1
+cloudwatchwriter_batch_bytes_bucket{log_group="logGroup",le="4096"} 1
+cloudwatchwriter_batch_bytes_bucket{log_group="logGroup",le="16384"} 1
+cloudwatchwriter_batch_bytes_bucket{log_group="logGroup",le="65536"} 1
+cloudwatchwriter_batch_bytes_bucket{log_group="logGroup",le="262144"} 1
+cloudwatchwriter_batch_bytes_bucket{log_group="logGroup",le="1.048576e+06"} 1
+cloudwatchwriter_batch_bytes_bucket{log_group="logGroup",le="4.194304e+06"} 1
+cloudwatchwriter_batch_bytes_bucket{log_group="logGroup",le="+Inf"} 1
+cloudwatchwriter_batch_bytes_sum{log_group="logGroup"} 11
+cloudwatchwriter_batch_bytes_count{log_group="logGroup"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"cloudwatchwriter_events_sent_total", "cloudwatchwriter_queued_events", "cloudwatchwriter_batch_bytes"))
+
+	count, err := testutil.GatherAndCount(registry, "cloudwatchwriter_send_duration_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,112 @@
+// Package cwslog provides a log/slog Handler which sends logs to AWS
+// CloudWatch Logs with a cloudwatchwriter.CloudWatchWriter.
+//
+// Logs are encoded as JSON objects with the same field names and level names
+// as zerolog uses, so that logs from both loggers can be queried in the same
+// way with CloudWatch Logs Insights.
+package cwslog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+// Handler is a slog.Handler which writes each record as one JSON log event to
+// a CloudWatchWriter.
+type Handler struct {
+	writer  *cloudwatchwriter.CloudWatchWriter
+	handler slog.Handler
+}
+
+// NewHandler returns a Handler writing to the given writer. The options are
+// the same as for slog.NewJSONHandler, opts may be nil for the defaults.
+// Records with a level mapped to a zerolog level below the writer's minimum
+// level, see cloudwatchwriter.WithMinLevel, are discarded as well as those
+// below opts.Level.
+func NewHandler(writer *cloudwatchwriter.CloudWatchWriter, opts *slog.HandlerOptions) *Handler {
+	var jsonOpts slog.HandlerOptions
+	if opts != nil {
+		jsonOpts = *opts
+	}
+	replaceAttr := jsonOpts.ReplaceAttr
+	jsonOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 {
+			a = replaceBuiltinAttr(a)
+		}
+		if replaceAttr != nil {
+			return replaceAttr(groups, a)
+		}
+		return a
+	}
+
+	return &Handler{
+		writer:  writer,
+		handler: slog.NewJSONHandler(writer, &jsonOpts),
+	}
+}
+
+// replaceBuiltinAttr renames the built-in attributes, and the levels, to what
+// zerolog uses.
+func replaceBuiltinAttr(a slog.Attr) slog.Attr {
+	switch a.Key {
+	case slog.TimeKey:
+		a.Key = zerolog.TimestampFieldName
+	case slog.LevelKey:
+		a.Key = zerolog.LevelFieldName
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(ZerologLevel(level).String())
+		}
+	case slog.MessageKey:
+		a.Key = zerolog.MessageFieldName
+	case slog.SourceKey:
+		a.Key = zerolog.CallerFieldName
+	}
+	return a
+}
+
+// ZerologLevel maps a slog level to the zerolog level of the same severity.
+// Levels in between the standard slog levels are mapped to the next level
+// down, e.g. slog.LevelInfo+2 to zerolog.InfoLevel.
+func ZerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return zerolog.TraceLevel
+	case level < slog.LevelInfo:
+		return zerolog.DebugLevel
+	case level < slog.LevelWarn:
+		return zerolog.InfoLevel
+	case level < slog.LevelError:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.ErrorLevel
+	}
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.writer.Enabled(ZerologLevel(level)) && h.handler.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	return h.handler.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{
+		writer:  h.writer,
+		handler: h.handler.WithAttrs(attrs),
+	}
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{
+		writer:  h.writer,
+		handler: h.handler.WithGroup(name),
+	}
+}
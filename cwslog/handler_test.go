@@ -0,0 +1,86 @@
+package cwslog_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cwslog"
+)
+
+type mockClient struct {
+	sync.Mutex
+	messages []string
+}
+
+func (c *mockClient) DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	return &cloudwatchlogs.DescribeLogStreamsOutput{
+		LogStreams: []types.LogStream{{LogStreamName: params.LogStreamNamePrefix}},
+	}, nil
+}
+
+func (c *mockClient) CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (c *mockClient) CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
+
+func (c *mockClient) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, event := range params.LogEvents {
+		c.messages = append(c.messages, *event.Message)
+	}
+	return &cloudwatchlogs.PutLogEventsOutput{}, nil
+}
+
+func TestHandler(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithMinLevel(zerolog.InfoLevel),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	removeTime := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == "time" && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return a
+	}
+	logger := slog.New(cwslog.NewHandler(cloudWatchWriter, &slog.HandlerOptions{
+		Level:       slog.LevelDebug,
+		ReplaceAttr: removeTime,
+	}))
+
+	logger.Debug("discarded by the writer's minimum level")
+	logger.Info("hello", "port", 666)
+	logger.With("user", "deadpool").WithGroup("request").Warn("slow", "ms", 1200)
+	logger.Log(context.Background(), slog.LevelError+4, "fatal")
+	cloudWatchWriter.Close()
+
+	assert.Equal(t, []string{
+		`{"level":"info","message":"hello","port":666}` + "\n",
+		`{"level":"warn","message":"slow","user":"deadpool","request":{"ms":1200}}` + "\n",
+		`{"level":"error","message":"fatal"}` + "\n",
+	}, client.messages)
+}
+
+func TestZerologLevel(t *testing.T) {
+	assert.Equal(t, zerolog.TraceLevel, cwslog.ZerologLevel(slog.LevelDebug-1))
+	assert.Equal(t, zerolog.DebugLevel, cwslog.ZerologLevel(slog.LevelDebug))
+	assert.Equal(t, zerolog.InfoLevel, cwslog.ZerologLevel(slog.LevelInfo+2))
+	assert.Equal(t, zerolog.WarnLevel, cwslog.ZerologLevel(slog.LevelWarn))
+	assert.Equal(t, zerolog.ErrorLevel, cwslog.ZerologLevel(slog.LevelError+4))
+}
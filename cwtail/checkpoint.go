@@ -0,0 +1,84 @@
+package cwtail
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// checkpoint is how far a file had been sent when the checkpoint was saved.
+// A file is told apart from the one which replaced it, after it was rotated,
+// by the checksum of its first bytes, as there is nothing else portable which
+// identifies a file across restarts.
+type checkpoint struct {
+	Offset          int64  `json:"offset"`
+	FingerprintSize int    `json:"fingerprint_size"`
+	Fingerprint     uint32 `json:"fingerprint"`
+}
+
+// matches reports whether f is the file the checkpoint is for.
+func (c *checkpoint) matches(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Size() < c.Offset {
+		return false
+	}
+	buf := make([]byte, c.FingerprintSize)
+	n, _ := f.ReadAt(buf, 0)
+	return n == c.FingerprintSize && crc32.ChecksumIEEE(buf) == c.Fingerprint
+}
+
+// readCheckpoints returns the checkpoints, by file path, saved at path, which
+// are none if path is empty or there is no file there yet.
+func readCheckpoints(path string) (map[string]*checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+
+	var checkpoints map[string]*checkpoint
+	if err = json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, fmt.Errorf("read checkpoint %s: %w", path, err)
+	}
+	return checkpoints, nil
+}
+
+// writeCheckpoints saves the checkpoints at path, replacing the file there in
+// one go, so that it is never left half written.
+func writeCheckpoints(path string, checkpoints map[string]checkpoint) error {
+	data, err := json.Marshal(checkpoints)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	return nil
+}
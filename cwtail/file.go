@@ -0,0 +1,92 @@
+package cwtail
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// fingerprintSize is how many bytes from the start of a file are used to tell
+// whether it is still the file in the checkpoint.
+const fingerprintSize = 1024
+
+// file is a file being followed.
+type file struct {
+	path string
+	// f is nil while the file doesn't exist
+	f *os.File
+	// read is how far the file has been read, offset is how far its lines
+	// have been written, the difference being partial, the line which hasn't
+	// ended yet.
+	read    int64
+	offset  int64
+	partial []byte
+}
+
+// open opens the file, if it exists, carrying on from the checkpoint if there
+// is one for the path and it is for the same file. A file which has replaced
+// the one in the checkpoint is read from its start, and a file without a
+// checkpoint from its end if atEnd is true, or from its start.
+func (f *file) open(c *checkpoint, atEnd bool) error {
+	osFile, err := os.Open(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f.f = osFile
+
+	var start int64
+	if c != nil {
+		if c.matches(osFile) {
+			start = c.Offset
+		}
+	} else if atEnd {
+		info, err := osFile.Stat()
+		if err != nil {
+			return err
+		}
+		start = info.Size()
+	}
+	return f.seek(start)
+}
+
+// seek carries on reading the file from offset.
+func (f *file) seek(offset int64) error {
+	f.read, f.offset, f.partial = offset, offset, nil
+	_, err := f.f.Seek(offset, io.SeekStart)
+	return err
+}
+
+func (f *file) close() {
+	if f.f != nil {
+		f.f.Close()
+		f.f = nil
+	}
+	f.partial = nil
+}
+
+// checkpoint returns how far the file's lines have been written, if it is
+// open.
+func (f *file) checkpoint() (checkpoint, bool) {
+	if f.f == nil {
+		return checkpoint{}, false
+	}
+
+	// Only the bytes before the offset are fingerprinted, as those have
+	// been read, and so are there to compare with when starting again
+	size := int64(fingerprintSize)
+	if f.offset < size {
+		size = f.offset
+	}
+	buf := make([]byte, size)
+	n, _ := f.f.ReadAt(buf, 0)
+	return checkpoint{
+		Offset:          f.offset,
+		FingerprintSize: n,
+		Fingerprint:     crc32.ChecksumIEEE(buf[:n]),
+	}, true
+}
@@ -0,0 +1,266 @@
+// Package cwtail follows log files and sends the lines appended to them to AWS
+// CloudWatch Logs with a cloudwatchwriter.CloudWatchWriter, as a lightweight
+// alternative to the CloudWatch agent.
+package cwtail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+const (
+	defaultPollInterval       = time.Second
+	defaultCheckpointInterval = 5 * time.Second
+	// maxLineSize is the length at which a line which hasn't ended yet is
+	// sent anyway, as CloudWatch doesn't accept longer log events.
+	maxLineSize = 256 * 1024
+	readSize    = 32 * 1024
+)
+
+// Tailer follows files, writing each line appended to them to a writer, one
+// Write per line without the line ending. Files are checked for new lines at
+// the poll interval. A file which is renamed or deleted and replaced by a new
+// one, as log rotation does, is read to its end and then the new file is
+// followed from its start, and a file which is truncated is followed from its
+// start again.
+type Tailer struct {
+	writer             io.Writer
+	files              []*file
+	checkpointPath     string
+	pollInterval       time.Duration
+	checkpointInterval time.Duration
+	fromStart          bool
+	errorHandler       func(error)
+	clock              cloudwatchwriter.Clock
+}
+
+// Option configures a Tailer, see New.
+type Option func(*Tailer)
+
+// WithCheckpoint saves how far each file has been sent in the file at path, so
+// that a Tailer which is restarted carries on from there, rather than from the
+// end of the files. The checkpoint is saved every 5 seconds, and when Run
+// returns. The writer is flushed first, if it has a Flush method like a
+// CloudWatchWriter's, so that the checkpoint only covers the lines which have
+// been sent, and it isn't saved if Flush returns an error.
+func WithCheckpoint(path string) Option {
+	return func(t *Tailer) {
+		t.checkpointPath = path
+	}
+}
+
+// WithPollInterval sets how often the files are checked for new lines, the
+// default is once a second.
+func WithPollInterval(interval time.Duration) Option {
+	return func(t *Tailer) {
+		t.pollInterval = interval
+	}
+}
+
+// WithFromStart makes the Tailer send the lines already in the files when it
+// starts, for files which are not in the checkpoint, rather than only the
+// lines appended to them afterwards.
+func WithFromStart() Option {
+	return func(t *Tailer) {
+		t.fromStart = true
+	}
+}
+
+// WithErrorHandler sets a function which is called with the errors from
+// reading the files, writing the lines and saving the checkpoint, which don't
+// stop the Tailer. By default they are ignored.
+func WithErrorHandler(handler func(error)) Option {
+	return func(t *Tailer) {
+		t.errorHandler = handler
+	}
+}
+
+// WithClock makes the Tailer use clock rather than the system clock for the
+// poll and checkpoint intervals, such as in tests.
+func WithClock(clock cloudwatchwriter.Clock) Option {
+	return func(t *Tailer) {
+		t.clock = clock
+	}
+}
+
+// New returns a Tailer following the files at paths, which don't have to exist
+// yet, and writing their lines to writer, usually a CloudWatchWriter.
+func New(writer io.Writer, paths []string, opts ...Option) (*Tailer, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no files to follow")
+	}
+
+	t := &Tailer{
+		writer:             writer,
+		pollInterval:       defaultPollInterval,
+		checkpointInterval: defaultCheckpointInterval,
+		clock:              cloudwatchwriter.SystemClock(),
+	}
+	for _, path := range paths {
+		t.files = append(t.files, &file{path: path})
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if t.pollInterval <= 0 {
+		return nil, errors.New("poll interval must be positive")
+	}
+	if t.clock == nil {
+		return nil, errors.New("clock must not be nil")
+	}
+	return t, nil
+}
+
+// Run follows the files until ctx is done, then saves the checkpoint, if there
+// is one, returning the error from saving it.
+func (t *Tailer) Run(ctx context.Context) error {
+	checkpoints, err := readCheckpoints(t.checkpointPath)
+	if err != nil {
+		return err
+	}
+	for _, f := range t.files {
+		t.report(f.open(checkpoints[f.path], !t.fromStart))
+	}
+	defer func() {
+		for _, f := range t.files {
+			f.close()
+		}
+	}()
+
+	timer := t.clock.NewTimer(t.pollInterval)
+	defer timer.Stop()
+	lastCheckpoint := t.clock.Now()
+	for {
+		for _, f := range t.files {
+			t.poll(f)
+		}
+		if t.checkpointPath != "" && t.clock.Now().Sub(lastCheckpoint) >= t.checkpointInterval {
+			t.report(t.saveCheckpoint())
+			lastCheckpoint = t.clock.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			if t.checkpointPath == "" {
+				return nil
+			}
+			return t.saveCheckpoint()
+		case <-timer.C():
+			timer.Reset(t.pollInterval)
+		}
+	}
+}
+
+// poll writes the lines appended to the file since the last poll, and deals
+// with the file having been rotated or truncated.
+func (t *Tailer) poll(f *file) {
+	if f.f == nil {
+		// The file didn't exist, or was rotated, so a new one is read from
+		// its start
+		if err := f.open(nil, false); err != nil || f.f == nil {
+			t.report(err)
+			return
+		}
+	}
+
+	t.readLines(f)
+
+	current, err := os.Stat(f.path)
+	opened, openedErr := f.f.Stat()
+	switch {
+	case openedErr != nil:
+		t.report(openedErr)
+	case err != nil || !os.SameFile(current, opened):
+		// Rotated: send what was written to the old file before it was
+		// replaced, then move on to the new one
+		t.readLines(f)
+		t.flushPartial(f)
+		f.close()
+		t.poll(f)
+	case current.Size() < f.read:
+		// Truncated in place
+		t.report(f.seek(0))
+	}
+}
+
+// readLines reads the file to its end, writing each complete line. A line
+// which hasn't ended yet is kept until it does, unless it is too long.
+func (t *Tailer) readLines(f *file) {
+	buf := make([]byte, readSize)
+	for {
+		n, err := f.f.Read(buf)
+		f.read += int64(n)
+		f.partial = append(f.partial, buf[:n]...)
+
+		for {
+			i := bytes.IndexByte(f.partial, '\n')
+			if i < 0 {
+				break
+			}
+			t.write(f, f.partial[:i], i+1)
+		}
+		if len(f.partial) >= maxLineSize {
+			t.flushPartial(f)
+		}
+
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.report(err)
+			return
+		}
+	}
+}
+
+// flushPartial writes the line which hasn't ended yet, if there is one.
+func (t *Tailer) flushPartial(f *file) {
+	if len(f.partial) > 0 {
+		t.write(f, f.partial, len(f.partial))
+	}
+}
+
+// write writes the line, without its line ending, and moves the offset of the
+// file past the n bytes it took up.
+func (t *Tailer) write(f *file, line []byte, n int) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) > 0 {
+		// The writer may keep the line, so it mustn't share f.partial
+		if _, err := t.writer.Write(append([]byte(nil), line...)); err != nil {
+			t.report(err)
+		}
+	}
+	f.partial = f.partial[n:]
+	f.offset += int64(n)
+}
+
+// saveCheckpoint flushes the writer, so that the lines written so far have
+// been sent, and then saves how far each file has been sent.
+func (t *Tailer) saveCheckpoint() error {
+	if flusher, ok := t.writer.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			return err
+		}
+	}
+
+	checkpoints := make(map[string]checkpoint, len(t.files))
+	for _, f := range t.files {
+		if c, ok := f.checkpoint(); ok {
+			checkpoints[f.path] = c
+		}
+	}
+	return writeCheckpoints(t.checkpointPath, checkpoints)
+}
+
+func (t *Tailer) report(err error) {
+	if err != nil && t.errorHandler != nil {
+		t.errorHandler(err)
+	}
+}
@@ -0,0 +1,274 @@
+package cwtail_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+	"github.com/tracmo/cloudwatchwriter/cwtail"
+)
+
+const pollInterval = 10 * time.Millisecond
+
+type recordingWriter struct {
+	mu      sync.Mutex
+	lines   []string
+	flushes int
+}
+
+func (w *recordingWriter) Write(log []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lines = append(w.lines, string(log))
+	return len(log), nil
+}
+
+func (w *recordingWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.flushes++
+	return nil
+}
+
+func (w *recordingWriter) getLines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([]string(nil), w.lines...)
+}
+
+func (w *recordingWriter) waitForLines(t *testing.T, expected ...string) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		return len(w.getLines()) >= len(expected)
+	}, time.Second, pollInterval)
+	assert.Equal(t, expected, w.getLines())
+}
+
+// runTailer runs a Tailer on the paths, returning the function which stops it
+// and returns the error from Run.
+func runTailer(t *testing.T, writer *recordingWriter, paths []string, opts ...cwtail.Option) func() error {
+	t.Helper()
+	tailer, err := cwtail.New(writer, paths, append([]cwtail.Option{cwtail.WithPollInterval(pollInterval)}, opts...)...)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- tailer.Run(ctx)
+	}()
+	// Let it open the files before the test changes them
+	time.Sleep(3 * pollInterval)
+
+	stopped := false
+	stop := func() error {
+		if stopped {
+			return nil
+		}
+		stopped = true
+		cancel()
+		return <-done
+	}
+	t.Cleanup(func() {
+		stop()
+	})
+	return stop
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestNewNoFiles(t *testing.T) {
+	_, err := cwtail.New(&recordingWriter{}, nil)
+	assert.Error(t, err)
+}
+
+func TestTailerFollowsAppendedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "before\n")
+
+	writer := &recordingWriter{}
+	runTailer(t, writer, []string{path})
+
+	appendFile(t, path, "first\r\nsecond")
+	writer.waitForLines(t, "first")
+
+	// The second line is only written once it has ended
+	appendFile(t, path, " line\n\n")
+	writer.waitForLines(t, "first", "second line")
+}
+
+func TestTailerFromStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "before\n")
+
+	writer := &recordingWriter{}
+	runTailer(t, writer, []string{path}, cwtail.WithFromStart())
+
+	appendFile(t, path, "after\n")
+	writer.waitForLines(t, "before", "after")
+}
+
+func TestTailerMultipleFilesAndFileCreatedLater(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.log")
+	second := filepath.Join(dir, "second.log")
+	appendFile(t, first, "")
+
+	writer := &recordingWriter{}
+	runTailer(t, writer, []string{first, second})
+
+	appendFile(t, first, "first\n")
+	writer.waitForLines(t, "first")
+
+	// A file which didn't exist when the tailer started is sent from its
+	// start
+	appendFile(t, second, "second\n")
+	writer.waitForLines(t, "first", "second")
+}
+
+func TestTailerRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+
+	writer := &recordingWriter{}
+	runTailer(t, writer, []string{path})
+
+	appendFile(t, path, "one\n")
+	writer.waitForLines(t, "one")
+
+	// The end of the old file is sent, including a line which didn't end,
+	// then the new file from its start
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	require.NoError(t, os.Rename(path, path+".1"))
+	_, err = f.WriteString("two\nthree")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	appendFile(t, path, "four\n")
+
+	writer.waitForLines(t, "one", "two", "three", "four")
+}
+
+func TestTailerRotationByDeleting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+
+	writer := &recordingWriter{}
+	runTailer(t, writer, []string{path})
+
+	appendFile(t, path, "one\n")
+	writer.waitForLines(t, "one")
+
+	require.NoError(t, os.Remove(path))
+	time.Sleep(3 * pollInterval)
+	appendFile(t, path, "two\n")
+	writer.waitForLines(t, "one", "two")
+}
+
+func TestTailerTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "")
+
+	writer := &recordingWriter{}
+	runTailer(t, writer, []string{path})
+
+	appendFile(t, path, "a longer line\n")
+	writer.waitForLines(t, "a longer line")
+
+	require.NoError(t, os.Truncate(path, 0))
+	time.Sleep(3 * pollInterval)
+	appendFile(t, path, "short\n")
+	writer.waitForLines(t, "a longer line", "short")
+}
+
+func TestTailerCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	rotated := filepath.Join(dir, "rotated.log")
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+	appendFile(t, path, "before\n")
+	appendFile(t, rotated, "old\n")
+
+	writer := &recordingWriter{}
+	stop := runTailer(t, writer, []string{path, rotated}, cwtail.WithCheckpoint(checkpoint))
+	appendFile(t, path, "one\n")
+	writer.waitForLines(t, "one")
+	require.NoError(t, stop())
+	assert.Equal(t, 1, writer.flushes)
+	assert.FileExists(t, checkpoint)
+
+	// While the tailer is stopped, one file has lines appended, and the
+	// other is replaced by a new file
+	appendFile(t, path, "two\n")
+	require.NoError(t, os.Remove(rotated))
+	appendFile(t, rotated, "new\n")
+
+	writer = &recordingWriter{}
+	runTailer(t, writer, []string{path, rotated}, cwtail.WithCheckpoint(checkpoint))
+	writer.waitForLines(t, "two", "new")
+}
+
+func TestTailerBadCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+	require.NoError(t, os.WriteFile(checkpoint, []byte("not json"), 0o644))
+
+	tailer, err := cwtail.New(&recordingWriter{}, []string{filepath.Join(dir, "app.log")}, cwtail.WithCheckpoint(checkpoint))
+	require.NoError(t, err)
+	assert.Error(t, tailer.Run(context.Background()))
+}
+
+func TestTailerClock(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+	appendFile(t, path, "")
+
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	writer := &recordingWriter{}
+	tailer, err := cwtail.New(writer, []string{path}, cwtail.WithClock(clock), cwtail.WithCheckpoint(checkpoint))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- tailer.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		require.NoError(t, <-done)
+	}()
+
+	// The file is polled once a second on the clock
+	require.NoError(t, clock.WaitForTimers(1, time.Second))
+	appendFile(t, path, "line\n")
+	time.Sleep(3 * pollInterval)
+	assert.Empty(t, writer.getLines())
+	clock.Advance(time.Second)
+	writer.waitForLines(t, "line")
+
+	// The checkpoint is saved every 5 seconds on the clock
+	assert.NoFileExists(t, checkpoint)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, clock.WaitForTimers(1, time.Second))
+		clock.Advance(time.Second)
+	}
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(checkpoint)
+		return err == nil
+	}, time.Second, pollInterval)
+}
@@ -0,0 +1,70 @@
+// Package cwzap adapts a cloudwatchwriter.CloudWatchWriter for use with zap,
+// see https://github.com/uber-go/zap.
+//
+// It is a separate module so that zap is not a dependency of the
+// cloudwatchwriter module.
+package cwzap
+
+import (
+	"github.com/rs/zerolog"
+	"github.com/tracmo/cloudwatchwriter"
+	"go.uber.org/zap/zapcore"
+)
+
+// WriteSyncer is a zapcore.WriteSyncer writing to a CloudWatchWriter. Sync
+// flushes the writer, so that zap's Logger.Sync blocks until the logs have
+// been sent to CloudWatch.
+type WriteSyncer struct {
+	writer *cloudwatchwriter.CloudWatchWriter
+}
+
+// NewWriteSyncer returns a WriteSyncer writing to the given writer.
+func NewWriteSyncer(writer *cloudwatchwriter.CloudWatchWriter) *WriteSyncer {
+	return &WriteSyncer{writer: writer}
+}
+
+// Write implements io.Writer, each write is one log event.
+func (s *WriteSyncer) Write(log []byte) (int, error) {
+	return s.writer.Write(log)
+}
+
+// Sync implements zapcore.WriteSyncer by flushing the writer.
+func (s *WriteSyncer) Sync() error {
+	return s.writer.Flush()
+}
+
+// NewCore returns a zapcore.Core which encodes entries with enc and writes them
+// to the given writer. Entries are logged if they are enabled by enab and their
+// level, mapped to the zerolog level of the same severity, is not below the
+// writer's minimum level, see cloudwatchwriter.WithMinLevel.
+func NewCore(writer *cloudwatchwriter.CloudWatchWriter, enc zapcore.Encoder, enab zapcore.LevelEnabler) zapcore.Core {
+	return zapcore.NewCore(enc, NewWriteSyncer(writer), levelEnabler{writer: writer, enab: enab})
+}
+
+type levelEnabler struct {
+	writer *cloudwatchwriter.CloudWatchWriter
+	enab   zapcore.LevelEnabler
+}
+
+func (l levelEnabler) Enabled(level zapcore.Level) bool {
+	return l.enab.Enabled(level) && l.writer.Enabled(ZerologLevel(level))
+}
+
+// ZerologLevel maps a zap level to the zerolog level of the same severity.
+func ZerologLevel(level zapcore.Level) zerolog.Level {
+	switch {
+	case level < zapcore.InfoLevel:
+		return zerolog.DebugLevel
+	case level == zapcore.InfoLevel:
+		return zerolog.InfoLevel
+	case level == zapcore.WarnLevel:
+		return zerolog.WarnLevel
+	case level == zapcore.ErrorLevel:
+		return zerolog.ErrorLevel
+	case level == zapcore.FatalLevel:
+		return zerolog.FatalLevel
+	default:
+		// DPanicLevel and PanicLevel
+		return zerolog.PanicLevel
+	}
+}
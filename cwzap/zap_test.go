@@ -0,0 +1,85 @@
+package cwzap_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cwzap"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type mockClient struct {
+	sync.Mutex
+	messages []string
+}
+
+func (c *mockClient) DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	return &cloudwatchlogs.DescribeLogStreamsOutput{
+		LogStreams: []types.LogStream{{LogStreamName: params.LogStreamNamePrefix}},
+	}, nil
+}
+
+func (c *mockClient) CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (c *mockClient) CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
+
+func (c *mockClient) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, event := range params.LogEvents {
+		c.messages = append(c.messages, *event.Message)
+	}
+	return &cloudwatchlogs.PutLogEventsOutput{}, nil
+}
+
+func (c *mockClient) getMessages() []string {
+	c.Lock()
+	defer c.Unlock()
+
+	return append([]string(nil), c.messages...)
+}
+
+func TestNewCore(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 5*time.Second, "logGroup", "logStream",
+		cloudwatchwriter.WithMinLevel(zerolog.InfoLevel),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = ""
+	logger := zap.New(cwzap.NewCore(cloudWatchWriter, zapcore.NewJSONEncoder(encoderConfig), zapcore.DebugLevel))
+
+	logger.Debug("discarded by the writer's minimum level")
+	logger.Info("hello", zap.Int("port", 666))
+
+	// Sync flushes the writer, rather than waiting for the 5 second batch
+	// interval
+	assert.NoError(t, logger.Sync())
+	assert.Equal(t, []string{`{"level":"info","msg":"hello","port":666}` + "\n"}, client.getMessages())
+}
+
+func TestZerologLevel(t *testing.T) {
+	assert.Equal(t, zerolog.DebugLevel, cwzap.ZerologLevel(zapcore.DebugLevel))
+	assert.Equal(t, zerolog.InfoLevel, cwzap.ZerologLevel(zapcore.InfoLevel))
+	assert.Equal(t, zerolog.WarnLevel, cwzap.ZerologLevel(zapcore.WarnLevel))
+	assert.Equal(t, zerolog.ErrorLevel, cwzap.ZerologLevel(zapcore.ErrorLevel))
+	assert.Equal(t, zerolog.PanicLevel, cwzap.ZerologLevel(zapcore.DPanicLevel))
+	assert.Equal(t, zerolog.FatalLevel, cwzap.ZerologLevel(zapcore.FatalLevel))
+}
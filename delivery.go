@@ -0,0 +1,221 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"sync"
+)
+
+// Delivery is the delivery to CloudWatch of a log written with WriteAsync.
+type Delivery struct {
+	mu sync.Mutex
+	// pending is the number of the log's events which haven't been dealt
+	// with yet, plus one until WriteAsync has queued all of them.
+	pending int
+	err     error
+	done    chan struct{}
+}
+
+func newDelivery() *Delivery {
+	return &Delivery{pending: 1, done: make(chan struct{})}
+}
+
+// Done returns a channel which is closed once the log has been accepted by
+// CloudWatch, or has failed to be.
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
+// Err returns nil once CloudWatch has accepted the log, or why it didn't,
+// such as the log being dropped or the error from sending its batch. It
+// returns nil until Done is closed. A log which was discarded on purpose,
+// such as by a filter or sampling, is done with no error, as is one accepted
+// in a batch although CloudWatch rejected it, see WithRejectedEventsHandler.
+func (d *Delivery) Err() error {
+	select {
+	case <-d.done:
+	default:
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.err
+}
+
+// Wait blocks until the log has been accepted by CloudWatch, or has failed to
+// be, returning Err, or until ctx is done, returning ctx.Err().
+func (d *Delivery) Wait(ctx context.Context) error {
+	select {
+	case <-d.done:
+		return d.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// add adds the log's events which are about to be queued.
+func (d *Delivery) add(n int) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending += n
+}
+
+// fail records why the log wasn't delivered, if it is the first reason.
+func (d *Delivery) fail(err error) {
+	if d == nil || err == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// eventDone records that one of the log's events has been dealt with, err
+// being why it wasn't delivered, if it wasn't. The delivery is done once all
+// of them have been.
+func (d *Delivery) eventDone(err error) {
+	if d == nil {
+		return
+	}
+	d.fail(err)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending--
+	if d.pending == 0 {
+		close(d.done)
+	}
+}
+
+// WriteAsync is like Write, but it returns the log's Delivery, which is done
+// once CloudWatch has accepted the log, or it has failed to be, such as for
+// audit logs which must be known to have been stored. Unlike Write, it
+// doesn't return the last error from sending earlier logs, only the error for
+// this log when it is dropped as it is written, in which case the Delivery is
+// done already, with the same error, even with WithWriteNeverFails. It only
+// applies to a CloudWatchWriter, not a SyncWriter or LambdaWriter, whose
+// Write already returns once the log has been sent.
+func (c *CloudWatchWriter) WriteAsync(log []byte) (*Delivery, error) {
+	delivery := newDelivery()
+	var err error
+	if c.splitLines {
+		_, err = writeLines(log, func(line []byte) error {
+			_, err := c.writeLevel(context.Background(), c.levelOf(line), line, "", delivery)
+			return err
+		})
+	} else {
+		_, err = c.writeLevel(context.Background(), c.levelOf(log), log, "", delivery)
+	}
+	// All of the log's events have been queued
+	delivery.eventDone(nil)
+	return delivery, err
+}
+
+// barrier waits for the events which were queued before it to be delivered,
+// or to fail to be, see WaitForDelivery.
+type barrier struct {
+	// target is the number of events which had been queued when the barrier
+	// was made.
+	target int64
+	// err is why the first of the events which failed wasn't delivered.
+	err  error
+	done chan struct{}
+}
+
+// barrier returns a barrier for the events queued so far.
+func (q *eventQueue) barrier() *barrier {
+	q.Lock()
+	defer q.Unlock()
+
+	b := &barrier{target: q.added, done: make(chan struct{})}
+	if q.finished >= q.added {
+		close(b.done)
+		return b
+	}
+	q.barriers = append(q.barriers, b)
+	return b
+}
+
+// removeBarrier stops the barrier from waiting, for a caller which has given
+// up on it.
+func (q *eventQueue) removeBarrier(b *barrier) {
+	q.Lock()
+	defer q.Unlock()
+
+	for i := range q.barriers {
+		if q.barriers[i] == b {
+			q.barriers = append(q.barriers[:i], q.barriers[i+1:]...)
+			return
+		}
+	}
+}
+
+// finish records that the next n events have been delivered, or failed to be
+// because of err.
+func (q *eventQueue) finish(n int, err error) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.finishLocked(n, err)
+}
+
+// finishLocked is finish with the lock held.
+func (q *eventQueue) finishLocked(n int, err error) {
+	if n == 0 {
+		return
+	}
+	start := q.finished
+	q.finished += int64(n)
+
+	waiting := q.barriers[:0]
+	for _, b := range q.barriers {
+		if err != nil && b.err == nil && start < b.target {
+			b.err = err
+		}
+		if q.finished >= b.target {
+			close(b.done)
+		} else {
+			waiting = append(waiting, b)
+		}
+	}
+	q.barriers = waiting
+}
+
+// WaitForDelivery blocks until every log written before it was called has
+// been accepted by CloudWatch, or has failed to be, such as a flush barrier at
+// the end of a request or a test, or until ctx is done, returning ctx.Err().
+// It asks for the queued logs to be sent without waiting for the batch
+// interval, and with WithManualPump it sends them on the calling goroutine,
+// like Pump. It returns why the first of those logs which wasn't delivered
+// wasn't, such as the error from sending its batch, rather than the last error
+// from sending any logs, like Flush. It waits for Start for a writer which
+// isn't running.
+func (c *CloudWatchWriter) WaitForDelivery(ctx context.Context) error {
+	b := c.queue.barrier()
+
+	if c.manualPump {
+		c.pumpQueued()
+	} else {
+		select {
+		case c.sendNow <- struct{}{}:
+		default:
+		}
+	}
+
+	select {
+	case <-b.done:
+		return b.err
+	case <-ctx.Done():
+		c.queue.removeBarrier(b)
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,170 @@
+package cloudwatchwriter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterWriteAsync(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}},
+	}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+		cloudwatchwriter.WithLineSplitting(),
+		cloudwatchwriter.WithDenyFilters(cloudwatchwriter.FieldEquals("noisy", "true")),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	failed, err := cloudWatchWriter.WriteAsync([]byte("failed"))
+	assert.NoError(t, err)
+	assertNotDone(t, failed)
+	assert.Equal(t, serverError{}, cloudWatchWriter.Pump())
+	assert.Equal(t, serverError{}, failed.Wait(context.Background()))
+
+	// Each line is a log event, the delivery is done once they all are
+	sent, err := cloudWatchWriter.WriteAsync([]byte("first\nsecond"))
+	assert.NoError(t, err)
+	assertNotDone(t, sent)
+	assert.NoError(t, cloudWatchWriter.Pump())
+	assert.NoError(t, sent.Wait(context.Background()))
+	assert.Equal(t, 2, client.numLogs())
+
+	filtered, err := cloudWatchWriter.WriteAsync([]byte(`{"noisy":true}`))
+	assert.NoError(t, err)
+	assert.NoError(t, filtered.Wait(context.Background()))
+}
+
+func TestCloudWatchWriterWriteAsyncDropped(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client,
+		cloudwatchwriter.WithMaxQueueSize(1, 0),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.DropOldest),
+	)
+	defer cloudWatchWriter.Close()
+
+	evicted, err := cloudWatchWriter.WriteAsync([]byte("evicted"))
+	assert.NoError(t, err)
+	kept, err := cloudWatchWriter.WriteAsync([]byte("kept"))
+	assert.NoError(t, err)
+	assert.Equal(t, cloudwatchwriter.ErrQueueFull, evicted.Wait(context.Background()))
+	assertNotDone(t, kept)
+
+	close(client.putLogEventsGate)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, kept.Wait(ctx))
+}
+
+func TestCloudWatchWriterWriteAsyncTooLarge(t *testing.T) {
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithOversizePolicy(cloudwatchwriter.Reject),
+		cloudwatchwriter.WithWriteNeverFails(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	delivery, err := cloudWatchWriter.WriteAsync(make([]byte, 300*1024))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(delivery.Err(), cloudwatchwriter.ErrMessageTooLarge))
+}
+
+func assertNotDone(t *testing.T, delivery *cloudwatchwriter.Delivery) {
+	t.Helper()
+	select {
+	case <-delivery.Done():
+		t.Errorf("delivery done: %v", delivery.Err())
+	default:
+	}
+	assert.NoError(t, delivery.Err())
+}
+
+func TestCloudWatchWriterWaitForDelivery(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client)
+	defer cloudWatchWriter.Close()
+
+	_, err := cloudWatchWriter.Write([]byte("second"))
+	assert.NoError(t, err)
+
+	// The first batch is stuck
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, cloudWatchWriter.WaitForDelivery(ctx))
+
+	close(client.putLogEventsGate)
+	// Without waiting for the batch interval
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, cloudWatchWriter.WaitForDelivery(ctx))
+	assert.Equal(t, 2, client.numLogs())
+
+	// Nothing to wait for
+	assert.NoError(t, cloudWatchWriter.WaitForDelivery(context.Background()))
+}
+
+func TestCloudWatchWriterWaitForDeliveryDropOldest(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client,
+		cloudwatchwriter.WithMaxQueueSize(2, 0),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.DropOldest),
+	)
+	defer cloudWatchWriter.Close()
+
+	for _, log := range []string{"evicted", "second"} {
+		_, err := cloudWatchWriter.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	waited := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		waited <- cloudWatchWriter.WaitForDelivery(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Evicting a log the barrier waits for counts as finishing it, failed, so
+	// the barrier is done once the others are, and returns why it wasn't
+	// delivered
+	_, err := cloudWatchWriter.Write([]byte("third"))
+	assert.NoError(t, err)
+	close(client.putLogEventsGate)
+	assert.True(t, errors.Is(<-waited, cloudwatchwriter.ErrQueueFull))
+
+	assert.NoError(t, cloudWatchWriter.WaitForDelivery(context.Background()))
+	assert.Equal(t, 3, client.numLogs())
+}
+
+func TestCloudWatchWriterWaitForDeliveryError(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}},
+	}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, _ = cloudWatchWriter.Write([]byte("failed"))
+	assert.Equal(t, serverError{}, cloudWatchWriter.WaitForDelivery(context.Background()))
+
+	// The earlier failure isn't reported again
+	_, _ = cloudWatchWriter.Write([]byte("sent"))
+	assert.NoError(t, cloudWatchWriter.WaitForDelivery(context.Background()))
+	assert.Equal(t, 1, client.numLogs())
+}
@@ -0,0 +1,73 @@
+package cloudwatchwriter
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// DropReason is why a log was dropped rather than stored by CloudWatch, see
+// WithDropHandler.
+type DropReason int
+
+const (
+	// DropReasonQueueFull is a log which was written while the queue was
+	// full, including one which Write gave up waiting for space for.
+	DropReasonQueueFull DropReason = iota + 1
+	// DropReasonEvicted is a queued log discarded to make space for a newer
+	// one, with the DropOldest overflow policy.
+	DropReasonEvicted
+	// DropReasonTooLarge is a log larger than CloudWatch accepts, with the
+	// Reject oversize policy.
+	DropReasonTooLarge
+	// DropReasonTooOld is a log left in the spool by a previous run which is
+	// too old for CloudWatch to accept, or a queued log older than the
+	// maximum event age, see WithMaxEventAge.
+	DropReasonTooOld
+	// DropReasonRejected is a log event which CloudWatch rejected, although
+	// it accepted the rest of its batch, see RejectedEvents.
+	DropReasonRejected
+	// DropReasonUndelivered is a log event whose batch could not be sent.
+	DropReasonUndelivered
+	// DropReasonRateLimited is a log which was over the rate limit, see
+	// WithRateLimit.
+	DropReasonRateLimited
+)
+
+func (r DropReason) String() string {
+	switch r {
+	case DropReasonQueueFull:
+		return "queue full"
+	case DropReasonEvicted:
+		return "evicted"
+	case DropReasonTooLarge:
+		return "too large"
+	case DropReasonTooOld:
+		return "too old"
+	case DropReasonRejected:
+		return "rejected"
+	case DropReasonUndelivered:
+		return "undelivered"
+	case DropReasonRateLimited:
+		return "rate limited"
+	default:
+		return "unknown"
+	}
+}
+
+// callDropHandler calls the drop handler, if there is one, with the message
+// of a log which was dropped.
+func (c *CloudWatchWriter) callDropHandler(message string, reason DropReason) {
+	if c.dropHandler != nil {
+		c.dropHandler([]byte(message), reason)
+	}
+}
+
+// droppedEvents calls the drop handler with each of the events.
+func (c *CloudWatchWriter) droppedEvents(events []types.InputLogEvent, reason DropReason) {
+	if c.dropHandler == nil {
+		return
+	}
+	for _, event := range events {
+		c.dropHandler([]byte(aws.ToString(event.Message)), reason)
+	}
+}
@@ -0,0 +1,125 @@
+package cloudwatchwriter_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+type droppedLog struct {
+	log    string
+	reason cloudwatchwriter.DropReason
+}
+
+// helperDropHandler returns a drop handler which records the dropped logs, and
+// a function returning them.
+func helperDropHandler() (cloudwatchwriter.Option, func() []droppedLog) {
+	var mu sync.Mutex
+	var dropped []droppedLog
+	handler := func(log []byte, reason cloudwatchwriter.DropReason) {
+		mu.Lock()
+		defer mu.Unlock()
+		dropped = append(dropped, droppedLog{string(log), reason})
+	}
+	get := func() []droppedLog {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]droppedLog(nil), dropped...)
+	}
+	return cloudwatchwriter.WithDropHandler(handler), get
+}
+
+func TestCloudWatchWriterDropHandlerQueueFull(t *testing.T) {
+	client := &mockClient{}
+	withDropHandler, dropped := helperDropHandler()
+	cloudWatchWriter := helperBlockedWriter(t, client,
+		cloudwatchwriter.WithMaxQueueSize(0, 10),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.DropOldest),
+		withDropHandler,
+	)
+
+	for _, message := range []string{"aaaa", "bbbb", "cccc", "far too long"} {
+		_, _ = cloudWatchWriter.Write([]byte(message))
+	}
+
+	close(client.putLogEventsGate)
+	cloudWatchWriter.Close()
+
+	assert.Equal(t, []droppedLog{
+		{"aaaa", cloudwatchwriter.DropReasonEvicted},
+		{"far too long", cloudwatchwriter.DropReasonQueueFull},
+	}, dropped())
+}
+
+func TestCloudWatchWriterDropHandlerTooLarge(t *testing.T) {
+	withDropHandler, dropped := helperDropHandler()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithOversizePolicy(cloudwatchwriter.Reject),
+		withDropHandler,
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	log := make([]byte, 300*1024)
+	_, err = cloudWatchWriter.Write(log)
+	assert.Error(t, err)
+
+	if assert.Len(t, dropped(), 1) {
+		assert.Equal(t, droppedLog{string(log), cloudwatchwriter.DropReasonTooLarge}, dropped()[0])
+	}
+}
+
+func TestCloudWatchWriterDropHandlerRejected(t *testing.T) {
+	client := &mockClient{
+		rejectedLogEventsInfo: &types.RejectedLogEventsInfo{
+			ExpiredLogEventEndIndex:  aws.Int32(1),
+			TooOldLogEventEndIndex:   aws.Int32(2),
+			TooNewLogEventStartIndex: aws.Int32(3),
+		},
+	}
+	withDropHandler, dropped := helperDropHandler()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream", withDropHandler)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	for _, message := range []string{"expired", "too old", "ok", "too new"} {
+		if _, err = cloudWatchWriter.Write([]byte(message)); err != nil {
+			t.Fatalf("cloudWatchWriter.Write: %v", err)
+		}
+	}
+	cloudWatchWriter.Close()
+
+	assert.Equal(t, []droppedLog{
+		{"expired", cloudwatchwriter.DropReasonRejected},
+		{"too old", cloudwatchwriter.DropReasonRejected},
+		{"too new", cloudwatchwriter.DropReasonRejected},
+	}, dropped())
+}
+
+func TestCloudWatchWriterDropHandlerUndelivered(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+	withDropHandler, dropped := helperDropHandler()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+		withDropHandler,
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	_, err = cloudWatchWriter.Write([]byte("hello world"))
+	assert.NoError(t, err)
+	cloudWatchWriter.Close()
+
+	assert.Equal(t, []droppedLog{{"hello world", cloudwatchwriter.DropReasonUndelivered}}, dropped())
+}
@@ -0,0 +1,130 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ecsMetadataTimeout is how long to wait for the ECS task metadata endpoint.
+const ecsMetadataTimeout = 2 * time.Second
+
+// ecsMetadata is what the writer uses from the ECS task metadata endpoint,
+// version 4, whose URL ECS sets in ECS_CONTAINER_METADATA_URI_V4.
+type ecsMetadata struct {
+	Cluster       string
+	TaskARN       string
+	ContainerName string
+}
+
+// getECSMetadata returns the metadata of the ECS task the program is running
+// in, looked up the first time, or nil if it can't be, such as when not
+// running on ECS.
+func (c *CloudWatchWriter) getECSMetadata() *ecsMetadata {
+	c.ecsMetadataOnce.Do(func() {
+		metadata, err := c.lookupECSMetadata()
+		if err != nil {
+			c.logf("no ECS task metadata: %v", err)
+			return
+		}
+		c.ecsMetadata = metadata
+	})
+	return c.ecsMetadata
+}
+
+func (c *CloudWatchWriter) lookupECSMetadata() (*ecsMetadata, error) {
+	endpoint := os.Getenv("ECS_CONTAINER_METADATA_URI_V4")
+	if endpoint == "" {
+		return nil, errors.New("ECS_CONTAINER_METADATA_URI_V4 is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, ecsMetadataTimeout)
+	defer cancel()
+
+	var container struct {
+		Name string
+	}
+	if err := getJSON(ctx, endpoint, &container); err != nil {
+		return nil, err
+	}
+	var task struct {
+		Cluster string
+		TaskARN string
+	}
+	if err := getJSON(ctx, endpoint+"/task", &task); err != nil {
+		return nil, err
+	}
+	return &ecsMetadata{
+		Cluster:       task.Cluster,
+		TaskARN:       task.TaskARN,
+		ContainerName: container.Name,
+	}, nil
+}
+
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: %w", url, err)
+	}
+	return nil
+}
+
+// ecsNameReplacements returns the replacements of the ECS placeholders in
+// log group and log stream names. The cluster and task are given by the last
+// part of their ARNs, as names can't contain colons. Without ECS metadata they
+// are replaced with the host name.
+func (c *CloudWatchWriter) ecsNameReplacements() []string {
+	metadata := c.getECSMetadata()
+	if metadata == nil {
+		host := hostname()
+		return []string{"{ecs_cluster}", host, "{ecs_task_id}", host, "{ecs_container}", host}
+	}
+	return []string{
+		"{ecs_cluster}", lastARNPart(metadata.Cluster),
+		"{ecs_task_id}", lastARNPart(metadata.TaskARN),
+		"{ecs_container}", metadata.ContainerName,
+	}
+}
+
+// lastARNPart returns the part of the ARN after the last slash, such as the
+// ID of a task, or the string itself if there isn't one.
+func lastARNPart(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// ecsMetadataFields returns the ECS metadata as the fields to add to each log for
+// WithECSMetadataFields, or nil if there is no metadata.
+func (c *CloudWatchWriter) ecsMetadataFields() []field {
+	metadata := c.getECSMetadata()
+	if metadata == nil {
+		return nil
+	}
+
+	var fields []field
+	for _, f := range [][2]string{
+		{"ecs_cluster", metadata.Cluster},
+		{"ecs_task_arn", metadata.TaskARN},
+		{"ecs_container", metadata.ContainerName},
+	} {
+		value, _ := json.Marshal(f[1])
+		fields = append(fields, newField(f[0], value))
+	}
+	return fields
+}
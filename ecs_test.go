@@ -0,0 +1,74 @@
+package cloudwatchwriter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+// newECSMetadataServer returns a task metadata endpoint, version 4, and sets
+// ECS_CONTAINER_METADATA_URI_V4 to it for the test.
+func newECSMetadataServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v4/container":
+			_, _ = w.Write([]byte(`{"Name":"web","DockerId":"abc"}`))
+		case "/v4/container/task":
+			_, _ = w.Write([]byte(`{"Cluster":"arn:aws:ecs:us-east-1:123456789012:cluster/prod","TaskARN":"arn:aws:ecs:us-east-1:123456789012:task/prod/0123abcd"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", server.URL+"/v4/container")
+}
+
+func TestCloudWatchWriterECSMetadataFields(t *testing.T) {
+	newECSMetadataServer(t)
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "{ecs_cluster}/{ecs_container}/{ecs_task_id}",
+		cloudwatchwriter.WithECSMetadataFields(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	for _, log := range []string{`{"message":"hello"}`, `{}`, "not JSON"} {
+		_, err = cloudWatchWriter.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	cloudWatchWriter.Close()
+
+	fields := `"ecs_cluster":"arn:aws:ecs:us-east-1:123456789012:cluster/prod","ecs_task_arn":"arn:aws:ecs:us-east-1:123456789012:task/prod/0123abcd","ecs_container":"web"`
+	assert.Equal(t, []string{
+		`{` + fields + `,"message":"hello"}`,
+		`{` + fields + `}`,
+		"not JSON",
+	}, client.Messages("logGroup", "prod/web/0123abcd"))
+}
+
+func TestCloudWatchWriterECSMetadataMissing(t *testing.T) {
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "")
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "app-{ecs_task_id}",
+		cloudwatchwriter.WithECSMetadataFields(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	// Not on ECS, so the logs are left as they are, and the host name is
+	// used in the log stream name
+	_, err = cloudWatchWriter.Write([]byte(`{"message":"hello"}`))
+	assert.NoError(t, err)
+	cloudWatchWriter.Close()
+	hostname, err := os.Hostname()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"message":"hello"}`}, client.Messages("logGroup", "app-"+hostname))
+}
@@ -0,0 +1,48 @@
+package cloudwatchwriter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/smithy-go"
+)
+
+var (
+	// ErrBatchIntervalTooSmall is returned for a batch interval of less than
+	// 200 milliseconds, the minimum.
+	ErrBatchIntervalTooSmall = errors.New("cloudwatchwriter: batch interval is less than the minimum")
+	// ErrResourceCreationDenied is wrapped in the error from the constructor
+	// when the credentials aren't allowed to create the log group or log
+	// stream, which needs the logs:CreateLogGroup or logs:CreateLogStream
+	// permission.
+	ErrResourceCreationDenied = errors.New("cloudwatchwriter: not allowed to create the log group or log stream")
+	// ErrQueueFull is returned by Write for a log dropped because the queue
+	// was full.
+	ErrQueueFull = errors.New("cloudwatchwriter: queue is full")
+	// ErrMessageTooLarge matches the *MessageTooLargeError returned by Write
+	// for a log which is too large, with errors.Is.
+	ErrMessageTooLarge = errors.New("cloudwatchwriter: log is too large")
+	// ErrRateLimited is returned by Write for a log dropped because it was
+	// over the rate limit, see WithRateLimit.
+	ErrRateLimited = errors.New("cloudwatchwriter: log is over the rate limit")
+	// ErrTooOld is why a queued log which was dropped for being older than
+	// the maximum event age wasn't delivered, see WithMaxEventAge.
+	ErrTooOld = errors.New("cloudwatchwriter: log is too old")
+	// ErrSpoolCorrupt is why a queued log which couldn't be read back from
+	// the spool wasn't delivered, see WithSpool.
+	ErrSpoolCorrupt = errors.New("cloudwatchwriter: log is corrupt in the spool")
+	// ErrNotStarted is returned by Flush for a writer made with
+	// WithManualStart which isn't running, before Start or after Stop.
+	ErrNotStarted = errors.New("cloudwatchwriter: writer is not started")
+)
+
+// wrapCreateErr wraps the error from the named request which creates the log
+// group or log stream, and in ErrResourceCreationDenied too if it was refused
+// for lack of permission.
+func wrapCreateErr(err error, request string) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException" {
+		return fmt.Errorf("%s: %w: %w", request, ErrResourceCreationDenied, err)
+	}
+	return fmt.Errorf("%s: %w", request, err)
+}
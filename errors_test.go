@@ -0,0 +1,61 @@
+package cloudwatchwriter_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestNewWithClientErrBatchIntervalTooSmall(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 100*time.Millisecond, "logGroup", "logStream")
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrBatchIntervalTooSmall))
+}
+
+func TestNewWithClientErrResourceCreationDenied(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{createLogGroupErr: denied}, 200*time.Millisecond, "logGroup", "logStream")
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrResourceCreationDenied))
+
+	var apiErr smithy.APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, "AccessDeniedException", apiErr.ErrorCode())
+	}
+
+	// Other errors are not mistaken for it
+	_, err = cloudwatchwriter.NewWithClient(&mockClient{createLogGroupErr: serverError{}}, 200*time.Millisecond, "logGroup", "logStream")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, cloudwatchwriter.ErrResourceCreationDenied))
+}
+
+func TestCloudWatchWriterErrQueueFull(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client, cloudwatchwriter.WithMaxQueueSize(1, 0))
+
+	_, err := cloudWatchWriter.Write([]byte("queued"))
+	assert.NoError(t, err)
+	_, err = cloudWatchWriter.Write([]byte("dropped"))
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrQueueFull))
+
+	close(client.putLogEventsGate)
+	cloudWatchWriter.Close()
+}
+
+func TestCloudWatchWriterErrMessageTooLarge(t *testing.T) {
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithOversizePolicy(cloudwatchwriter.Reject),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, err = cloudWatchWriter.Write(make([]byte, 300*1024))
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrMessageTooLarge))
+
+	var tooLarge *cloudwatchwriter.MessageTooLargeError
+	assert.True(t, errors.As(err, &tooLarge))
+}
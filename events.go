@@ -0,0 +1,160 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// LogEvent is a log with its own timestamp, see WriteEvents.
+type LogEvent struct {
+	// Message is the log.
+	Message string
+	// Timestamp is when the log happened, the zero time means now.
+	Timestamp time.Time
+}
+
+// inputLogEvents returns the log events to send for the logs, see
+// appendInputLogEvents. With the Reject policy, it returns the error for the
+// first log which is too large, having dropped it.
+func (c *CloudWatchWriter) inputLogEvents(logs []LogEvent) ([]types.InputLogEvent, error) {
+	now := c.clock.Now()
+	events := make([]types.InputLogEvent, 0, len(logs))
+	for _, log := range logs {
+		var err error
+		if events, err = c.appendInputLogEvents(events, log, now); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// appendInputLogEvents appends the log events to send for the log, after
+// passing it through the transformers, trimming its newlines, stripping ANSI
+// escape sequences, redacting it, applying the invalid UTF-8 policy, adding
+// the fields and sequence number and applying the oversize policy, which can
+// make it several events. A log which is empty or only whitespace by then is
+// skipped. With the Reject policy, it returns the error for a log which is
+// too large, having dropped it.
+func (c *CloudWatchWriter) appendInputLogEvents(events []types.InputLogEvent, log LogEvent, now time.Time) ([]types.InputLogEvent, error) {
+	if log.Timestamp.IsZero() && c.timestampField != "" {
+		log.Timestamp, _ = c.logTimestamp(log.Message)
+	}
+	if log.Timestamp.IsZero() {
+		log.Timestamp = now
+	}
+	log, keep := c.transform(log)
+	if !keep {
+		return events, nil
+	}
+
+	message := log.Message
+	if c.trimNewlines {
+		message = strings.TrimRight(message, "\r\n")
+	}
+	if c.stripANSI {
+		message = stripANSI(message)
+	}
+	message = sanitizeUTF8(c.redact(message), c.invalidUTF8Policy)
+	if strings.TrimSpace(message) == "" {
+		// CloudWatch rejects the whole batch for an empty log event
+		c.Lock()
+		c.stats.SkippedEmpty++
+		c.Unlock()
+		return events, nil
+	}
+	timestamp := log.Timestamp.UnixMilli()
+	fitted := c.addSequenceNumber(c.addFields(message))
+	if len(fitted) <= maxMessageSize {
+		// Most logs fit, without the slice fitMessage returns
+		return append(events, newInputLogEvent(fitted, timestamp)), nil
+	}
+	messages, err := fitMessage(fitted, c.oversizePolicy)
+	if err != nil {
+		c.dropped(message, err)
+		return nil, err
+	}
+	for _, message := range messages {
+		events = append(events, newInputLogEvent(message, timestamp))
+	}
+	return events, nil
+}
+
+// eventValues holds what the pointers of a log event point to, so that they
+// take one allocation rather than two.
+type eventValues struct {
+	message   string
+	timestamp int64
+}
+
+func newInputLogEvent(message string, timestamp int64) types.InputLogEvent {
+	v := &eventValues{message: message, timestamp: timestamp}
+	return types.InputLogEvent{Message: &v.message, Timestamp: &v.timestamp}
+}
+
+// maxPooledEvents is the capacity above which an eventBuffer isn't kept for
+// reuse, such as one which held a log split into many events.
+const maxPooledEvents = 64
+
+// eventBuffers holds the eventBuffers which the log events of each log
+// written are built in, as they are only needed until they have been queued
+// or batched.
+var eventBuffers = sync.Pool{
+	New: func() interface{} { return new(eventBuffer) },
+}
+
+type eventBuffer struct {
+	events []types.InputLogEvent
+}
+
+// release gives the buffer back to eventBuffers, once its events are no
+// longer needed.
+func (b *eventBuffer) release() {
+	if cap(b.events) > maxPooledEvents {
+		return
+	}
+	// Don't hold on to the messages
+	clear(b.events)
+	b.events = b.events[:0]
+	eventBuffers.Put(b)
+}
+
+// WriteEvents queues many logs at once, keeping their timestamps, which is
+// quicker than writing them one at a time, such as for logs which are already
+// received in batches. The logs are checked before any are queued: with the
+// Reject oversize policy, a log which is too large makes WriteEvents return
+// its *MessageTooLargeError without queueing any of them. Otherwise, like
+// Write, it returns ErrQueueFull if the queue fills up, in which case the logs
+// after the first one which didn't fit are dropped too, or the last error from
+// sending logs to CloudWatch.
+func (c *CloudWatchWriter) WriteEvents(logs []LogEvent) error {
+	err := c.writeEvents(logs)
+	if c.writeNeverFails {
+		return nil
+	}
+	return err
+}
+
+func (c *CloudWatchWriter) writeEvents(logs []LogEvent) error {
+	events, err := c.inputLogEvents(logs)
+	if err != nil {
+		return err
+	}
+
+	n, err := c.queue.enqueueAll(context.Background(), events, nil)
+	if err != nil {
+		for _, event := range events[n:] {
+			c.dropped(*event.Message, err)
+		}
+		return err
+	}
+
+	lastErr := c.getErr()
+	if lastErr != nil {
+		c.setErr(nil)
+	}
+	return lastErr
+}
@@ -0,0 +1,127 @@
+package cloudwatchwriter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterWriteEvents(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	earlier := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	before := time.Now().Truncate(time.Millisecond)
+	err = cloudWatchWriter.WriteEvents([]cloudwatchwriter.LogEvent{
+		{Message: "first", Timestamp: earlier},
+		{Message: "second"},
+	})
+	assert.NoError(t, err)
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 2, len(logs)) {
+		assert.Equal(t, "first", aws.ToString(logs[0].Message))
+		assert.Equal(t, earlier.UnixMilli(), aws.ToInt64(logs[0].Timestamp))
+		// A log without a timestamp is given the time it was written
+		assert.Equal(t, "second", aws.ToString(logs[1].Message))
+		assert.True(t, aws.ToInt64(logs[1].Timestamp) >= before.UnixMilli())
+	}
+}
+
+func TestCloudWatchWriterWriteEventsTooLarge(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithOversizePolicy(cloudwatchwriter.Reject),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	err = cloudWatchWriter.WriteEvents([]cloudwatchwriter.LogEvent{
+		{Message: "ok"},
+		{Message: strings.Repeat("a", 300*1024)},
+	})
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrMessageTooLarge))
+	cloudWatchWriter.Close()
+
+	// None of them are queued
+	assert.Equal(t, 0, client.numLogs())
+}
+
+func TestCloudWatchWriterWriteEventsQueueFull(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client, cloudwatchwriter.WithMaxQueueSize(2, 0))
+
+	err := cloudWatchWriter.WriteEvents([]cloudwatchwriter.LogEvent{
+		{Message: "a"}, {Message: "b"}, {Message: "c"}, {Message: "d"},
+	})
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrQueueFull))
+	assert.Equal(t, int64(2), cloudWatchWriter.Stats().DroppedQueueFull)
+
+	close(client.putLogEventsGate)
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 3, len(logs)) {
+		assert.Equal(t, "a", aws.ToString(logs[1].Message))
+		assert.Equal(t, "b", aws.ToString(logs[2].Message))
+	}
+}
+
+func TestCloudWatchWriterSkipsEmptyLogs(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	for _, log := range []string{"", " \t", "\n", "log\n"} {
+		n, err := cloudWatchWriter.Write([]byte(log))
+		assert.NoError(t, err)
+		assert.Equal(t, len(log), n)
+	}
+	assert.NoError(t, cloudWatchWriter.WriteEvents([]cloudwatchwriter.LogEvent{{Message: ""}, {Message: "event"}}))
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 2, len(logs)) {
+		assert.Equal(t, "log\n", *logs[0].Message)
+		assert.Equal(t, "event", *logs[1].Message)
+	}
+	assert.Equal(t, int64(4), cloudWatchWriter.Stats().SkippedEmpty)
+}
+
+func TestCloudWatchWriterNewlineTrimming(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithNewlineTrimming(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	logger := zerolog.New(cloudWatchWriter)
+	logger.Info().Msg("hello")
+	_, err = cloudWatchWriter.Write([]byte("windows\r\n"))
+	assert.NoError(t, err)
+	_, err = cloudWatchWriter.Write([]byte("\r\n"))
+	assert.NoError(t, err)
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 2, len(logs)) {
+		assert.Equal(t, `{"level":"info","message":"hello"}`, *logs[0].Message)
+		assert.Equal(t, "windows", *logs[1].Message)
+	}
+	assert.Equal(t, int64(1), cloudWatchWriter.Stats().SkippedEmpty)
+}
@@ -0,0 +1,45 @@
+package cloudwatchwriter
+
+import "expvar"
+
+// publishExpvar publishes the writer's Stats with expvar, under the name set
+// by WithExpvar.
+func (c *CloudWatchWriter) publishExpvar() {
+	expvar.Publish(c.expvarName, expvar.Func(c.expvarStats))
+}
+
+// expvarStats returns the writer's Stats in the form published with expvar,
+// as JSON with snake_case names.
+func (c *CloudWatchWriter) expvarStats() interface{} {
+	stats := c.Stats()
+
+	var lastError string
+	if stats.LastError != nil {
+		lastError = stats.LastError.Error()
+	}
+	return map[string]interface{}{
+		"queued_events":            stats.QueuedEvents,
+		"queued_bytes":             stats.QueuedBytes,
+		"events_sent":              stats.EventsSent,
+		"batches_sent":             stats.BatchesSent,
+		"retries":                  stats.Retries,
+		"throttles":                stats.Throttles,
+		"throttled":                stats.Throttled,
+		"effective_batch_interval": stats.EffectiveBatchInterval.String(),
+		"dropped_queue_full":       stats.DroppedQueueFull,
+		"dropped_oldest":           stats.DroppedOldest,
+		"dropped_too_large":        stats.DroppedTooLarge,
+		"dropped_undelivered":      stats.DroppedUndelivered,
+		"dropped_rate_limited":     stats.DroppedRateLimited,
+		"dropped_too_old":          stats.DroppedTooOld,
+		"sampled_out":              stats.SampledOut,
+		"filtered_out":             stats.FilteredOut,
+		"skipped_empty":            stats.SkippedEmpty,
+		"rejected_too_old":         stats.RejectedTooOld,
+		"rejected_expired":         stats.RejectedExpired,
+		"rejected_too_new":         stats.RejectedTooNew,
+		"last_error":               lastError,
+		"last_error_time":          stats.LastErrorTime,
+		"last_success_time":        stats.LastSuccessTime,
+	}
+}
@@ -0,0 +1,38 @@
+package cloudwatchwriter_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterExpvar(t *testing.T) {
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithExpvar("cloudwatchwriter_test"),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	v := expvar.Get("cloudwatchwriter_test")
+	if assert.NotNil(t, v) {
+		var stats map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(v.String()), &stats))
+		assert.Equal(t, float64(1), stats["events_sent"])
+		assert.Equal(t, "", stats["last_error"])
+	}
+
+	// The name is taken now
+	_, err = cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithExpvar("cloudwatchwriter_test"),
+	)
+	assert.Error(t, err)
+}
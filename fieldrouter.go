@@ -0,0 +1,294 @@
+package cloudwatchwriter
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/rs/zerolog"
+)
+
+// Destination is a log group and log stream which a FieldRouter sends logs
+// to. The names can contain the same placeholders as those given to New.
+type Destination struct {
+	LogGroupName  string
+	LogStreamName string
+}
+
+func (d Destination) String() string {
+	return d.LogGroupName + "/" + d.LogStreamName
+}
+
+// FieldRouter sends each log to a destination chosen by the value of one of
+// its fields, such as a log group for each tenant of a multi-tenant service.
+// The writer for a destination is created by the first log sent to it, and
+// only so many are kept active: when another one is needed, the destination
+// which was written to least recently is closed in the background, sending its
+// logs, until it is written to again. Like a Router, the destinations share the client and one
+// goroutine sending the logs.
+type FieldRouter struct {
+	client        CloudWatchLogsClient
+	batchInterval time.Duration
+	opts          []Option
+	field         string
+	destination   func(value string) Destination
+	maxActive     int
+	loop          *sendLoop
+
+	mu sync.Mutex
+	// active holds the destinations' writers, the least recently written to
+	// at the back of lru.
+	active map[Destination]*list.Element
+	lru    *list.List
+	closed bool
+	// evicted holds the destinations being closed to make room for others,
+	// which are still pumped until they have been, and evicting counts them.
+	evicted  map[*activeDestination]struct{}
+	evicting sync.WaitGroup
+}
+
+type activeDestination struct {
+	destination Destination
+	writer      *CloudWatchWriter
+	// inUse counts the logs being written to the writer, which is only
+	// closed once they have been.
+	inUse sync.WaitGroup
+}
+
+// NewFieldRouter returns a FieldRouter sending each log to the destination
+// returned by destination for the value of the field, with at most maxActive
+// destinations active at once, or an error. The value of a field which isn't a
+// string is its JSON, and the value is empty for a log without the field, or
+// which isn't JSON. The options apply to the writer of each destination, see
+// New, except for WithManualStart, WithSpool and WithExpvar, which a
+// FieldRouter doesn't support.
+func NewFieldRouter(cfg aws.Config, field string, destination func(value string) Destination, maxActive int, opts ...Option) (*FieldRouter, error) {
+	return NewFieldRouterWithClient(cloudwatchlogs.NewFromConfig(cfg), defaultBatchInterval, field, destination, maxActive, opts...)
+}
+
+// NewFieldRouterWithClient is like NewFieldRouter, with the client used by
+// every destination, see NewWithClient.
+func NewFieldRouterWithClient(client CloudWatchLogsClient, batchInterval time.Duration, field string, destination func(value string) Destination, maxActive int, opts ...Option) (*FieldRouter, error) {
+	if destination == nil {
+		return nil, errors.New("destination must not be nil")
+	}
+	if maxActive < 1 {
+		return nil, errors.New("max active destinations must be at least 1")
+	}
+	loop, opts, err := newSendLoop(batchInterval, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &FieldRouter{
+		client:        client,
+		batchInterval: batchInterval,
+		opts:          opts,
+		field:         field,
+		destination:   destination,
+		maxActive:     maxActive,
+		loop:          loop,
+		active:        make(map[Destination]*list.Element),
+		lru:           list.New(),
+		evicted:       make(map[*activeDestination]struct{}),
+	}
+	loop.start(r.writers)
+	return r, nil
+}
+
+// Write writes the log to the destination for the value of its field, see
+// CloudWatchWriter.Write. The first log for a destination waits for its log
+// group and log stream to be found or created, as do the logs for other
+// destinations in the meantime, and returns the error if that fails, in which
+// case the log is not sent.
+func (r *FieldRouter) Write(log []byte) (int, error) {
+	return r.write(log, func(c *CloudWatchWriter) (int, error) {
+		return c.Write(log)
+	})
+}
+
+// WriteLevel implements the zerolog.LevelWriter interface, writing the log
+// like Write, unless it is below the writers' minimum level, see
+// CloudWatchWriter.WriteLevel.
+func (r *FieldRouter) WriteLevel(level zerolog.Level, log []byte) (int, error) {
+	return r.write(log, func(c *CloudWatchWriter) (int, error) {
+		return c.WriteLevel(level, log)
+	})
+}
+
+// write calls fn with the writer for the log's destination. The writer is
+// looked up with the lock held, but written to without it, so that a Write
+// waiting for room in a full queue doesn't stop the logs from being sent, and
+// a writer being closed waits for the logs being written to it.
+func (r *FieldRouter) write(log []byte, fn func(*CloudWatchWriter) (int, error)) (int, error) {
+	destination := r.destination(fieldValue(log, r.field))
+
+	r.mu.Lock()
+	active, evicted, err := r.writer(destination)
+	r.mu.Unlock()
+
+	if evicted != nil {
+		go r.closeEvicted(evicted)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("destination %s: %w", destination, err)
+	}
+	defer active.inUse.Done()
+
+	n, err := fn(active.writer)
+	if err != nil {
+		return n, fmt.Errorf("destination %s: %w", destination, err)
+	}
+	return n, nil
+}
+
+// writer returns the destination, creating its writer if it isn't active, in
+// which case the destination which has to be closed to make room for it is
+// returned too. The destination is in use until its inUse is done. It is
+// called with the lock held.
+func (r *FieldRouter) writer(destination Destination) (*activeDestination, *activeDestination, error) {
+	if r.closed {
+		return nil, nil, ErrClosed
+	}
+	if e, ok := r.active[destination]; ok {
+		r.lru.MoveToFront(e)
+		active := e.Value.(*activeDestination)
+		active.inUse.Add(1)
+		return active, nil, nil
+	}
+
+	writer, err := NewWithClient(r.client, r.batchInterval, destination.LogGroupName, destination.LogStreamName, r.opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var evicted *activeDestination
+	if r.lru.Len() >= r.maxActive {
+		evicted = r.lru.Remove(r.lru.Back()).(*activeDestination)
+		delete(r.active, evicted.destination)
+		r.evicted[evicted] = struct{}{}
+		r.evicting.Add(1)
+	}
+	active := &activeDestination{destination: destination, writer: writer}
+	active.inUse.Add(1)
+	r.active[destination] = r.lru.PushFront(active)
+	return active, evicted, nil
+}
+
+// closeEvicted closes a destination which has been evicted, once the logs
+// being written to it have been, sending its logs.
+func (r *FieldRouter) closeEvicted(evicted *activeDestination) {
+	defer r.evicting.Done()
+
+	evicted.inUse.Wait()
+	if err := evicted.writer.CloseWithContext(context.Background()); err != nil {
+		evicted.writer.logf("closing the inactive destination %s: %v", evicted.destination, err)
+	}
+
+	r.mu.Lock()
+	delete(r.evicted, evicted)
+	r.mu.Unlock()
+}
+
+// activeDestinations returns the active destinations, the most recently
+// written to first.
+func (r *FieldRouter) activeDestinations() []*activeDestination {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	active := make([]*activeDestination, 0, r.lru.Len())
+	for e := r.lru.Front(); e != nil; e = e.Next() {
+		active = append(active, e.Value.(*activeDestination))
+	}
+	return active
+}
+
+// writers returns the writers of the active destinations, and of those being
+// closed, which may have logs being written to them still.
+func (r *FieldRouter) writers() []*CloudWatchWriter {
+	var writers []*CloudWatchWriter
+	for _, a := range r.activeDestinations() {
+		writers = append(writers, a.writer)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for evicted := range r.evicted {
+		writers = append(writers, evicted.writer)
+	}
+	return writers
+}
+
+// Active returns the number of active destinations.
+func (r *FieldRouter) Active() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lru.Len()
+}
+
+// fieldValue returns the value of the field of a JSON log, as the string it
+// holds or else as its JSON, or "" if the log hasn't got the field.
+func fieldValue(log []byte, name string) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(log, &fields); err != nil {
+		return ""
+	}
+	raw, ok := fields[name]
+	if !ok {
+		return ""
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw)
+	}
+	return value
+}
+
+// Flush sends the logs written so far to every active destination, and blocks
+// until they have been sent, returning the first error, see
+// CloudWatchWriter.Flush.
+func (r *FieldRouter) Flush() error {
+	var firstErr error
+	for _, a := range r.activeDestinations() {
+		if err := a.writer.Flush(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("destination %s: %w", a.destination, err)
+		}
+	}
+	return firstErr
+}
+
+// Close blocks until the router has completed writing the logs to CloudWatch.
+func (r *FieldRouter) Close() {
+	_ = r.CloseWithContext(context.Background())
+}
+
+// CloseWithContext closes the writer of every active destination at the same
+// time, see CloudWatchWriter.CloseWithContext, returning the first error.
+// Writing to the router afterwards returns ErrClosed.
+func (r *FieldRouter) CloseWithContext(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	// The evicted destinations are pumped until they have been closed
+	r.evicting.Wait()
+	r.loop.close()
+
+	active := r.activeDestinations()
+	writers := make([]*CloudWatchWriter, len(active))
+	for i, a := range active {
+		writers[i] = a.writer
+	}
+	for i, err := range closeAll(ctx, writers) {
+		if err != nil {
+			return fmt.Errorf("destination %s: %w", active[i].destination, err)
+		}
+	}
+	return nil
+}
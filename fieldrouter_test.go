@@ -0,0 +1,157 @@
+package cloudwatchwriter_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func tenantDestination(tenant string) cloudwatchwriter.Destination {
+	if tenant == "" {
+		tenant = "unknown"
+	}
+	return cloudwatchwriter.Destination{LogGroupName: "tenant-" + tenant, LogStreamName: "app"}
+}
+
+func TestFieldRouter(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	router, err := cloudwatchwriter.NewFieldRouterWithClient(client, 200*time.Millisecond, "tenant", tenantDestination, 10)
+	if err != nil {
+		t.Fatalf("NewFieldRouterWithClient: %v", err)
+	}
+	defer router.Close()
+
+	for _, log := range []string{`{"tenant":"a"}`, `{"tenant":"b"}`, `{"tenant":1}`, `{"tenant":"a","n":2}`, `{}`, `not JSON`} {
+		_, err = router.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 4, router.Active())
+	assert.NoError(t, router.Flush())
+
+	assert.Equal(t, []string{`{"tenant":"a"}`, `{"tenant":"a","n":2}`}, client.Messages("tenant-a", "app"))
+	assert.Equal(t, []string{`{"tenant":"b"}`}, client.Messages("tenant-b", "app"))
+	assert.Equal(t, []string{`{"tenant":1}`}, client.Messages("tenant-1", "app"))
+	assert.Equal(t, []string{`{}`, `not JSON`}, client.Messages("tenant-unknown", "app"))
+}
+
+func TestFieldRouterMaxActive(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	router, err := cloudwatchwriter.NewFieldRouterWithClient(client, time.Hour, "tenant", tenantDestination, 2)
+	if err != nil {
+		t.Fatalf("NewFieldRouterWithClient: %v", err)
+	}
+	defer router.Close()
+
+	logger := zerolog.New(router)
+	logger.Info().Str("tenant", "a").Msg("first")
+	logger.Info().Str("tenant", "b").Msg("first")
+	logger.Info().Str("tenant", "a").Msg("second")
+	assert.Empty(t, client.Messages("tenant-b", "app"))
+
+	// b was written to least recently, so it's closed in the background to
+	// make room for c, sending its logs
+	logger.Info().Str("tenant", "c").Msg("first")
+	assert.Equal(t, 2, router.Active())
+	_, err = client.WaitForEvents("tenant-b", "app", 1, time.Second)
+	assert.NoError(t, err)
+	assert.Empty(t, client.Messages("tenant-a", "app"))
+
+	// Writing to b again makes it active again, closing a
+	logger.Info().Str("tenant", "b").Msg("second")
+	_, err = client.WaitForEvents("tenant-a", "app", 2, time.Second)
+	assert.NoError(t, err)
+
+	router.Close()
+	assert.Len(t, client.Messages("tenant-b", "app"), 2)
+	assert.Len(t, client.Messages("tenant-c", "app"), 1)
+	_, err = router.Write([]byte(`{"tenant":"a"}`))
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrClosed))
+}
+
+func TestFieldRouterBlock(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	router, err := cloudwatchwriter.NewFieldRouterWithClient(client, 200*time.Millisecond, "tenant", tenantDestination, 1,
+		cloudwatchwriter.WithMaxQueueSize(1, 0),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.Block),
+	)
+	if err != nil {
+		t.Fatalf("NewFieldRouterWithClient: %v", err)
+	}
+	defer router.Close()
+
+	// A Write waiting for room in the queue doesn't stop the queue from being
+	// sent, even to a destination being closed to make room for another
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, log := range []string{`{"tenant":"a","n":1}`, `{"tenant":"a","n":2}`, `{"tenant":"a","n":3}`, `{"tenant":"b"}`, `{"tenant":"a","n":4}`} {
+			_, err := router.Write([]byte(log))
+			assert.NoError(t, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write blocked")
+	}
+
+	router.Close()
+	assert.Len(t, client.Messages("tenant-a", "app"), 4)
+	assert.Len(t, client.Messages("tenant-b", "app"), 1)
+}
+
+func TestFieldRouterSendsEveryBatchInterval(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	router, err := cloudwatchwriter.NewFieldRouterWithClient(client, time.Second, "tenant", tenantDestination, 10,
+		cloudwatchwriter.WithClock(clock),
+	)
+	if err != nil {
+		t.Fatalf("NewFieldRouterWithClient: %v", err)
+	}
+	defer router.Close()
+
+	_, err = router.Write([]byte(`{"tenant":"a"}`))
+	assert.NoError(t, err)
+	_, err = router.Write([]byte(`{"tenant":"b"}`))
+	assert.NoError(t, err)
+
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(time.Second)
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	assert.Len(t, client.Messages("tenant-a", "app"), 1)
+	assert.Len(t, client.Messages("tenant-b", "app"), 1)
+}
+
+func TestFieldRouterDestinationError(t *testing.T) {
+	client := &mockClient{
+		createLogGroupErr: errors.New("denied"),
+	}
+	router, err := cloudwatchwriter.NewFieldRouterWithClient(client, 200*time.Millisecond, "tenant", tenantDestination, 10)
+	if err != nil {
+		t.Fatalf("NewFieldRouterWithClient: %v", err)
+	}
+	defer router.Close()
+
+	_, err = router.Write([]byte(`{"tenant":"a"}`))
+	assert.Error(t, err)
+	assert.Equal(t, 0, router.Active())
+}
+
+func TestFieldRouterInvalid(t *testing.T) {
+	_, err := cloudwatchwriter.NewFieldRouterWithClient(&mockClient{}, 200*time.Millisecond, "tenant", nil, 10)
+	assert.Error(t, err)
+
+	_, err = cloudwatchwriter.NewFieldRouterWithClient(&mockClient{}, 200*time.Millisecond, "tenant", tenantDestination, 0)
+	assert.Error(t, err)
+
+	_, err = cloudwatchwriter.NewFieldRouterWithClient(&mockClient{}, 200*time.Millisecond, "tenant", tenantDestination, 10,
+		cloudwatchwriter.WithManualStart(),
+	)
+	assert.Error(t, err)
+}
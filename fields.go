@@ -0,0 +1,105 @@
+package cloudwatchwriter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// field is a field added to each log which is a JSON object, see
+// WithStaticFields and WithECSMetadataFields.
+type field struct {
+	name  string
+	value json.RawMessage
+	// key, if not nil, is the name encoded as JSON, see newField.
+	key []byte
+}
+
+// newField returns a field whose name is encoded once, rather than for each
+// log it is added to.
+func newField(name string, value json.RawMessage) field {
+	key, _ := json.Marshal(name)
+	return field{name: name, value: value, key: key}
+}
+
+// encodedName returns the field's name encoded as JSON.
+func (f field) encodedName() []byte {
+	if f.key != nil {
+		return f.key
+	}
+	name, _ := json.Marshal(f.name)
+	return name
+}
+
+// staticFields returns the fields of WithStaticFields, in order of their
+// names, or an error if a value can't be encoded as JSON.
+func staticFields(values map[string]interface{}) ([]field, error) {
+	fields := make([]field, 0, len(values))
+	for name, value := range values {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("static field %s: %w", name, err)
+		}
+		fields = append(fields, newField(name, encoded))
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].name < fields[j].name
+	})
+	return fields, nil
+}
+
+// addFields adds the fields at the start of a log which is a JSON object,
+// apart from those it already has. With wrapLogs, any other log is made the
+// message field of a JSON object with the fields, otherwise it is left as it
+// is.
+func (c *CloudWatchWriter) addFields(message string) string {
+	return addFields(message, c.fields, c.wrapLogs)
+}
+
+func addFields(message string, fields []field, wrap bool) string {
+	if len(fields) == 0 {
+		return message
+	}
+
+	// rest is the log after its opening brace
+	var existing map[string]json.RawMessage
+	var rest string
+	if trimmed := strings.TrimSpace(message); strings.HasPrefix(trimmed, "{") && json.Unmarshal([]byte(trimmed), &existing) == nil {
+		rest = message[strings.IndexByte(message, '{')+1:]
+	} else if wrap {
+		text, _ := json.Marshal(strings.TrimRight(message, "\r\n"))
+		existing = map[string]json.RawMessage{"message": text}
+		rest = `"message":` + string(text) + `}`
+	} else {
+		return message
+	}
+
+	var b strings.Builder
+	// The log and all of the fields, so that the builder only grows once
+	size := len(rest) + 1
+	for _, f := range fields {
+		size += len(f.name) + len(f.value) + 4
+	}
+	b.Grow(size)
+	b.WriteByte('{')
+	for _, f := range fields {
+		if _, ok := existing[f.name]; ok {
+			continue
+		}
+		b.Write(f.encodedName())
+		b.WriteByte(':')
+		b.Write(f.value)
+		b.WriteByte(',')
+	}
+	if b.Len() == 1 {
+		return message
+	}
+
+	if len(existing) == 0 {
+		// An empty object, so the last field doesn't need its comma
+		return strings.TrimSuffix(b.String(), ",") + rest
+	}
+	b.WriteString(rest)
+	return b.String()
+}
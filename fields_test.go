@@ -0,0 +1,53 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterStaticFields(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithStaticFields(map[string]interface{}{
+			"service": "api",
+			"version": 3,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	for _, log := range []string{
+		`{"level":"info","message":"hello"}` + "\n",
+		// The log's own fields are kept
+		`{"service":"worker"}`,
+		`{}`,
+		"plain text\n",
+		`{"not":"closed"`,
+	} {
+		_, err = cloudWatchWriter.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	cloudWatchWriter.Close()
+
+	assert.Equal(t, []string{
+		`{"service":"api","version":3,"level":"info","message":"hello"}` + "\n",
+		`{"version":3,"service":"worker"}`,
+		`{"service":"api","version":3}`,
+		`{"service":"api","version":3,"message":"plain text"}`,
+		`{"service":"api","version":3,"message":"{\"not\":\"closed\""}`,
+	}, client.Messages("logGroup", "logStream"))
+}
+
+func TestCloudWatchWriterStaticFieldsInvalid(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithStaticFields(map[string]interface{}{
+			"channel": make(chan int),
+		}),
+	)
+	assert.Error(t, err)
+}
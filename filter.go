@@ -0,0 +1,78 @@
+package cloudwatchwriter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Filter reports whether a log matches, for WithAllowFilters and
+// WithDenyFilters.
+type Filter func(log []byte) bool
+
+// FieldEquals returns a Filter matching JSON logs whose field has the value.
+// The value of a field which isn't a string is its JSON, and the value is
+// empty for a log without the field, or which isn't JSON. If name is "", the
+// whole log is matched instead of a field.
+func FieldEquals(name, value string) Filter {
+	return func(log []byte) bool {
+		return filterValue(log, name) == value
+	}
+}
+
+// FieldContains returns a Filter matching logs whose field contains the
+// substring, see FieldEquals.
+func FieldContains(name, substr string) Filter {
+	return func(log []byte) bool {
+		return strings.Contains(filterValue(log, name), substr)
+	}
+}
+
+// FieldMatches returns a Filter matching logs whose field matches the
+// pattern, see FieldEquals.
+func FieldMatches(name string, pattern *regexp.Regexp) Filter {
+	return func(log []byte) bool {
+		return pattern.MatchString(filterValue(log, name))
+	}
+}
+
+// filterValue returns the value of the field of the log for a Filter, or the
+// whole log if name is "".
+func filterValue(log []byte, name string) string {
+	if name == "" {
+		return string(log)
+	}
+	return fieldValue(log, name)
+}
+
+// filters are the filters of WithAllowFilters and WithDenyFilters.
+type filters struct {
+	allow []Filter
+	deny  []Filter
+}
+
+// filteredOut reports whether the log is discarded by the filters, counting
+// it if it is: when it doesn't match any of the allow filters, if there are
+// some, or matches one of the deny filters.
+func (c *CloudWatchWriter) filteredOut(log []byte) bool {
+	f := c.filters
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return false
+	}
+	if (len(f.allow) == 0 || anyMatch(f.allow, log)) && !anyMatch(f.deny, log) {
+		return false
+	}
+
+	c.Lock()
+	c.stats.FilteredOut++
+	c.Unlock()
+	return true
+}
+
+func anyMatch(filters []Filter, log []byte) bool {
+	for _, filter := range filters {
+		if filter(log) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package cloudwatchwriter_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter cloudwatchwriter.Filter
+		log    string
+		match  bool
+	}{
+		{"equals", cloudwatchwriter.FieldEquals("path", "/healthz"), `{"path":"/healthz"}`, true},
+		{"equals other value", cloudwatchwriter.FieldEquals("path", "/healthz"), `{"path":"/orders"}`, false},
+		{"equals number", cloudwatchwriter.FieldEquals("status", "200"), `{"status":200}`, true},
+		{"equals missing field", cloudwatchwriter.FieldEquals("path", "/healthz"), `{"message":"/healthz"}`, false},
+		{"contains", cloudwatchwriter.FieldContains("message", "reset"), `{"message":"connection reset by peer"}`, true},
+		{"contains not JSON", cloudwatchwriter.FieldContains("message", "reset"), `connection reset by peer`, false},
+		{"contains whole log", cloudwatchwriter.FieldContains("", "reset"), `connection reset by peer`, true},
+		{"matches", cloudwatchwriter.FieldMatches("user_agent", regexp.MustCompile(`(?i)bot`)), `{"user_agent":"Googlebot/2.1"}`, true},
+		{"matches not", cloudwatchwriter.FieldMatches("user_agent", regexp.MustCompile(`(?i)bot`)), `{"user_agent":"curl/8.0"}`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.match, test.filter([]byte(test.log)))
+		})
+	}
+}
+
+func TestCloudWatchWriterFilters(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithAllowFilters(
+			cloudwatchwriter.FieldEquals("service", "orders"),
+			cloudwatchwriter.FieldEquals("service", "payments"),
+		),
+		cloudwatchwriter.WithDenyFilters(cloudwatchwriter.FieldEquals("path", "/healthz")),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	logger := zerolog.New(cloudWatchWriter)
+	logger.Info().Str("service", "orders").Str("path", "/orders").Msg("kept")
+	logger.Info().Str("service", "payments").Msg("kept")
+	logger.Info().Str("service", "orders").Str("path", "/healthz").Msg("denied")
+	logger.Info().Str("service", "search").Msg("not allowed")
+	logger.Info().Msg("not allowed")
+
+	stats := cloudWatchWriter.Stats()
+	assert.Equal(t, int64(3), stats.FilteredOut)
+	assert.Equal(t, 2, stats.QueuedEvents)
+}
+
+func TestSyncWriterFilters(t *testing.T) {
+	client := &mockClient{}
+	syncWriter, err := cloudwatchwriter.NewSyncWithClient(client, "logGroup", "logStream",
+		cloudwatchwriter.WithDenyFilters(cloudwatchwriter.FieldContains("", "noisy")),
+	)
+	if err != nil {
+		t.Fatalf("NewSyncWithClient: %v", err)
+	}
+
+	n, err := syncWriter.Write([]byte("noisy log"))
+	assert.NoError(t, err)
+	assert.Equal(t, 9, n)
+	_, err = syncWriter.Write([]byte("useful log"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, client.numLogs())
+	assert.Equal(t, int64(1), syncWriter.Stats().FilteredOut)
+}
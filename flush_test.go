@@ -0,0 +1,56 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterFlush(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 5*time.Second, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 1"}, exampleLog{Message: "Test message 2"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 2, client.numLogs())
+
+	// Nothing to flush
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 1, client.numPutLogEventsCalls())
+}
+
+func TestCloudWatchWriterFlushError(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 5*time.Second, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	assert.Error(t, cloudWatchWriter.Flush())
+
+	// The error has been reported, so it isn't reported again
+	_, err = cloudWatchWriter.Write([]byte("hello world"))
+	assert.NoError(t, err)
+}
+
+func TestCloudWatchWriterFlushAfterClose(t *testing.T) {
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	cloudWatchWriter.Close()
+
+	assert.NoError(t, cloudWatchWriter.Flush())
+}
@@ -0,0 +1,48 @@
+package cloudwatchwriter
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// heartbeatMessage is the message of the logs written by WithHeartbeat.
+const heartbeatMessage = "heartbeat"
+
+// heartbeat writes a heartbeat log every interval, until the writer has
+// stopped.
+func (c *CloudWatchWriter) heartbeat() {
+	started := c.clock.Now()
+	timer := c.clock.NewTimer(c.heartbeatInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+			_ = c.enqueueLog(c.ctx, c.heartbeatLog(c.clock.Now().Sub(started)))
+			timer.Reset(c.heartbeatInterval)
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// heartbeatLog returns a heartbeat log, with how long the writer has been
+// running and some of its stats.
+func (c *CloudWatchWriter) heartbeatLog(uptime time.Duration) string {
+	stats := c.Stats()
+	dropped := stats.DroppedQueueFull + stats.DroppedOldest + stats.DroppedTooLarge + stats.DroppedUndelivered + stats.DroppedRateLimited + stats.DroppedTooOld
+
+	var log bytes.Buffer
+	logger := zerolog.New(&log)
+	logger.Info().
+		Bool("heartbeat", true).
+		Int64("uptime_seconds", int64(uptime/time.Second)).
+		Int("queued_events", stats.QueuedEvents).
+		Int64("events_sent", stats.EventsSent).
+		Int64("events_dropped", dropped).
+		Bool("throttled", stats.Throttled).
+		Msg(heartbeatMessage)
+	return log.String()
+}
@@ -0,0 +1,57 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterHeartbeat(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithHeartbeat(5*time.Minute),
+		// Heartbeats aren't filtered
+		cloudwatchwriter.WithDenyFilters(cloudwatchwriter.FieldEquals("heartbeat", "true")),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(5 * time.Minute)
+	for cloudWatchWriter.Stats().QueuedEvents < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(5 * time.Minute)
+	for cloudWatchWriter.Stats().QueuedEvents < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.Equal(t, []string{
+		"log",
+		`{"level":"info","heartbeat":true,"uptime_seconds":300,"queued_events":0,"events_sent":1,"events_dropped":0,"throttled":false,"message":"heartbeat"}` + "\n",
+		`{"level":"info","heartbeat":true,"uptime_seconds":600,"queued_events":0,"events_sent":2,"events_dropped":0,"throttled":false,"message":"heartbeat"}` + "\n",
+	}, client.Messages("logGroup", "logStream"))
+}
+
+func TestCloudWatchWriterHeartbeatNegative(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithHeartbeat(-time.Minute),
+	)
+	assert.EqualError(t, err, "heartbeat interval must not be negative")
+}
@@ -0,0 +1,51 @@
+package cloudwatchwriter
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// BatchSend describes how sending a batch of log events to CloudWatch went,
+// see WithSendHook.
+type BatchSend struct {
+	// Events is the number of log events in the batch, Bytes is the total
+	// size of their messages.
+	Events int
+	Bytes  int
+	// Attempts is the number of requests made to send the batch, more than
+	// one if it was retried.
+	Attempts int
+	// Start is when the first attempt started, Duration is how long it took
+	// until the batch was sent or given up on, including any backoff.
+	Start    time.Time
+	Duration time.Duration
+	// Latency is how long the last request to CloudWatch took, the round
+	// trip of the request which sent the batch if it was sent.
+	Latency time.Duration
+	// Err is nil if the batch was sent, otherwise it is why it wasn't.
+	Err error
+}
+
+// callSendHooks calls the send hooks with how sending the batch went.
+func (c *CloudWatchWriter) callSendHooks(batch []types.InputLogEvent, attempts int, start time.Time, latency time.Duration, err error) {
+	if len(c.sendHooks) == 0 {
+		return
+	}
+
+	send := BatchSend{
+		Events:   len(batch),
+		Attempts: attempts,
+		Start:    start,
+		Duration: c.clock.Now().Sub(start),
+		Latency:  latency,
+		Err:      err,
+	}
+	for _, event := range batch {
+		send.Bytes += len(aws.ToString(event.Message))
+	}
+	for _, hook := range c.sendHooks {
+		hook(send)
+	}
+}
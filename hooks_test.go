@@ -0,0 +1,88 @@
+package cloudwatchwriter_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterSendHook(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}},
+	}
+	var mu sync.Mutex
+	var sends []cloudwatchwriter.BatchSend
+	hook := func(send cloudwatchwriter.BatchSend) {
+		mu.Lock()
+		defer mu.Unlock()
+		sends = append(sends, send)
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+		}),
+		cloudwatchwriter.WithSendHook(hook),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	for _, message := range []string{"first", "second"} {
+		_, err = cloudWatchWriter.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+	cloudWatchWriter.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, sends, 1) {
+		assert.Equal(t, 2, sends[0].Events)
+		assert.Equal(t, len("first")+len("second"), sends[0].Bytes)
+		assert.Equal(t, 2, sends[0].Attempts)
+		assert.NoError(t, sends[0].Err)
+		assert.True(t, sends[0].Duration >= time.Millisecond)
+	}
+}
+
+func TestCloudWatchWriterBatchSentHandler(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}},
+	}
+	var mu sync.Mutex
+	var sent []cloudwatchwriter.BatchSend
+	handler := func(send cloudwatchwriter.BatchSend) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, send)
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+		cloudwatchwriter.WithBatchSentHandler(handler),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// A batch which isn't sent isn't reported
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 1"})
+	assert.Error(t, cloudWatchWriter.Flush())
+
+	_, err = cloudWatchWriter.Write([]byte("hello world"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, 1, sent[0].Events)
+		assert.Equal(t, len("hello world"), sent[0].Bytes)
+		assert.True(t, sent[0].Latency <= sent[0].Duration)
+	}
+}
@@ -0,0 +1,145 @@
+package cloudwatchwriter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+)
+
+// Importer uploads logs from the past to a log stream, such as logs kept in
+// files before the program sent them to CloudWatch. It sorts the logs by
+// timestamp and sends them in as few batches as CloudWatch allows, by size,
+// number of logs and the 24 hours a batch can span, skipping the logs which
+// CloudWatch would reject for being too old.
+type Importer struct {
+	writer *CloudWatchWriter
+}
+
+// ImportResult is what Import did with the logs.
+type ImportResult struct {
+	// Imported is the number of log events sent to CloudWatch, Batches is
+	// the number of requests they were sent in.
+	Imported int
+	Batches  int
+	// Skipped is the number of logs which were not sent, because they were
+	// more than 14 days old, older than the retention period of the log
+	// group, or more than 2 hours in the future.
+	Skipped int
+}
+
+// NewImporter returns an Importer uploading logs to the log stream, or an
+// error. It accepts the same options as New, apart from those about the queue
+// and the batch interval, which have no effect, and WithSpool, which makes it
+// return an error. The retry policy applies to each batch.
+func NewImporter(cfg aws.Config, logGroupName, logStreamName string, opts ...Option) (*Importer, error) {
+	return NewImporterWithClient(cloudwatchlogs.NewFromConfig(cfg), logGroupName, logStreamName, opts...)
+}
+
+// NewImporterWithClient is like NewImporter, with the CloudWatch Logs client
+// to use.
+func NewImporterWithClient(client CloudWatchLogsClient, logGroupName, logStreamName string, opts ...Option) (*Importer, error) {
+	writer, err := newWriter(client, defaultBatchInterval, logGroupName, logStreamName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if writer.spoolDir != "" {
+		return nil, errors.New("an importer can't use a spool")
+	}
+	return &Importer{writer: writer}, nil
+}
+
+// Import sends the logs to CloudWatch, in order of their timestamps, and
+// returns once they have been sent. The log group is looked up first, for its
+// retention period, if the client implements DescribeLogGroups. If a batch can't be sent, Import stops there and returns
+// the error, with the result counting the batches sent before it. A log with
+// the zero Timestamp is given the current time, and the oversize policy
+// applies to logs which are too large.
+func (i *Importer) Import(logs []LogEvent) (ImportResult, error) {
+	var result ImportResult
+	c := i.writer
+
+	oldest, err := i.oldestAccepted()
+	if err != nil {
+		return result, err
+	}
+	newest := c.clock.Now().Add(maxEventFuture).UnixMilli()
+
+	events, err := c.inputLogEvents(logs)
+	if err != nil {
+		return result, err
+	}
+	accepted := events[:0]
+	for _, event := range events {
+		switch timestamp := aws.ToInt64(event.Timestamp); {
+		case timestamp < oldest:
+			c.callDropHandler(aws.ToString(event.Message), DropReasonTooOld)
+			result.Skipped++
+		case timestamp > newest:
+			result.Skipped++
+		default:
+			accepted = append(accepted, event)
+		}
+	}
+	sort.SliceStable(accepted, func(a, b int) bool {
+		return *accepted[a].Timestamp < *accepted[b].Timestamp
+	})
+
+	var current batch
+	send := func() error {
+		n := len(current.events)
+		if err := c.sendBatch(current.take()); err != nil {
+			return err
+		}
+		result.Imported += n
+		result.Batches++
+		return nil
+	}
+	for _, event := range accepted {
+		if !current.fits(event) || current.full() {
+			if err = send(); err != nil {
+				return result, err
+			}
+		}
+		current.add(event)
+	}
+	if len(current.events) > 0 {
+		err = send()
+	}
+	return result, err
+}
+
+// oldestAccepted returns the timestamp, in milliseconds since the epoch, of
+// the oldest log event that CloudWatch accepts for the log group, going by
+// the 14 day limit and its retention period, if the client can look it up.
+func (i *Importer) oldestAccepted() (int64, error) {
+	maxAge := maxEventAge
+	if _, ok := i.writer.baseClient.(logGroupDescriber); !ok {
+		return i.writer.clock.Now().Add(-maxAge).UnixMilli(), nil
+	}
+	logGroup, err := i.writer.findLogGroup(i.writer.ctx)
+	if err != nil {
+		return 0, fmt.Errorf("cloudwatchlogs.Client.DescribeLogGroups: %w", err)
+	}
+
+	if logGroup != nil && logGroup.RetentionInDays != nil {
+		if retention := time.Duration(*logGroup.RetentionInDays) * 24 * time.Hour; retention < maxAge {
+			maxAge = retention
+		}
+	}
+	return i.writer.clock.Now().Add(-maxAge).UnixMilli(), nil
+}
+
+// Stats returns the importer's counters.
+func (i *Importer) Stats() Stats {
+	return i.writer.Stats()
+}
+
+// Close cancels an Import in progress, which returns an error, and any later
+// ones.
+func (i *Importer) Close() {
+	i.writer.cancel()
+}
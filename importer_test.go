@@ -0,0 +1,112 @@
+package cloudwatchwriter_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestImporter(t *testing.T) {
+	client := &mockClient{}
+	importer, err := cloudwatchwriter.NewImporterWithClient(client, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewImporterWithClient: %v", err)
+	}
+	defer importer.Close()
+
+	now := time.Now()
+	result, err := importer.Import([]cloudwatchwriter.LogEvent{
+		{Message: "yesterday", Timestamp: now.Add(-24 * time.Hour)},
+		{Message: "last week", Timestamp: now.Add(-7 * 24 * time.Hour)},
+		{Message: "too old", Timestamp: now.Add(-15 * 24 * time.Hour)},
+		{Message: "too new", Timestamp: now.Add(3 * time.Hour)},
+		{Message: "an hour ago", Timestamp: now.Add(-time.Hour)},
+	})
+	assert.NoError(t, err)
+
+	// The logs more than 24 hours apart are in separate batches
+	assert.Equal(t, cloudwatchwriter.ImportResult{Imported: 3, Batches: 2, Skipped: 2}, result)
+	assert.Equal(t, 2, client.numPutLogEventsCalls())
+	logs := client.getLogEvents()
+	if assert.Equal(t, 3, len(logs)) {
+		assert.Equal(t, "last week", aws.ToString(logs[0].Message))
+		assert.Equal(t, "yesterday", aws.ToString(logs[1].Message))
+		assert.Equal(t, "an hour ago", aws.ToString(logs[2].Message))
+	}
+}
+
+func TestImporterRetention(t *testing.T) {
+	client := &mockClient{}
+	importer, err := cloudwatchwriter.NewImporterWithClient(client, "logGroup", "logStream",
+		cloudwatchwriter.WithRetentionDays(3),
+	)
+	if err != nil {
+		t.Fatalf("NewImporterWithClient: %v", err)
+	}
+	defer importer.Close()
+
+	now := time.Now()
+	result, err := importer.Import([]cloudwatchwriter.LogEvent{
+		{Message: "expired", Timestamp: now.Add(-4 * 24 * time.Hour)},
+		{Message: "kept", Timestamp: now.Add(-2 * 24 * time.Hour)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, cloudwatchwriter.ImportResult{Imported: 1, Batches: 1, Skipped: 1}, result)
+}
+
+func TestImporterUnsupportedClient(t *testing.T) {
+	// Without DescribeLogGroups, only the 14 day limit applies
+	importer, err := cloudwatchwriter.NewImporterWithClient(basicClient{&mockClient{}}, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewImporterWithClient: %v", err)
+	}
+	defer importer.Close()
+
+	now := time.Now()
+	result, err := importer.Import([]cloudwatchwriter.LogEvent{
+		{Message: "too old", Timestamp: now.Add(-15 * 24 * time.Hour)},
+		{Message: "kept", Timestamp: now.Add(-2 * 24 * time.Hour)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, cloudwatchwriter.ImportResult{Imported: 1, Batches: 1, Skipped: 1}, result)
+}
+
+func TestImporterManyLogs(t *testing.T) {
+	client := &mockClient{}
+	importer, err := cloudwatchwriter.NewImporterWithClient(client, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewImporterWithClient: %v", err)
+	}
+	defer importer.Close()
+
+	// More than fit in one batch
+	start := time.Now().Add(-time.Hour)
+	logs := make([]cloudwatchwriter.LogEvent, 10001)
+	for i := range logs {
+		logs[i] = cloudwatchwriter.LogEvent{Message: fmt.Sprintf("log %d", i), Timestamp: start.Add(time.Duration(i) * time.Millisecond)}
+	}
+	result, err := importer.Import(logs)
+	assert.NoError(t, err)
+	assert.Equal(t, cloudwatchwriter.ImportResult{Imported: 10001, Batches: 2}, result)
+}
+
+func TestImporterError(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+	importer, err := cloudwatchwriter.NewImporterWithClient(client, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+	)
+	if err != nil {
+		t.Fatalf("NewImporterWithClient: %v", err)
+	}
+	defer importer.Close()
+
+	result, err := importer.Import([]cloudwatchwriter.LogEvent{{Message: "hello world", Timestamp: time.Now()}})
+	assert.Error(t, err)
+	assert.Equal(t, cloudwatchwriter.ImportResult{}, result)
+}
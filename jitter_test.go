@@ -0,0 +1,43 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterFirstSendJitter(t *testing.T) {
+	client := &mockClient{}
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Minute, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithFirstSendJitter(time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+
+	// Unless the jitter happens to be a nanosecond or less, the batch
+	// interval isn't enough for the first batch
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(time.Minute + time.Nanosecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 0, client.numPutLogEventsCalls())
+
+	clock.Advance(time.Hour)
+	assert.NoError(t, client.waitForLogs(1, time.Second))
+}
+
+func TestCloudWatchWriterFirstSendJitterNegative(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, time.Minute, "logGroup", "logStream",
+		cloudwatchwriter.WithFirstSendJitter(-time.Second),
+	)
+	assert.EqualError(t, err, "first send jitter must not be negative")
+}
@@ -0,0 +1,168 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/rs/zerolog"
+)
+
+// LambdaWriter is a writer for AWS Lambda functions, which keeps the logs in a
+// batch until FlushSync is called at the end of each invocation, rather than
+// relying on a goroutine to send them in the background, as Lambda freezes
+// the process between invocations. There is no batch interval, and so no
+// minimum interval between batches either: the logs are sent by FlushSync, or
+// by Write when the batch is full. It is safe for concurrent use.
+type LambdaWriter struct {
+	mu      sync.Mutex
+	writer  *CloudWatchWriter
+	current batch
+	closed  bool
+}
+
+// NewLambda returns a LambdaWriter sending the logs to the log stream, or an
+// error. It is meant to be created once, outside of the handler, so that it
+// is reused by the invocations. It accepts the same options as New, apart from
+// those about the queue and the batch interval, which have no effect, and
+// WithSpool, which makes it return an error.
+func NewLambda(cfg aws.Config, logGroupName, logStreamName string, opts ...Option) (*LambdaWriter, error) {
+	return NewLambdaWithClient(cloudwatchlogs.NewFromConfig(cfg), logGroupName, logStreamName, opts...)
+}
+
+// NewLambdaWithClient is like NewLambda, with the CloudWatch Logs client to
+// use.
+func NewLambdaWithClient(client CloudWatchLogsClient, logGroupName, logStreamName string, opts ...Option) (*LambdaWriter, error) {
+	writer, err := newWriter(client, defaultBatchInterval, logGroupName, logStreamName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if writer.spoolDir != "" {
+		return nil, errors.New("a Lambda writer can't use a spool")
+	}
+	if writer.expvarName != "" {
+		writer.publishExpvar()
+	}
+	l := &LambdaWriter{writer: writer}
+	writer.summaryWriter = l.writeSummary
+	if writer.aggregation.window > 0 {
+		go writer.aggregate()
+	}
+	return l, nil
+}
+
+// Write implements the io.Writer interface. It adds the log to the batch,
+// first sending the batch if the log doesn't fit in it, in which case it
+// returns the error from sending it.
+func (l *LambdaWriter) Write(log []byte) (int, error) {
+	if l.writer.splitLines {
+		return writeLines(log, func(line []byte) error {
+			_, err := l.write(l.writer.levelOf(line), line)
+			return err
+		})
+	}
+	return l.write(l.writer.levelOf(log), log)
+}
+
+// write writes a log at the level, unless the writer screens it out, see
+// CloudWatchWriter.screen.
+func (l *LambdaWriter) write(level zerolog.Level, log []byte) (int, error) {
+	c := l.writer
+	if ok, err := c.screen(context.Background(), level, log, ""); !ok {
+		return c.writeResult(log, err)
+	}
+	return c.writeResult(log, l.add(log, ""))
+}
+
+// writeSummary adds a log standing for several others to the batch, see
+// CloudWatchWriter.writeSummary.
+func (l *LambdaWriter) writeSummary(_ context.Context, log string) error {
+	return l.add(nil, log)
+}
+
+// add adds the log events for the log, or the text if it isn't empty, see
+// CloudWatchWriter.logEvents, to the batch, first sending the batch if they
+// don't fit in it.
+func (l *LambdaWriter) add(log []byte, text string) error {
+	c := l.writer
+	buf, err := c.logEvents(log, text)
+	if err != nil {
+		return err
+	}
+	defer buf.release()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+
+	for _, event := range buf.events {
+		if !l.current.fits(event) || l.current.full() {
+			if err = c.sendBatch(l.current.take()); err != nil {
+				return err
+			}
+		}
+		l.current.add(event)
+	}
+	return nil
+}
+
+// WriteLevel implements the zerolog.LevelWriter interface, discarding logs
+// below the writer's minimum level, see WithMinLevel.
+func (l *LambdaWriter) WriteLevel(level zerolog.Level, log []byte) (int, error) {
+	if !l.writer.Enabled(level) {
+		return len(log), nil
+	}
+	if l.writer.splitLines {
+		return writeLines(log, func(line []byte) error {
+			_, err := l.write(level, line)
+			return err
+		})
+	}
+	return l.write(level, log)
+}
+
+// Enabled reports whether logs at the given level are sent by WriteLevel,
+// rather than discarded.
+func (l *LambdaWriter) Enabled(level zerolog.Level) bool {
+	return l.writer.Enabled(level)
+}
+
+// FlushSync sends the logs written since the last batch was sent, including
+// those counted rather than added to it, see WithDuplicateSuppression and
+// WithAggregation, on the calling goroutine, and returns the error from
+// sending them. It should be called before the handler returns. It doesn't
+// make a request if there are no logs to send.
+func (l *LambdaWriter) FlushSync() error {
+	l.writer.endRepeats()
+	l.writer.endAggregation()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.writer.sendBatch(l.current.take())
+}
+
+// Stats returns the writer's counters.
+func (l *LambdaWriter) Stats() Stats {
+	return l.writer.Stats()
+}
+
+// Close sends the remaining logs, like FlushSync, then makes any later Write
+// return ErrClosed.
+func (l *LambdaWriter) Close() error {
+	l.writer.endRepeats()
+	l.writer.endAggregation()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.writer.sendBatch(l.current.take())
+	l.closed = true
+	l.writer.cancel()
+	return err
+}
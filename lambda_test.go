@@ -0,0 +1,102 @@
+package cloudwatchwriter_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestLambdaWriter(t *testing.T) {
+	client := &mockClient{}
+	lambdaWriter, err := cloudwatchwriter.NewLambdaWithClient(client, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewLambdaWithClient: %v", err)
+	}
+
+	// An invocation's logs are sent together by FlushSync
+	for i := 0; i < 3; i++ {
+		_, err = lambdaWriter.Write([]byte(fmt.Sprintf("Test message %d", i)))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 0, client.numPutLogEventsCalls())
+	assert.NoError(t, lambdaWriter.FlushSync())
+	assert.Equal(t, 3, client.numLogs())
+	assert.Equal(t, 1, client.numPutLogEventsCalls())
+
+	// Nothing is sent when there is nothing to send
+	assert.NoError(t, lambdaWriter.FlushSync())
+	assert.Equal(t, 1, client.numPutLogEventsCalls())
+
+	// Close sends the rest
+	_, err = lambdaWriter.Write([]byte("last"))
+	assert.NoError(t, err)
+	assert.NoError(t, lambdaWriter.Close())
+	assert.Equal(t, 4, client.numLogs())
+
+	_, err = lambdaWriter.Write([]byte("too late"))
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrClosed))
+}
+
+func TestLambdaWriterFullBatch(t *testing.T) {
+	client := &mockClient{}
+	lambdaWriter, err := cloudwatchwriter.NewLambdaWithClient(client, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewLambdaWithClient: %v", err)
+	}
+	defer lambdaWriter.Close()
+
+	// A full batch is sent by Write
+	for i := 0; i < 10001; i++ {
+		if _, err = lambdaWriter.Write([]byte("hello")); err != nil {
+			t.Fatalf("lambdaWriter.Write: %v", err)
+		}
+	}
+	assert.Equal(t, 10000, client.numLogs())
+	assert.NoError(t, lambdaWriter.FlushSync())
+	assert.Equal(t, 10001, client.numLogs())
+}
+
+func TestLambdaWriterFlushSyncError(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+	lambdaWriter, err := cloudwatchwriter.NewLambdaWithClient(client, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+	)
+	if err != nil {
+		t.Fatalf("NewLambdaWithClient: %v", err)
+	}
+	defer lambdaWriter.Close()
+
+	_, err = lambdaWriter.Write([]byte("hello world"))
+	assert.NoError(t, err)
+	assert.Error(t, lambdaWriter.FlushSync())
+}
+
+func TestLambdaWriterAggregation(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	lambdaWriter, err := cloudwatchwriter.NewLambdaWithClient(client, "logGroup", "logStream",
+		cloudwatchwriter.WithAggregation(time.Minute, nil),
+	)
+	if err != nil {
+		t.Fatalf("NewLambdaWithClient: %v", err)
+	}
+	defer lambdaWriter.Close()
+
+	for _, log := range []string{"retry 1 of 3", "retry 2 of 3", "retry 3 of 3"} {
+		_, err = lambdaWriter.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	// The summary is sent by FlushSync with the batch
+	assert.NoError(t, lambdaWriter.FlushSync())
+
+	assert.Equal(t, []string{
+		"retry 1 of 3",
+		`{"aggregated":2,"message":"retry 3 of 3"}`,
+	}, client.Messages("logGroup", "logStream"))
+}
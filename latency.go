@@ -0,0 +1,64 @@
+package cloudwatchwriter
+
+import (
+	"sort"
+	"time"
+)
+
+// latencyWindow is the number of the most recently delivered log events whose
+// delivery latencies are kept for Stats.
+const latencyWindow = 1024
+
+// latencies are the delivery latencies of the most recently delivered log
+// events, in a circular buffer.
+type latencies struct {
+	samples []time.Duration
+	next    int
+}
+
+func (l *latencies) add(latency time.Duration) {
+	if len(l.samples) < latencyWindow {
+		l.samples = append(l.samples, latency)
+		return
+	}
+	l.samples[l.next] = latency
+	l.next = (l.next + 1) % latencyWindow
+}
+
+// summary returns the median, 95th percentile and maximum of the latencies,
+// which are zero if there aren't any.
+func (l *latencies) summary() (p50, p95, maximum time.Duration) {
+	if len(l.samples) == 0 {
+		return 0, 0, 0
+	}
+
+	sorted := make([]time.Duration, len(l.samples))
+	copy(sorted, l.samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return percentile(sorted, 50), percentile(sorted, 95), sorted[len(sorted)-1]
+}
+
+// percentile returns the pth percentile of the sorted latencies, by the
+// nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// delivered records the delivery latencies of the events of a batch which
+// CloudWatch has accepted.
+func (c *CloudWatchWriter) delivered(queued []queuedEvent) {
+	now := c.clock.Now()
+
+	c.Lock()
+	defer c.Unlock()
+
+	for _, event := range queued {
+		c.latencies.add(now.Sub(event.at))
+	}
+}
@@ -0,0 +1,41 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterDeliveryLatency(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithManualPump(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	stats := cloudWatchWriter.Stats()
+	assert.Zero(t, stats.DeliveryLatencyP50)
+	assert.Zero(t, stats.DeliveryLatencyP95)
+	assert.Zero(t, stats.DeliveryLatencyMax)
+
+	for i := 0; i < 20; i++ {
+		_, err = cloudWatchWriter.Write([]byte("log"))
+		assert.NoError(t, err)
+		clock.Advance(time.Second)
+	}
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	// The logs took from 1 to 20 seconds
+	stats = cloudWatchWriter.Stats()
+	assert.Equal(t, 10*time.Second, stats.DeliveryLatencyP50)
+	assert.Equal(t, 19*time.Second, stats.DeliveryLatencyP95)
+	assert.Equal(t, 20*time.Second, stats.DeliveryLatencyMax)
+}
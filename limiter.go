@@ -0,0 +1,117 @@
+package cloudwatchwriter
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// Limiter limits the rate of the PutLogEvents requests of the writers it is
+// shared by, and the bytes of logs they send, such as the writers of the
+// tenants of a multi-tenant program, so that together they stay under the
+// account's quotas. Batches wait for it before being sent, rather than being
+// throttled by CloudWatch. It is safe for concurrent use.
+type Limiter struct {
+	requestsPerSecond float64
+	bytesPerSecond    float64
+
+	mu sync.Mutex
+	// requests and bytes are the tokens in the buckets at last, which go
+	// negative when batches are waiting for them.
+	requests float64
+	bytes    float64
+	last     time.Time
+}
+
+// NewLimiter returns a Limiter allowing requestsPerSecond PutLogEvents
+// requests, and bytesPerSecond bytes of logs, counted as CloudWatch counts
+// the size of a batch, per second on average, for use with WithLimiter. A
+// limit of zero means no limit. Bursts of up to a second's worth are allowed,
+// and of at least one request and one full batch.
+func NewLimiter(requestsPerSecond, bytesPerSecond float64) *Limiter {
+	return &Limiter{
+		requestsPerSecond: requestsPerSecond,
+		bytesPerSecond:    bytesPerSecond,
+		requests:          requestBurst(requestsPerSecond),
+		bytes:             byteBurst(bytesPerSecond),
+	}
+}
+
+func requestBurst(perSecond float64) float64 {
+	return max(perSecond, 1)
+}
+
+func byteBurst(perSecond float64) float64 {
+	return max(perSecond, batchSizeLimit)
+}
+
+func (l *Limiter) validate() error {
+	if l.requestsPerSecond < 0 || l.bytesPerSecond < 0 {
+		return errors.New("limiter rates must not be negative")
+	}
+	return nil
+}
+
+// reserve takes a request and the bytes from the buckets, at now, and returns
+// how long to wait for them to have been available.
+func (l *Limiter) reserve(now time.Time, bytes int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() && now.After(l.last) {
+		elapsed := now.Sub(l.last).Seconds()
+		l.requests = min(requestBurst(l.requestsPerSecond), l.requests+elapsed*l.requestsPerSecond)
+		l.bytes = min(byteBurst(l.bytesPerSecond), l.bytes+elapsed*l.bytesPerSecond)
+	}
+	if l.last.IsZero() || now.After(l.last) {
+		l.last = now
+	}
+
+	var wait time.Duration
+	if l.requestsPerSecond > 0 {
+		l.requests--
+		wait = max(wait, deficit(l.requests, l.requestsPerSecond))
+	}
+	if l.bytesPerSecond > 0 {
+		l.bytes -= float64(bytes)
+		wait = max(wait, deficit(l.bytes, l.bytesPerSecond))
+	}
+	return wait
+}
+
+// deficit returns how long it takes for a bucket of tokens to stop being
+// negative.
+func deficit(tokens, perSecond float64) time.Duration {
+	if tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tokens / perSecond * float64(time.Second))
+}
+
+// waitForLimiter waits for the limiter, if there is one, to allow the batch to
+// be sent, returning an error if the writer abandons sending logs meanwhile.
+func (c *CloudWatchWriter) waitForLimiter(batch []types.InputLogEvent) error {
+	if c.limiter == nil {
+		return nil
+	}
+
+	size := 0
+	for _, event := range batch {
+		size += len(*event.Message) + additionalBytesPerLogEvent
+	}
+	wait := c.limiter.reserve(c.clock.Now(), size)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := c.clock.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
@@ -0,0 +1,95 @@
+package cloudwatchwriter_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterLimiter(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	limiter := cloudwatchwriter.NewLimiter(1, 0)
+	newWriter := func(logStreamName string) *cloudwatchwriter.CloudWatchWriter {
+		cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", logStreamName,
+			cloudwatchwriter.WithClock(clock),
+			cloudwatchwriter.WithManualPump(),
+			cloudwatchwriter.WithLimiter(limiter),
+		)
+		if err != nil {
+			t.Fatalf("NewWithClient: %v", err)
+		}
+		return cloudWatchWriter
+	}
+	first, second := newWriter("first"), newWriter("second")
+	defer first.Close()
+	defer second.Close()
+
+	_, err := first.Write([]byte("log"))
+	assert.NoError(t, err)
+	assert.NoError(t, first.Pump())
+	assert.Equal(t, 1, client.PutLogEventsCalls())
+
+	// The second writer waits for the limiter, which the first has used up
+	_, err = second.Write([]byte("log"))
+	assert.NoError(t, err)
+	pumped := make(chan error)
+	go func() {
+		pumped <- second.Pump()
+	}()
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	assert.Equal(t, 1, client.PutLogEventsCalls())
+
+	clock.Advance(time.Second)
+	assert.NoError(t, <-pumped)
+	assert.Equal(t, []string{"log"}, client.Messages("logGroup", "second"))
+}
+
+func TestCloudWatchWriterLimiterBytes(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithLimiter(cloudwatchwriter.NewLimiter(0, 1024*1024)),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	writeLogs := func() {
+		for i := 0; i < 4; i++ {
+			_, err := cloudWatchWriter.Write([]byte(strings.Repeat("a", 200*1024)))
+			assert.NoError(t, err)
+		}
+	}
+
+	// Up to a second's worth of bytes can be sent straight away
+	writeLogs()
+	assert.NoError(t, cloudWatchWriter.Pump())
+	assert.Equal(t, 1, client.PutLogEventsCalls())
+
+	writeLogs()
+	pumped := make(chan error)
+	go func() {
+		pumped <- cloudWatchWriter.Pump()
+	}()
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	assert.Equal(t, 1, client.PutLogEventsCalls())
+
+	clock.Advance(time.Second)
+	assert.NoError(t, <-pumped)
+	assert.Equal(t, 2, client.PutLogEventsCalls())
+}
+
+func TestCloudWatchWriterLimiterNegative(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithLimiter(cloudwatchwriter.NewLimiter(1, -1)),
+	)
+	assert.EqualError(t, err, "limiter rates must not be negative")
+}
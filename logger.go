@@ -0,0 +1,24 @@
+package cloudwatchwriter
+
+// Logger is told about what the writer is doing internally, such as retrying
+// or throttled requests and log streams being created again, see
+// WithInternalLogger. The standard library's *testing.T is a Logger, and
+// log.Printf can be used as one with LoggerFunc.
+type Logger interface {
+	Logf(format string, args ...interface{})
+}
+
+// LoggerFunc is a function which is a Logger.
+type LoggerFunc func(format string, args ...interface{})
+
+// Logf calls f.
+func (f LoggerFunc) Logf(format string, args ...interface{}) {
+	f(format, args...)
+}
+
+// logf passes a message to the internal logger, if there is one.
+func (c *CloudWatchWriter) logf(format string, args ...interface{}) {
+	if c.logger != nil {
+		c.logger.Logf("cloudwatchwriter: "+format, args...)
+	}
+}
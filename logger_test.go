@@ -0,0 +1,67 @@
+package cloudwatchwriter_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+type recordingLogger struct {
+	sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Logf(format string, args ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) getMessages() []string {
+	l.Lock()
+	defer l.Unlock()
+	return append([]string(nil), l.messages...)
+}
+
+func TestCloudWatchWriterInternalLogger(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}},
+	}
+	logger := &recordingLogger{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+		}),
+		cloudwatchwriter.WithInternalLogger(logger),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	cloudWatchWriter.Close()
+
+	messages := logger.getMessages()
+	if assert.Len(t, messages, 3) {
+		assert.Equal(t, "cloudwatchwriter: creating log group logGroup", messages[0])
+		assert.Equal(t, "cloudwatchwriter: creating log stream logStream", messages[1])
+		assert.Contains(t, messages[2], "cloudwatchwriter: retrying sending 1 logs")
+		assert.Contains(t, messages[2], "internal server error")
+	}
+}
+
+func TestLoggerFunc(t *testing.T) {
+	var logged string
+	logger := cloudwatchwriter.LoggerFunc(func(format string, args ...interface{}) {
+		logged = fmt.Sprintf(format, args...)
+	})
+
+	logger.Logf("hello %s", "world")
+	assert.Equal(t, "hello world", logged)
+}
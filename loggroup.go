@@ -0,0 +1,187 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// retentionDays are the numbers of days that CloudWatch accepts as the
+// retention period of a log group.
+var retentionDays = []int32{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
+// retentionPolicyPutter is implemented by clients which can set the retention
+// period of a log group, such as *cloudwatchlogs.Client. It isn't part of
+// CloudWatchLogsClient, as only WithRetentionDays needs it.
+type retentionPolicyPutter interface {
+	PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error)
+}
+
+// logGroupDescriber is implemented by clients which can look up log groups,
+// such as *cloudwatchlogs.Client, and resourceTagger by those which can tag
+// them. Only WithLogGroupTags needs both, so they aren't part of
+// CloudWatchLogsClient either.
+type logGroupDescriber interface {
+	DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error)
+}
+
+type resourceTagger interface {
+	TagResource(ctx context.Context, params *cloudwatchlogs.TagResourceInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagResourceOutput, error)
+}
+
+func validRetentionDays(days int32) bool {
+	for _, d := range retentionDays {
+		if d == days {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLogGroupName returns the name of the log group identified by either its
+// name or its ARN, such as arn:aws:logs:eu-west-2:123456789012:log-group:name,
+// with or without the ":*" suffix which DescribeLogGroups adds, and the ARN if
+// it was given one. PutLogEvents and CreateLogStream only accept a log group
+// name, so only the name is used in the requests, which go to the account and
+// region of the client, see checkLogGroupARN.
+func parseLogGroupName(logGroupIdentifier string) (string, *arn.ARN, error) {
+	if !arn.IsARN(logGroupIdentifier) {
+		return logGroupIdentifier, nil, nil
+	}
+
+	parsed, err := arn.Parse(logGroupIdentifier)
+	if err != nil {
+		return "", nil, fmt.Errorf("parse log group ARN: %s: %w", logGroupIdentifier, err)
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(parsed.Resource, "log-group:"), ":*")
+	if parsed.Service != "logs" || !strings.HasPrefix(parsed.Resource, "log-group:") || name == "" || strings.Contains(name, ":") {
+		return "", nil, fmt.Errorf("not a log group ARN: %s", logGroupIdentifier)
+	}
+	return name, &parsed, nil
+}
+
+// checkLogGroupARN makes sure that a log group given by its ARN is the one the
+// logs are sent to, by looking up the log group with its name in the account
+// and region of the client, and comparing their ARNs. Otherwise the logs would
+// go to another log group with the same name, or one the writer created.
+func (c *CloudWatchWriter) checkLogGroupARN(ctx context.Context) error {
+	if c.logGroupARN == nil {
+		return nil
+	}
+
+	logGroup, err := c.findLogGroup(ctx)
+	if err != nil {
+		return fmt.Errorf("cloudwatchlogs.Client.DescribeLogGroups: %w", err)
+	}
+	if logGroup == nil {
+		return fmt.Errorf("log group not found in the account and region of the client: %s", c.logGroupARN)
+	}
+	found, err := arn.Parse(aws.ToString(logGroup.LogGroupArn))
+	if err != nil {
+		return fmt.Errorf("parse log group ARN: %s: %w", aws.ToString(logGroup.LogGroupArn), err)
+	}
+	if found.Partition != c.logGroupARN.Partition || found.Region != c.logGroupARN.Region || found.AccountID != c.logGroupARN.AccountID {
+		return fmt.Errorf("log group %s is not in the account and region of the client, which has %s", c.logGroupARN, found)
+	}
+	return nil
+}
+
+// createLogGroup creates the log group, with the tags, the log group class and
+// the retention period if they have been set. A log group which already exists is left as it is, as
+// it may belong to someone else. A log group given by its ARN is never
+// created, as it may be in another account.
+func (c *CloudWatchWriter) createLogGroup(ctx context.Context) error {
+	if c.logGroupARN != nil {
+		return fmt.Errorf("log group not found: %s", c.logGroupARN)
+	}
+	c.logf("creating log group %s", *c.logGroupName)
+	_, err := c.client.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName:  c.logGroupName,
+		Tags:          c.logGroupTags,
+		LogGroupClass: c.logGroupClass,
+	})
+	if err != nil {
+		if isAlreadyExists(err) {
+			return nil
+		}
+		return wrapCreateErr(err, "cloudwatchlogs.Client.CreateLogGroup")
+	}
+	c.logGroupCreated = true
+
+	if c.retentionDays > 0 {
+		// The constructor checked that the client implements it
+		_, err = c.client.(retentionPolicyPutter).PutRetentionPolicy(ctx, &cloudwatchlogs.PutRetentionPolicyInput{
+			LogGroupName:    c.logGroupName,
+			RetentionInDays: aws.Int32(c.retentionDays),
+		})
+		if err != nil {
+			return fmt.Errorf("cloudwatchlogs.Client.PutRetentionPolicy: %w", err)
+		}
+	}
+	return nil
+}
+
+// tagLogGroup adds the tags to the log group, if the writer didn't create it
+// with them. TagResource needs the ARN of the log group, which is looked up
+// with DescribeLogGroups.
+func (c *CloudWatchWriter) tagLogGroup(ctx context.Context) error {
+	if len(c.logGroupTags) == 0 || c.logGroupCreated {
+		return nil
+	}
+
+	logGroup, err := c.findLogGroup(ctx)
+	if err != nil {
+		return fmt.Errorf("cloudwatchlogs.Client.DescribeLogGroups: %w", err)
+	}
+	if logGroup == nil {
+		return fmt.Errorf("log group not found: %s", aws.ToString(c.logGroupName))
+	}
+
+	// The constructor checked that the client implements it
+	_, err = c.client.(resourceTagger).TagResource(ctx, &cloudwatchlogs.TagResourceInput{
+		ResourceArn: logGroup.LogGroupArn,
+		Tags:        c.logGroupTags,
+	})
+	if err != nil {
+		return fmt.Errorf("cloudwatchlogs.Client.TagResource: %w", err)
+	}
+	return nil
+}
+
+// findLogGroup goes through the pages of log groups whose names start with our
+// log group name and returns the one with exactly our name, or nil if there
+// isn't one. The client must implement DescribeLogGroups.
+func (c *CloudWatchWriter) findLogGroup(ctx context.Context) (*types.LogGroup, error) {
+	describer, ok := c.client.(logGroupDescriber)
+	if !ok {
+		return nil, unsupportedOperation("DescribeLogGroups")
+	}
+	input := &cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: c.logGroupName,
+	}
+	for {
+		output, err := describer.DescribeLogGroups(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if output == nil {
+			return nil, nil
+		}
+
+		for _, logGroup := range output.LogGroups {
+			if aws.ToString(logGroup.LogGroupName) == aws.ToString(c.logGroupName) {
+				return &logGroup, nil
+			}
+		}
+
+		if aws.ToString(output.NextToken) == "" || aws.ToString(output.NextToken) == aws.ToString(input.NextToken) {
+			return nil, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
@@ -0,0 +1,183 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterRetentionDays(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetentionDays(30),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	assert.Equal(t, aws.Int32(30), client.getRetentionInDays())
+}
+
+func TestCloudWatchWriterRetentionDaysExistingLogGroup(t *testing.T) {
+	client := &mockClient{
+		logGroupName: aws.String("logGroup"),
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetentionDays(30),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// Someone else's log group is left alone
+	assert.Nil(t, client.getRetentionInDays())
+}
+
+func TestCloudWatchWriterInvalidRetentionDays(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetentionDays(31),
+	)
+	assert.Error(t, err)
+}
+
+// basicClient only has the methods of CloudWatchLogsClient, hiding the
+// optional ones of the client it wraps.
+type basicClient struct {
+	cloudwatchwriter.CloudWatchLogsClient
+}
+
+func TestCloudWatchWriterRetentionDaysUnsupportedClient(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(basicClient{&mockClient{}}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetentionDays(30),
+	)
+	assert.Error(t, err)
+
+	// Without the option the client is enough
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(basicClient{&mockClient{}}, 200*time.Millisecond, "logGroup", "logStream")
+	if assert.NoError(t, err) {
+		cloudWatchWriter.Close()
+	}
+}
+
+func TestCloudWatchWriterLogGroupTagsUnsupportedClient(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(basicClient{&mockClient{}}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithLogGroupTags(map[string]string{"team": "platform"}),
+	)
+	assert.Error(t, err)
+}
+
+func TestCloudWatchWriterLogGroupTags(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithLogGroupTags(map[string]string{"team": "platform"}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	assert.Equal(t, map[string]string{"team": "platform"}, client.getLogGroupTags())
+}
+
+func TestCloudWatchWriterLogGroupTagsExistingLogGroup(t *testing.T) {
+	client := &mockClient{
+		logGroupName: aws.String("logGroup"),
+		logGroupTags: map[string]string{"owner": "someone"},
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithLogGroupTags(map[string]string{"team": "platform"}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	assert.Equal(t, map[string]string{"owner": "someone", "team": "platform"}, client.getLogGroupTags())
+}
+
+func TestCloudWatchWriterLogGroupClass(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithLogGroupClass(types.LogGroupClassInfrequentAccess),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	assert.Equal(t, types.LogGroupClassInfrequentAccess, client.getLogGroupClass())
+}
+
+func TestCloudWatchWriterLogGroupArn(t *testing.T) {
+	for _, logGroupIdentifier := range []string{
+		"arn:aws:logs:eu-west-2:123456789012:log-group:logGroup",
+		"arn:aws:logs:eu-west-2:123456789012:log-group:logGroup:*",
+	} {
+		client := &mockClient{
+			logGroupName: aws.String("logGroup"),
+		}
+
+		cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, logGroupIdentifier, "logStream")
+		if err != nil {
+			t.Fatalf("NewWithClient: %v", err)
+		}
+		helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+		cloudWatchWriter.Close()
+
+		assert.Equal(t, "logGroup", client.getLogGroupName())
+		assert.Equal(t, 1, client.numLogs())
+	}
+}
+
+func TestCloudWatchWriterLogGroupArnNotFound(t *testing.T) {
+	client := &mockClient{}
+
+	_, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "arn:aws:logs:eu-west-2:123456789012:log-group:logGroup", "logStream")
+	assert.Error(t, err)
+
+	// The log group may be in another account, so it isn't created in the
+	// client's
+	assert.Equal(t, "", client.getLogGroupName())
+}
+
+func TestCloudWatchWriterLogGroupArnOtherAccount(t *testing.T) {
+	for _, logGroupIdentifier := range []string{
+		"arn:aws:logs:eu-west-2:210987654321:log-group:logGroup",
+		"arn:aws:logs:us-east-1:123456789012:log-group:logGroup",
+	} {
+		client := &mockClient{
+			logGroupName: aws.String("logGroup"),
+		}
+
+		_, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, logGroupIdentifier, "logStream")
+		assert.Error(t, err, logGroupIdentifier)
+		assert.Equal(t, 0, client.numCreateLogStreamCalls(), logGroupIdentifier)
+	}
+}
+
+func TestCloudWatchWriterLogGroupArnUnsupportedClient(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(basicClient{&mockClient{}}, 200*time.Millisecond, "arn:aws:logs:eu-west-2:123456789012:log-group:logGroup", "logStream")
+	assert.Error(t, err)
+}
+
+func TestCloudWatchWriterInvalidLogGroupArn(t *testing.T) {
+	for _, logGroupIdentifier := range []string{
+		"arn:aws:s3:::bucket",
+		"arn:aws:logs:eu-west-2:123456789012:log-group:logGroup:log-stream:logStream",
+		"arn:aws:logs:eu-west-2:123456789012:log-group:",
+	} {
+		_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, logGroupIdentifier, "logStream")
+		assert.Error(t, err, logGroupIdentifier)
+	}
+}
@@ -0,0 +1,97 @@
+package cloudwatchwriter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterSetLogStream(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	client.RequireSequenceTokens()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "first")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	for _, message := range []string{"one", "two"} {
+		_, err = cloudWatchWriter.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+	// The logs written before the switch go to the old log stream
+	assert.NoError(t, cloudWatchWriter.SetLogStream("second"))
+	assert.Equal(t, []string{"one", "two"}, client.Messages("logGroup", "first"))
+
+	_, err = cloudWatchWriter.Write([]byte("three"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, []string{"three"}, client.Messages("logGroup", "second"))
+
+	// Switching back carries on with the old log stream's sequence token
+	assert.NoError(t, cloudWatchWriter.SetLogStream("first"))
+	_, err = cloudWatchWriter.Write([]byte("four"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.CloseWithContext(context.Background()))
+	assert.Equal(t, []string{"one", "two", "four"}, client.Messages("logGroup", "first"))
+
+	assert.True(t, errors.Is(cloudWatchWriter.SetLogStream("third"), cloudwatchwriter.ErrClosed))
+}
+
+func TestCloudWatchWriterSetLogStreamError(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	denied := false
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithMiddleware(func(next cloudwatchwriter.Call) cloudwatchwriter.Call {
+			return func(ctx context.Context, operation string, input interface{}) (interface{}, error) {
+				if operation == "CreateLogStream" && denied {
+					return nil, errors.New("denied")
+				}
+				return next(ctx, operation, input)
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// The log stream can't be created, so the writer carries on with the
+	// current one
+	denied = true
+	assert.Error(t, cloudWatchWriter.SetLogStream("other"))
+
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, []string{"log"}, client.Messages("logGroup", "logStream"))
+}
+
+func TestCloudWatchWriterLogStreamLookupOrder(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	for _, logStreamName := range []string{"first", "second"} {
+		// Ordered by last event time, the log stream is looked up without a
+		// prefix, which the client would reject, and created the first time
+		cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", logStreamName,
+			cloudwatchwriter.WithLogStreamLookupOrder(types.OrderByLastEventTime, true),
+		)
+		if err != nil {
+			t.Fatalf("NewWithClient: %v", err)
+		}
+		_, err = cloudWatchWriter.Write([]byte("hello " + logStreamName))
+		assert.NoError(t, err)
+		cloudWatchWriter.Close()
+	}
+	assert.Equal(t, []string{"hello first"}, client.Messages("logGroup", "first"))
+	assert.Equal(t, []string{"hello second"}, client.Messages("logGroup", "second"))
+
+	_, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "first",
+		cloudwatchwriter.WithLogStreamLookupOrder("CreationTime", false),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package cloudwatchwriter
+
+import (
+	"bytes"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// buildInfo is what the session marker logs say about the program.
+type buildInfo struct {
+	version   string
+	commit    string
+	goVersion string
+}
+
+// readBuildInfo returns the program's module version and the VCS revision it
+// was built from, when the go command recorded them, and the Go version.
+func readBuildInfo() buildInfo {
+	info := buildInfo{goVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.version = build.Main.Version
+	for _, setting := range build.Settings {
+		if setting.Key == "vcs.revision" {
+			info.commit = setting.Value
+		}
+	}
+	return info
+}
+
+// writeSessionStart writes the log marking the start of the writer's session,
+// see WithSessionMarkers.
+func (c *CloudWatchWriter) writeSessionStart() {
+	c.sessionStarted = c.clock.Now()
+	_ = c.enqueueLog(c.ctx, c.sessionLog("start", -1))
+}
+
+// writeSessionStop writes the log marking the end of the writer's session,
+// the first time it is called.
+func (c *CloudWatchWriter) writeSessionStop() {
+	c.sessionStop.Do(func() {
+		_ = c.enqueueLog(c.ctx, c.sessionLog("stop", c.clock.Now().Sub(c.sessionStarted)))
+	})
+}
+
+// sessionLog returns a session marker log, with how long the session lasted
+// if uptime isn't negative.
+func (c *CloudWatchWriter) sessionLog(session string, uptime time.Duration) string {
+	info := readBuildInfo()
+
+	var log bytes.Buffer
+	logger := zerolog.New(&log)
+	event := logger.Info().
+		Str("session", session).
+		Str("version", info.version).
+		Str("commit", info.commit).
+		Str("go_version", info.goVersion).
+		Str("hostname", hostname())
+	if uptime >= 0 {
+		event = event.Int64("uptime_seconds", int64(uptime/time.Second))
+	}
+	event.Msg("session " + session)
+	return log.String()
+}
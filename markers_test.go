@@ -0,0 +1,57 @@
+package cloudwatchwriter_test
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterSessionMarkers(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithSessionMarkers(),
+		// Session markers aren't filtered
+		cloudwatchwriter.WithDenyFilters(cloudwatchwriter.FieldEquals("session", "start")),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+	clock.Advance(90 * time.Second)
+	cloudWatchWriter.Close()
+	// Closing again doesn't write another marker
+	cloudWatchWriter.Close()
+
+	messages := client.Messages("logGroup", "logStream")
+	if !assert.Len(t, messages, 3) {
+		return
+	}
+	assert.Equal(t, "log", messages[1])
+
+	hostname, _ := os.Hostname()
+	for i, session := range map[int]string{0: "start", 2: "stop"} {
+		var marker map[string]interface{}
+		if assert.NoError(t, json.Unmarshal([]byte(messages[i]), &marker)) {
+			assert.Equal(t, "info", marker["level"])
+			assert.Equal(t, session, marker["session"])
+			assert.Equal(t, "session "+session, marker["message"])
+			assert.Equal(t, runtime.Version(), marker["go_version"])
+			assert.Equal(t, hostname, marker["hostname"])
+			assert.Contains(t, marker, "version")
+			assert.Contains(t, marker, "commit")
+		}
+	}
+	assert.NotContains(t, messages[0], "uptime_seconds")
+	assert.Contains(t, messages[2], `"uptime_seconds":90`)
+}
@@ -0,0 +1,166 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+)
+
+// Call makes a call to the CloudWatch Logs API. The operation is the name of
+// the API operation, such as "PutLogEvents", the input is its input, such as a
+// *cloudwatchlogs.PutLogEventsInput, and the output is its output, such as a
+// *cloudwatchlogs.PutLogEventsOutput.
+type Call func(ctx context.Context, operation string, input interface{}) (output interface{}, err error)
+
+// Middleware wraps the calls the writer makes to CloudWatch Logs, see
+// WithMiddleware.
+type Middleware func(next Call) Call
+
+// middlewareClient is a CloudWatchLogsClient which makes its calls through the
+// middleware.
+type middlewareClient struct {
+	call Call
+}
+
+func newMiddlewareClient(client CloudWatchLogsClient, middleware []Middleware) *middlewareClient {
+	call := clientCall(client)
+	// The first middleware is the outermost one
+	for i := len(middleware) - 1; i >= 0; i-- {
+		call = middleware[i](call)
+	}
+	return &middlewareClient{call: call}
+}
+
+// clientCall returns the Call which calls the client, at the end of the chain
+// of middleware.
+func clientCall(client CloudWatchLogsClient) Call {
+	return func(ctx context.Context, operation string, input interface{}) (interface{}, error) {
+		switch params := input.(type) {
+		case *cloudwatchlogs.CreateLogGroupInput:
+			return client.CreateLogGroup(ctx, params)
+		case *cloudwatchlogs.CreateLogStreamInput:
+			return client.CreateLogStream(ctx, params)
+		case *cloudwatchlogs.DescribeLogGroupsInput:
+			describer, ok := client.(logGroupDescriber)
+			if !ok {
+				return nil, unsupportedOperation(operation)
+			}
+			return describer.DescribeLogGroups(ctx, params)
+		case *cloudwatchlogs.DescribeLogStreamsInput:
+			return client.DescribeLogStreams(ctx, params)
+		case *cloudwatchlogs.PutLogEventsInput:
+			return client.PutLogEvents(ctx, params)
+		case *cloudwatchlogs.PutRetentionPolicyInput:
+			putter, ok := client.(retentionPolicyPutter)
+			if !ok {
+				return nil, unsupportedOperation(operation)
+			}
+			return putter.PutRetentionPolicy(ctx, params)
+		case *cloudwatchlogs.TagResourceInput:
+			tagger, ok := client.(resourceTagger)
+			if !ok {
+				return nil, unsupportedOperation(operation)
+			}
+			return tagger.TagResource(ctx, params)
+		default:
+			return nil, fmt.Errorf("%s: unexpected input %T", operation, input)
+		}
+	}
+}
+
+// unsupportedOperation returns the error for an operation which the client
+// doesn't implement, as it isn't part of CloudWatchLogsClient.
+func unsupportedOperation(operation string) error {
+	return fmt.Errorf("%s: not implemented by the client", operation)
+}
+
+// unexpectedOutput returns the error for a middleware returning the wrong type
+// of output for an operation.
+func unexpectedOutput(operation string, output interface{}) error {
+	return fmt.Errorf("%s: unexpected output %T", operation, output)
+}
+
+func (c *middlewareClient) CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	out, err := c.call(ctx, "CreateLogGroup", params)
+	if err != nil {
+		return nil, err
+	}
+	output, ok := out.(*cloudwatchlogs.CreateLogGroupOutput)
+	if !ok && out != nil {
+		return nil, unexpectedOutput("CreateLogGroup", out)
+	}
+	return output, nil
+}
+
+func (c *middlewareClient) CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	out, err := c.call(ctx, "CreateLogStream", params)
+	if err != nil {
+		return nil, err
+	}
+	output, ok := out.(*cloudwatchlogs.CreateLogStreamOutput)
+	if !ok && out != nil {
+		return nil, unexpectedOutput("CreateLogStream", out)
+	}
+	return output, nil
+}
+
+func (c *middlewareClient) DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	out, err := c.call(ctx, "DescribeLogGroups", params)
+	if err != nil {
+		return nil, err
+	}
+	output, ok := out.(*cloudwatchlogs.DescribeLogGroupsOutput)
+	if !ok && out != nil {
+		return nil, unexpectedOutput("DescribeLogGroups", out)
+	}
+	return output, nil
+}
+
+func (c *middlewareClient) DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	out, err := c.call(ctx, "DescribeLogStreams", params)
+	if err != nil {
+		return nil, err
+	}
+	output, ok := out.(*cloudwatchlogs.DescribeLogStreamsOutput)
+	if !ok && out != nil {
+		return nil, unexpectedOutput("DescribeLogStreams", out)
+	}
+	return output, nil
+}
+
+func (c *middlewareClient) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	out, err := c.call(ctx, "PutLogEvents", params)
+	if err != nil {
+		return nil, err
+	}
+	output, ok := out.(*cloudwatchlogs.PutLogEventsOutput)
+	if !ok && out != nil {
+		return nil, unexpectedOutput("PutLogEvents", out)
+	}
+	return output, nil
+}
+
+func (c *middlewareClient) PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	out, err := c.call(ctx, "PutRetentionPolicy", params)
+	if err != nil {
+		return nil, err
+	}
+	output, ok := out.(*cloudwatchlogs.PutRetentionPolicyOutput)
+	if !ok && out != nil {
+		return nil, unexpectedOutput("PutRetentionPolicy", out)
+	}
+	return output, nil
+}
+
+func (c *middlewareClient) TagResource(ctx context.Context, params *cloudwatchlogs.TagResourceInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagResourceOutput, error) {
+	out, err := c.call(ctx, "TagResource", params)
+	if err != nil {
+		return nil, err
+	}
+	output, ok := out.(*cloudwatchlogs.TagResourceOutput)
+	if !ok && out != nil {
+		return nil, unexpectedOutput("TagResource", out)
+	}
+	return output, nil
+}
@@ -0,0 +1,110 @@
+package cloudwatchwriter_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterMiddleware(t *testing.T) {
+	client := &mockClient{}
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) cloudwatchwriter.Middleware {
+		return func(next cloudwatchwriter.Call) cloudwatchwriter.Call {
+			return func(ctx context.Context, operation string, input interface{}) (interface{}, error) {
+				mu.Lock()
+				calls = append(calls, name+" "+operation)
+				mu.Unlock()
+				return next(ctx, operation, input)
+			}
+		}
+	}
+	prefix := func(next cloudwatchwriter.Call) cloudwatchwriter.Call {
+		return func(ctx context.Context, operation string, input interface{}) (interface{}, error) {
+			if params, ok := input.(*cloudwatchlogs.PutLogEventsInput); ok {
+				for i := range params.LogEvents {
+					params.LogEvents[i].Message = aws.String("app: " + *params.LogEvents[i].Message)
+				}
+			}
+			return next(ctx, operation, input)
+		}
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithMiddleware(record("first"), record("second")),
+		cloudwatchwriter.WithMiddleware(prefix),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	events := client.getLogEvents()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "app: log", *events[0].Message)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"first DescribeLogStreams", "second DescribeLogStreams",
+		"first CreateLogGroup", "second CreateLogGroup",
+		"first DescribeLogStreams", "second DescribeLogStreams",
+		"first CreateLogStream", "second CreateLogStream",
+		"first PutLogEvents", "second PutLogEvents",
+	}, calls)
+}
+
+func TestCloudWatchWriterMiddlewareReturnsError(t *testing.T) {
+	client := &mockClient{}
+	errDenied := errors.New("not allowed")
+	deny := func(next cloudwatchwriter.Call) cloudwatchwriter.Call {
+		return func(ctx context.Context, operation string, input interface{}) (interface{}, error) {
+			if operation == "PutLogEvents" {
+				return nil, errDenied
+			}
+			return next(ctx, operation, input)
+		}
+	}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithMiddleware(deny),
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(cloudWatchWriter.Flush(), errDenied))
+	assert.Equal(t, 0, client.numPutLogEventsCalls())
+}
+
+func TestCloudWatchWriterMiddlewareWrongOutput(t *testing.T) {
+	wrongOutput := func(next cloudwatchwriter.Call) cloudwatchwriter.Call {
+		return func(ctx context.Context, operation string, input interface{}) (interface{}, error) {
+			if operation == "CreateLogStream" {
+				return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+			}
+			return next(ctx, operation, input)
+		}
+	}
+
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithMiddleware(wrongOutput),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// MirrorWriter sends every log to each of its CloudWatchWriters, such as
+// writers for log groups in two regions or two accounts, for setups which
+// require the logs to be kept in more than one place. Each writer has its own
+// queue, batches and retries, so one destination failing or being slow
+// doesn't hold up the others.
+type MirrorWriter struct {
+	writers []*CloudWatchWriter
+}
+
+// NewMirror returns a MirrorWriter sending every log to all of the writers.
+func NewMirror(writers ...*CloudWatchWriter) *MirrorWriter {
+	return &MirrorWriter{
+		writers: writers,
+	}
+}
+
+// Write writes the log to every writer, see CloudWatchWriter.Write. If any of
+// them return an error, then the first one is returned, after the log has been
+// written to the rest.
+func (m *MirrorWriter) Write(log []byte) (int, error) {
+	return m.WriteContext(context.Background(), log)
+}
+
+// WriteContext is like Write, see CloudWatchWriter.WriteContext.
+func (m *MirrorWriter) WriteContext(ctx context.Context, log []byte) (int, error) {
+	return m.each(func(c *CloudWatchWriter) (int, error) {
+		return c.WriteContext(ctx, log)
+	}, len(log))
+}
+
+// WriteLevel implements the zerolog.LevelWriter interface, each writer
+// discarding the log if it is below its minimum level.
+func (m *MirrorWriter) WriteLevel(level zerolog.Level, log []byte) (int, error) {
+	return m.each(func(c *CloudWatchWriter) (int, error) {
+		return c.WriteLevel(level, log)
+	}, len(log))
+}
+
+// Flush flushes every writer, see CloudWatchWriter.Flush, returning the first
+// error.
+func (m *MirrorWriter) Flush() error {
+	_, err := m.each(func(c *CloudWatchWriter) (int, error) {
+		return 0, c.Flush()
+	}, 0)
+	return err
+}
+
+// Close closes every writer, blocking until they have all completed writing
+// their logs to CloudWatch.
+func (m *MirrorWriter) Close() {
+	_ = m.CloseWithContext(context.Background())
+}
+
+// CloseWithContext closes every writer at the same time, see
+// CloudWatchWriter.CloseWithContext, returning the first error.
+func (m *MirrorWriter) CloseWithContext(ctx context.Context) error {
+	errs := make([]error, len(m.writers))
+	done := make(chan int)
+	for i, c := range m.writers {
+		go func(i int, c *CloudWatchWriter) {
+			errs[i] = c.CloseWithContext(ctx)
+			done <- i
+		}(i, c)
+	}
+	for range m.writers {
+		<-done
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("mirror %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// each calls fn with every writer, returning n and the first error.
+func (m *MirrorWriter) each(fn func(*CloudWatchWriter) (int, error), n int) (int, error) {
+	var firstErr error
+	for i, c := range m.writers {
+		if _, err := fn(c); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("mirror %d: %w", i, err)
+		}
+	}
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	return n, nil
+}
@@ -0,0 +1,68 @@
+package cloudwatchwriter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestMirrorWriter(t *testing.T) {
+	client1 := &mockClient{}
+	client2 := &mockClient{}
+
+	writer1, err := cloudwatchwriter.NewWithClient(client1, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	writer2, err := cloudwatchwriter.NewWithClient(client2, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	mirror := cloudwatchwriter.NewMirror(writer1, writer2)
+
+	logs := logsContainer{}
+	for i := 0; i < 3; i++ {
+		log := exampleLog{Message: "Test message"}
+		helperWriteLogs(t, mirror, log)
+		logs.addLog(log)
+	}
+	assert.NoError(t, mirror.Flush())
+
+	expectedLogs, err := logs.getLogEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualLogMessages(t, expectedLogs, client1.getLogEvents())
+	assertEqualLogMessages(t, expectedLogs, client2.getLogEvents())
+
+	assert.NoError(t, mirror.CloseWithContext(context.Background()))
+}
+
+func TestMirrorWriterIndependentRetries(t *testing.T) {
+	client1 := &mockClient{}
+	client2 := &mockClient{
+		putLogEventsShouldError: true,
+	}
+
+	writer1, err := cloudwatchwriter.NewWithClient(client1, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	writer2, err := cloudwatchwriter.NewWithClient(client2, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	mirror := cloudwatchwriter.NewMirror(writer1, writer2)
+	defer mirror.Close()
+
+	// The failing destination doesn't stop the logs going to the other one
+	helperWriteLogs(t, mirror, exampleLog{Message: "Test message"})
+	assert.Error(t, mirror.Flush())
+	assert.Equal(t, 1, client1.numLogs())
+	assert.Equal(t, 0, client2.numLogs())
+}
@@ -0,0 +1,702 @@
+package cloudwatchwriter
+
+import (
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/rs/zerolog"
+)
+
+// Option configures a CloudWatchWriter at construction time, see New and
+// NewWithClient.
+type Option func(*CloudWatchWriter)
+
+// WithBatchInterval sets the maximum time between batches of logs sent to
+// CloudWatch. It must not be less than 200 milliseconds, otherwise the
+// constructor returns ErrBatchIntervalTooSmall.
+func WithBatchInterval(interval time.Duration) Option {
+	return func(c *CloudWatchWriter) {
+		c.batchInterval = interval
+	}
+}
+
+// WithSequenceTokens makes the writer fetch and send the sequence tokens which
+// PutLogEvents used to require. AWS now ignores them, so they are disabled by
+// default, which allows several writers (in one or many processes) to send to
+// the same log stream. This is only needed for endpoints that still enforce
+// them; the writer also switches them on by itself if it receives an
+// InvalidSequenceTokenException.
+func WithSequenceTokens() Option {
+	return func(c *CloudWatchWriter) {
+		c.sequenceTokens = true
+	}
+}
+
+// WithRetryPolicy sets how batches which fail to be sent are retried, see
+// RetryPolicy. By default a batch is sent up to 5 times.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *CloudWatchWriter) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithMaxQueueSize limits the number of logs, and the total size in bytes of
+// their messages, that are queued waiting to be sent to CloudWatch. A limit of
+// zero means no limit, which is the default. What happens to logs written
+// while the queue is full is decided by the overflow policy.
+func WithMaxQueueSize(maxEvents, maxBytes int) Option {
+	return func(c *CloudWatchWriter) {
+		c.maxQueueEvents = maxEvents
+		c.maxQueueBytes = maxBytes
+	}
+}
+
+// WithMaxBufferedBytes limits the total size in bytes of the messages of the
+// logs queued waiting to be sent to CloudWatch, discarding the oldest of them
+// to make space for the logs written while the queue is full, so that when
+// CloudWatch can't be reached for a long time the program keeps the most
+// recent logs rather than running out of memory. It is the same as
+// WithMaxQueueSize with maxBytes, leaving the limit on the number of logs as
+// it is, and WithOverflowPolicy with DropOldest, unless WithOverflowPolicy is
+// also given, before or after it, in which case its policy is kept. The logs
+// discarded are counted by Stats().DroppedOldest, and passed to the drop
+// handler, see WithDropHandler.
+func WithMaxBufferedBytes(n int) Option {
+	return func(c *CloudWatchWriter) {
+		c.maxQueueBytes = n
+		if !c.overflowPolicySet {
+			c.overflowPolicy = DropOldest
+		}
+	}
+}
+
+// WithOverflowPolicy sets what happens to logs written while the queue is
+// full, the default is DropNewest, or DropOldest with WithMaxBufferedBytes.
+// It has no effect without WithMaxQueueSize or WithMaxBufferedBytes.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(c *CloudWatchWriter) {
+		c.overflowPolicy = policy
+		c.overflowPolicySet = true
+	}
+}
+
+// WithBlockTimeout sets the maximum time that Write waits for space in the
+// queue with the Block overflow policy, after which the log is discarded and
+// Write returns ErrQueueFull. The default of zero means waiting for as long as it
+// takes.
+func WithBlockTimeout(timeout time.Duration) Option {
+	return func(c *CloudWatchWriter) {
+		c.blockTimeout = timeout
+	}
+}
+
+// WithMinLevel makes the writer discard logs below the given level, when
+// zerolog writes to it with WriteLevel, which it does when the writer is used
+// directly as the output of a zerolog.Logger or in a zerolog.MultiLevelWriter.
+// Logs without a level are always kept. The default is zerolog.TraceLevel,
+// which keeps every log.
+func WithMinLevel(level zerolog.Level) Option {
+	return func(c *CloudWatchWriter) {
+		c.minLevel = level
+	}
+}
+
+// WithOversizePolicy sets what happens to logs which are larger than the 256KB
+// that CloudWatch accepts for a log event, the default is Truncate.
+func WithOversizePolicy(policy OversizePolicy) Option {
+	return func(c *CloudWatchWriter) {
+		c.oversizePolicy = policy
+	}
+}
+
+// WithInvalidUTF8Policy sets what happens to logs which aren't valid UTF-8,
+// which CloudWatch rejects along with the rest of the batch, such as logs of
+// binary data. The default is KeepInvalidUTF8, which leaves them as they are.
+func WithInvalidUTF8Policy(policy InvalidUTF8Policy) Option {
+	return func(c *CloudWatchWriter) {
+		c.invalidUTF8Policy = policy
+	}
+}
+
+// WithRejectedEventsHandler sets a function which is called whenever
+// CloudWatch accepts a batch but rejects some of its log events, for being too
+// old or too new, which would otherwise go unnoticed. The function is called
+// by the goroutine sending the logs, so it should return quickly. The number
+// of rejected events is also counted in Stats.
+func WithRejectedEventsHandler(handler func(RejectedEvents)) Option {
+	return func(c *CloudWatchWriter) {
+		c.rejectedEventsHandler = handler
+	}
+}
+
+// WithDropHandler sets a function which is called with each log which is
+// dropped rather than stored by CloudWatch, and why, so that none are lost
+// without a trace. Logs dropped by Write are passed to it before Write
+// returns, by the goroutine writing them, and the others by the goroutine
+// sending the logs, so it should return quickly and must not write to the
+// writer.
+func WithDropHandler(handler func(log []byte, reason DropReason)) Option {
+	return func(c *CloudWatchWriter) {
+		c.dropHandler = handler
+	}
+}
+
+// WithRetentionDays sets the retention period of the log group, if the writer
+// creates it, rather than leaving the logs to never expire. It must be one of
+// the numbers of days that CloudWatch accepts, such as 1, 7, 30 or 365,
+// otherwise the constructor returns an error, as it does if the client doesn't
+// have a PutRetentionPolicy method, like *cloudwatchlogs.Client does. A log
+// group which already exists is not changed.
+func WithRetentionDays(days int32) Option {
+	return func(c *CloudWatchWriter) {
+		c.retentionDays = days
+	}
+}
+
+// WithLogGroupTags sets tags on the log group, such as for cost allocation.
+// They are included when the writer creates the log group, and added to a log
+// group which already exists when the writer starts. The client must have
+// DescribeLogGroups and TagResource methods, like *cloudwatchlogs.Client does,
+// otherwise the constructor returns an error.
+func WithLogGroupTags(tags map[string]string) Option {
+	return func(c *CloudWatchWriter) {
+		c.logGroupTags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			c.logGroupTags[k] = v
+		}
+	}
+}
+
+// WithLogGroupClass sets the class of the log group, if the writer creates it,
+// such as types.LogGroupClassInfrequentAccess, which costs less but supports
+// fewer CloudWatch Logs features. The class of a log group which already exists
+// can't be changed.
+func WithLogGroupClass(class types.LogGroupClass) Option {
+	return func(c *CloudWatchWriter) {
+		c.logGroupClass = class
+	}
+}
+
+// WithFallbackWriter sets a writer, such as a file or os.Stderr, which is
+// written the logs of any batch that still can't be sent to CloudWatch after
+// it has been retried, one per line, so that they aren't lost during an
+// outage. Errors from it are ignored.
+func WithFallbackWriter(w io.Writer) Option {
+	return func(c *CloudWatchWriter) {
+		c.fallbackWriter = w
+	}
+}
+
+// WithDeadLetterHandler sets a function which is called with the events of any
+// batch that still can't be sent to CloudWatch after it has been retried, and
+// the error, so that the application can keep them or send them somewhere
+// else. It is called by the goroutine sending the logs, so it should not
+// block for long; the events belong to it.
+func WithDeadLetterHandler(handler func(events []types.InputLogEvent, err error)) Option {
+	return func(c *CloudWatchWriter) {
+		c.deadLetterHandler = handler
+	}
+}
+
+// WithSpool makes the writer queue the logs in files in dir, which is created
+// if needed, rather than in memory, so that a long outage doesn't use more
+// and more memory. How far the logs have been sent is recorded in dir too, and
+// the files are deleted once their logs have been sent. Logs left in dir by a
+// previous writer, which crashed or was closed before sending them, are
+// queued by the constructor before any new logs. A log which can't be read
+// back from dir isn't sent, and its delivery fails with ErrSpoolCorrupt. Only
+// one writer should use dir at a time.
+func WithSpool(dir string) Option {
+	return func(c *CloudWatchWriter) {
+		c.spoolDir = dir
+	}
+}
+
+// WithSendHook adds a function which is called after each batch has been sent
+// to CloudWatch, or given up on, with how it went, such as for metrics or
+// tracing. It can be used more than once to add several hooks. They are called
+// by the goroutine sending the logs, so they should return quickly.
+func WithSendHook(hook func(BatchSend)) Option {
+	return func(c *CloudWatchWriter) {
+		c.sendHooks = append(c.sendHooks, hook)
+	}
+}
+
+// WithWriteNeverFails makes Write always return the length of the log and no
+// error, even when the log is dropped, and stops the errors from sending logs
+// from being returned by Write or Flush. Loggers which write to several
+// outputs, such as zerolog.MultiLevelWriter, then don't treat the writer as
+// failing. The errors and dropped logs can still be handled with Errors,
+// WithErrorHandler, WithDropHandler and Stats.
+func WithWriteNeverFails() Option {
+	return func(c *CloudWatchWriter) {
+		c.writeNeverFails = true
+	}
+}
+
+// WithBatchSentHandler adds a function which is called after each batch has
+// been sent to CloudWatch successfully, with its size and the latency of the
+// request, such as for delivery auditing. It is a send hook which skips the
+// batches that were given up on, see WithSendHook.
+func WithBatchSentHandler(handler func(BatchSend)) Option {
+	return WithSendHook(func(send BatchSend) {
+		if send.Err == nil {
+			handler(send)
+		}
+	})
+}
+
+// WithInternalLogger sets a Logger which is told about what the writer is
+// doing internally, which is otherwise invisible: retries, throttling, and log
+// groups and log streams being created, including after they have been
+// deleted. It is for diagnosing problems with sending the logs, so it must not
+// write to the writer itself.
+func WithInternalLogger(logger Logger) Option {
+	return func(c *CloudWatchWriter) {
+		c.logger = logger
+	}
+}
+
+// WithExpvar publishes the writer's Stats with the expvar package under name,
+// so that they are served by /debug/vars along with the program's other
+// variables. The name must not already be published, otherwise the
+// constructor returns an error, and it can't be used again afterwards, even
+// once the writer has been closed.
+func WithExpvar(name string) Option {
+	return func(c *CloudWatchWriter) {
+		c.expvarName = name
+	}
+}
+
+// WithErrorHandler sets a function which is called with the errors from
+// sending logs to CloudWatch, such as a batch which could not be sent after
+// retrying. The errors are then no longer returned by the next Write or Flush,
+// so Write only fails when the log being written is dropped, rather than for
+// a batch of earlier logs. The handler is called by the goroutine sending the
+// logs, so it should return quickly.
+func WithErrorHandler(handler func(error)) Option {
+	return func(c *CloudWatchWriter) {
+		c.errorHandler = handler
+	}
+}
+
+// WithMiddleware wraps every call the writer makes to CloudWatch Logs with the
+// middleware, such as to audit the calls, change their input or measure them,
+// without implementing CloudWatchLogsClient. The first middleware is the
+// outermost one, it is called first and returns last. A middleware can return
+// its own output or error instead of calling next, but the output must be of
+// the operation's output type.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *CloudWatchWriter) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+// WithClock makes the writer use clock rather than the system clock, such as
+// in tests, to control when batches are sent and retried without waiting for
+// them. The timestamps of logs written without one, and, for WithSpool and
+// Importer, how old logs are, also come from the clock.
+func WithClock(clock Clock) Option {
+	return func(c *CloudWatchWriter) {
+		c.clock = clock
+	}
+}
+
+// WithManualPump stops the writer from sending the logs in the background, for
+// tests: the logs are only sent when Pump, Flush or Close is called, on the
+// calling goroutine, so that a test knows when they have been sent without
+// sleeping. The batch interval has no effect, and with the Block overflow
+// policy, Write waits for Pump to make space in the queue. It can't be used
+// with WithSpool.
+func WithManualPump() Option {
+	return func(c *CloudWatchWriter) {
+		c.manualPump = true
+	}
+}
+
+// WithManualStart stops the constructor from calling CloudWatch and starting
+// to send the logs, which Start does instead, such as to create the writer
+// before the program is ready to reach AWS. Logs written before Start are
+// queued. Close sends them, having found or created the log stream, even if
+// the writer was never started. It can't be used with WithManualPump, and has
+// no effect on NewSync, NewLambda and NewImporter, which call
+// CloudWatch straight away.
+func WithManualStart() Option {
+	return func(c *CloudWatchWriter) {
+		c.manualStart = true
+	}
+}
+
+// WithLogStreamLookupOrder sets the order in which the writer goes through the
+// log streams of the log group, with DescribeLogStreams, to find its log
+// stream when it starts or switches to another one. The default is
+// types.OrderByLogStreamName, ascending, which is the only order CloudWatch
+// accepts a log stream name prefix with, so only the log streams whose names
+// start with the writer's are listed. With types.OrderByLastEventTime every
+// log stream of the log group is listed, which takes more requests in a log
+// group with many of them, unless the writer's log stream is among the first,
+// such as the most recently written to with descending.
+func WithLogStreamLookupOrder(orderBy types.OrderBy, descending bool) Option {
+	return func(c *CloudWatchWriter) {
+		c.logStreamOrderBy = orderBy
+		c.logStreamDescending = descending
+	}
+}
+
+// WithLogStreamRotation makes the writer switch to a new log stream at the
+// start of each period, such as every hour or day, with SetLogStream. The log
+// streams are named after the one given to the constructor, with its
+// placeholders replaced anew, followed by a hyphen and the start of the
+// period, in UTC, formatted with the time layout, such as app-2024-05-01 for
+// a daily period with the layout "2006-01-02". The period must be at least a
+// minute. If the new log stream can't be created,
+// the error is reported like an error sending logs, and the writer tries again
+// every 10 seconds. It has no effect on NewSync, NewLambda and NewImporter.
+func WithLogStreamRotation(period time.Duration, layout string) Option {
+	return func(c *CloudWatchWriter) {
+		c.rotation.period = period
+		c.rotation.layout = layout
+	}
+}
+
+// WithLogStreamSizeRotation makes the writer switch to a new log stream, with
+// SetLogStream, once it has sent maxEvents log events, or maxBytes bytes of
+// messages, to the current one, to keep the log streams to a manageable size.
+// A limit of zero means no limit. The nth new log stream is named after the
+// one given to the constructor, or the period's with WithLogStreamRotation,
+// followed by suffix(n), which is a hyphen and n if suffix is nil, such as
+// app-1, app-2 and so on. The counts start from zero for each writer, and the
+// switch happens after the batch which reaches a limit, so a log stream can
+// get a few batches more than the limits while it is switching. If the new log
+// stream can't be created, the error is reported like an error sending logs,
+// and the writer tries again after the next batch. It has no effect on
+// NewSync, NewLambda and NewImporter.
+func WithLogStreamSizeRotation(maxEvents, maxBytes int, suffix func(n int) string) Option {
+	return func(c *CloudWatchWriter) {
+		c.rotation.maxEvents = int64(maxEvents)
+		c.rotation.maxBytes = int64(maxBytes)
+		c.rotation.suffix = suffix
+	}
+}
+
+// WithEC2Metadata sets the client used to look up the EC2 instance ID for the
+// {instance_id} placeholder in the log group and log stream names, instead of
+// one for the default instance metadata endpoint. The instance ID is looked
+// up with IMDSv2 the first time it is needed, waiting up to 2 seconds, and
+// the host name is used instead if it can't be, such as when the program
+// isn't running on EC2.
+func WithEC2Metadata(client *imds.Client) Option {
+	return func(c *CloudWatchWriter) {
+		c.imdsClient = client
+	}
+}
+
+// WithECSMetadataFields adds the ECS cluster, task ARN and container name, as
+// the ecs_cluster, ecs_task_arn and ecs_container fields, at the start of each
+// log which is a JSON object, such as zerolog's. The metadata is looked up
+// when the writer is created, from the task metadata endpoint in
+// ECS_CONTAINER_METADATA_URI_V4, waiting up to 2 seconds, and if it can't be,
+// such as when the program isn't running on ECS or Fargate, the logs are left
+// as they are. The {ecs_cluster}, {ecs_task_id} and {ecs_container}
+// placeholders put it in the log stream name instead, see New.
+func WithECSMetadataFields() Option {
+	return func(c *CloudWatchWriter) {
+		c.ecsFields = true
+	}
+}
+
+// WithStaticFields adds the fields, such as the service, environment, version
+// and host name, to each log which is a JSON object, such as zerolog's, apart
+// from those the log already has, so that they don't have to be set up in
+// every logger. A log which isn't a JSON object is made the message field of
+// one, with the fields. The values are encoded as JSON, and the constructor
+// returns an error if one can't be.
+func WithStaticFields(fields map[string]interface{}) Option {
+	return func(c *CloudWatchWriter) {
+		c.staticFields = fields
+	}
+}
+
+// WithTimestampField makes the writer take the timestamp of each log which is
+// a JSON object from its field with the name, such as zerolog's "time", rather
+// than using the time the log is written, so that logs which are buffered or
+// written late keep their timestamps. A string is parsed with the time layout,
+// or time.RFC3339Nano, which also parses time.RFC3339, if the layout is "". A
+// number is the Unix time in seconds, or in milliseconds, microseconds or
+// nanoseconds for the layouts zerolog.TimeFormatUnixMs, TimeFormatUnixMicro
+// and TimeFormatUnixNano. Logs without the field, or whose field can't be
+// parsed, get the time they are written, as do all logs without this option.
+// The timestamps of logs written with WriteEvents are kept.
+func WithTimestampField(name, layout string) Option {
+	return func(c *CloudWatchWriter) {
+		c.timestampField = name
+		c.timestampLayout = layout
+	}
+}
+
+// WithFlushLevel makes the writer send the logs straight away, rather than
+// waiting for the batch interval, when a log at the level or above is written,
+// such as zerolog.ErrorLevel, so that critical logs reach CloudWatch within
+// milliseconds. The level is zerolog's when it writes with WriteLevel, and
+// otherwise is read from the zerolog.LevelFieldName field of JSON logs. Write
+// doesn't wait for the logs to be sent, except for fatal and panic logs, which
+// are always sent before Write returns, so that they are sent before zerolog
+// exits or panics, see FlushHook. With WithManualPump, they are sent by Write,
+// on the calling goroutine.
+func WithFlushLevel(level zerolog.Level) Option {
+	return func(c *CloudWatchWriter) {
+		c.flushLevel = level
+		c.flushOnLevel = true
+	}
+}
+
+// WithSampling keeps one in n of the logs below the level, chosen at random,
+// such as one in 10 debug and info logs with zerolog.WarnLevel, discarding the
+// rest before they are queued, to keep down the cost of sending many logs.
+// Logs at the level or above are always kept, as are logs without a level.
+// The level is zerolog's when it writes with WriteLevel, and otherwise is read
+// from the zerolog.LevelFieldName field of JSON logs. The logs discarded are
+// counted by Stats.SampledOut. With n of 0 or 1 every log is kept.
+func WithSampling(n int, level zerolog.Level) Option {
+	return func(c *CloudWatchWriter) {
+		c.sampling.n = n
+		c.sampling.level = level
+	}
+}
+
+// WithSamplingKey makes WithSampling choose the logs to keep by the value of
+// a field of JSON logs, such as a request ID, rather than at random, so that
+// either all of the logs of a request are kept or none of them are. One in n
+// of the values is kept, by their hash, and logs without the field are still
+// chosen at random.
+func WithSamplingKey(field string) Option {
+	return func(c *CloudWatchWriter) {
+		c.sampling.key = field
+	}
+}
+
+// WithDuplicateSuppression collapses identical logs written one after the
+// other within the window, such as from an error in a tight loop, to protect
+// against log storms. The first log is sent as usual, and the copies of it
+// which follow within the window are sent as one more log, once the window has
+// passed or another log is written, with a "repeated" field counting them. A
+// log which isn't a JSON object is made the message field of one. Logs are
+// only identical if all of their bytes are, including any timestamp. A
+// SyncWriter sends the log counting the copies straight away, and a
+// LambdaWriter adds it to its batch.
+func WithDuplicateSuppression(window time.Duration) Option {
+	return func(c *CloudWatchWriter) {
+		c.repeats.window = window
+	}
+}
+
+// WithAggregation reduces the logs sent during a storm, such as the same error
+// from every request during an incident, by counting the logs with the same
+// fingerprint in each window rather than sending all of them. The first log
+// with a fingerprint in a window is sent as usual, and the others are sent as
+// one summary at the end of the window, or by Flush or Close: the last of
+// them, with an "aggregated" field counting them. A log which isn't a JSON
+// object is made the message field of one. The fingerprint is given by the
+// function, and logs for which it returns "" are never aggregated. If it is
+// nil, the fingerprint of a JSON log is its level and message fields,
+// otherwise the whole log, with the numbers left out, so that logs such as
+// "retry 3 of 5" from the same line of code have the same fingerprint. A
+// SyncWriter sends the summaries straight away, and a LambdaWriter adds them to
+// its batch.
+func WithAggregation(window time.Duration, fingerprint func(log []byte) string) Option {
+	return func(c *CloudWatchWriter) {
+		c.aggregation.window = window
+		c.aggregation.fingerprint = fingerprint
+	}
+}
+
+// WithRateLimit limits the logs sent for each value of a field of JSON logs,
+// such as the logger or subsystem, to perSecond on average, with bursts of up
+// to burst, so that one noisy part of the program can't use up the rate at
+// which CloudWatch accepts logs from the log stream for the others. Logs over
+// the limit are dropped, so Write returns ErrRateLimited and the drop handler,
+// if there is one, is called with DropReasonRateLimited. The logs without the
+// field share a limit, as do all logs if field is "".
+func WithRateLimit(field string, perSecond float64, burst int) Option {
+	return func(c *CloudWatchWriter) {
+		c.rateLimit.field = field
+		c.rateLimit.perSecond = perSecond
+		c.rateLimit.burst = float64(burst)
+	}
+}
+
+// WithRedaction applies the redactions to each log, in order, before it is
+// queued, such as RedactEmails, RedactCardNumbers and RedactFields, so that
+// the information they remove never reaches CloudWatch. The logs passed to
+// the drop handler are redacted too.
+func WithRedaction(redactions ...Redaction) Option {
+	return func(c *CloudWatchWriter) {
+		c.redactions = append(c.redactions, redactions...)
+	}
+}
+
+// WithAllowFilters discards the logs which don't match any of the filters,
+// such as FieldEquals, FieldContains and FieldMatches, before they are queued.
+// It can be given more than once, adding to the filters.
+func WithAllowFilters(filters ...Filter) Option {
+	return func(c *CloudWatchWriter) {
+		c.filters.allow = append(c.filters.allow, filters...)
+	}
+}
+
+// WithDenyFilters discards the logs which match any of the filters before they
+// are queued, such as known noisy logs, whether or not they match the filters
+// of WithAllowFilters. It can be given more than once, adding to the filters.
+func WithDenyFilters(filters ...Filter) Option {
+	return func(c *CloudWatchWriter) {
+		c.filters.deny = append(c.filters.deny, filters...)
+	}
+}
+
+// WithTransformer passes each log through the transformer before it is queued,
+// after the writer has found its timestamp, which the transformer can change
+// along with the message, and before the redactions and fields are applied.
+// The log is discarded if the transformer returns false. It can be given more
+// than once, the transformers being applied in order, so that enrichment,
+// redaction and filtering of your own can be composed. The transformers apply
+// to the logs of WriteEvents and Importer too.
+func WithTransformer(transformer func(log LogEvent) (LogEvent, bool)) Option {
+	return func(c *CloudWatchWriter) {
+		c.transformers = append(c.transformers, transformer)
+	}
+}
+
+// WithLineSplitting makes each line of a log written to the writer a separate
+// log, without its newline, skipping empty lines, such as when the output of
+// a subprocess is copied to the writer, rather than sending many lines as one
+// log event. Each line is filtered, sampled and rate limited on its own, and
+// a line written with Write has its own level field. Write returns the first
+// error of the lines, having written the others.
+func WithLineSplitting() Option {
+	return func(c *CloudWatchWriter) {
+		c.splitLines = true
+	}
+}
+
+// WithANSIStripping removes ANSI escape sequences, such as the colours of
+// console output, from logs before they are queued, so that they don't get in
+// the way of searching the logs with CloudWatch Logs Insights.
+func WithANSIStripping() Option {
+	return func(c *CloudWatchWriter) {
+		c.stripANSI = true
+	}
+}
+
+// WithNewlineTrimming removes the newlines at the end of logs, such as the
+// one zerolog ends each log with, before they are queued. Logs which are
+// empty or only whitespace are never sent, whether or not the newlines are
+// trimmed, as CloudWatch rejects the whole batch for an empty log event, and
+// Stats().SkippedEmpty counts them.
+func WithNewlineTrimming() Option {
+	return func(c *CloudWatchWriter) {
+		c.trimNewlines = true
+	}
+}
+
+// WithHeartbeat writes a heartbeat log every interval, such as every 5
+// minutes, so that a log stream which goes quiet because the program has
+// nothing to log can be told apart from one whose logs aren't being sent. It
+// is an info log with the message "heartbeat", a "heartbeat" field of true,
+// and fields with how long the writer has been running and the logs queued,
+// sent and dropped, see Stats. It isn't filtered, sampled or rate limited like
+// the logs written to the writer. It only applies to a CloudWatchWriter, not a
+// SyncWriter or LambdaWriter.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(c *CloudWatchWriter) {
+		c.heartbeatInterval = interval
+	}
+}
+
+// WithSessionMarkers writes a log when the writer is created and another when
+// it is closed, so that deploys and restarts can be matched up with the logs
+// around them. They are info logs with the messages "session start" and
+// "session stop", a "session" field of "start" or "stop", and fields with the
+// program's module version and the VCS commit it was built from, when the go
+// command recorded them, the Go version and the host name. The stop log also
+// has how long the writer was running. They aren't filtered, sampled or rate
+// limited like the logs written to the writer. It only applies to a
+// CloudWatchWriter, not a SyncWriter or LambdaWriter.
+func WithSessionMarkers() Option {
+	return func(c *CloudWatchWriter) {
+		c.sessionMarkers = true
+	}
+}
+
+// WithSequenceNumbers numbers the logs in the field, such as "seq", counting
+// from 1, so that whoever reads them can tell from a gap in the numbers that
+// logs were lost, such as when the queue was full or a batch could not be
+// sent. The logs discarded on purpose, by filters, sampling, rate limits,
+// duplicate suppression, aggregation and transformers, and those which are
+// empty, aren't numbered, so they don't leave gaps. A log which isn't a JSON
+// object is made the message field of one, and a log which already has the
+// field keeps its own, leaving a gap. The numbers start again from 1 for
+// each writer, such as when the program restarts, see WithSessionMarkers.
+func WithSequenceNumbers(field string) Option {
+	return func(c *CloudWatchWriter) {
+		c.sequenceField = field
+	}
+}
+
+// WithMaxEventAge sets how old a queued log can be, going by its timestamp,
+// before it is dropped rather than sent, such as after CloudWatch couldn't be
+// reached for a long time, as CloudWatch rejects log events more than 14 days
+// old, so a little less than that leaves time for sending the logs. By
+// default logs of any age are sent. The logs dropped are counted by
+// Stats().DroppedTooOld and passed to the drop handler, see WithDropHandler,
+// and their deliveries fail with ErrTooOld, see WriteAsync. It applies to the
+// logs replayed from a spool too, see WithSpool, which are dropped at 14 days
+// anyway, but has no effect on NewSync, NewLambda and NewImporter, which send
+// the logs straight away.
+func WithMaxEventAge(age time.Duration) Option {
+	return func(c *CloudWatchWriter) {
+		c.maxAge = age
+	}
+}
+
+// WithFirstSendJitter delays the first batch by a random time of up to max,
+// on top of the batch interval, each time the writer starts sending the logs,
+// so that the many instances of a program restarted at once, such as by a
+// deploy, don't all send their batches at the same time, and get throttled
+// for exceeding the account's limit on PutLogEvents requests. The batches
+// which follow are still sent every batch interval, keeping the delay. Full
+// batches, and the logs sent by Flush or WithFlushLevel, aren't delayed. It
+// has no effect with WithManualPump, nor on NewSync, NewLambda and
+// NewImporter.
+func WithFirstSendJitter(max time.Duration) Option {
+	return func(c *CloudWatchWriter) {
+		c.firstSendJitter = max
+	}
+}
+
+// WithLimiter makes the writer wait for the limiter before sending each
+// batch, including retries, so that the writers sharing it, such as one for
+// each tenant or log stream of a program, together stay under the account's
+// quotas on PutLogEvents requests and bytes, see NewLimiter. It applies to
+// SyncWriter and LambdaWriter too, whose Write and Flush then wait for it.
+func WithLimiter(limiter *Limiter) Option {
+	return func(c *CloudWatchWriter) {
+		c.limiter = limiter
+	}
+}
+
+// WithSenders makes the writer hand its batches to n goroutines which send
+// them, so that it carries on batching the logs written in the meantime
+// rather than waiting for slow PutLogEvents requests. The batches of each log
+// stream are still sent one at a time, in order, so more than one sender only
+// helps the writers which send the logs of several log streams, which then
+// send up to n batches at once: a writer with streams, see Stream, a Router,
+// a FieldRouter or a Pool. Flush and Pump still wait for the batches to be
+// sent. Zero, the default, sends the batches on the goroutine batching the
+// logs.
+func WithSenders(n int) Option {
+	return func(c *CloudWatchWriter) {
+		c.numSenders = n
+	}
+}
@@ -0,0 +1,36 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestNewWithClientWithBatchInterval(t *testing.T) {
+	client := &mockClient{}
+
+	// WithBatchInterval takes precedence over the positional batch interval
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 5*time.Second, "logGroup", "logStream",
+		cloudwatchwriter.WithBatchInterval(200*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+
+	if err = client.waitForLogs(1, 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, client.numLogs())
+}
+
+func TestNewWithClientWithBatchIntervalTooSmall(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 5*time.Second, "logGroup", "logStream",
+		cloudwatchwriter.WithBatchInterval(199*time.Millisecond),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package cloudwatchwriter
+
+import (
+	"bytes"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/rs/zerolog"
+)
+
+// stackFieldName is the field of the log written by CapturePanics holding the
+// stack trace of the panic.
+const stackFieldName = "stack"
+
+// CapturePanics, deferred at the start of a goroutine or of main, writes a
+// panic as a log at zerolog.PanicLevel, with the panic as its message and the
+// stack trace in its "stack" field, and sends it before panicking again with
+// the same value, so that the cause of a crash reaches CloudWatch:
+//
+//	defer cloudwatchwriter.CapturePanics(cloudWatchWriter)
+//
+// The writer can be a CloudWatchWriter, or any of the other writers in this
+// package: it is flushed with its Flush or FlushSync method. A goroutine which
+// doesn't panic is left alone. CapturePanics must be deferred itself, rather
+// than called by a deferred function, for it to recover the panic.
+func CapturePanics(w zerolog.LevelWriter) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	var log bytes.Buffer
+	logger := zerolog.New(&log)
+	logger.WithLevel(zerolog.PanicLevel).
+		Timestamp().
+		Str(stackFieldName, string(debug.Stack())).
+		Msg(fmt.Sprint(value))
+	_, _ = w.WriteLevel(zerolog.PanicLevel, log.Bytes())
+
+	switch w := w.(type) {
+	case interface{ Flush() error }:
+		_ = w.Flush()
+	case interface{ FlushSync() error }:
+		_ = w.FlushSync()
+	}
+	panic(value)
+}
@@ -0,0 +1,69 @@
+package cloudwatchwriter_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCapturePanics(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Hour, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	func() {
+		defer func() {
+			assert.Equal(t, "boom", recover())
+		}()
+		defer cloudwatchwriter.CapturePanics(cloudWatchWriter)
+		panic("boom")
+	}()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 1, len(logs)) {
+		var log struct {
+			Level   string
+			Message string
+			Stack   string
+		}
+		assert.NoError(t, json.Unmarshal([]byte(*logs[0].Message), &log))
+		assert.Equal(t, "panic", log.Level)
+		assert.Equal(t, "boom", log.Message)
+		assert.True(t, strings.Contains(log.Stack, "TestCapturePanics"), log.Stack)
+	}
+}
+
+func TestCapturePanicsLambdaWriter(t *testing.T) {
+	client := &mockClient{}
+	lambdaWriter, err := cloudwatchwriter.NewLambdaWithClient(client, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewLambdaWithClient: %v", err)
+	}
+
+	assert.Panics(t, func() {
+		defer cloudwatchwriter.CapturePanics(lambdaWriter)
+		panic("boom")
+	})
+	assert.Equal(t, 1, client.numLogs())
+}
+
+func TestCapturePanicsNoPanic(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Hour, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	func() {
+		defer cloudwatchwriter.CapturePanics(cloudWatchWriter)
+	}()
+	assert.Equal(t, 0, client.numLogs())
+}
@@ -0,0 +1,369 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/rs/zerolog"
+)
+
+// Pool hands out writers to many destinations, such as a log stream for each
+// tenant of a multi-tenant service, which share the client and one goroutine
+// sending the logs, like a Router. The writer for a destination is created by
+// the first log written to it, and closed, sending its logs, once it hasn't
+// been written to for the idle timeout, until it is written to again. The
+// logs queued by all of the destinations can be limited to a total size, so
+// that many destinations can't use up the memory between them.
+type Pool struct {
+	client        CloudWatchLogsClient
+	batchInterval time.Duration
+	opts          []Option
+	idleTimeout   time.Duration
+	budget        *queueBudget
+	loop          *sendLoop
+
+	mu     sync.Mutex
+	active map[Destination]*poolDestination
+	closed bool
+	// creating counts the destinations whose writers are being created
+	creating sync.WaitGroup
+	// idle holds the destinations being closed for being idle, which are
+	// still pumped until they have been, and idling counts them.
+	idle        map[*poolDestination]Destination
+	idling      sync.WaitGroup
+	stopReaper  chan struct{}
+	reaperDone  chan struct{}
+	stopReaping sync.Once
+}
+
+type poolDestination struct {
+	// writer is nil until it has been created, when ready is closed, unless
+	// creating it failed with err.
+	writer    *CloudWatchWriter
+	err       error
+	ready     chan struct{}
+	lastWrite time.Time
+	// inUse counts the logs being written to the writer, which is only
+	// closed once they have been.
+	inUse sync.WaitGroup
+}
+
+// NewPool returns a Pool whose destinations are closed once they haven't been
+// written to for idleTimeout, and whose queues hold at most maxQueueBytes
+// bytes of logs between them, or an error. Zero means never closing idle
+// destinations, and no limit. The options apply to the writer of each
+// destination, see New, except for WithManualStart, WithSpool and WithExpvar,
+// which a Pool doesn't support.
+func NewPool(cfg aws.Config, idleTimeout time.Duration, maxQueueBytes int, opts ...Option) (*Pool, error) {
+	return NewPoolWithClient(cloudwatchlogs.NewFromConfig(cfg), defaultBatchInterval, idleTimeout, maxQueueBytes, opts...)
+}
+
+// NewPoolWithClient is like NewPool, with the client used by every
+// destination, see NewWithClient.
+func NewPoolWithClient(client CloudWatchLogsClient, batchInterval, idleTimeout time.Duration, maxQueueBytes int, opts ...Option) (*Pool, error) {
+	if idleTimeout < 0 {
+		return nil, errors.New("idle timeout must not be negative")
+	}
+	if maxQueueBytes < 0 {
+		return nil, errors.New("max queue size must not be negative")
+	}
+	loop, opts, err := newSendLoop(batchInterval, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pool{
+		client:        client,
+		batchInterval: batchInterval,
+		idleTimeout:   idleTimeout,
+		loop:          loop,
+		active:        make(map[Destination]*poolDestination),
+		idle:          make(map[*poolDestination]Destination),
+		stopReaper:    make(chan struct{}),
+		reaperDone:    make(chan struct{}),
+	}
+	if maxQueueBytes > 0 {
+		p.budget = &queueBudget{max: maxQueueBytes}
+		opts = append(opts, withQueueBudget(p.budget))
+	}
+	p.opts = opts
+	loop.start(p.writers)
+	if idleTimeout > 0 {
+		go p.reapIdle()
+	} else {
+		close(p.reaperDone)
+	}
+	return p, nil
+}
+
+// PoolWriter writes to one of the destinations of a Pool.
+type PoolWriter struct {
+	pool        *Pool
+	destination Destination
+}
+
+// Writer returns a writer to the log stream in the log group, whose names can
+// contain the same placeholders as those given to New. The writers for the
+// same log group and log stream share their destination's writer, and it is
+// only created by the first log written to one of them.
+func (p *Pool) Writer(logGroupName, logStreamName string) *PoolWriter {
+	return &PoolWriter{pool: p, destination: Destination{LogGroupName: logGroupName, LogStreamName: logStreamName}}
+}
+
+// Write writes the log to the destination, see CloudWatchWriter.Write. The
+// first log for a destination waits for its log group and log stream to be
+// found or created, as do the other logs for it in the meantime, and returns
+// the error if that fails, in which case the log is not sent. A
+// log which would take the logs queued by the pool over its limit is dropped,
+// and Write returns ErrQueueFull.
+func (w *PoolWriter) Write(log []byte) (int, error) {
+	return w.pool.write(w.destination, func(c *CloudWatchWriter) (int, error) {
+		return c.Write(log)
+	})
+}
+
+// WriteLevel implements the zerolog.LevelWriter interface, writing the log
+// like Write, unless it is below the writers' minimum level, see
+// CloudWatchWriter.WriteLevel.
+func (w *PoolWriter) WriteLevel(level zerolog.Level, log []byte) (int, error) {
+	return w.pool.write(w.destination, func(c *CloudWatchWriter) (int, error) {
+		return c.WriteLevel(level, log)
+	})
+}
+
+// write calls fn with the writer for the destination, creating it if it isn't
+// active. The destination is looked up with the lock held, but its writer is
+// created and written to without it, so that neither setting up a destination
+// nor a Write waiting for room in a full queue holds up the others, and a
+// writer being closed waits for the logs being written to it.
+func (p *Pool) write(destination Destination, fn func(*CloudWatchWriter) (int, error)) (int, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return 0, ErrClosed
+	}
+	active, ok := p.active[destination]
+	if !ok {
+		active = &poolDestination{ready: make(chan struct{})}
+		p.active[destination] = active
+		p.creating.Add(1)
+	}
+	active.lastWrite = p.loop.clock.Now()
+	active.inUse.Add(1)
+	p.mu.Unlock()
+	defer active.inUse.Done()
+
+	if !ok {
+		p.create(destination, active)
+	}
+	<-active.ready
+	if active.err != nil {
+		return 0, fmt.Errorf("destination %s: %w", destination, active.err)
+	}
+
+	n, err := fn(active.writer)
+	if err != nil {
+		return n, fmt.Errorf("destination %s: %w", destination, err)
+	}
+	return n, nil
+}
+
+// create creates the writer of the destination, which is no longer active if
+// that fails, so that the next log for it tries again.
+func (p *Pool) create(destination Destination, active *poolDestination) {
+	defer p.creating.Done()
+
+	writer, err := NewWithClient(p.client, p.batchInterval, destination.LogGroupName, destination.LogStreamName, p.opts...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	active.writer, active.err = writer, err
+	if err != nil {
+		delete(p.active, destination)
+	}
+	close(active.ready)
+}
+
+// reapIdle closes the destinations which haven't been written to for the idle
+// timeout, checking every idle timeout, until the pool is closed.
+func (p *Pool) reapIdle() {
+	defer close(p.reaperDone)
+
+	timer := p.loop.clock.NewTimer(p.idleTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+			p.closeIdle()
+			timer.Reset(p.idleTimeout)
+		case <-p.stopReaper:
+			return
+		}
+	}
+}
+
+// closeIdle closes the destinations which haven't been written to for the
+// idle timeout, sending their logs.
+func (p *Pool) closeIdle() {
+	now := p.loop.clock.Now()
+
+	p.mu.Lock()
+	idle := make(map[*poolDestination]Destination)
+	for destination, active := range p.active {
+		if active.writer != nil && now.Sub(active.lastWrite) >= p.idleTimeout {
+			idle[active] = destination
+			p.idle[active] = destination
+			delete(p.active, destination)
+		}
+	}
+	p.idling.Add(len(idle))
+	p.mu.Unlock()
+
+	for active, destination := range idle {
+		go p.closeIdleDestination(destination, active)
+	}
+}
+
+// closeIdleDestination closes an idle destination, once the logs being
+// written to it have been, sending its logs.
+func (p *Pool) closeIdleDestination(destination Destination, active *poolDestination) {
+	defer p.idling.Done()
+
+	active.inUse.Wait()
+	if err := active.writer.CloseWithContext(context.Background()); err != nil {
+		active.writer.logf("closing the idle destination %s: %v", destination, err)
+	}
+
+	p.mu.Lock()
+	delete(p.idle, active)
+	p.mu.Unlock()
+}
+
+// activeDestinations returns the active destinations and their writers,
+// leaving out those whose writers are still being created.
+func (p *Pool) activeDestinations() ([]Destination, []*CloudWatchWriter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	destinations := make([]Destination, 0, len(p.active))
+	writers := make([]*CloudWatchWriter, 0, len(p.active))
+	for destination, active := range p.active {
+		if active.writer == nil {
+			continue
+		}
+		destinations = append(destinations, destination)
+		writers = append(writers, active.writer)
+	}
+	return destinations, writers
+}
+
+// writers returns the writers of the active destinations, and of those being
+// closed for being idle, which may have logs being written to them still.
+func (p *Pool) writers() []*CloudWatchWriter {
+	_, writers := p.activeDestinations()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for active := range p.idle {
+		writers = append(writers, active.writer)
+	}
+	return writers
+}
+
+// Active returns the number of active destinations.
+func (p *Pool) Active() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.active)
+}
+
+// Flush sends the logs written so far to every active destination, and blocks
+// until they have been sent, returning the first error, see
+// CloudWatchWriter.Flush.
+func (p *Pool) Flush() error {
+	destinations, writers := p.activeDestinations()
+	var firstErr error
+	for i, c := range writers {
+		if err := c.Flush(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("destination %s: %w", destinations[i], err)
+		}
+	}
+	return firstErr
+}
+
+// Close blocks until the pool has completed writing the logs to CloudWatch.
+func (p *Pool) Close() {
+	_ = p.CloseWithContext(context.Background())
+}
+
+// CloseWithContext closes the writer of every active destination at the same
+// time, see CloudWatchWriter.CloseWithContext, returning the first error.
+// Writing to the pool afterwards returns ErrClosed.
+func (p *Pool) CloseWithContext(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.stopReaping.Do(func() { close(p.stopReaper) })
+	<-p.reaperDone
+	p.creating.Wait()
+	// The idle destinations are pumped until they have been closed
+	p.idling.Wait()
+	p.loop.close()
+
+	destinations, writers := p.activeDestinations()
+	for i, err := range closeAll(ctx, writers) {
+		if err != nil {
+			return fmt.Errorf("destination %s: %w", destinations[i], err)
+		}
+	}
+	return nil
+}
+
+// queueBudget limits the total size of the logs queued by several writers,
+// see NewPool.
+type queueBudget struct {
+	mu    sync.Mutex
+	bytes int
+	max   int
+}
+
+// take takes size bytes from the budget, reporting whether there were enough
+// left.
+func (b *queueBudget) take(size int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.bytes+size > b.max {
+		return false
+	}
+	b.bytes += size
+	return true
+}
+
+// release gives size bytes back to the budget, if there is one.
+func (b *queueBudget) release(size int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bytes -= size
+}
+
+// withQueueBudget makes the writer's queue share the budget with the other
+// writers of a Pool.
+func withQueueBudget(budget *queueBudget) Option {
+	return func(c *CloudWatchWriter) {
+		c.queueBudget = budget
+	}
+}
@@ -0,0 +1,134 @@
+package cloudwatchwriter_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestPool(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	pool, err := cloudwatchwriter.NewPoolWithClient(client, time.Hour, 0, 0)
+	if err != nil {
+		t.Fatalf("NewPoolWithClient: %v", err)
+	}
+	defer pool.Close()
+
+	a, b := pool.Writer("tenant-a", "app"), pool.Writer("tenant-b", "app")
+	assert.Equal(t, 0, pool.Active())
+	for _, w := range []*cloudwatchwriter.PoolWriter{a, b, pool.Writer("tenant-a", "app")} {
+		_, err = w.Write([]byte("log"))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, pool.Active())
+	assert.NoError(t, pool.Flush())
+
+	assert.Equal(t, []string{"log", "log"}, client.Messages("tenant-a", "app"))
+	assert.Equal(t, []string{"log"}, client.Messages("tenant-b", "app"))
+
+	pool.Close()
+	_, err = a.Write([]byte("log"))
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrClosed))
+}
+
+func TestPoolBlock(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	pool, err := cloudwatchwriter.NewPoolWithClient(client, 200*time.Millisecond, 0, 0,
+		cloudwatchwriter.WithMaxQueueSize(1, 0),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.Block),
+	)
+	if err != nil {
+		t.Fatalf("NewPoolWithClient: %v", err)
+	}
+	defer pool.Close()
+
+	// A Write waiting for room in the queue doesn't stop the queue from being
+	// sent
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a, b := pool.Writer("tenant-a", "app"), pool.Writer("tenant-b", "app")
+		for _, w := range []*cloudwatchwriter.PoolWriter{a, a, a, b} {
+			_, err := w.Write([]byte("log"))
+			assert.NoError(t, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write blocked")
+	}
+
+	pool.Close()
+	assert.Len(t, client.Messages("tenant-a", "app"), 3)
+	assert.Len(t, client.Messages("tenant-b", "app"), 1)
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	pool, err := cloudwatchwriter.NewPoolWithClient(client, time.Hour, time.Minute, 0,
+		cloudwatchwriter.WithClock(clock),
+	)
+	if err != nil {
+		t.Fatalf("NewPoolWithClient: %v", err)
+	}
+	defer pool.Close()
+
+	a, b := pool.Writer("tenant-a", "app"), pool.Writer("tenant-b", "app")
+	_, err = a.Write([]byte("first"))
+	assert.NoError(t, err)
+	assert.NoError(t, clock.WaitForTimers(2, time.Second))
+	clock.Advance(30 * time.Second)
+	_, err = b.Write([]byte("first"))
+	assert.NoError(t, err)
+
+	// a is closed, sending its logs, as it's been idle for the timeout
+	clock.Advance(30 * time.Second)
+	_, err = client.WaitForEvents("tenant-a", "app", 1, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pool.Active())
+	assert.Empty(t, client.Messages("tenant-b", "app"))
+
+	// Writing to it again creates it again
+	_, err = a.Write([]byte("second"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, pool.Active())
+	pool.Close()
+	assert.Equal(t, []string{"first", "second"}, client.Messages("tenant-a", "app"))
+	assert.Equal(t, []string{"first"}, client.Messages("tenant-b", "app"))
+}
+
+func TestPoolMaxQueueBytes(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	pool, err := cloudwatchwriter.NewPoolWithClient(client, time.Hour, 0, 10)
+	if err != nil {
+		t.Fatalf("NewPoolWithClient: %v", err)
+	}
+	defer pool.Close()
+
+	_, err = pool.Writer("tenant-a", "app").Write([]byte("12345"))
+	assert.NoError(t, err)
+	_, err = pool.Writer("tenant-b", "app").Write([]byte("12345"))
+	assert.NoError(t, err)
+	_, err = pool.Writer("tenant-c", "app").Write([]byte("1"))
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrQueueFull))
+
+	// Sending the logs makes room for more
+	assert.NoError(t, pool.Flush())
+	_, err = pool.Writer("tenant-c", "app").Write([]byte("1"))
+	assert.NoError(t, err)
+}
+
+func TestPoolInvalid(t *testing.T) {
+	_, err := cloudwatchwriter.NewPoolWithClient(&mockClient{}, time.Hour, -time.Minute, 0)
+	assert.EqualError(t, err, "idle timeout must not be negative")
+	_, err = cloudwatchwriter.NewPoolWithClient(&mockClient{}, time.Hour, 0, -1)
+	assert.EqualError(t, err, "max queue size must not be negative")
+	_, err = cloudwatchwriter.NewPoolWithClient(&mockClient{}, time.Hour, 0, 0, cloudwatchwriter.WithSpool(t.TempDir()))
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package cloudwatchwriter
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// pump moves the queued logs into batches and sends them. It is only used by
+// one goroutine at a time, the queueMonitor or, with WithManualPump, the one
+// calling Pump.
+type pump struct {
+	c       *CloudWatchWriter
+	current batch
+	// queued holds what is kept about the events in the batch, and those
+	// queued among them which have expired, see WithMaxEventAge, whose
+	// indexes in queued are in expired.
+	queued  []queuedEvent
+	expired []int
+	// spooled is the position in the spool after the last event in the batch
+	spooled spoolPosition
+	// chunk holds the events dequeued at once by drain.
+	chunk chunk
+	// sending holds a token while a batch is being sent by the senders, see
+	// WithSenders, so that the next one waits for it.
+	sending chan struct{}
+}
+
+func newPump(c *CloudWatchWriter) *pump {
+	return &pump{c: c, spooled: c.queue.position(), sending: make(chan struct{}, 1)}
+}
+
+// send sends the batch or, if the writer has senders, hands it to them once
+// the last batch has been sent, so that the batches are sent in order.
+func (p *pump) send() {
+	events := p.current.take()
+	if p.c.senders == nil {
+		p.finish(events, p.queued, p.expired, p.spooled)
+		p.queued = p.queued[:0]
+		p.expired = p.expired[:0]
+		return
+	}
+
+	queued, expired, spooled := p.queued, p.expired, p.spooled
+	p.queued, p.expired = nil, nil
+	p.sending <- struct{}{}
+	p.c.senders.send(func() {
+		defer func() { <-p.sending }()
+		p.finish(events, queued, expired, spooled)
+	})
+}
+
+// wait waits for the batch being sent by the senders, if there is one.
+func (p *pump) wait() {
+	p.sending <- struct{}{}
+	<-p.sending
+}
+
+// finish sends the events, and records how long they took to be delivered if
+// CloudWatch accepted them, and tells their deliveries how it went, along
+// with those of the expired events, in the order they were queued. It then
+// records in the spool that they have been dealt with, up to spooled, unless
+// they were abandoned by CloseWithContext, so that they are sent again by the
+// next writer using the spool.
+func (p *pump) finish(events []types.InputLogEvent, queued []queuedEvent, expired []int, spooled spoolPosition) {
+	err := p.c.sendBatch(events)
+	start := 0
+	for _, i := range expired {
+		p.finishSent(queued[start:i], err)
+		queued[i].delivery.eventDone(ErrTooOld)
+		p.c.queue.finish(1, ErrTooOld)
+		start = i + 1
+	}
+	p.finishSent(queued[start:], err)
+	if p.c.ctx.Err() == nil {
+		if err := p.c.queue.commit(spooled); err != nil {
+			p.c.setErr(err)
+		}
+	}
+}
+
+// finishSent finishes events which were sent, with the error from sending
+// them.
+func (p *pump) finishSent(queued []queuedEvent, err error) {
+	if err == nil {
+		p.c.delivered(queued)
+	}
+	for _, queued := range queued {
+		queued.delivery.eventDone(err)
+	}
+	p.c.queue.finish(len(queued), err)
+}
+
+// drain moves the queued logs into the batch, calling send whenever the batch
+// is full, leaving the last batch to be sent.
+func (p *pump) drain(send func()) {
+	var oldest int64
+	if p.c.maxAge > 0 {
+		oldest = p.c.clock.Now().Add(-p.c.maxAge).UnixMilli()
+	}
+	for {
+		p.c.queue.dequeueChunk(&p.chunk, dequeueChunkSize)
+		if len(p.chunk.events) == 0 {
+			return
+		}
+
+		for i, logEvent := range p.chunk.events {
+			if *logEvent.Timestamp < oldest {
+				// It is finished with the batch, in its place among the
+				// events
+				p.c.droppedTooOld(aws.ToString(logEvent.Message))
+				p.expired = append(p.expired, len(p.queued))
+				p.queued = append(p.queued, p.chunk.queued[i])
+				if len(p.chunk.positions) > 0 {
+					p.spooled = p.chunk.positions[i]
+				}
+				continue
+			}
+
+			// Send the batch before adding the next message, if the message
+			// would push it over one of the limits on a batch.
+			if !p.current.fits(logEvent) {
+				send()
+			}
+
+			p.current.add(logEvent)
+			p.queued = append(p.queued, p.chunk.queued[i])
+			if len(p.chunk.positions) > 0 {
+				p.spooled = p.chunk.positions[i]
+			}
+
+			if p.current.full() {
+				send()
+			}
+		}
+	}
+}
+
+// Pump sends the logs written so far, on the calling goroutine, in as many
+// batches as they take, and returns once they have been sent. Like Flush, it
+// returns the last error from sending logs to CloudWatch which has not been
+// reported yet. It can only be used with WithManualPump, in which case Flush
+// is the same as Pump.
+func (c *CloudWatchWriter) Pump() error {
+	if !c.manualPump {
+		return errors.New("the writer isn't pumped manually, see WithManualPump")
+	}
+
+	c.endRepeats()
+	c.endAggregation()
+
+	c.pumpQueued()
+	return c.takeErr()
+}
+
+// pumpQueued sends the queued logs, in as many batches as they take, for a
+// writer which is pumped manually, and waits for them to be sent.
+func (c *CloudWatchWriter) pumpQueued() {
+	c.startPump()
+	c.waitForSends()
+}
+
+// startPump is like pumpQueued, without waiting for the batches handed to the
+// senders, see WithSenders.
+func (c *CloudWatchWriter) startPump() {
+	c.pumpMu.Lock()
+	defer c.pumpMu.Unlock()
+
+	c.pump.drain(c.pump.send)
+	c.pump.send()
+	c.pumpStreams()
+}
+
+// pumpRemaining sends the remaining logs for CloseWithContext, when the writer
+// is pumped manually.
+func (c *CloudWatchWriter) pumpRemaining() {
+	c.pumpMu.Lock()
+	defer c.pumpMu.Unlock()
+
+	c.pump.drain(c.pump.send)
+	c.pump.send()
+	c.pump.wait()
+	close(c.done)
+}
@@ -0,0 +1,84 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterManualPump(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	// Nothing is sent until Pump is called
+	for i := 0; i < 10001; i++ {
+		_, err = cloudWatchWriter.Write([]byte("log"))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 0, client.numPutLogEventsCalls())
+
+	// Pump sends all of them, in as many batches as it takes
+	assert.NoError(t, cloudWatchWriter.Pump())
+	assert.Equal(t, 2, client.numPutLogEventsCalls())
+	assert.Equal(t, 10001, client.numLogs())
+
+	// Without logs to send, Pump doesn't make any requests
+	assert.NoError(t, cloudWatchWriter.Pump())
+	assert.Equal(t, 2, client.numPutLogEventsCalls())
+
+	// Flush and Close pump too
+	_, err = cloudWatchWriter.Write([]byte("flushed"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 10002, client.numLogs())
+
+	_, err = cloudWatchWriter.Write([]byte("closed"))
+	assert.NoError(t, err)
+	cloudWatchWriter.Close()
+	assert.Equal(t, 10003, client.numLogs())
+	cloudWatchWriter.Close()
+}
+
+func TestCloudWatchWriterManualPumpError(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}},
+	}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+	assert.Error(t, cloudWatchWriter.Pump())
+	assert.Equal(t, 0, client.numLogs())
+}
+
+func TestCloudWatchWriterPumpWithoutManualPump(t *testing.T) {
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	assert.Error(t, cloudWatchWriter.Pump())
+}
+
+func TestCloudWatchWriterManualPumpSpool(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithSpool(t.TempDir()),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,360 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// OverflowPolicy decides what happens to a log written while the queue of
+// logs waiting to be sent to CloudWatch is full, see WithMaxQueueSize.
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the log being written, Write returns ErrQueueFull.
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards as many of the oldest queued logs as needed to make
+	// space for the log being written.
+	DropOldest
+	// Block makes Write wait until enough queued logs have been sent to make
+	// space for the log being written. If the writer is closed in the
+	// meantime, or the wait is longer than the block timeout, the log is
+	// discarded and Write returns ErrQueueFull.
+	Block
+)
+
+// eventQueue is the queue of log events waiting to be batched, optionally
+// bounded by number of events or total message size.
+type eventQueue struct {
+	sync.Mutex
+	items ring[types.InputLogEvent]
+	// queued holds what is kept about each of the events while it is queued,
+	// in the same order.
+	queued       ring[queuedEvent]
+	events       int
+	bytes        int
+	maxEvents    int
+	maxBytes     int
+	policy       OverflowPolicy
+	blockTimeout time.Duration
+	clock        Clock
+	// spaceFreed is closed, and replaced, when events are removed while
+	// there are writers waiting for space.
+	spaceFreed chan struct{}
+	waiters    int
+	// notify receives a value when events are added or the queue is closed,
+	// without blocking the sender, so that the consumer can sleep while the
+	// queue is empty.
+	notify chan struct{}
+	closed bool
+	// spool, if not nil, holds the events on disk instead of items.
+	spool *spool
+	// budget, if not nil, limits the size of the events queued by this queue
+	// and those of the other writers of a Pool.
+	budget *queueBudget
+	// droppedOldest counts the events removed by the DropOldest policy, and
+	// onEvict, if not nil, is called with each of them, outside of the lock.
+	droppedOldest int64
+	onEvict       func(types.InputLogEvent)
+	// added is the number of events ever queued, and finished is how many of
+	// them have been delivered or failed to be, which they are in order.
+	// barriers are waiting for finished to reach their targets.
+	added    int64
+	finished int64
+	barriers []*barrier
+}
+
+// queuedEvent is what is kept about a queued event, in memory even with a
+// spool.
+type queuedEvent struct {
+	// at is when the event was queued.
+	at time.Time
+	// delivery, if not nil, is the Delivery of the log the event is part of,
+	// see WriteAsync.
+	delivery *Delivery
+	// size is the size of the event's message.
+	size int
+}
+
+// newEventQueue returns an eventQueue, a limit of zero means no limit, as does
+// a block timeout of zero.
+func newEventQueue(maxEvents, maxBytes int, policy OverflowPolicy, blockTimeout time.Duration, clock Clock) *eventQueue {
+	return &eventQueue{
+		maxEvents:    maxEvents,
+		maxBytes:     maxBytes,
+		policy:       policy,
+		blockTimeout: blockTimeout,
+		clock:        clock,
+		spaceFreed:   make(chan struct{}),
+		notify:       make(chan struct{}, 1),
+	}
+}
+
+// enqueue adds the event to the queue, applying the overflow policy if the
+// queue is full, as part of the delivery, if there is one. With the Block
+// policy it returns ctx.Err() if ctx is done before there is space for the
+// event.
+func (q *eventQueue) enqueue(ctx context.Context, event types.InputLogEvent, delivery *Delivery) error {
+	_, err := q.enqueueAll(ctx, []types.InputLogEvent{event}, delivery)
+	return err
+}
+
+// enqueueAll is like enqueue for several events, taking the lock once. It
+// stops at the first event which can't be queued, returning how many were.
+func (q *eventQueue) enqueueAll(ctx context.Context, events []types.InputLogEvent, delivery *Delivery) (n int, err error) {
+	var evicted []types.InputLogEvent
+	q.Lock()
+	for ; n < len(events); n++ {
+		if evicted, err = q.add(ctx, events[n], delivery, evicted); err != nil {
+			break
+		}
+	}
+	q.Unlock()
+
+	for _, e := range evicted {
+		q.onEvict(e)
+	}
+	return n, err
+}
+
+// add adds the event with the lock held, appending the events removed by the
+// DropOldest policy to evicted if there is an onEvict function to call with
+// them. The deliveries of the events removed are failed with ErrQueueFull.
+func (q *eventQueue) add(ctx context.Context, event types.InputLogEvent, delivery *Delivery, evicted []types.InputLogEvent) ([]types.InputLogEvent, error) {
+	size := len(*event.Message)
+
+	if q.maxBytes > 0 && size > q.maxBytes {
+		// It would never fit
+		return evicted, ErrQueueFull
+	}
+
+	var timeout <-chan time.Time
+	for q.isFull(size) {
+		switch {
+		case q.policy == DropOldest:
+			removed, queued, ok := q.remove()
+			if !ok {
+				return evicted, ErrQueueFull
+			}
+			queued.delivery.eventDone(ErrQueueFull)
+			q.finishLocked(1, ErrQueueFull)
+			q.droppedOldest++
+			if q.onEvict != nil {
+				evicted = append(evicted, removed)
+			}
+			continue
+		case q.policy != Block || q.closed:
+			return evicted, ErrQueueFull
+		}
+
+		if timeout == nil && q.blockTimeout > 0 {
+			timer := q.clock.NewTimer(q.blockTimeout)
+			defer timer.Stop()
+			timeout = timer.C()
+		}
+
+		spaceFreed := q.spaceFreed
+		q.waiters++
+		q.Unlock()
+		select {
+		case <-spaceFreed:
+		case <-timeout:
+			q.Lock()
+			q.waiters--
+			return evicted, ErrQueueFull
+		case <-ctx.Done():
+			q.Lock()
+			q.waiters--
+			return evicted, ctx.Err()
+		}
+		q.Lock()
+		q.waiters--
+	}
+
+	if q.budget != nil && !q.budget.take(size) {
+		// The other queues sharing the budget hold the rest of it
+		return evicted, ErrQueueFull
+	}
+	if q.spool != nil {
+		if err := q.spool.push(event); err != nil {
+			q.budget.release(size)
+			return evicted, err
+		}
+	} else {
+		q.items.push(event)
+	}
+	q.queued.push(queuedEvent{at: q.clock.Now(), delivery: delivery, size: size})
+	q.added++
+	q.events++
+	q.bytes += size
+	q.signal()
+	return evicted, nil
+}
+
+func (q *eventQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *eventQueue) isFull(size int) bool {
+	return (q.maxEvents > 0 && q.events+1 > q.maxEvents) ||
+		(q.maxBytes > 0 && q.bytes+size > q.maxBytes)
+}
+
+// dequeueChunkSize is the most events dequeueChunk removes at once, which
+// keeps the lock from being held for long while writers are waiting for it.
+const dequeueChunkSize = 256
+
+// chunk holds the events removed by dequeueChunk, what is kept about them,
+// and with a spool, the position in it after each of them. Its slices are
+// reused by each call.
+type chunk struct {
+	events    []types.InputLogEvent
+	queued    []queuedEvent
+	positions []spoolPosition
+}
+
+// dequeueChunk removes up to max of the oldest events into the chunk, taking
+// the lock once, leaving it empty if the queue is.
+func (q *eventQueue) dequeueChunk(c *chunk, max int) {
+	// Don't hold on to the messages of the last chunk
+	clear(c.events)
+	clear(c.queued)
+	c.events, c.queued, c.positions = c.events[:0], c.queued[:0], c.positions[:0]
+
+	q.Lock()
+	defer q.Unlock()
+
+	if q.spool != nil {
+		for len(c.events) < max {
+			event, ok := q.popSpool()
+			if !ok {
+				break
+			}
+			c.events = append(c.events, event)
+			c.positions = append(c.positions, q.spool.position())
+		}
+	} else {
+		c.events = q.items.popN(c.events, max)
+	}
+	if len(c.events) == 0 {
+		return
+	}
+	c.queued = q.queued.popN(c.queued, len(c.events))
+
+	size := 0
+	for _, event := range c.events {
+		size += len(*event.Message)
+	}
+	q.budget.release(size)
+	q.events -= len(c.events)
+	q.bytes -= size
+	if q.waiters > 0 {
+		q.wakeWaiters()
+	}
+}
+
+func (q *eventQueue) wakeWaiters() {
+	close(q.spaceFreed)
+	q.spaceFreed = make(chan struct{})
+}
+
+func (q *eventQueue) remove() (event types.InputLogEvent, queued queuedEvent, ok bool) {
+	if q.spool != nil {
+		event, ok = q.popSpool()
+	} else {
+		event, ok = q.items.pop()
+	}
+	if ok {
+		queued, _ = q.queued.pop()
+		q.budget.release(len(*event.Message))
+		q.events--
+		q.bytes -= len(*event.Message)
+	}
+	return event, queued, ok
+}
+
+// popSpool pops the oldest event from the spool, first finishing those before
+// it which couldn't be read back from the spool with ErrSpoolCorrupt, so that
+// the events queued line up with the events popped. It is called with the
+// lock held.
+func (q *eventQueue) popSpool() (types.InputLogEvent, bool) {
+	event, ok := q.spool.pop()
+	for n := q.spool.takeSkipped(); n > 0; n-- {
+		skipped, _ := q.queued.pop()
+		skipped.delivery.eventDone(ErrSpoolCorrupt)
+		q.finishLocked(1, ErrSpoolCorrupt)
+		q.budget.release(skipped.size)
+		q.events--
+		q.bytes -= skipped.size
+	}
+	return event, ok
+}
+
+// close stops Write from blocking on a full queue, as nothing is going to
+// wait for the queue to drain.
+func (q *eventQueue) close() {
+	q.Lock()
+	defer q.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		q.wakeWaiters()
+		q.signal()
+	}
+}
+
+// counters returns the number of queued events, their total size, and the
+// number of events removed by the DropOldest policy.
+func (q *eventQueue) counters() (events, bytes int, droppedOldest int64) {
+	if q == nil {
+		// The writer failed to be created
+		return 0, 0, 0
+	}
+	q.Lock()
+	defer q.Unlock()
+
+	return q.events, q.bytes, q.droppedOldest
+}
+
+// position returns the position in the spool of the next event to be
+// dequeued, if there is a spool.
+func (q *eventQueue) position() spoolPosition {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.spool == nil {
+		return spoolPosition{}
+	}
+	return q.spool.position()
+}
+
+// commit records that the events dequeued before pos have been sent, if there
+// is a spool, so they are not sent again.
+func (q *eventQueue) commit(pos spoolPosition) error {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.spool == nil {
+		return nil
+	}
+	return q.spool.commit(pos)
+}
+
+// closeSpool closes the spool, if there is one, once nothing else is going to
+// be dequeued.
+func (q *eventQueue) closeSpool() error {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.spool == nil {
+		return nil
+	}
+	err := q.spool.close()
+	q.spool = nil
+	return err
+}
@@ -0,0 +1,342 @@
+package cloudwatchwriter_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+// helperBlockedWriter returns a writer whose first batch, holding one log, is
+// stuck in PutLogEvents until the gate is closed, so that subsequent logs stay
+// in the queue.
+func helperBlockedWriter(t *testing.T, client *mockClient, opts ...cloudwatchwriter.Option) *cloudwatchwriter.CloudWatchWriter {
+	client.putLogEventsGate = make(chan struct{})
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream", opts...)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	if _, err = cloudWatchWriter.Write([]byte("first")); err != nil {
+		t.Fatalf("cloudWatchWriter.Write: %v", err)
+	}
+	if err = client.waitForPutLogEventsCalls(1, 300*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	return cloudWatchWriter
+}
+
+func TestCloudWatchWriterDropNewest(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client, cloudwatchwriter.WithMaxQueueSize(3, 0))
+
+	for i := 0; i < 3; i++ {
+		helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: fmt.Sprintf("Test message %d", i)})
+	}
+	_, err := cloudWatchWriter.Write([]byte("dropped"))
+	assert.Error(t, err)
+
+	close(client.putLogEventsGate)
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 4, len(logs)) {
+		assert.Contains(t, *logs[3].Message, "Test message 2")
+	}
+}
+
+func TestCloudWatchWriterDropOldest(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client,
+		cloudwatchwriter.WithMaxQueueSize(0, 10),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.DropOldest),
+	)
+
+	for _, message := range []string{"aaaa", "bbbb", "cccc", "dddd"} {
+		_, err := cloudWatchWriter.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+
+	// A log that can never fit into the queue is still rejected
+	_, err := cloudWatchWriter.Write([]byte("far too long"))
+	assert.Error(t, err)
+
+	close(client.putLogEventsGate)
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 3, len(logs)) {
+		assert.Equal(t, "cccc", *logs[1].Message)
+		assert.Equal(t, "dddd", *logs[2].Message)
+	}
+}
+
+func TestCloudWatchWriterMaxBufferedBytes(t *testing.T) {
+	client := &mockClient{}
+	var evicted []string
+	cloudWatchWriter := helperBlockedWriter(t, client,
+		cloudwatchwriter.WithMaxBufferedBytes(10),
+		cloudwatchwriter.WithDropHandler(func(log []byte, reason cloudwatchwriter.DropReason) {
+			evicted = append(evicted, string(log))
+		}),
+	)
+
+	for _, message := range []string{"aaaa", "bbbb", "cccc", "dddd"} {
+		_, err := cloudWatchWriter.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"aaaa", "bbbb"}, evicted)
+	assert.Equal(t, int64(2), cloudWatchWriter.Stats().DroppedOldest)
+
+	close(client.putLogEventsGate)
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 3, len(logs)) {
+		assert.Equal(t, "cccc", *logs[1].Message)
+		assert.Equal(t, "dddd", *logs[2].Message)
+	}
+}
+
+func TestCloudWatchWriterMaxBufferedBytesOverflowPolicy(t *testing.T) {
+	// The policy is kept whichever option comes first
+	for _, opts := range [][]cloudwatchwriter.Option{
+		{cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.DropNewest), cloudwatchwriter.WithMaxBufferedBytes(10)},
+		{cloudwatchwriter.WithMaxBufferedBytes(10), cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.DropNewest)},
+	} {
+		client := &mockClient{}
+		cloudWatchWriter := helperBlockedWriter(t, client, opts...)
+
+		for _, message := range []string{"aaaa", "bbbb"} {
+			_, err := cloudWatchWriter.Write([]byte(message))
+			assert.NoError(t, err)
+		}
+		_, err := cloudWatchWriter.Write([]byte("cccc"))
+		assert.True(t, errors.Is(err, cloudwatchwriter.ErrQueueFull))
+		assert.Equal(t, int64(0), cloudWatchWriter.Stats().DroppedOldest)
+
+		close(client.putLogEventsGate)
+		cloudWatchWriter.Close()
+	}
+}
+
+func TestCloudWatchWriterMaxEventAge(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	var dropped []string
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithMaxEventAge(time.Hour),
+		cloudwatchwriter.WithDropHandler(func(log []byte, reason cloudwatchwriter.DropReason) {
+			assert.Equal(t, cloudwatchwriter.DropReasonTooOld, reason)
+			dropped = append(dropped, string(log))
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	old, err := cloudWatchWriter.WriteAsync([]byte("old"))
+	assert.NoError(t, err)
+	clock.Advance(2 * time.Hour)
+	recent, err := cloudWatchWriter.WriteAsync([]byte("recent"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.Equal(t, cloudwatchwriter.ErrTooOld, old.Wait(context.Background()))
+	assert.NoError(t, recent.Wait(context.Background()))
+	assert.Equal(t, []string{"recent"}, client.Messages("logGroup", "logStream"))
+	assert.Equal(t, []string{"old"}, dropped)
+	assert.Equal(t, int64(1), cloudWatchWriter.Stats().DroppedTooOld)
+}
+
+func TestCloudWatchWriterMaxEventAgeInterleaved(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithMaxEventAge(time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// The expired logs don't split the batch
+	now := time.Now()
+	assert.NoError(t, cloudWatchWriter.WriteEvents([]cloudwatchwriter.LogEvent{
+		{Message: "first", Timestamp: now},
+		{Message: "old", Timestamp: now.Add(-2 * time.Hour)},
+		{Message: "second", Timestamp: now},
+		{Message: "older", Timestamp: now.Add(-3 * time.Hour)},
+		{Message: "third", Timestamp: now},
+	}))
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.Equal(t, 1, client.numPutLogEventsCalls())
+	assert.Equal(t, 3, client.numLogs())
+	assert.Equal(t, int64(2), cloudWatchWriter.Stats().DroppedTooOld)
+}
+
+func TestCloudWatchWriterBlock(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client,
+		cloudwatchwriter.WithMaxQueueSize(1, 0),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.Block),
+	)
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 1"})
+
+	written := make(chan struct{})
+	go func() {
+		helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 2"})
+		close(written)
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("expected Write to block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(client.putLogEventsGate)
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("expected Write to return once the queue had space")
+	}
+
+	cloudWatchWriter.Close()
+	assert.Equal(t, 3, client.numLogs())
+}
+
+func TestNewWithClientNegativeMaxQueueSize(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithMaxQueueSize(-1, 0),
+	)
+	assert.Error(t, err)
+}
+
+func TestCloudWatchWriterBlockTimeout(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client,
+		cloudwatchwriter.WithMaxQueueSize(1, 0),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.Block),
+		cloudwatchwriter.WithBlockTimeout(20*time.Millisecond),
+	)
+	defer cloudWatchWriter.Close()
+	defer close(client.putLogEventsGate)
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 1"})
+
+	start := time.Now()
+	_, err := cloudWatchWriter.Write([]byte("dropped"))
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+}
+
+func TestCloudWatchWriterWriteContext(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client,
+		cloudwatchwriter.WithMaxQueueSize(1, 0),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.Block),
+	)
+	defer cloudWatchWriter.Close()
+	defer close(client.putLogEventsGate)
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := cloudWatchWriter.WriteContext(ctx, []byte("dropped"))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestCloudWatchWriterSpool(t *testing.T) {
+	client := &mockClient{}
+	dir := t.TempDir()
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithSpool(dir),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	logs := logsContainer{}
+	for i := 0; i < 10; i++ {
+		log := exampleLog{Message: fmt.Sprintf("Test message %d", i)}
+		helperWriteLogs(t, cloudWatchWriter, log)
+		logs.addLog(log)
+	}
+	assert.NoError(t, cloudWatchWriter.CloseWithContext(context.Background()))
+
+	expectedLogs, err := logs.getLogEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualLogMessages(t, expectedLogs, client.getLogEvents())
+
+	// All the logs have been sent, so the spool is empty
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCloudWatchWriterSpoolKeepsAbandonedLogs(t *testing.T) {
+	client := &mockClient{}
+	dir := t.TempDir()
+
+	cloudWatchWriter := helperBlockedWriter(t, client, cloudwatchwriter.WithSpool(dir))
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, cloudWatchWriter.CloseWithContext(ctx))
+
+	// The logs which weren't sent are still in the spool
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, entries)
+}
+
+func TestCloudWatchWriterSpoolReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	// The first writer gives up on sending its logs
+	cloudWatchWriter := helperBlockedWriter(t, &mockClient{}, cloudwatchwriter.WithSpool(dir))
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, cloudWatchWriter.CloseWithContext(ctx))
+
+	// The next one sends them before its own logs
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithSpool(dir),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	_, err = cloudWatchWriter.Write([]byte("last"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.CloseWithContext(context.Background()))
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 3, len(logs)) {
+		assert.Equal(t, "first", *logs[0].Message)
+		assert.Contains(t, *logs[1].Message, "Test message")
+		assert.Equal(t, "last", *logs[2].Message)
+	}
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
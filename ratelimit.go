@@ -0,0 +1,68 @@
+package cloudwatchwriter
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRateLimitKeys is how many keys the rate limit keeps buckets for before
+// forgetting those which are full, as a key without a bucket gets a full one.
+const maxRateLimitKeys = 10000
+
+// rateLimit is a token bucket for each value of a field, see WithRateLimit.
+type rateLimit struct {
+	field     string
+	perSecond float64
+	burst     float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimited reports whether the log is over the rate limit for the value of
+// its field, in which case it is dropped, otherwise it takes a token.
+func (c *CloudWatchWriter) rateLimited(log []byte) bool {
+	r := &c.rateLimit
+	if r.perSecond == 0 {
+		return false
+	}
+	var key string
+	if r.field != "" {
+		key = fieldValue(log, r.field)
+	}
+	now := c.clock.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[key]
+	if !ok {
+		if len(r.buckets) >= maxRateLimitKeys {
+			r.forgetFull(now)
+		}
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[key] = b
+	}
+	b.tokens = min(r.burst, b.tokens+now.Sub(b.last).Seconds()*r.perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return true
+	}
+	b.tokens--
+	return false
+}
+
+// forgetFull forgets the buckets which have filled up since they were last
+// used, as they are the same as new ones. It is called with the lock held.
+func (r *rateLimit) forgetFull(now time.Time) {
+	for key, b := range r.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*r.perSecond >= r.burst {
+			delete(r.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cloudwatchwriter_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterRateLimit(t *testing.T) {
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	var dropped []string
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithRateLimit("logger", 2, 3),
+		cloudwatchwriter.WithDropHandler(func(log []byte, reason cloudwatchwriter.DropReason) {
+			assert.Equal(t, cloudwatchwriter.DropReasonRateLimited, reason)
+			dropped = append(dropped, string(log))
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	write := func(log string) error {
+		_, err := cloudWatchWriter.Write([]byte(log))
+		return err
+	}
+
+	// The burst is used up by the noisy logger, without affecting the others
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, write(`{"logger":"noisy"}`))
+	}
+	assert.True(t, errors.Is(write(`{"logger":"noisy"}`), cloudwatchwriter.ErrRateLimited))
+	assert.NoError(t, write(`{"logger":"quiet"}`))
+	assert.NoError(t, write(`no logger`))
+
+	// Tokens are added at the rate
+	clock.Advance(time.Second)
+	assert.NoError(t, write(`{"logger":"noisy"}`))
+	assert.NoError(t, write(`{"logger":"noisy"}`))
+	assert.True(t, errors.Is(write(`{"logger":"noisy"}`), cloudwatchwriter.ErrRateLimited))
+
+	stats := cloudWatchWriter.Stats()
+	assert.Equal(t, int64(2), stats.DroppedRateLimited)
+	assert.Equal(t, 7, stats.QueuedEvents)
+	assert.Equal(t, []string{`{"logger":"noisy"}`, `{"logger":"noisy"}`}, dropped)
+}
+
+func TestCloudWatchWriterRateLimitInvalid(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRateLimit("logger", -1, 1),
+	)
+	assert.Error(t, err)
+
+	_, err = cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRateLimit("logger", 1, 0),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package cloudwatchwriter
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+)
+
+// ReadFrom implements the io.ReaderFrom interface, writing each line read
+// from r as a log, without its newline, skipping empty lines, until the end
+// of r, such as the output of a subprocess. The lines are written as they
+// arrive, whatever size the reads are, so a line is never split in two. It is
+// used by io.Copy, and so by os/exec for a writer given as the Stdout or
+// Stderr of a Cmd. Each line is filtered, sampled and rate limited on its own,
+// like with WithLineSplitting. It carries on reading when a line can't be
+// written, so as not to hold up whatever writes to r, and returns the number
+// of bytes read and the error from reading r, or else the first error from
+// writing a line.
+func (c *CloudWatchWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	reader := bufio.NewReader(r)
+	var writeErr error
+	for {
+		line, readErr := reader.ReadString('\n')
+		n += int64(len(line))
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			b := stringBytes(line)
+			if _, err := c.writeLevel(context.Background(), c.levelOf(b), b, line, nil); err != nil && writeErr == nil {
+				writeErr = err
+			}
+		}
+		if readErr == io.EOF {
+			return n, writeErr
+		}
+		if readErr != nil {
+			return n, readErr
+		}
+	}
+}
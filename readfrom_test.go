@@ -0,0 +1,53 @@
+package cloudwatchwriter_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterReadFrom(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Hour, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// The lines arrive a byte at a time, and io.Copy uses ReadFrom
+	output := "first line\r\n\nsecond line\nno newline"
+	n, err := io.Copy(cloudWatchWriter, iotest.OneByteReader(strings.NewReader(output)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(output)), n)
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.Equal(t, []string{"first line", "second line", "no newline"}, client.Messages("logGroup", "logStream"))
+}
+
+func TestCloudWatchWriterReadFromError(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Hour, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	readErr := errors.New("read failed")
+	n, err := cloudWatchWriter.ReadFrom(io.MultiReader(strings.NewReader("line\n"), iotest.ErrReader(readErr)))
+	assert.True(t, errors.Is(err, readErr))
+	assert.Equal(t, int64(5), n)
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.Equal(t, []string{"line"}, client.Messages("logGroup", "logStream"))
+}
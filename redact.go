@@ -0,0 +1,190 @@
+package cloudwatchwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the values of the fields redacted by RedactFields.
+const Redacted = "[REDACTED]"
+
+// Redaction changes a log before it is queued, such as to mask personal data
+// which mustn't reach CloudWatch, see WithRedaction.
+type Redaction func(log string) string
+
+// RedactPattern returns a Redaction replacing each match of the pattern with
+// the replacement, which can refer to submatches like in
+// regexp.Regexp.ReplaceAllString.
+func RedactPattern(pattern *regexp.Regexp, replacement string) Redaction {
+	return func(log string) string {
+		return pattern.ReplaceAllString(log, replacement)
+	}
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// RedactEmails returns a Redaction replacing email addresses with "[email]".
+func RedactEmails() Redaction {
+	return RedactPattern(emailPattern, "[email]")
+}
+
+var cardNumberPattern = regexp.MustCompile(`\b(?:[0-9][ -]?){12,18}[0-9]\b`)
+
+// RedactCardNumbers returns a Redaction replacing payment card numbers, of 13
+// to 19 digits which may be grouped with spaces or dashes, with "[card]". Only
+// numbers with a valid Luhn check digit are replaced, to leave most other long
+// numbers alone.
+func RedactCardNumbers() Redaction {
+	return func(log string) string {
+		return cardNumberPattern.ReplaceAllStringFunc(log, func(number string) string {
+			if !luhn(number) {
+				return number
+			}
+			return "[card]"
+		})
+	}
+}
+
+// luhn reports whether the digits of the number, ignoring anything else, have
+// a valid Luhn check digit.
+func luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			continue
+		}
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// RedactFields returns a Redaction replacing the values of the named fields of
+// JSON logs, at any depth, with Redacted, such as for passwords or tokens.
+// Logs which aren't JSON are left as they are.
+func RedactFields(names ...string) Redaction {
+	redacted := make(map[string]bool, len(names))
+	for _, name := range names {
+		redacted[name] = true
+	}
+
+	return func(log string) string {
+		found := false
+		for name := range redacted {
+			if strings.Contains(log, string(quote(name))) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return log
+		}
+
+		body := strings.TrimRight(log, " \t\r\n")
+		decoder := json.NewDecoder(strings.NewReader(body))
+		decoder.UseNumber()
+		var b strings.Builder
+		if err := copyRedacted(decoder, &b, redacted); err != nil {
+			return log
+		}
+		if _, err := decoder.Token(); err != io.EOF {
+			return log
+		}
+		return b.String() + log[len(body):]
+	}
+}
+
+// copyRedacted copies the next JSON value from the decoder to b, with the
+// values of the redacted fields replaced.
+func copyRedacted(decoder *json.Decoder, b *strings.Builder, redacted map[string]bool) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		b.WriteByte('{')
+		for i := 0; decoder.More(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			name, _ := key.(string)
+			b.Write(quote(name))
+			b.WriteByte(':')
+			if redacted[name] {
+				var value json.RawMessage
+				if err = decoder.Decode(&value); err != nil {
+					return err
+				}
+				b.Write(quote(Redacted))
+				continue
+			}
+			if err = copyRedacted(decoder, b, redacted); err != nil {
+				return err
+			}
+		}
+		if _, err = decoder.Token(); err != nil {
+			return err
+		}
+		b.WriteByte('}')
+	case json.Delim('['):
+		b.WriteByte('[')
+		for i := 0; decoder.More(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err = copyRedacted(decoder, b, redacted); err != nil {
+				return err
+			}
+		}
+		if _, err = decoder.Token(); err != nil {
+			return err
+		}
+		b.WriteByte(']')
+	default:
+		switch value := token.(type) {
+		case json.Number:
+			b.WriteString(value.String())
+		case string:
+			b.Write(quote(value))
+		default:
+			encoded, _ := json.Marshal(value)
+			b.Write(encoded)
+		}
+	}
+	return nil
+}
+
+// quote returns the string as JSON, without escaping HTML characters, which
+// zerolog doesn't either.
+func quote(s string) []byte {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// redact applies the redactions of WithRedaction to the log.
+func (c *CloudWatchWriter) redact(log string) string {
+	for _, redaction := range c.redactions {
+		log = redaction(log)
+	}
+	return log
+}
@@ -0,0 +1,61 @@
+package cloudwatchwriter_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestRedactions(t *testing.T) {
+	tests := []struct {
+		name      string
+		redaction cloudwatchwriter.Redaction
+		log       string
+		want      string
+	}{
+		{"email", cloudwatchwriter.RedactEmails(), `{"message":"sent to jane.doe+test@example.co.uk"}`, `{"message":"sent to [email]"}`},
+		{"card", cloudwatchwriter.RedactCardNumbers(), "paid with 4111 1111 1111 1111.", "paid with [card]."},
+		{"card with dashes", cloudwatchwriter.RedactCardNumbers(), "card=5500-0000-0000-0004", "card=[card]"},
+		{"not a card", cloudwatchwriter.RedactCardNumbers(), "order 1234567890123", "order 1234567890123"},
+		{"pattern", cloudwatchwriter.RedactPattern(regexp.MustCompile(`token=\w+`), "token=***"), "GET /?token=abc123", "GET /?token=***"},
+		{
+			"fields",
+			cloudwatchwriter.RedactFields("password", "token"),
+			`{"user":{"name":"jane","password":"hunter2"},"tokens":[{"token":{"id":1}}],"n":1.50,"ok":true,"html":"<b>"}` + "\n",
+			`{"user":{"name":"jane","password":"[REDACTED]"},"tokens":[{"token":"[REDACTED]"}],"n":1.50,"ok":true,"html":"<b>"}` + "\n",
+		},
+		{"fields without them", cloudwatchwriter.RedactFields("password"), `{"user":"jane"}`, `{"user":"jane"}`},
+		{"fields of a log which isn't JSON", cloudwatchwriter.RedactFields("password"), `"password": hunter2`, `"password": hunter2`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, test.redaction(test.log))
+		})
+	}
+}
+
+func TestCloudWatchWriterRedaction(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithRedaction(cloudwatchwriter.RedactEmails(), cloudwatchwriter.RedactFields("password")),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, err = cloudWatchWriter.Write([]byte(`{"email":"jane@example.com","password":"hunter2"}`))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.WriteEvents([]cloudwatchwriter.LogEvent{{Message: "from jane@example.com"}}))
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.Equal(t, []string{
+		`{"email":"[email]","password":"[REDACTED]"}`,
+		"from [email]",
+	}, client.Messages("logGroup", "logStream"))
+}
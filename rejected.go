@@ -0,0 +1,80 @@
+package cloudwatchwriter
+
+import "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+
+// RejectedEvents are the log events of a batch which CloudWatch rejected,
+// although it accepted the rest of the batch. An event can be both too old
+// and expired.
+type RejectedEvents struct {
+	// TooOld are the events more than 14 days old, or older than the log
+	// group.
+	TooOld []types.InputLogEvent
+	// Expired are the events older than the retention period of the log
+	// group.
+	Expired []types.InputLogEvent
+	// TooNew are the events more than 2 hours in the future.
+	TooNew []types.InputLogEvent
+}
+
+// rejectedEvents returns the events of the batch which info says were
+// rejected, or nil if none were.
+func rejectedEvents(batch []types.InputLogEvent, info *types.RejectedLogEventsInfo) *RejectedEvents {
+	if info == nil {
+		return nil
+	}
+
+	// Indexes outside of the batch shouldn't happen, but mustn't cause a panic
+	index := func(i *int32, otherwise int) int {
+		switch {
+		case i == nil:
+			return otherwise
+		case *i < 0:
+			return 0
+		case int(*i) > len(batch):
+			return len(batch)
+		default:
+			return int(*i)
+		}
+	}
+
+	// The end indexes are exclusive, the start index is inclusive
+	rejected := &RejectedEvents{
+		TooOld:  batch[:index(info.TooOldLogEventEndIndex, 0)],
+		Expired: batch[:index(info.ExpiredLogEventEndIndex, 0)],
+		TooNew:  batch[index(info.TooNewLogEventStartIndex, len(batch)):],
+	}
+	if len(rejected.TooOld) == 0 && len(rejected.Expired) == 0 && len(rejected.TooNew) == 0 {
+		return nil
+	}
+	return rejected
+}
+
+func (c *CloudWatchWriter) handleRejectedEvents(batch []types.InputLogEvent, info *types.RejectedLogEventsInfo) {
+	rejected := rejectedEvents(batch, info)
+	if rejected == nil {
+		return
+	}
+
+	c.Lock()
+	c.stats.RejectedTooOld += int64(len(rejected.TooOld))
+	c.stats.RejectedExpired += int64(len(rejected.Expired))
+	c.stats.RejectedTooNew += int64(len(rejected.TooNew))
+	c.Unlock()
+
+	if c.rejectedEventsHandler != nil {
+		c.rejectedEventsHandler(*rejected)
+	}
+	if c.dropHandler != nil {
+		// An event can be both too old and expired, it is only dropped once
+		old := rejected.TooOld
+		if len(rejected.Expired) > len(old) {
+			old = rejected.Expired
+		}
+		c.droppedEvents(old, DropReasonRejected)
+		if start := len(batch) - len(rejected.TooNew); start >= len(old) {
+			c.droppedEvents(rejected.TooNew, DropReasonRejected)
+		} else {
+			c.droppedEvents(batch[len(old):], DropReasonRejected)
+		}
+	}
+}
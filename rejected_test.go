@@ -0,0 +1,72 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterRejectedEvents(t *testing.T) {
+	client := &mockClient{
+		rejectedLogEventsInfo: &types.RejectedLogEventsInfo{
+			ExpiredLogEventEndIndex:  aws.Int32(1),
+			TooOldLogEventEndIndex:   aws.Int32(2),
+			TooNewLogEventStartIndex: aws.Int32(4),
+		},
+	}
+
+	var rejected []cloudwatchwriter.RejectedEvents
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRejectedEventsHandler(func(events cloudwatchwriter.RejectedEvents) {
+			rejected = append(rejected, events)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	for _, message := range []string{"expired", "too old", "ok", "ok", "too new"} {
+		if _, err = cloudWatchWriter.Write([]byte(message)); err != nil {
+			t.Fatalf("cloudWatchWriter.Write: %v", err)
+		}
+	}
+	cloudWatchWriter.Close()
+
+	if assert.Equal(t, 1, len(rejected)) {
+		assertEqualLogMessages(t, []types.InputLogEvent{{Message: aws.String("expired")}}, rejected[0].Expired)
+		assertEqualLogMessages(t, []types.InputLogEvent{{Message: aws.String("expired")}, {Message: aws.String("too old")}}, rejected[0].TooOld)
+		assertEqualLogMessages(t, []types.InputLogEvent{{Message: aws.String("too new")}}, rejected[0].TooNew)
+	}
+
+	stats := cloudWatchWriter.Stats()
+	assert.Equal(t, int64(2), stats.RejectedTooOld)
+	assert.Equal(t, int64(1), stats.RejectedExpired)
+	assert.Equal(t, int64(1), stats.RejectedTooNew)
+}
+
+func TestCloudWatchWriterNoRejectedEvents(t *testing.T) {
+	client := &mockClient{
+		rejectedLogEventsInfo: &types.RejectedLogEventsInfo{},
+	}
+
+	called := false
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRejectedEventsHandler(func(cloudwatchwriter.RejectedEvents) {
+			called = true
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	cloudWatchWriter.Close()
+
+	assert.False(t, called)
+	stats := cloudWatchWriter.Stats()
+	assert.Equal(t, int64(0), stats.RejectedTooOld+stats.RejectedExpired+stats.RejectedTooNew)
+}
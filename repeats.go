@@ -0,0 +1,103 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// repeatedField is the field of the event which a run of identical logs is
+// collapsed into, counting the copies, see WithDuplicateSuppression.
+const repeatedField = "repeated"
+
+// repeats is the run of identical logs being collapsed, see
+// WithDuplicateSuppression.
+type repeats struct {
+	window time.Duration
+
+	mu sync.Mutex
+	// last is the last log which was queued, at since, and n is the number of
+	// copies of it written since, within the window, which haven't been
+	// queued.
+	last  string
+	since time.Time
+	n     int
+	// run tells the runs apart, so that the timer ending one doesn't end the
+	// next.
+	run int
+}
+
+// suppressRepeat reports whether the log is a copy of the last one written,
+// within the window, in which case it is counted rather than queued. When a
+// log isn't, the copies of the last one are queued as one event first.
+func (c *CloudWatchWriter) suppressRepeat(ctx context.Context, log []byte) bool {
+	r := &c.repeats
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := c.clock.Now()
+	if string(log) == r.last && now.Sub(r.since) < r.window {
+		r.n++
+		if r.n == 1 {
+			go c.endRepeatsAfter(r.run, r.since.Add(r.window).Sub(now))
+		}
+		return true
+	}
+
+	c.queueRepeats(ctx)
+	r.last = string(log)
+	r.since = now
+	r.run++
+	return false
+}
+
+// endRepeatsAfter queues the copies of the log of the run once the window has
+// passed, unless the run has already ended.
+func (c *CloudWatchWriter) endRepeatsAfter(run int, d time.Duration) {
+	timer := c.clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+	case <-c.ctx.Done():
+		return
+	}
+
+	r := &c.repeats
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.run == run {
+		c.queueRepeats(c.ctx)
+		r.last = ""
+	}
+}
+
+// endRepeats queues the copies of the last log which haven't been, for Flush
+// and Close.
+func (c *CloudWatchWriter) endRepeats() {
+	if c.repeats.window == 0 {
+		return
+	}
+
+	r := &c.repeats
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c.queueRepeats(context.Background())
+}
+
+// queueRepeats queues the copies of the last log, if there are any, as a copy
+// of it with the repeated field counting them. It is called with the lock
+// held.
+func (c *CloudWatchWriter) queueRepeats(ctx context.Context) {
+	r := &c.repeats
+	if r.n == 0 {
+		return
+	}
+
+	fields := []field{{name: repeatedField, value: []byte(strconv.Itoa(r.n))}}
+	_ = c.writeSummary(ctx, addFields(r.last, fields, true))
+	r.n = 0
+}
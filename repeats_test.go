@@ -0,0 +1,93 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterDuplicateSuppression(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithDuplicateSuppression(time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	for _, log := range []string{"a", "a", "a", "b", `{"error":"failed"}` + "\n", `{"error":"failed"}` + "\n", "a"} {
+		_, err = cloudWatchWriter.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	assert.Equal(t, []string{
+		"a",
+		`{"repeated":2,"message":"a"}`,
+		"b",
+		`{"error":"failed"}` + "\n",
+		`{"repeated":1,"error":"failed"}` + "\n",
+		"a",
+	}, client.Messages("logGroup", "logStream"))
+}
+
+func TestCloudWatchWriterDuplicateSuppressionWindow(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithDuplicateSuppression(time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err = cloudWatchWriter.Write([]byte("a"))
+		assert.NoError(t, err)
+	}
+
+	// Once the window has passed, the copies are queued without waiting for
+	// another log
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(time.Minute)
+	for cloudWatchWriter.Stats().QueuedEvents < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err = cloudWatchWriter.Write([]byte("a"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Pump())
+	assert.Equal(t, []string{"a", `{"repeated":2,"message":"a"}`, "a"}, client.Messages("logGroup", "logStream"))
+}
+
+func TestCloudWatchWriterDuplicateSuppressionClose(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithDuplicateSuppression(time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = cloudWatchWriter.Write([]byte("a"))
+		assert.NoError(t, err)
+	}
+	cloudWatchWriter.Close()
+	assert.Equal(t, 2, client.numLogs())
+}
+
+func TestCloudWatchWriterDuplicateSuppressionInvalid(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithDuplicateSuppression(-time.Second),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package cloudwatchwriter
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+const (
+	// defaultMaxAttempts is the number of times a batch is sent before giving
+	// up on it, including the first attempt.
+	defaultMaxAttempts = 5
+	// defaultInitialBackoff is the delay before the first retry of a batch.
+	defaultInitialBackoff = 200 * time.Millisecond
+	// defaultMaxBackoff caps the delay between two attempts at sending a batch.
+	defaultMaxBackoff = 10 * time.Second
+)
+
+// RetryPolicy controls how batches that fail to be sent to CloudWatch are
+// retried. The delay between attempts doubles after each attempt, starting at
+// InitialBackoff and capped at MaxBackoff, and is randomised so that writers
+// failing at the same time don't retry in lock step.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a batch is sent, including
+	// the first attempt, so 1 disables retries. Attempts which CloudWatch
+	// throttles are always retried, and don't count towards the maximum.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Zero means the
+	// default of 200 milliseconds.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between two attempts. Zero means the
+	// default of 10 seconds.
+	MaxBackoff time.Duration
+	// Retryable reports whether a PutLogEvents error is worth retrying. If nil,
+	// connection errors, timeouts, throttling and 5xx responses are retried,
+	// the same errors that the AWS SDK retries.
+	Retryable func(err error) bool
+}
+
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    defaultMaxAttempts,
+		InitialBackoff: defaultInitialBackoff,
+		MaxBackoff:     defaultMaxBackoff,
+	}
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return errors.New("retry policy must allow at least 1 attempt")
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		return errors.New("retry policy backoff must not be negative")
+	}
+	return nil
+}
+
+func (p RetryPolicy) isRetryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// backoff returns the delay before the retry following the given attempt,
+// which starts at 1. The delay is picked at random from the upper half of the
+// exponential backoff.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	initial, max := p.InitialBackoff, p.MaxBackoff
+	if initial == 0 {
+		initial = defaultInitialBackoff
+	}
+	if max == 0 {
+		max = defaultMaxBackoff
+	}
+
+	delay := initial
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
@@ -0,0 +1,271 @@
+package cloudwatchwriter_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+// serverError is a retryable error, like a 5xx response from CloudWatch.
+type serverError struct{}
+
+func (serverError) Error() string { return "internal server error" }
+
+func (serverError) HTTPStatusCode() int { return 500 }
+
+func TestCloudWatchWriterRetry(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}, serverError{}},
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Millisecond,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	cloudWatchWriter.Close()
+
+	assert.Equal(t, 1, client.numLogs())
+	assert.Equal(t, 3, client.numPutLogEventsCalls())
+}
+
+func TestCloudWatchWriterRetryMaxAttempts(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}, serverError{}, serverError{}},
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: 10 * time.Millisecond,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	time.Sleep(250 * time.Millisecond)
+
+	assert.Equal(t, 0, client.numLogs())
+	assert.Equal(t, 2, client.numPutLogEventsCalls())
+
+	_, err = cloudWatchWriter.Write([]byte("hello world"))
+	assert.Equal(t, serverError{}, err)
+}
+
+func TestCloudWatchWriterNoRetryOfPermanentErrors(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{errors.New("access denied")},
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	cloudWatchWriter.Close()
+
+	assert.Equal(t, 0, client.numLogs())
+	assert.Equal(t, 1, client.numPutLogEventsCalls())
+}
+
+func TestNewWithClientInvalidRetryPolicy(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{}),
+	)
+	assert.Error(t, err)
+}
+
+func TestCloudWatchWriterDataAlreadyAccepted(t *testing.T) {
+	client := &mockClient{
+		requireSequenceToken: true,
+		putLogEventsErrors: []error{
+			serverError{},
+			&types.DataAlreadyAcceptedException{ExpectedSequenceToken: aws.String(sequenceToken)},
+		},
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithSequenceTokens(),
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Millisecond,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// The retry of the first batch finds that it was accepted after all
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 1"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 2, client.numPutLogEventsCalls())
+
+	// The next batch uses the sequence token from the exception
+	client.setExpectedSequenceToken(aws.String(sequenceToken))
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message 2"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 3, client.numPutLogEventsCalls())
+	assert.Equal(t, 1, client.numLogs())
+}
+
+func TestCloudWatchWriterFallbackWriter(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+	var fallback bytes.Buffer
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+		cloudwatchwriter.WithFallbackWriter(&fallback),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, err = cloudWatchWriter.Write([]byte("first"))
+	assert.NoError(t, err)
+	_, err = cloudWatchWriter.Write([]byte("second\n"))
+	assert.NoError(t, err)
+	assert.Error(t, cloudWatchWriter.Flush())
+
+	assert.Equal(t, "first\nsecond\n", fallback.String())
+}
+
+func TestCloudWatchWriterDeadLetterHandler(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+	var deadLetters []types.InputLogEvent
+	var deadLetterErr error
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+		cloudwatchwriter.WithDeadLetterHandler(func(events []types.InputLogEvent, err error) {
+			deadLetters = append(deadLetters, events...)
+			deadLetterErr = err
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	err = cloudWatchWriter.Flush()
+	assert.Error(t, err)
+
+	if assert.Len(t, deadLetters, 1) {
+		assert.Contains(t, aws.ToString(deadLetters[0].Message), "Test message")
+	}
+	assert.Equal(t, err, deadLetterErr)
+}
+
+func TestCloudWatchWriterErrorHandler(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+	var handled []error
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+		cloudwatchwriter.WithErrorHandler(func(err error) {
+			handled = append(handled, err)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Len(t, handled, 1)
+
+	// The error isn't returned by the next Write either
+	_, err = cloudWatchWriter.Write([]byte("hello world"))
+	assert.NoError(t, err)
+}
+
+func TestCloudWatchWriterErrors(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	err = cloudWatchWriter.Flush()
+	assert.Error(t, err)
+
+	select {
+	case received := <-cloudWatchWriter.Errors():
+		assert.Equal(t, err, received)
+	default:
+		t.Fatal("no error received")
+	}
+
+	// The channel is closed with the writer
+	cloudWatchWriter.Close()
+	_, ok := <-cloudWatchWriter.Errors()
+	assert.False(t, ok)
+}
+
+func TestCloudWatchWriterWriteNeverFails(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+		cloudwatchwriter.WithOversizePolicy(cloudwatchwriter.Reject),
+		cloudwatchwriter.WithWriteNeverFails(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// A dropped log doesn't fail
+	log := make([]byte, 300*1024)
+	n, err := cloudWatchWriter.Write(log)
+	assert.NoError(t, err)
+	assert.Equal(t, len(log), n)
+	assert.Equal(t, int64(1), cloudWatchWriter.Stats().DroppedTooLarge)
+
+	// Neither does a batch which isn't sent
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+	_, err = cloudWatchWriter.Write([]byte("hello world"))
+	assert.NoError(t, err)
+
+	// But it is still reported
+	select {
+	case err = <-cloudWatchWriter.Errors():
+		assert.Error(t, err)
+	default:
+		t.Fatal("no error received")
+	}
+}
@@ -0,0 +1,92 @@
+package cloudwatchwriter
+
+// minRingCapacity is the initial capacity of a ring, which it never shrinks
+// below.
+const minRingCapacity = 64
+
+// ring is a FIFO queue, of log events or what is kept about them, backed by a
+// circular buffer, which grows as needed and shrinks again once it has
+// drained. It is not safe for concurrent use.
+type ring[T any] struct {
+	buf   []T
+	head  int
+	count int
+}
+
+func (r *ring[T]) len() int {
+	return r.count
+}
+
+func (r *ring[T]) push(item T) {
+	if r.count == len(r.buf) {
+		r.resize(2 * len(r.buf))
+	}
+	r.buf[(r.head+r.count)%len(r.buf)] = item
+	r.count++
+}
+
+// pop removes and returns the oldest item, ok is false if the ring is empty.
+func (r *ring[T]) pop() (item T, ok bool) {
+	if r.count == 0 {
+		return item, false
+	}
+
+	item = r.buf[r.head]
+	// Don't hold on to the message
+	var zero T
+	r.buf[r.head] = zero
+	r.head = (r.head + 1) % len(r.buf)
+	r.count--
+
+	r.shrink()
+	return item, true
+}
+
+// popN removes up to n of the oldest items, appending them to dst, with a copy
+// for each of the at most two runs they take up in the buffer.
+func (r *ring[T]) popN(dst []T, n int) []T {
+	n = min(n, r.count)
+	for n > 0 {
+		end := min(r.head+n, len(r.buf))
+		dst = append(dst, r.buf[r.head:end]...)
+		// Don't hold on to the messages
+		clear(r.buf[r.head:end])
+		popped := end - r.head
+		r.head = end % len(r.buf)
+		r.count -= popped
+		n -= popped
+	}
+
+	r.shrink()
+	return dst
+}
+
+// shrink halves the buffer, as many times as needed, once it is no more than
+// a quarter full.
+func (r *ring[T]) shrink() {
+	capacity := len(r.buf)
+	for capacity > minRingCapacity && r.count <= capacity/4 {
+		capacity /= 2
+	}
+	if capacity != len(r.buf) {
+		r.resize(capacity)
+	}
+}
+
+func (r *ring[T]) resize(capacity int) {
+	if capacity < minRingCapacity {
+		capacity = minRingCapacity
+	}
+
+	buf := make([]T, capacity)
+	if r.count > 0 {
+		if r.head+r.count <= len(r.buf) {
+			copy(buf, r.buf[r.head:r.head+r.count])
+		} else {
+			n := copy(buf, r.buf[r.head:])
+			copy(buf[n:], r.buf[:r.count-n])
+		}
+	}
+	r.buf = buf
+	r.head = 0
+}
@@ -0,0 +1,66 @@
+package cloudwatchwriter
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing(t *testing.T) {
+	var r ring[types.InputLogEvent]
+
+	_, ok := r.pop()
+	assert.False(t, ok)
+
+	// Interleave pushes and pops so that the ring wraps around while growing
+	// and shrinking.
+	next, expected := 0, 0
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 1000; i++ {
+			r.push(types.InputLogEvent{Message: aws.String(strconv.Itoa(next))})
+			next++
+			if i%3 == 0 {
+				event, ok := r.pop()
+				if assert.True(t, ok) {
+					assert.Equal(t, strconv.Itoa(expected), *event.Message)
+				}
+				expected++
+			}
+		}
+		for r.len() > 0 {
+			event, _ := r.pop()
+			assert.Equal(t, strconv.Itoa(expected), *event.Message)
+			expected++
+		}
+		assert.Equal(t, minRingCapacity, len(r.buf))
+	}
+	assert.Equal(t, next, expected)
+}
+
+func TestRingPopN(t *testing.T) {
+	var r ring[int]
+
+	// Wrap around, so that the items take up two runs of the buffer
+	for i := 0; i < 48; i++ {
+		r.push(i)
+	}
+	for i := 0; i < 40; i++ {
+		r.pop()
+	}
+	for i := 48; i < 1000; i++ {
+		r.push(i)
+	}
+
+	items := r.popN(nil, 10)
+	assert.Equal(t, []int{40, 41, 42, 43, 44, 45, 46, 47, 48, 49}, items)
+	items = r.popN(items[:0], 2000)
+	assert.Len(t, items, 950)
+	assert.Equal(t, 50, items[0])
+	assert.Equal(t, 999, items[len(items)-1])
+	assert.Equal(t, 0, r.len())
+	assert.Equal(t, minRingCapacity, len(r.buf))
+	assert.Empty(t, r.popN(nil, 10))
+}
@@ -0,0 +1,168 @@
+package cloudwatchwriter
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// rotationRetryInterval is how long to wait before trying again to switch to
+// the next period's log stream, when SetLogStream failed.
+const rotationRetryInterval = 10 * time.Second
+
+// rotation is how the writer rotates log streams, see WithLogStreamRotation
+// and WithLogStreamSizeRotation.
+type rotation struct {
+	// base is the log stream name given to the constructor, which the names
+	// of the rotated log streams start with, once expanded.
+	base   string
+	period time.Duration
+	layout string
+	// maxEvents and maxBytes, if not zero, are the number of events and
+	// bytes of messages sent to a log stream before switching to the next
+	// one, whose name ends with suffix(n) for the nth.
+	maxEvents int64
+	maxBytes  int64
+	suffix    func(n int) string
+	// full receives a value when the current log stream has reached one of
+	// the limits.
+	full chan struct{}
+}
+
+// rotatedLogStreamName returns the name of the nth log stream of the period
+// containing t, counting from zero, which has no suffix.
+func (c *CloudWatchWriter) rotatedLogStreamName(t time.Time, n int) string {
+	r := c.rotation
+	name := c.expandName(r.base, t)
+	if r.period > 0 {
+		name += "-" + t.UTC().Truncate(r.period).Format(r.layout)
+	}
+	if n > 0 {
+		name += r.suffix(n)
+	}
+	return name
+}
+
+func (r rotation) bySize() bool {
+	return r.maxEvents > 0 || r.maxBytes > 0
+}
+
+func defaultLogStreamSuffix(n int) string {
+	return "-" + strconv.Itoa(n)
+}
+
+// setupRotation checks the rotation options and switches to the first log
+// stream, before the writer is set up.
+func (c *CloudWatchWriter) setupRotation() error {
+	if c.rotation.period != 0 {
+		if c.rotation.period < time.Minute {
+			return errors.New("log stream rotation period must be at least a minute")
+		}
+		if c.rotation.layout == "" {
+			return errors.New("log stream rotation layout must not be empty")
+		}
+	}
+	if c.rotation.maxEvents < 0 || c.rotation.maxBytes < 0 {
+		return errors.New("log stream rotation size must not be negative")
+	}
+	if c.rotation.period == 0 && !c.rotation.bySize() {
+		return nil
+	}
+	if c.rotation.suffix == nil {
+		c.rotation.suffix = defaultLogStreamSuffix
+	}
+
+	c.rotation.base = c.logStreamTemplate
+	c.rotation.full = make(chan struct{}, 1)
+	c.logStreamName = aws.String(c.rotatedLogStreamName(c.clock.Now(), 0))
+	return nil
+}
+
+// rotateLogStreams switches to the next log stream at the start of each
+// period, and when the current one is full, until the writer is closed. If it
+// fails, the error is reported like an error sending logs, and it tries again
+// shortly, or once the next batch has been sent for a full log stream.
+func (c *CloudWatchWriter) rotateLogStreams() {
+	var timeout <-chan time.Time
+	var timer Timer
+	if c.rotation.period > 0 {
+		timer = c.clock.NewTimer(c.untilNextPeriod())
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+
+	n := 0
+	for {
+		var next int
+		var wait time.Duration
+		select {
+		case <-timeout:
+			wait = c.untilNextPeriod()
+		case <-c.rotation.full:
+			if !c.logStreamFull() {
+				// Already switched since
+				continue
+			}
+			next = n + 1
+		case <-c.done:
+			return
+		}
+
+		name := c.rotatedLogStreamName(c.clock.Now(), next)
+		err := c.SetLogStream(name)
+		switch {
+		case err == nil:
+			n = next
+		case errors.Is(err, ErrClosed):
+			return
+		default:
+			c.logf("switching to log stream %s: %v", name, err)
+			c.reportErr(err)
+			wait = min(wait, rotationRetryInterval)
+		}
+		if wait > 0 {
+			timer.Reset(wait)
+		}
+	}
+}
+
+// untilNextPeriod returns the time until the next rotation period starts.
+func (c *CloudWatchWriter) untilNextPeriod() time.Duration {
+	now := c.clock.Now()
+	return now.Truncate(c.rotation.period).Add(c.rotation.period).Sub(now)
+}
+
+// sentToLogStream counts the events of a batch sent to the current log
+// stream, letting rotateLogStreams know once it is full.
+func (c *CloudWatchWriter) sentToLogStream(batch []types.InputLogEvent) {
+	if !c.rotation.bySize() {
+		return
+	}
+
+	c.Lock()
+	c.logStreamEvents += int64(len(batch))
+	for _, event := range batch {
+		c.logStreamBytes += int64(len(aws.ToString(event.Message)))
+	}
+	c.Unlock()
+
+	if c.logStreamFull() {
+		select {
+		case c.rotation.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// logStreamFull returns whether the current log stream has reached one of the
+// limits of WithLogStreamSizeRotation.
+func (c *CloudWatchWriter) logStreamFull() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return (c.rotation.maxEvents > 0 && c.logStreamEvents >= c.rotation.maxEvents) ||
+		(c.rotation.maxBytes > 0 && c.logStreamBytes >= c.rotation.maxBytes)
+}
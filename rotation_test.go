@@ -0,0 +1,109 @@
+package cloudwatchwriter_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterLogStreamRotation(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	// Half an hour before midnight yesterday, so that CloudWatch accepts the
+	// timestamps
+	midnight := time.Now().UTC().Truncate(24 * time.Hour)
+	clock := cloudwatchwritertest.NewClock(midnight.Add(-30 * time.Minute))
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "app",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithLogStreamRotation(24*time.Hour, "2006-01-02"),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	yesterday := "app-" + midnight.Add(-time.Hour).Format("2006-01-02")
+	today := "app-" + midnight.Format("2006-01-02")
+
+	_, err = cloudWatchWriter.Write([]byte("before midnight"))
+	assert.NoError(t, err)
+
+	// At midnight the logs written so far are flushed to yesterday's log
+	// stream, and the writer switches to today's, setting the timer for
+	// the next midnight once it has
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(30 * time.Minute)
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	assert.Equal(t, []string{"before midnight"}, client.Messages("logGroup", yesterday))
+
+	_, err = cloudWatchWriter.Write([]byte("after midnight"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Pump())
+	assert.Equal(t, []string{"after midnight"}, client.Messages("logGroup", today))
+}
+
+func TestCloudWatchWriterLogStreamRotationInvalid(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "app",
+		cloudwatchwriter.WithLogStreamRotation(time.Second, "2006-01-02"),
+	)
+	assert.Error(t, err)
+
+	_, err = cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "app",
+		cloudwatchwriter.WithLogStreamRotation(time.Hour, ""),
+	)
+	assert.Error(t, err)
+}
+
+func TestCloudWatchWriterLogStreamSizeRotation(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	created := make(chan string, 10)
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "app",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithLogStreamSizeRotation(2, 0, func(n int) string {
+			return fmt.Sprintf(".part%d", n)
+		}),
+		cloudwatchwriter.WithMiddleware(func(next cloudwatchwriter.Call) cloudwatchwriter.Call {
+			return func(ctx context.Context, operation string, input interface{}) (interface{}, error) {
+				if in, ok := input.(*cloudwatchlogs.CreateLogStreamInput); ok {
+					created <- aws.ToString(in.LogStreamName)
+				}
+				return next(ctx, operation, input)
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+	assert.Equal(t, "app", <-created)
+
+	for _, message := range []string{"one", "two"} {
+		_, err = cloudWatchWriter.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	// The log stream is full, so the writer switches to the next one, and
+	// SetLogStream waits for it to have switched
+	select {
+	case name := <-created:
+		assert.Equal(t, "app.part1", name)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the next log stream")
+	}
+	assert.NoError(t, cloudWatchWriter.SetLogStream("app.part1"))
+
+	_, err = cloudWatchWriter.Write([]byte("three"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Pump())
+	assert.Equal(t, []string{"one", "two"}, client.Messages("logGroup", "app"))
+	assert.Equal(t, []string{"three"}, client.Messages("logGroup", "app.part1"))
+}
@@ -0,0 +1,245 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/rs/zerolog"
+)
+
+// Route is one of the log streams of a Router, which receives the logs at
+// MinLevel or above which don't go to a route with a higher MinLevel.
+type Route struct {
+	MinLevel      zerolog.Level
+	LogStreamName string
+}
+
+// Router sends each log to one of the log streams of a log group, according to
+// its level, such as errors to app-errors and the rest to app-info. The log
+// streams share the client, and the logs of all of them are sent by one
+// goroutine, every batch interval, so a Router costs little more than a
+// CloudWatchWriter however many routes it has.
+type Router struct {
+	// routes are sorted by MinLevel, highest first, and writers[i] sends the
+	// logs of routes[i].
+	routes  []Route
+	writers []*CloudWatchWriter
+	loop    *sendLoop
+}
+
+// NewRouter returns a Router sending the logs to the routes' log streams in
+// the log group, or an error. The options apply to the writer of each route,
+// see New, except for WithManualStart, WithSpool and WithExpvar, which a Router
+// doesn't support.
+func NewRouter(cfg aws.Config, logGroupName string, routes []Route, opts ...Option) (*Router, error) {
+	return NewRouterWithClient(cloudwatchlogs.NewFromConfig(cfg), defaultBatchInterval, logGroupName, routes, opts...)
+}
+
+// NewRouterWithClient is like NewRouter, with the client used by every route,
+// see NewWithClient.
+func NewRouterWithClient(client CloudWatchLogsClient, batchInterval time.Duration, logGroupName string, routes []Route, opts ...Option) (*Router, error) {
+	if len(routes) == 0 {
+		return nil, errors.New("a router needs at least one route")
+	}
+	routes = append([]Route(nil), routes...)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].MinLevel > routes[j].MinLevel
+	})
+	for i := 1; i < len(routes); i++ {
+		if routes[i].MinLevel == routes[i-1].MinLevel {
+			return nil, fmt.Errorf("two routes have the minimum level %s", routes[i].MinLevel)
+		}
+	}
+	loop, opts, err := newSendLoop(batchInterval, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Router{
+		routes: routes,
+		loop:   loop,
+	}
+	for _, route := range routes {
+		writer, err := NewWithClient(client, batchInterval, logGroupName, route.LogStreamName, opts...)
+		if err != nil {
+			for _, c := range r.writers {
+				c.Close()
+			}
+			return nil, fmt.Errorf("route %s: %w", route.LogStreamName, err)
+		}
+		r.writers = append(r.writers, writer)
+	}
+
+	loop.start(func() []*CloudWatchWriter { return r.writers })
+	return r, nil
+}
+
+// route returns the writer for logs at the level. Logs without a level go to
+// the route with the lowest MinLevel, as do those below it.
+func (r *Router) route(level zerolog.Level) *CloudWatchWriter {
+	if level != zerolog.NoLevel {
+		for i, route := range r.routes {
+			if level >= route.MinLevel {
+				return r.writers[i]
+			}
+		}
+	}
+	return r.writers[len(r.writers)-1]
+}
+
+// Write writes the log to the route for its level, given by its
+// zerolog.LevelFieldName field, see CloudWatchWriter.Write.
+func (r *Router) Write(log []byte) (int, error) {
+	return r.route(logLevel(log)).Write(log)
+}
+
+// WriteLevel implements the zerolog.LevelWriter interface, writing the log to
+// the route for the level, which discards it if it is below the writer's
+// minimum level, see CloudWatchWriter.WriteLevel.
+func (r *Router) WriteLevel(level zerolog.Level, log []byte) (int, error) {
+	return r.route(level).WriteLevel(level, log)
+}
+
+// Flush sends the logs written so far to every log stream, and blocks until
+// they have been sent, returning the first error, see CloudWatchWriter.Flush.
+func (r *Router) Flush() error {
+	var firstErr error
+	for i, c := range r.writers {
+		if err := c.Flush(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("route %s: %w", r.routes[i].LogStreamName, err)
+		}
+	}
+	return firstErr
+}
+
+// Close blocks until the router has completed writing the logs to CloudWatch.
+func (r *Router) Close() {
+	_ = r.CloseWithContext(context.Background())
+}
+
+// CloseWithContext closes the writer of every route at the same time, see
+// CloudWatchWriter.CloseWithContext, returning the first error.
+func (r *Router) CloseWithContext(ctx context.Context) error {
+	r.loop.close()
+
+	for i, err := range closeAll(ctx, r.writers) {
+		if err != nil {
+			return fmt.Errorf("route %s: %w", r.routes[i].LogStreamName, err)
+		}
+	}
+	return nil
+}
+
+// closeAll closes the writers at the same time, returning their errors.
+func closeAll(ctx context.Context, writers []*CloudWatchWriter) []error {
+	errs := make([]error, len(writers))
+	var wg sync.WaitGroup
+	for i, c := range writers {
+		wg.Add(1)
+		go func(i int, c *CloudWatchWriter) {
+			defer wg.Done()
+			errs[i] = c.CloseWithContext(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+	return errs
+}
+
+// sendLoop sends the logs of a router's writers, which are pumped manually,
+// every batch interval. Each writer's batch interval grows while CloudWatch
+// throttles it, and the longest one is used.
+type sendLoop struct {
+	clock         Clock
+	batchInterval time.Duration
+	stop          chan struct{}
+	stopped       chan struct{}
+	stopOnce      sync.Once
+}
+
+// probeOptions applies the options to a writer which is never used, to find
+// out what they set before any writer is created.
+func probeOptions(batchInterval time.Duration, opts []Option) *CloudWatchWriter {
+	probe := &CloudWatchWriter{
+		batchInterval: batchInterval,
+		clock:         systemClock{},
+	}
+	for _, opt := range opts {
+		opt(probe)
+	}
+	return probe
+}
+
+// newSendLoop checks the options given to a router, returning the loop and
+// the options for its writers. The clock and batch interval are found with
+// probeOptions.
+func newSendLoop(batchInterval time.Duration, opts []Option) (*sendLoop, []Option, error) {
+	probe := probeOptions(batchInterval, opts)
+	switch {
+	case probe.manualStart:
+		return nil, nil, errors.New("a router can't be started manually")
+	case probe.spoolDir != "":
+		return nil, nil, errors.New("a router can't use a spool")
+	case probe.expvarName != "":
+		// Each writer would publish under the same name
+		return nil, nil, errors.New("a router can't publish its stats with expvar")
+	case probe.clock == nil:
+		return nil, nil, errors.New("clock must not be nil")
+	}
+
+	loop := &sendLoop{
+		clock:         probe.clock,
+		batchInterval: probe.batchInterval,
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	opts = append(opts[:len(opts):len(opts)], WithManualPump())
+	if probe.numSenders > 0 {
+		// The writers share the senders, sending their batches in parallel
+		opts = append(opts, withSenders(newSenders(probe.numSenders)))
+	}
+	return loop, opts, nil
+}
+
+// start starts sending the logs of the writers returned by writers, until the
+// loop is closed.
+func (l *sendLoop) start(writers func() []*CloudWatchWriter) {
+	go func() {
+		defer close(l.stopped)
+
+		timer := l.clock.NewTimer(l.interval(writers()))
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C():
+				current := writers()
+				for _, c := range current {
+					c.startPump()
+				}
+				timer.Reset(l.interval(current))
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (l *sendLoop) interval(writers []*CloudWatchWriter) time.Duration {
+	interval := l.batchInterval
+	for _, c := range writers {
+		interval = max(interval, c.getEffectiveBatchInterval())
+	}
+	return interval
+}
+
+// close stops the loop, returning once it has stopped.
+func (l *sendLoop) close() {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.stopped
+}
@@ -0,0 +1,107 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestRouter(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	router, err := cloudwatchwriter.NewRouterWithClient(client, 200*time.Millisecond, "logGroup", []cloudwatchwriter.Route{
+		{MinLevel: zerolog.DebugLevel, LogStreamName: "app-info"},
+		{MinLevel: zerolog.ErrorLevel, LogStreamName: "app-errors"},
+	})
+	if err != nil {
+		t.Fatalf("NewRouterWithClient: %v", err)
+	}
+	defer router.Close()
+
+	logger := zerolog.New(router)
+	logger.Info().Msg("info")
+	logger.Error().Msg("error")
+	// Below the lowest route's level, so it goes to that route, whose
+	// writer keeps trace logs by default
+	logger.Trace().Msg("trace")
+	logger.Log().Msg("no level")
+	assert.NoError(t, router.Flush())
+
+	assert.Equal(t, []string{
+		`{"level":"info","message":"info"}` + "\n",
+		`{"level":"trace","message":"trace"}` + "\n",
+		`{"message":"no level"}` + "\n",
+	}, client.Messages("logGroup", "app-info"))
+	assert.Equal(t, []string{`{"level":"error","message":"error"}` + "\n"}, client.Messages("logGroup", "app-errors"))
+}
+
+func TestRouterWrite(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	router, err := cloudwatchwriter.NewRouterWithClient(client, 200*time.Millisecond, "logGroup", []cloudwatchwriter.Route{
+		{MinLevel: zerolog.WarnLevel, LogStreamName: "app-warnings"},
+		{MinLevel: zerolog.InfoLevel, LogStreamName: "app-info"},
+	})
+	if err != nil {
+		t.Fatalf("NewRouterWithClient: %v", err)
+	}
+	defer router.Close()
+
+	// Write routes by the level field, rather than the level of the event
+	for _, log := range []string{`{"level":"warn"}`, `{"level":"panic"}`, `{"level":"info"}`, `not JSON`} {
+		_, err = router.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, router.Flush())
+
+	assert.Equal(t, []string{`{"level":"warn"}`, `{"level":"panic"}`}, client.Messages("logGroup", "app-warnings"))
+	assert.Equal(t, []string{`{"level":"info"}`, `not JSON`}, client.Messages("logGroup", "app-info"))
+}
+
+func TestRouterSendsEveryBatchInterval(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	router, err := cloudwatchwriter.NewRouterWithClient(client, time.Second, "logGroup", []cloudwatchwriter.Route{
+		{MinLevel: zerolog.DebugLevel, LogStreamName: "app-info"},
+		{MinLevel: zerolog.ErrorLevel, LogStreamName: "app-errors"},
+	}, cloudwatchwriter.WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewRouterWithClient: %v", err)
+	}
+	defer router.Close()
+
+	logger := zerolog.New(router)
+	logger.Info().Msg("info")
+	logger.Error().Msg("error")
+
+	// One timer sends the logs of both log streams
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	assert.Equal(t, 1, clock.Timers())
+	clock.Advance(time.Second)
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	assert.Len(t, client.Messages("logGroup", "app-info"), 1)
+	assert.Len(t, client.Messages("logGroup", "app-errors"), 1)
+}
+
+func TestRouterInvalid(t *testing.T) {
+	_, err := cloudwatchwriter.NewRouterWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", nil)
+	assert.Error(t, err)
+
+	_, err = cloudwatchwriter.NewRouterWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", []cloudwatchwriter.Route{
+		{MinLevel: zerolog.InfoLevel, LogStreamName: "a"},
+		{MinLevel: zerolog.InfoLevel, LogStreamName: "b"},
+	})
+	assert.Error(t, err)
+
+	_, err = cloudwatchwriter.NewRouterWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", []cloudwatchwriter.Route{
+		{MinLevel: zerolog.InfoLevel, LogStreamName: "a"},
+	}, cloudwatchwriter.WithSpool(t.TempDir()))
+	assert.Error(t, err)
+
+	_, err = cloudwatchwriter.NewRouterWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", []cloudwatchwriter.Route{
+		{MinLevel: zerolog.InfoLevel, LogStreamName: "a"},
+	}, cloudwatchwriter.WithExpvar("router"))
+	assert.Error(t, err)
+}
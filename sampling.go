@@ -0,0 +1,60 @@
+package cloudwatchwriter
+
+import (
+	"hash/fnv"
+	"math/rand"
+
+	"github.com/rs/zerolog"
+)
+
+// sampling is how the writer samples logs, see WithSampling.
+type sampling struct {
+	// n is how many logs below level there are for each one kept, sampling
+	// is off if it is zero.
+	n     int
+	level zerolog.Level
+	// key is the field whose value decides whether a log is kept, see
+	// WithSamplingKey.
+	key string
+}
+
+// sampledOut reports whether a log at the level is discarded by sampling,
+// counting it if it is. Logs without a level are always kept.
+func (c *CloudWatchWriter) sampledOut(level zerolog.Level, log []byte) bool {
+	s := c.sampling
+	if s.n <= 1 || level == zerolog.NoLevel || level >= s.level {
+		return false
+	}
+	if s.keep(log) {
+		return false
+	}
+
+	c.Lock()
+	c.stats.SampledOut++
+	c.Unlock()
+	return true
+}
+
+// levelOf returns the level of a log written without one, read from its
+// zerolog.LevelFieldName field only if an option needs it, otherwise
+// zerolog.NoLevel.
+func (c *CloudWatchWriter) levelOf(log []byte) zerolog.Level {
+	if !c.flushOnLevel && c.sampling.n <= 1 {
+		return zerolog.NoLevel
+	}
+	return logLevel(log)
+}
+
+// keep decides whether a log below the level is kept: at random, or by the
+// hash of the value of the key field, so that every log with the same value is
+// kept or discarded together.
+func (s sampling) keep(log []byte) bool {
+	if s.key != "" {
+		if value := fieldValue(log, s.key); value != "" {
+			h := fnv.New32a()
+			h.Write([]byte(value))
+			return h.Sum32()%uint32(s.n) == 0
+		}
+	}
+	return rand.Intn(s.n) == 0
+}
@@ -0,0 +1,104 @@
+package cloudwatchwriter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterSampling(t *testing.T) {
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithSampling(1<<30, zerolog.WarnLevel),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	logger := zerolog.New(cloudWatchWriter)
+	for i := 0; i < 100; i++ {
+		logger.Info().Msg("info")
+	}
+	logger.Warn().Msg("warn")
+	logger.Error().Msg("error")
+	logger.Log().Msg("no level")
+	// Write reads the level field
+	_, err = cloudWatchWriter.Write([]byte(`{"level":"debug"}`))
+	assert.NoError(t, err)
+	_, err = cloudWatchWriter.Write([]byte(`{"level":"warn"}`))
+	assert.NoError(t, err)
+
+	stats := cloudWatchWriter.Stats()
+	assert.Equal(t, int64(101), stats.SampledOut)
+	assert.Equal(t, 4, stats.QueuedEvents)
+}
+
+func TestCloudWatchWriterSamplingRate(t *testing.T) {
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithSampling(2, zerolog.WarnLevel),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	logger := zerolog.New(cloudWatchWriter)
+	for i := 0; i < 1000; i++ {
+		logger.Debug().Msg("debug")
+	}
+
+	// About half are kept
+	stats := cloudWatchWriter.Stats()
+	assert.Equal(t, int64(1000), stats.SampledOut+int64(stats.QueuedEvents))
+	assert.InDelta(t, 500, stats.QueuedEvents, 100)
+}
+
+func TestCloudWatchWriterSamplingKey(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithSampling(4, zerolog.WarnLevel),
+		cloudwatchwriter.WithSamplingKey("request_id"),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	logger := zerolog.New(cloudWatchWriter)
+	for i := 0; i < 5; i++ {
+		for id := 0; id < 100; id++ {
+			logger.Info().Str("request_id", fmt.Sprint("request-", id)).Msg("info")
+		}
+	}
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	// Each request has either all of its logs or none of them
+	logs := make(map[string]int)
+	for _, event := range client.getLogEvents() {
+		var log struct {
+			RequestID string `json:"request_id"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(*event.Message), &log))
+		logs[log.RequestID]++
+	}
+	for id, n := range logs {
+		assert.Equal(t, 5, n, id)
+	}
+	assert.InDelta(t, 25, len(logs), 15)
+	assert.Equal(t, int64(500-5*len(logs)), cloudWatchWriter.Stats().SampledOut)
+}
+
+func TestCloudWatchWriterSamplingInvalid(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithSampling(-1, zerolog.WarnLevel),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,29 @@
+package cloudwatchwriter
+
+// senders limits the number of batches being sent at once by the writers
+// sharing it, see WithSenders. Each batch is sent by a goroutine of its own.
+type senders struct {
+	slots chan struct{}
+}
+
+func newSenders(n int) *senders {
+	return &senders{slots: make(chan struct{}, n)}
+}
+
+// send calls fn on another goroutine, once fewer than the maximum number of
+// batches are being sent.
+func (s *senders) send(fn func()) {
+	s.slots <- struct{}{}
+	go func() {
+		defer func() { <-s.slots }()
+		fn()
+	}()
+}
+
+// withSenders makes the writer share the senders with the other writers of a
+// router, or the streams of a writer.
+func withSenders(s *senders) Option {
+	return func(c *CloudWatchWriter) {
+		c.senders = s
+	}
+}
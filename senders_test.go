@@ -0,0 +1,132 @@
+package cloudwatchwriter_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+// blockingPuts holds the PutLogEvents calls until they are released, keeping
+// track of how many are made at once.
+type blockingPuts struct {
+	started chan string
+	release chan struct{}
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func newBlockingPuts() *blockingPuts {
+	return &blockingPuts{started: make(chan string, 10), release: make(chan struct{})}
+}
+
+func (b *blockingPuts) middleware(next cloudwatchwriter.Call) cloudwatchwriter.Call {
+	return func(ctx context.Context, operation string, input interface{}) (interface{}, error) {
+		if operation != "PutLogEvents" {
+			return next(ctx, operation, input)
+		}
+		b.mu.Lock()
+		b.inFlight++
+		b.maxInFlight = max(b.maxInFlight, b.inFlight)
+		b.mu.Unlock()
+		defer func() {
+			b.mu.Lock()
+			b.inFlight--
+			b.mu.Unlock()
+		}()
+
+		b.started <- *input.(*cloudwatchlogs.PutLogEventsInput).LogStreamName
+		<-b.release
+		return next(ctx, operation, input)
+	}
+}
+
+func (b *blockingPuts) waitForStart(t *testing.T) string {
+	t.Helper()
+	select {
+	case logStreamName := <-b.started:
+		return logStreamName
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for PutLogEvents")
+		return ""
+	}
+}
+
+func TestSendersSendLogStreamsInParallel(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	puts := newBlockingPuts()
+	router, err := cloudwatchwriter.NewRouterWithClient(client, time.Second, "logGroup", []cloudwatchwriter.Route{
+		{MinLevel: zerolog.DebugLevel, LogStreamName: "app-info"},
+		{MinLevel: zerolog.ErrorLevel, LogStreamName: "app-errors"},
+	},
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithMiddleware(puts.middleware),
+		cloudwatchwriter.WithSenders(2),
+	)
+	if err != nil {
+		t.Fatalf("NewRouterWithClient: %v", err)
+	}
+	defer router.Close()
+
+	logger := zerolog.New(router)
+	logger.Info().Msg("info")
+	logger.Error().Msg("error")
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(time.Second)
+
+	// Both log streams' batches are being sent at once
+	assert.ElementsMatch(t, []string{"app-info", "app-errors"}, []string{puts.waitForStart(t), puts.waitForStart(t)})
+	close(puts.release)
+	assert.NoError(t, router.Flush())
+	assert.Len(t, client.Messages("logGroup", "app-info"), 1)
+	assert.Len(t, client.Messages("logGroup", "app-errors"), 1)
+}
+
+func TestSendersKeepLogStreamOrder(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	puts := newBlockingPuts()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Second, "logGroup", "app",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithMiddleware(puts.middleware),
+		cloudwatchwriter.WithSenders(4),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	_, err = cloudWatchWriter.Write([]byte("first"))
+	assert.NoError(t, err)
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(time.Second)
+	assert.Equal(t, "app", puts.waitForStart(t))
+
+	// The writer carries on batching while the first batch is being sent, and
+	// the second waits for it
+	_, err = cloudWatchWriter.Write([]byte("second"))
+	assert.NoError(t, err)
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	clock.Advance(time.Second)
+	close(puts.release)
+
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, []string{"first", "second"}, client.Messages("logGroup", "app"))
+	assert.Equal(t, 1, puts.maxInFlight)
+}
+
+func TestSendersInvalid(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(cloudwatchwritertest.NewClient(), time.Second, "logGroup", "app",
+		cloudwatchwriter.WithSenders(-1),
+	)
+	assert.Error(t, err)
+}
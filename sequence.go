@@ -0,0 +1,19 @@
+package cloudwatchwriter
+
+import "strconv"
+
+// addSequenceNumber adds the next sequence number to the log, as the field of
+// WithSequenceNumbers, if there is one.
+func (c *CloudWatchWriter) addSequenceNumber(message string) string {
+	if c.sequenceField == "" {
+		return message
+	}
+
+	c.Lock()
+	c.sequenceNumber++
+	n := c.sequenceNumber
+	c.Unlock()
+
+	fields := []field{{name: c.sequenceField, value: []byte(strconv.FormatInt(n, 10))}}
+	return addFields(message, fields, true)
+}
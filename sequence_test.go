@@ -0,0 +1,48 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterSequenceNumbers(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualPump(),
+		cloudwatchwriter.WithSequenceNumbers("seq"),
+		cloudwatchwriter.WithMaxQueueSize(2, 0),
+		cloudwatchwriter.WithDenyFilters(cloudwatchwriter.FieldEquals("noisy", "true")),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	for _, log := range []string{
+		`{"message":"first"}`,
+		// Filtered out, so it isn't numbered
+		`{"noisy":true}`,
+		"second",
+		// Dropped, as the queue is full, so it leaves a gap
+		`{"message":"third"}`,
+	} {
+		_, _ = cloudWatchWriter.Write([]byte(log))
+	}
+	assert.NoError(t, cloudWatchWriter.Pump())
+	_, err = cloudWatchWriter.Write([]byte(`{"seq":"mine","message":"fourth"}`))
+	assert.NoError(t, err)
+	_, err = cloudWatchWriter.Write([]byte(`{"message":"fifth"}`))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Pump())
+
+	assert.Equal(t, []string{
+		`{"seq":1,"message":"first"}`,
+		`{"seq":2,"message":"second"}`,
+		`{"seq":"mine","message":"fourth"}`,
+		`{"seq":5,"message":"fifth"}`,
+	}, client.Messages("logGroup", "logStream"))
+}
@@ -0,0 +1,125 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/rs/zerolog"
+)
+
+// ShardedWriter spreads the logs over several log streams, the shards, in
+// turn, for a service writing more logs than one log stream can take. Unlike
+// those of a Router, the shards each have their own goroutine sending their
+// logs, so that their batches are sent in parallel. The logs written one after
+// the other are in different log streams, so their order is only kept by
+// their timestamps.
+type ShardedWriter struct {
+	shards []*CloudWatchWriter
+	// next is the number of logs written so far, which chooses the shard for
+	// the next one.
+	next atomic.Uint64
+}
+
+// NewShardedWriter returns a ShardedWriter sending the logs to n log streams
+// in the log group, named after logStreamName followed by -0, -1 and so on up
+// to n-1, or an error. The options apply to the writer of each shard, see New,
+// except for WithManualStart, WithSpool and WithExpvar, which a ShardedWriter
+// doesn't support.
+func NewShardedWriter(cfg aws.Config, logGroupName, logStreamName string, n int, opts ...Option) (*ShardedWriter, error) {
+	return NewShardedWriterWithClient(cloudwatchlogs.NewFromConfig(cfg), defaultBatchInterval, logGroupName, logStreamName, n, opts...)
+}
+
+// NewShardedWriterWithClient is like NewShardedWriter, with the client used by
+// every shard, see NewWithClient.
+func NewShardedWriterWithClient(client CloudWatchLogsClient, batchInterval time.Duration, logGroupName, logStreamName string, n int, opts ...Option) (*ShardedWriter, error) {
+	if n < 1 {
+		return nil, errors.New("a sharded writer needs at least one shard")
+	}
+	probe := probeOptions(batchInterval, opts)
+	switch {
+	case probe.manualStart:
+		return nil, errors.New("a sharded writer can't be started manually")
+	case probe.spoolDir != "":
+		return nil, errors.New("a sharded writer can't use a spool")
+	case probe.expvarName != "":
+		// Each shard would publish under the same name
+		return nil, errors.New("a sharded writer can't publish its stats with expvar")
+	}
+
+	s := &ShardedWriter{}
+	for i := 0; i < n; i++ {
+		name := logStreamName + "-" + strconv.Itoa(i)
+		writer, err := NewWithClient(client, batchInterval, logGroupName, name, opts...)
+		if err != nil {
+			for _, c := range s.shards {
+				c.Close()
+			}
+			return nil, fmt.Errorf("shard %s: %w", name, err)
+		}
+		s.shards = append(s.shards, writer)
+	}
+	return s, nil
+}
+
+// shard returns the writer for the next log.
+func (s *ShardedWriter) shard() *CloudWatchWriter {
+	return s.shards[(s.next.Add(1)-1)%uint64(len(s.shards))]
+}
+
+// Write writes the log to the next shard, see CloudWatchWriter.Write.
+func (s *ShardedWriter) Write(log []byte) (int, error) {
+	return s.shard().Write(log)
+}
+
+// WriteLevel implements the zerolog.LevelWriter interface, writing the log to
+// the next shard, which discards it if it is below the writers' minimum level,
+// see CloudWatchWriter.WriteLevel.
+func (s *ShardedWriter) WriteLevel(level zerolog.Level, log []byte) (int, error) {
+	return s.shard().WriteLevel(level, log)
+}
+
+// Flush sends the logs written so far to every shard at the same time, and
+// blocks until they have been sent, returning the first error, see
+// CloudWatchWriter.Flush.
+func (s *ShardedWriter) Flush() error {
+	errs := make([]error, len(s.shards))
+	var wg sync.WaitGroup
+	for i, c := range s.shards {
+		wg.Add(1)
+		go func(i int, c *CloudWatchWriter) {
+			defer wg.Done()
+			errs[i] = c.Flush()
+		}(i, c)
+	}
+	wg.Wait()
+	return s.firstErr(errs)
+}
+
+// Close blocks until the sharded writer has completed writing the logs to
+// CloudWatch.
+func (s *ShardedWriter) Close() {
+	_ = s.CloseWithContext(context.Background())
+}
+
+// CloseWithContext closes the writer of every shard at the same time, see
+// CloudWatchWriter.CloseWithContext, returning the first error.
+func (s *ShardedWriter) CloseWithContext(ctx context.Context) error {
+	return s.firstErr(closeAll(ctx, s.shards))
+}
+
+// firstErr returns the first of the shards' errors, with its log stream.
+func (s *ShardedWriter) firstErr(errs []error) error {
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("shard %s: %w", aws.ToString(s.shards[i].getLogStreamName()), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestShardedWriter(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	sharded, err := cloudwatchwriter.NewShardedWriterWithClient(client, time.Hour, "logGroup", "app", 3)
+	if err != nil {
+		t.Fatalf("NewShardedWriterWithClient: %v", err)
+	}
+	defer sharded.Close()
+
+	for _, log := range []string{"0", "1", "2", "3", "4"} {
+		_, err = sharded.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, sharded.Flush())
+
+	// The logs go to the shards in turn
+	assert.Equal(t, []string{"0", "3"}, client.Messages("logGroup", "app-0"))
+	assert.Equal(t, []string{"1", "4"}, client.Messages("logGroup", "app-1"))
+	assert.Equal(t, []string{"2"}, client.Messages("logGroup", "app-2"))
+}
+
+func TestShardedWriterSendsInParallel(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	sharded, err := cloudwatchwriter.NewShardedWriterWithClient(client, time.Second, "logGroup", "app", 2,
+		cloudwatchwriter.WithClock(clock),
+	)
+	if err != nil {
+		t.Fatalf("NewShardedWriterWithClient: %v", err)
+	}
+	defer sharded.Close()
+
+	_, err = sharded.Write([]byte("first"))
+	assert.NoError(t, err)
+	_, err = sharded.Write([]byte("second"))
+	assert.NoError(t, err)
+
+	// Each shard has its own timer
+	assert.NoError(t, clock.WaitForTimers(2, time.Second))
+	clock.Advance(time.Second)
+	_, err = client.WaitForEvents("logGroup", "app-0", 1, time.Second)
+	assert.NoError(t, err)
+	_, err = client.WaitForEvents("logGroup", "app-1", 1, time.Second)
+	assert.NoError(t, err)
+}
+
+func TestShardedWriterInvalid(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	for name, test := range map[string]struct {
+		n    int
+		opts []cloudwatchwriter.Option
+	}{
+		"no shards":    {n: 0},
+		"manual start": {n: 2, opts: []cloudwatchwriter.Option{cloudwatchwriter.WithManualStart()}},
+		"spool":        {n: 2, opts: []cloudwatchwriter.Option{cloudwatchwriter.WithSpool(t.TempDir())}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := cloudwatchwriter.NewShardedWriterWithClient(client, time.Hour, "logGroup", "app", test.n, test.opts...)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package cloudwatchwriter
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	// maxEventSize is 256KB, the limit imposed by AWS CloudWatch Logs on the
+	// size of a log event, including the additional bytes per log event, see:
+	// https://docs.aws.amazon.com/AmazonCloudWatchLogs/latest/APIReference/API_PutLogEvents.html
+	maxEventSize = 262144
+	// maxMessageSize is the maximum size of a log message in bytes.
+	maxMessageSize = maxEventSize - additionalBytesPerLogEvent
+	// truncatedMarker is appended to messages which have been truncated.
+	truncatedMarker = "...[TRUNCATED]"
+)
+
+// OversizePolicy decides what happens to a log which is larger than the 256KB
+// that CloudWatch accepts for a log event, see WithOversizePolicy.
+type OversizePolicy int
+
+const (
+	// Truncate cuts the log short, so that it fits into one log event with
+	// "...[TRUNCATED]" appended to it.
+	Truncate OversizePolicy = iota
+	// Split sends the log as several consecutive log events, with the same
+	// timestamp.
+	Split
+	// Reject discards the log, Write returns a *MessageTooLargeError.
+	Reject
+)
+
+// MessageTooLargeError is returned by Write for a log which is too large to be
+// sent to CloudWatch, with the Reject oversize policy.
+type MessageTooLargeError struct {
+	// Size is the size of the log in bytes.
+	Size int
+	// Limit is the maximum size of a log in bytes.
+	Limit int
+}
+
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("cloudwatchwriter: log of %d bytes is larger than the limit of %d bytes", e.Size, e.Limit)
+}
+
+// Is reports whether target is ErrMessageTooLarge.
+func (e *MessageTooLargeError) Is(target error) bool {
+	return target == ErrMessageTooLarge
+}
+
+// fitMessage applies the oversize policy to a message, returning the messages
+// to be sent in its place.
+func fitMessage(message string, policy OversizePolicy) ([]string, error) {
+	if len(message) <= maxMessageSize {
+		return []string{message}, nil
+	}
+
+	switch policy {
+	case Split:
+		var parts []string
+		for len(message) > maxMessageSize {
+			i := runeBoundary(message, maxMessageSize)
+			parts = append(parts, message[:i])
+			message = message[i:]
+		}
+		return append(parts, message), nil
+	case Reject:
+		return nil, &MessageTooLargeError{Size: len(message), Limit: maxMessageSize}
+	default:
+		i := runeBoundary(message, maxMessageSize-len(truncatedMarker))
+		return []string{message[:i] + truncatedMarker}, nil
+	}
+}
+
+// runeBoundary returns the largest index no greater than max at which a UTF-8
+// encoded rune starts, so that the message is not cut in the middle of a rune.
+func runeBoundary(message string, max int) int {
+	for i := max; i > max-utf8.UTFMax && i > 0; i-- {
+		if utf8.RuneStart(message[i]) {
+			return i
+		}
+	}
+	return max
+}
@@ -0,0 +1,83 @@
+package cloudwatchwriter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+// maxMessageSize is the largest message that fits into a 256KB log event.
+const maxMessageSize = 262144 - 26
+
+func helperWriteOversizeLog(t *testing.T, message string, opts ...cloudwatchwriter.Option) (*mockClient, error) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream", opts...)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	_, err = cloudWatchWriter.Write([]byte(message))
+	cloudWatchWriter.Close()
+	return client, err
+}
+
+func TestCloudWatchWriterMaxSizeLog(t *testing.T) {
+	message := strings.Repeat("a", maxMessageSize)
+	client, err := helperWriteOversizeLog(t, message)
+	assert.NoError(t, err)
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 1, len(logs)) {
+		assert.Equal(t, message, *logs[0].Message)
+	}
+}
+
+func TestCloudWatchWriterTruncate(t *testing.T) {
+	// The multi-byte runes mustn't be cut in half
+	message := strings.Repeat("€", maxMessageSize/3+1)
+	client, err := helperWriteOversizeLog(t, message)
+	assert.NoError(t, err)
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 1, len(logs)) {
+		truncated := *logs[0].Message
+		assert.True(t, len(truncated) <= maxMessageSize)
+		assert.True(t, utf8.ValidString(truncated))
+		assert.True(t, strings.HasSuffix(truncated, "€...[TRUNCATED]"))
+	}
+}
+
+func TestCloudWatchWriterSplit(t *testing.T) {
+	message := strings.Repeat("€", maxMessageSize)
+	client, err := helperWriteOversizeLog(t, message, cloudwatchwriter.WithOversizePolicy(cloudwatchwriter.Split))
+	assert.NoError(t, err)
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 4, len(logs)) {
+		var joined string
+		for _, log := range logs {
+			assert.True(t, len(*log.Message) <= maxMessageSize)
+			assert.True(t, utf8.ValidString(*log.Message))
+			assert.Equal(t, *logs[0].Timestamp, *log.Timestamp)
+			joined += *log.Message
+		}
+		assert.Equal(t, message, joined)
+	}
+}
+
+func TestCloudWatchWriterReject(t *testing.T) {
+	client, err := helperWriteOversizeLog(t, strings.Repeat("a", maxMessageSize+1), cloudwatchwriter.WithOversizePolicy(cloudwatchwriter.Reject))
+
+	var tooLarge *cloudwatchwriter.MessageTooLargeError
+	if assert.True(t, errors.As(err, &tooLarge)) {
+		assert.Equal(t, maxMessageSize+1, tooLarge.Size)
+		assert.Equal(t, maxMessageSize, tooLarge.Limit)
+	}
+	assert.Equal(t, 0, client.numLogs())
+}
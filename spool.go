@@ -0,0 +1,391 @@
+package cloudwatchwriter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+const (
+	// spoolSegmentSize is the size at which the spool moves on to a new
+	// segment file, so that the segments which have been sent can be deleted.
+	spoolSegmentSize = 8 * 1024 * 1024
+	// spoolRecordHeaderSize is the size of the message length and the
+	// timestamp at the start of a record, spoolRecordTrailerSize is the size
+	// of the checksum at the end.
+	spoolRecordHeaderSize  = 12
+	spoolRecordTrailerSize = 4
+	spoolSegmentExt        = ".wal"
+	spoolCheckpointFile    = "checkpoint"
+)
+
+// spoolPosition is a position in the spool, an offset in a segment file.
+type spoolPosition struct {
+	segment int64
+	offset  int64
+}
+
+// spool is an on-disk FIFO queue of log events, made of numbered segment files
+// in a directory. Each event is a record of the length of its message, its
+// timestamp, the message, and a CRC-32 checksum of them. How far the events
+// have been sent is recorded in a checkpoint file, and segments are deleted
+// once all of their events have been sent. It is not safe for concurrent use.
+type spool struct {
+	dir         string
+	segmentSize int64
+	w           *os.File
+	write       spoolPosition
+	r           *os.File
+	reader      *bufio.Reader
+	read        spoolPosition
+	// committed is the position up to which the events have been sent.
+	committed spoolPosition
+	// first is the oldest segment of this run which hasn't been deleted.
+	first int64
+	// previous are the segments left by a previous run, whose events from
+	// replayFrom on hadn't been sent.
+	previous   []int64
+	replayFrom spoolPosition
+	// records counts the events pushed to each segment which hasn't been
+	// read to the end, popped counts those popped from the one being read,
+	// and skipped counts those which couldn't be, see takeSkipped.
+	records map[int64]int
+	popped  int
+	skipped int
+}
+
+// openSpool opens the spool in dir, creating the directory if needed. Events
+// are written to a new segment, so that a record which was cut short when a
+// previous process crashed is never written after.
+func openSpool(dir string) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+	segments, err := spoolSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	next := int64(1)
+	if len(segments) > 0 {
+		next = segments[len(segments)-1] + 1
+	}
+	s := &spool{
+		dir:         dir,
+		segmentSize: spoolSegmentSize,
+		write:       spoolPosition{segment: next},
+		read:        spoolPosition{segment: next},
+		committed:   spoolPosition{segment: next},
+		first:       next,
+		previous:    segments,
+		replayFrom:  readSpoolCheckpoint(dir),
+		records:     make(map[int64]int),
+	}
+	if err = s.createSegment(next); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// readSpoolCheckpoint returns the position up to which the events in dir have
+// been sent. Without a checkpoint, none of them have.
+func readSpoolCheckpoint(dir string) spoolPosition {
+	var pos spoolPosition
+	data, err := os.ReadFile(filepath.Join(dir, spoolCheckpointFile))
+	if err == nil {
+		_, err = fmt.Sscanf(string(data), "%d %d", &pos.segment, &pos.offset)
+	}
+	if err != nil {
+		return spoolPosition{}
+	}
+	return pos
+}
+
+// spoolSegments returns the numbers of the segment files in dir, in order.
+func spoolSegments(dir string) ([]int64, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExt))
+	if err != nil {
+		return nil, fmt.Errorf("list spool segments: %w", err)
+	}
+
+	var segments []int64
+	for _, match := range matches {
+		n, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(match), spoolSegmentExt), 10, 64)
+		if err == nil {
+			segments = append(segments, n)
+		}
+	}
+	// The names are zero padded, so Glob has sorted them by number already
+	return segments, nil
+}
+
+func (s *spool) segmentPath(segment int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", segment, spoolSegmentExt))
+}
+
+func (s *spool) createSegment(segment int64) error {
+	w, err := os.OpenFile(s.segmentPath(segment), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("create spool segment: %w", err)
+	}
+	if s.w != nil {
+		_ = s.w.Close()
+	}
+	s.w = w
+	s.write = spoolPosition{segment: segment}
+	return nil
+}
+
+// push appends the event to the spool. The record is written with a single
+// write, so it is complete once push returns, even though it isn't synced to
+// the disk.
+func (s *spool) push(event types.InputLogEvent) error {
+	if s.write.offset >= s.segmentSize {
+		if err := s.createSegment(s.write.segment + 1); err != nil {
+			return err
+		}
+	}
+
+	message := aws.ToString(event.Message)
+	record := make([]byte, spoolRecordHeaderSize+len(message)+spoolRecordTrailerSize)
+	binary.BigEndian.PutUint32(record, uint32(len(message)))
+	binary.BigEndian.PutUint64(record[4:], uint64(aws.ToInt64(event.Timestamp)))
+	copy(record[spoolRecordHeaderSize:], message)
+	checksum := crc32.ChecksumIEEE(record[:spoolRecordHeaderSize+len(message)])
+	binary.BigEndian.PutUint32(record[spoolRecordHeaderSize+len(message):], checksum)
+
+	if _, err := s.w.Write(record); err != nil {
+		return fmt.Errorf("write to spool: %w", err)
+	}
+	s.write.offset += int64(len(record))
+	s.records[s.write.segment]++
+	return nil
+}
+
+// pop removes and returns the oldest event, ok is false if the spool is empty.
+// The rest of a segment is skipped if one of its records can't be read.
+func (s *spool) pop() (event types.InputLogEvent, ok bool) {
+	for s.read != s.write {
+		if s.r == nil {
+			r, err := os.Open(s.segmentPath(s.read.segment))
+			if err == nil {
+				_, err = r.Seek(s.read.offset, io.SeekStart)
+			}
+			if err != nil {
+				if r != nil {
+					_ = r.Close()
+				}
+				if !s.nextReadSegment() {
+					return event, false
+				}
+				continue
+			}
+			s.r = r
+			s.reader = bufio.NewReader(r)
+		}
+
+		event, n, err := readSpoolRecord(s.reader)
+		if err == nil {
+			s.read.offset += n
+			s.popped++
+			return event, true
+		}
+		if !s.nextReadSegment() {
+			return event, false
+		}
+	}
+	return event, false
+}
+
+// nextReadSegment moves the reading on to the next segment, it returns false
+// if there isn't one.
+func (s *spool) nextReadSegment() bool {
+	if s.read.segment >= s.write.segment {
+		return false
+	}
+	if s.r != nil {
+		_ = s.r.Close()
+		s.r, s.reader = nil, nil
+	}
+	s.skipped += s.records[s.read.segment] - s.popped
+	delete(s.records, s.read.segment)
+	s.popped = 0
+	s.read = spoolPosition{segment: s.read.segment + 1}
+	return true
+}
+
+// takeSkipped returns the number of events which were skipped by pop, as they
+// couldn't be read back, since it was last called. They came before the event
+// pop returned, if it returned one.
+func (s *spool) takeSkipped() int {
+	n := s.skipped
+	s.skipped = 0
+	return n
+}
+
+func readSpoolRecord(reader *bufio.Reader) (event types.InputLogEvent, n int64, err error) {
+	header := make([]byte, spoolRecordHeaderSize)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return event, 0, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > maxEventSize {
+		return event, 0, errors.New("spool record too large")
+	}
+
+	rest := make([]byte, int(size)+spoolRecordTrailerSize)
+	if _, err = io.ReadFull(reader, rest); err != nil {
+		return event, 0, err
+	}
+	checksum := crc32.ChecksumIEEE(append(header, rest[:size]...))
+	if binary.BigEndian.Uint32(rest[size:]) != checksum {
+		return event, 0, errors.New("spool record checksum mismatch")
+	}
+
+	event = types.InputLogEvent{
+		Message:   aws.String(string(rest[:size])),
+		Timestamp: aws.Int64(int64(binary.BigEndian.Uint64(header[4:]))),
+	}
+	return event, int64(spoolRecordHeaderSize + len(rest)), nil
+}
+
+// position returns the position of the next event to be popped.
+func (s *spool) position() spoolPosition {
+	return s.read
+}
+
+// commit records that the events before pos have been sent, and deletes the
+// segments which only hold events before it.
+func (s *spool) commit(pos spoolPosition) error {
+	checkpoint := filepath.Join(s.dir, spoolCheckpointFile)
+	tmp := checkpoint + ".tmp"
+	err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", pos.segment, pos.offset)), 0o600)
+	if err == nil {
+		err = os.Rename(tmp, checkpoint)
+	}
+	if err != nil {
+		return fmt.Errorf("write spool checkpoint: %w", err)
+	}
+	s.committed = pos
+
+	for ; s.first < pos.segment; s.first++ {
+		if err = os.Remove(s.segmentPath(s.first)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("delete spool segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// close closes the segment files. If every event has been sent, the spool is
+// empty, so its files are deleted.
+func (s *spool) close() error {
+	if s.r != nil {
+		_ = s.r.Close()
+	}
+	err := s.w.Close()
+	if err != nil {
+		return fmt.Errorf("close spool: %w", err)
+	}
+
+	if s.committed != s.write {
+		return nil
+	}
+	for ; s.first <= s.write.segment; s.first++ {
+		if err = os.Remove(s.segmentPath(s.first)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("delete spool segment: %w", err)
+		}
+	}
+	if err = os.Remove(filepath.Join(s.dir, spoolCheckpointFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete spool checkpoint: %w", err)
+	}
+	return nil
+}
+
+// replay calls fn with each of the events left by a previous run which hadn't
+// been sent, in order, then deletes the previous run's segments. The rest of a
+// segment is skipped if one of its records can't be read, as happens to the
+// last record when a process crashes while writing it. An error from fn stops
+// the replay, leaving the segments to be replayed by the next run. It only
+// uses the previous run's files, so it is safe to call while the spool is in
+// use.
+func (s *spool) replay(fn func(types.InputLogEvent) error) error {
+	for _, segment := range s.previous {
+		if segment < s.replayFrom.segment {
+			continue
+		}
+		var offset int64
+		if segment == s.replayFrom.segment {
+			offset = s.replayFrom.offset
+		}
+		if err := s.replaySegment(segment, offset, fn); err != nil {
+			return err
+		}
+	}
+
+	for _, segment := range s.previous {
+		if err := os.Remove(s.segmentPath(segment)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("delete spool segment: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *spool) replaySegment(segment, offset int64, fn func(types.InputLogEvent) error) error {
+	r, err := os.Open(s.segmentPath(segment))
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	if _, err = r.Seek(offset, io.SeekStart); err != nil {
+		return nil
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		event, _, err := readSpoolRecord(reader)
+		if err != nil {
+			return nil
+		}
+		if err = fn(event); err != nil {
+			return err
+		}
+	}
+}
+
+// replaySpool queues the logs which a previous writer using the spool hadn't
+// sent, before any new logs are written. Logs too old for CloudWatch to accept
+// are dropped, or older than the maximum event age if it is less, see
+// WithMaxEventAge, as are logs which don't fit in the queue, depending on the
+// overflow policy. Any other error is reported like an error sending logs.
+func (c *CloudWatchWriter) replaySpool() {
+	maxAge := maxEventAge
+	if c.maxAge > 0 && c.maxAge < maxAge {
+		maxAge = c.maxAge
+	}
+	oldest := c.clock.Now().Add(-maxAge).UnixMilli()
+	err := c.queue.spool.replay(func(event types.InputLogEvent) error {
+		if aws.ToInt64(event.Timestamp) < oldest {
+			c.droppedTooOld(aws.ToString(event.Message))
+			return nil
+		}
+		err := c.queue.enqueue(c.ctx, event, nil)
+		if errors.Is(err, ErrQueueFull) {
+			c.dropped(aws.ToString(event.Message), err)
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		c.reportErr(err)
+	}
+}
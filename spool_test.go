@@ -0,0 +1,164 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool(dir)
+	if err != nil {
+		t.Fatalf("openSpool: %v", err)
+	}
+	// A new segment every couple of events
+	s.segmentSize = 40
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, s.push(helperEvent("message", time.UnixMilli(int64(i)))))
+	}
+	segments, err := spoolSegments(dir)
+	assert.NoError(t, err)
+	assert.Len(t, segments, 3)
+
+	for i := 0; i < 3; i++ {
+		event, ok := s.pop()
+		if assert.True(t, ok) {
+			assert.Equal(t, int64(i), aws.ToInt64(event.Timestamp))
+			assert.Equal(t, "message", aws.ToString(event.Message))
+		}
+	}
+
+	// The first segment has been sent
+	assert.NoError(t, s.commit(s.position()))
+	segments, err = spoolSegments(dir)
+	assert.NoError(t, err)
+	assert.Len(t, segments, 2)
+
+	for i := 3; i < 5; i++ {
+		event, ok := s.pop()
+		if assert.True(t, ok) {
+			assert.Equal(t, int64(i), aws.ToInt64(event.Timestamp))
+		}
+	}
+	_, ok := s.pop()
+	assert.False(t, ok)
+
+	// Once everything has been sent, nothing is left behind
+	assert.NoError(t, s.commit(s.position()))
+	assert.NoError(t, s.close())
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpoolCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool(dir)
+	if err != nil {
+		t.Fatalf("openSpool: %v", err)
+	}
+	s.segmentSize = 40
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, s.push(helperEvent("message", time.UnixMilli(int64(i)))))
+	}
+
+	// Damage the second event, in the first segment
+	path := filepath.Join(dir, "00000000000000000001.wal")
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	// The rest of the segment is skipped
+	var timestamps []int64
+	for event, ok := s.pop(); ok; event, ok = s.pop() {
+		timestamps = append(timestamps, aws.ToInt64(event.Timestamp))
+	}
+	assert.Equal(t, []int64{0, 2, 3}, timestamps)
+	assert.Equal(t, 1, s.takeSkipped())
+	assert.Equal(t, 0, s.takeSkipped())
+	assert.NoError(t, s.close())
+}
+
+func TestSpoolCorruptRecordBarrier(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool(dir)
+	if err != nil {
+		t.Fatalf("openSpool: %v", err)
+	}
+	s.segmentSize = 40
+	q := newEventQueue(0, 0, DropNewest, 0, systemClock{})
+	q.spool = s
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, q.enqueue(context.Background(), helperEvent("message", time.UnixMilli(int64(i))), nil))
+	}
+	b := q.barrier()
+
+	path := filepath.Join(dir, "00000000000000000001.wal")
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	// The event which couldn't be read back is finished, so the barrier is
+	// done once the others are
+	var c chunk
+	q.dequeueChunk(&c, 10)
+	assert.Len(t, c.events, 3)
+	q.finish(len(c.events), nil)
+	select {
+	case <-b.done:
+		assert.Equal(t, ErrSpoolCorrupt, b.err)
+	default:
+		t.Fatal("barrier not done")
+	}
+	events, bytes, _ := q.counters()
+	assert.Equal(t, 0, events)
+	assert.Equal(t, 0, bytes)
+	assert.NoError(t, s.close())
+}
+
+func TestSpoolReplay(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool(dir)
+	if err != nil {
+		t.Fatalf("openSpool: %v", err)
+	}
+	s.segmentSize = 40
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, s.push(helperEvent("message", time.UnixMilli(int64(i)))))
+	}
+	// Only the first 3 events were sent before the writer stopped
+	for i := 0; i < 3; i++ {
+		s.pop()
+	}
+	assert.NoError(t, s.commit(s.position()))
+	assert.NoError(t, s.close())
+
+	s, err = openSpool(dir)
+	if err != nil {
+		t.Fatalf("openSpool: %v", err)
+	}
+	var timestamps []int64
+	assert.NoError(t, s.replay(func(event types.InputLogEvent) error {
+		timestamps = append(timestamps, aws.ToInt64(event.Timestamp))
+		return nil
+	}))
+	assert.Equal(t, []int64{3, 4}, timestamps)
+
+	// Only the new run's segment is left
+	segments, err := spoolSegments(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{s.write.segment}, segments)
+	assert.NoError(t, s.close())
+}
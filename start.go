@@ -0,0 +1,209 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"errors"
+)
+
+// setup checks the log group given by its ARN, finds or creates the log
+// stream, and tags the log group, before the writer starts sending logs.
+func (c *CloudWatchWriter) setup(ctx context.Context) error {
+	if err := c.checkLogGroupARN(ctx); err != nil {
+		return err
+	}
+	logStreamName := c.getLogStreamName()
+	logStream, err := c.getOrCreateLogStream(ctx, logStreamName)
+	if err != nil {
+		return err
+	}
+	if c.sequenceTokens {
+		c.setNextSequenceToken(logStreamName, logStream.UploadSequenceToken)
+	}
+	if err = c.tagLogGroup(ctx); err != nil {
+		return err
+	}
+	c.setUp = true
+	return nil
+}
+
+// setupUntil is like setup, with the requests cancelled when either ctx is
+// done or the writer abandons sending logs.
+func (c *CloudWatchWriter) setupUntil(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(c.ctx, cancel)
+	defer stop()
+
+	return c.setup(ctx)
+}
+
+// Start starts sending the logs to CloudWatch, for a writer made with
+// WithManualStart or paused by Stop. The first time, it finds or creates the
+// log stream, and tags the log group, returning the error if that fails, in
+// which case Start can be called again. ctx only applies to those requests,
+// the writer carries on until it is stopped or closed. Starting a writer which
+// is running does nothing, and starting one which has been closed returns
+// ErrClosed.
+func (c *CloudWatchWriter) Start(ctx context.Context) error {
+	if c.manualPump {
+		return errors.New("a manually pumped writer can't be started")
+	}
+
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+
+	if c.isClosing() {
+		return ErrClosed
+	}
+	if c.getMonitorStopped() != nil {
+		return nil
+	}
+	if !c.setUp {
+		if err := c.setupUntil(ctx); err != nil {
+			return err
+		}
+	}
+
+	c.startMonitor()
+	if c.queue.spool != nil && !c.spoolReplayed {
+		c.spoolReplayed = true
+		c.replaySpool()
+	}
+	return nil
+}
+
+// Run sends the logs to CloudWatch on the calling goroutine until ctx is done,
+// then closes the writer, sending the remaining logs, and returns the error
+// from closing it, like Close. It suits a writer made with WithManualStart and
+// run by a group of goroutines such as an errgroup.Group. Like Start, it first
+// finds or creates the log stream, and returns the error if that fails. Run
+// returns an error straight away if the writer is already running, and nil
+// without closing the writer if Stop is called.
+func (c *CloudWatchWriter) Run(ctx context.Context) error {
+	if c.manualPump {
+		return errors.New("a manually pumped writer can't be run")
+	}
+
+	c.lifecycle.Lock()
+	switch {
+	case c.isClosing():
+		c.lifecycle.Unlock()
+		return ErrClosed
+	case c.getMonitorStopped() != nil:
+		c.lifecycle.Unlock()
+		return errors.New("the writer is already running")
+	}
+	if !c.setUp {
+		if err := c.setupUntil(ctx); err != nil {
+			c.lifecycle.Unlock()
+			return err
+		}
+	}
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	c.Lock()
+	c.stopMonitor, c.monitorStopped = stop, stopped
+	c.Unlock()
+	replay := c.queue.spool != nil && !c.spoolReplayed
+	c.spoolReplayed = true
+	c.lifecycle.Unlock()
+
+	replayed := make(chan struct{})
+	if replay {
+		// This can wait for the queueMonitor to make space in the queue
+		go func() {
+			defer close(replayed)
+			c.replaySpool()
+		}()
+	} else {
+		close(replayed)
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			// The queueMonitor sends the remaining logs and stops
+			c.setClosing()
+			c.queue.close()
+		case <-stopped:
+		}
+	}()
+	c.queueMonitor(stop, stopped)
+	<-replayed
+
+	select {
+	case <-c.done:
+		return c.CloseWithContext(context.Background())
+	default:
+		// Stopped by Stop
+		return nil
+	}
+}
+
+// Stop pauses sending the logs to CloudWatch, until Start is called again,
+// returning once a batch being sent has been dealt with. The logs written in
+// the meantime are queued, and a batch which was due is sent after Start, so
+// with the Block overflow policy Write may wait for Start once the queue is
+// full. Stopping a writer which is closing waits until it has closed.
+func (c *CloudWatchWriter) Stop() {
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+
+	c.Lock()
+	stop, stopped := c.stopMonitor, c.monitorStopped
+	c.stopMonitor, c.monitorStopped = nil, nil
+	c.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+}
+
+// startMonitor starts the queueMonitor, with the lifecycle lock held unless
+// the writer is being created.
+func (c *CloudWatchWriter) startMonitor() {
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	c.Lock()
+	c.stopMonitor, c.monitorStopped = stop, stopped
+	c.Unlock()
+
+	go c.queueMonitor(stop, stopped)
+}
+
+// getMonitorStopped returns the channel closed when the queueMonitor stops,
+// or nil if it isn't running.
+func (c *CloudWatchWriter) getMonitorStopped() chan struct{} {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.monitorStopped
+}
+
+// startClosing starts the queueMonitor to send the remaining logs for
+// CloseWithContext, if the writer isn't running, first finding or creating
+// the log stream if it has never been started. If that fails the logs are
+// sent anyway, and fail like any other batch which can't be sent.
+func (c *CloudWatchWriter) startClosing(ctx context.Context) {
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+
+	if c.closeStarted {
+		return
+	}
+	c.closeStarted = true
+	if c.getMonitorStopped() != nil {
+		return
+	}
+	select {
+	case <-c.done:
+		// Stopped after it had sent the remaining logs
+		return
+	default:
+	}
+
+	if !c.setUp {
+		if err := c.setupUntil(ctx); err != nil {
+			c.logf("setting up the log stream to send the remaining logs: %v", err)
+		}
+	}
+	c.startMonitor()
+}
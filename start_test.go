@@ -0,0 +1,190 @@
+package cloudwatchwriter_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+// countCalls returns a middleware counting the calls to CloudWatch Logs, and a
+// function returning the count.
+func countCalls() (cloudwatchwriter.Middleware, func() int) {
+	var mu sync.Mutex
+	var calls int
+	middleware := func(next cloudwatchwriter.Call) cloudwatchwriter.Call {
+		return func(ctx context.Context, operation string, input interface{}) (interface{}, error) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			return next(ctx, operation, input)
+		}
+	}
+	return middleware, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+}
+
+func TestCloudWatchWriterManualStart(t *testing.T) {
+	client := &mockClient{}
+	middleware, calls := countCalls()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualStart(),
+		cloudwatchwriter.WithMiddleware(middleware),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	// Nothing is sent, or even looked up, until Start
+	_, err = cloudWatchWriter.Write([]byte("before start"))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(cloudWatchWriter.Flush(), cloudwatchwriter.ErrNotStarted))
+	assert.Equal(t, 0, calls())
+
+	assert.NoError(t, cloudWatchWriter.Start(context.Background()))
+	assert.NoError(t, cloudWatchWriter.Start(context.Background()))
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 1, client.numLogs())
+
+	// Stop pauses sending until Start is called again
+	cloudWatchWriter.Stop()
+	cloudWatchWriter.Stop()
+	_, err = cloudWatchWriter.Write([]byte("while stopped"))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(cloudWatchWriter.Flush(), cloudwatchwriter.ErrNotStarted))
+	assert.Equal(t, 1, client.numLogs())
+
+	assert.NoError(t, cloudWatchWriter.Start(context.Background()))
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 2, client.numLogs())
+
+	cloudWatchWriter.Close()
+	assert.True(t, errors.Is(cloudWatchWriter.Start(context.Background()), cloudwatchwriter.ErrClosed))
+}
+
+func TestCloudWatchWriterManualStartClose(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualStart(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	// Close sends the logs of a writer which was never started
+	_, err = cloudWatchWriter.Write([]byte("never started"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.CloseWithContext(context.Background()))
+	assert.Equal(t, 1, client.numLogs())
+	assert.NoError(t, cloudWatchWriter.Flush())
+}
+
+func TestCloudWatchWriterStopThenClose(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	cloudWatchWriter.Stop()
+	_, err = cloudWatchWriter.Write([]byte("while stopped"))
+	assert.NoError(t, err)
+	cloudWatchWriter.Close()
+	assert.Equal(t, 1, client.numLogs())
+	cloudWatchWriter.Stop()
+	cloudWatchWriter.Close()
+}
+
+func TestCloudWatchWriterManualStartSetupError(t *testing.T) {
+	client := &mockClient{
+		createLogGroupErr: errors.New("denied"),
+	}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualStart(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// The error from setting up the log stream comes from Start rather
+	// than the constructor, and Start can be called again
+	assert.Error(t, cloudWatchWriter.Start(context.Background()))
+	assert.Error(t, cloudWatchWriter.Start(context.Background()))
+}
+
+func TestCloudWatchWriterManualStartManualPump(t *testing.T) {
+	_, err := cloudwatchwriter.NewWithClient(&mockClient{}, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualStart(),
+		cloudwatchwriter.WithManualPump(),
+	)
+	assert.Error(t, err)
+}
+
+func TestCloudWatchWriterRun(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualStart(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan error, 1)
+	go func() {
+		ran <- cloudWatchWriter.Run(ctx)
+	}()
+
+	_, err = cloudWatchWriter.Write([]byte("first"))
+	assert.NoError(t, err)
+	// Flush returns ErrNotStarted until Run has started
+	for errors.Is(cloudWatchWriter.Flush(), cloudwatchwriter.ErrNotStarted) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 1, client.numLogs())
+	assert.Error(t, cloudWatchWriter.Run(context.Background()))
+
+	// Cancelling ctx sends the remaining logs and closes the writer
+	_, err = cloudWatchWriter.Write([]byte("second"))
+	assert.NoError(t, err)
+	cancel()
+	assert.NoError(t, <-ran)
+	assert.Equal(t, 2, client.numLogs())
+	assert.True(t, errors.Is(cloudWatchWriter.Run(context.Background()), cloudwatchwriter.ErrClosed))
+}
+
+func TestCloudWatchWriterRunStop(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithManualStart(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	ran := make(chan error, 1)
+	go func() {
+		ran <- cloudWatchWriter.Run(context.Background())
+	}()
+	for errors.Is(cloudWatchWriter.Flush(), cloudwatchwriter.ErrNotStarted) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Stop makes Run return without closing the writer
+	cloudWatchWriter.Stop()
+	assert.NoError(t, <-ran)
+	_, err = cloudWatchWriter.Write([]byte("after stop"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Start(context.Background()))
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 1, client.numLogs())
+}
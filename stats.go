@@ -0,0 +1,167 @@
+package cloudwatchwriter
+
+import (
+	"errors"
+	"time"
+)
+
+// Stats are counters of what the writer has done since it was created.
+type Stats struct {
+	// RejectedTooOld is the number of log events rejected by CloudWatch for
+	// being more than 14 days old, or older than the log group.
+	RejectedTooOld int64
+	// RejectedExpired is the number of log events rejected by CloudWatch for
+	// being older than the retention period of the log group.
+	RejectedExpired int64
+	// RejectedTooNew is the number of log events rejected by CloudWatch for
+	// being more than 2 hours in the future.
+	RejectedTooNew int64
+	// Throttles is the number of requests to CloudWatch which have been
+	// throttled.
+	Throttles int64
+	// Throttled reports whether CloudWatch is currently throttling the writer,
+	// or has been recently, which makes the writer send batches less often.
+	Throttled bool
+	// EffectiveBatchInterval is the batch interval, increased while the writer
+	// is throttled.
+	EffectiveBatchInterval time.Duration
+
+	// QueuedEvents and QueuedBytes are the number of log events waiting to be
+	// sent, and the total size of their messages.
+	QueuedEvents int
+	QueuedBytes  int
+	// EventsSent and BatchesSent are the number of log events, and batches of
+	// them, which CloudWatch has accepted, including any events it rejected
+	// individually.
+	EventsSent  int64
+	BatchesSent int64
+	// Retries is the number of requests to CloudWatch which have been retried,
+	// including throttled ones.
+	Retries int64
+
+	// DroppedQueueFull is the number of logs discarded because the queue was
+	// full, including logs which Write gave up waiting for space for.
+	DroppedQueueFull int64
+	// DroppedOldest is the number of queued logs discarded to make space for
+	// newer ones, with the DropOldest overflow policy.
+	DroppedOldest int64
+	// DroppedTooLarge is the number of logs discarded for being larger than
+	// CloudWatch accepts, with the Reject oversize policy.
+	DroppedTooLarge int64
+	// DroppedUndelivered is the number of log events discarded because their
+	// batch could not be sent.
+	DroppedUndelivered int64
+	// DroppedRateLimited is the number of logs discarded for being over the
+	// rate limit, see WithRateLimit.
+	DroppedRateLimited int64
+	// DroppedTooOld is the number of log events discarded for being older
+	// than the maximum event age, see WithMaxEventAge, or being too old for
+	// CloudWatch to accept when replayed from the spool.
+	DroppedTooOld int64
+	// SampledOut is the number of logs discarded by sampling, see
+	// WithSampling.
+	SampledOut int64
+	// FilteredOut is the number of logs discarded by filters, see
+	// WithAllowFilters and WithDenyFilters, or by transformers, see
+	// WithTransformer.
+	FilteredOut int64
+	// SkippedEmpty is the number of logs which weren't sent for being empty
+	// or only whitespace, which CloudWatch would reject.
+	SkippedEmpty int64
+
+	// LastError is the last error from sending a batch to CloudWatch, even if
+	// the batch was then retried successfully, and LastErrorTime is when it
+	// happened.
+	LastError     error
+	LastErrorTime time.Time
+	// LastSuccessTime is when CloudWatch last accepted a batch.
+	LastSuccessTime time.Time
+
+	// DeliveryLatencyP50, DeliveryLatencyP95 and DeliveryLatencyMax are the
+	// median, 95th percentile and maximum of the time it took the last 1024
+	// log events sent, from being written to CloudWatch accepting their
+	// batch, including the batch interval and any retries. They are zero
+	// until a batch has been sent, and are only measured by a
+	// CloudWatchWriter, not a SyncWriter or LambdaWriter.
+	DeliveryLatencyP50 time.Duration
+	DeliveryLatencyP95 time.Duration
+	DeliveryLatencyMax time.Duration
+}
+
+// Stats returns the writer's counters.
+func (c *CloudWatchWriter) Stats() Stats {
+	queuedEvents, queuedBytes, droppedOldest := c.queue.counters()
+
+	c.RLock()
+	defer c.RUnlock()
+
+	stats := c.stats
+	stats.Throttled = c.throttleFactor > 1
+	stats.EffectiveBatchInterval = c.batchInterval * time.Duration(c.throttleFactor)
+	stats.QueuedEvents = queuedEvents
+	stats.QueuedBytes = queuedBytes
+	stats.DroppedOldest = droppedOldest
+	stats.DeliveryLatencyP50, stats.DeliveryLatencyP95, stats.DeliveryLatencyMax = c.latencies.summary()
+	return stats
+}
+
+// sent records that CloudWatch accepted a batch.
+func (c *CloudWatchWriter) sent(numEvents int) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.stats.EventsSent += int64(numEvents)
+	c.stats.BatchesSent++
+	c.stats.LastSuccessTime = c.clock.Now()
+}
+
+// sendFailed records an error from sending a batch, retried says whether it
+// is going to be retried, otherwise its events are dropped.
+func (c *CloudWatchWriter) sendFailed(err error, numEvents int, retried bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.stats.LastError = err
+	c.stats.LastErrorTime = c.clock.Now()
+	if retried {
+		c.stats.Retries++
+	} else {
+		c.stats.DroppedUndelivered += int64(numEvents)
+	}
+}
+
+// droppedTooOld records a log event discarded for being too old, and passes
+// its message to the drop handler.
+func (c *CloudWatchWriter) droppedTooOld(message string) {
+	c.Lock()
+	c.stats.DroppedTooOld++
+	c.Unlock()
+
+	c.callDropHandler(message, DropReasonTooOld)
+}
+
+// dropped records a log which Write discarded, because of err, and passes its
+// message to the drop handler.
+func (c *CloudWatchWriter) dropped(message string, err error) {
+	reason := DropReasonQueueFull
+	var tooLarge *MessageTooLargeError
+	switch {
+	case errors.As(err, &tooLarge):
+		reason = DropReasonTooLarge
+	case errors.Is(err, ErrRateLimited):
+		reason = DropReasonRateLimited
+	}
+
+	c.Lock()
+	switch reason {
+	case DropReasonTooLarge:
+		c.stats.DroppedTooLarge++
+	case DropReasonRateLimited:
+		c.stats.DroppedRateLimited++
+	default:
+		c.stats.DroppedQueueFull++
+	}
+	c.Unlock()
+
+	c.callDropHandler(message, reason)
+}
@@ -0,0 +1,93 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterStatsSent(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}},
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	}
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	stats := cloudWatchWriter.Stats()
+	assert.Equal(t, int64(3), stats.EventsSent)
+	assert.Equal(t, int64(1), stats.BatchesSent)
+	assert.Equal(t, int64(1), stats.Retries)
+	assert.Equal(t, serverError{}, stats.LastError)
+	assert.False(t, stats.LastErrorTime.Before(start))
+	assert.False(t, stats.LastSuccessTime.Before(stats.LastErrorTime))
+	assert.Equal(t, 0, stats.QueuedEvents)
+	assert.Equal(t, int64(0), stats.DroppedUndelivered)
+}
+
+func TestCloudWatchWriterStatsDropped(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client,
+		cloudwatchwriter.WithMaxQueueSize(2, 0),
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+		cloudwatchwriter.WithOversizePolicy(cloudwatchwriter.Reject),
+	)
+
+	for _, message := range []string{"second", "third", "dropped"} {
+		_, _ = cloudWatchWriter.Write([]byte(message))
+	}
+	_, _ = cloudWatchWriter.Write(make([]byte, 300*1024))
+
+	stats := cloudWatchWriter.Stats()
+	assert.Equal(t, 2, stats.QueuedEvents)
+	assert.Equal(t, len("second")+len("third"), stats.QueuedBytes)
+	assert.Equal(t, int64(1), stats.DroppedQueueFull)
+	assert.Equal(t, int64(1), stats.DroppedTooLarge)
+
+	// The blocked batch fails
+	client.Lock()
+	client.putLogEventsShouldError = true
+	client.Unlock()
+	close(client.putLogEventsGate)
+	cloudWatchWriter.Close()
+
+	stats = cloudWatchWriter.Stats()
+	assert.Equal(t, int64(3), stats.DroppedUndelivered)
+	assert.Equal(t, int64(0), stats.EventsSent)
+}
+
+func TestCloudWatchWriterStatsDroppedOldest(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter := helperBlockedWriter(t, client,
+		cloudwatchwriter.WithMaxQueueSize(1, 0),
+		cloudwatchwriter.WithOverflowPolicy(cloudwatchwriter.DropOldest),
+	)
+
+	for _, message := range []string{"dropped", "second"} {
+		_, err := cloudWatchWriter.Write([]byte(message))
+		assert.NoError(t, err)
+	}
+
+	stats := cloudWatchWriter.Stats()
+	assert.Equal(t, 1, stats.QueuedEvents)
+	assert.Equal(t, int64(1), stats.DroppedOldest)
+
+	close(client.putLogEventsGate)
+	cloudWatchWriter.Close()
+}
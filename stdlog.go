@@ -0,0 +1,50 @@
+package cloudwatchwriter
+
+import (
+	"bytes"
+	"log"
+)
+
+// StdLoggerFlags are the flags recommended for NewStdLogger: the date and time
+// to the microsecond, in UTC, and the file name and line number of the call.
+const StdLoggerFlags = log.LstdFlags | log.Lmicroseconds | log.LUTC | log.Lshortfile
+
+// NewStdLogger returns a standard library *log.Logger which writes to the
+// writer, see log.New for the meaning of prefix and flags. log.LUTC is always
+// added to the flags, so that the times in the logs match the timestamps of
+// the log events in CloudWatch. Each line of a log is sent as a separate log
+// event, without its newline, and empty lines are skipped.
+func NewStdLogger(w *CloudWatchWriter, prefix string, flags int) *log.Logger {
+	return log.New(&lineWriter{writer: w}, prefix, flags|log.LUTC)
+}
+
+// lineWriter writes each line of what is written to it as a separate log.
+type lineWriter struct {
+	writer *CloudWatchWriter
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	return writeLines(p, func(line []byte) error {
+		_, err := l.writer.Write(line)
+		return err
+	})
+}
+
+// writeLines calls write with each line of p, without its newline, skipping
+// empty lines, and returns len(p), or 0 and the first error.
+func writeLines(p []byte, write func(line []byte) error) (int, error) {
+	var firstErr error
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			continue
+		}
+		if err := write(line); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	return len(p), nil
+}
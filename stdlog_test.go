@@ -0,0 +1,96 @@
+package cloudwatchwriter_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestNewStdLogger(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	logger := cloudwatchwriter.NewStdLogger(cloudWatchWriter, "app: ", 0)
+	logger.Print("hello world")
+	logger.Print("first line\nsecond line\n\n")
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 3, len(logs)) {
+		assert.Equal(t, "app: hello world", *logs[0].Message)
+		assert.Equal(t, "app: first line", *logs[1].Message)
+		assert.Equal(t, "second line", *logs[2].Message)
+	}
+}
+
+func TestNewStdLoggerFlags(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	logger := cloudwatchwriter.NewStdLogger(cloudWatchWriter, "", cloudwatchwriter.StdLoggerFlags)
+	logger.Print("hello world")
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 1, len(logs)) {
+		assert.Regexp(t, regexp.MustCompile(`^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d\.\d{6} stdlog_test.go:\d+: hello world$`), *logs[0].Message)
+	}
+}
+
+func TestCloudWatchWriterLineSplitting(t *testing.T) {
+	client := &mockClient{}
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithLineSplitting(),
+		cloudwatchwriter.WithMinLevel(zerolog.InfoLevel),
+		cloudwatchwriter.WithSampling(1<<30, zerolog.InfoLevel),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	output := "first line\r\nsecond line\n\n{\"level\":\"debug\"}\n{\"level\":\"error\"}\n"
+	n, err := cloudWatchWriter.Write([]byte(output))
+	assert.NoError(t, err)
+	assert.Equal(t, len(output), n)
+	logger := zerolog.New(cloudWatchWriter)
+	logger.Info().Msg("zerolog")
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 4, len(logs)) {
+		assert.Equal(t, "first line", *logs[0].Message)
+		assert.Equal(t, "second line", *logs[1].Message)
+		// The debug line is sampled out by its own level
+		assert.Equal(t, `{"level":"error"}`, *logs[2].Message)
+		assert.Equal(t, `{"level":"info","message":"zerolog"}`, *logs[3].Message)
+	}
+}
+
+func TestSyncWriterLineSplitting(t *testing.T) {
+	client := &mockClient{}
+	syncWriter, err := cloudwatchwriter.NewSyncWithClient(client, "logGroup", "logStream", cloudwatchwriter.WithLineSplitting())
+	if err != nil {
+		t.Fatalf("NewSyncWithClient: %v", err)
+	}
+
+	_, err = syncWriter.Write([]byte("first line\nsecond line\n"))
+	assert.NoError(t, err)
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 2, len(logs)) {
+		assert.Equal(t, "first line", *logs[0].Message)
+		assert.Equal(t, "second line", *logs[1].Message)
+	}
+}
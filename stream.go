@@ -0,0 +1,95 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// Stream returns a writer to another log stream in the writer's log group,
+// such as one for audit logs beside the application's, or an error. It is
+// created with the same client and options as the writer, but its logs are
+// sent by the writer's goroutine whenever the writer sends its own, every
+// batch interval, rather than by a goroutine of its own, so it costs little
+// more than its queue. The spool and the expvar publishing, see WithSpool and
+// WithExpvar, remain the writer's own, and the placeholders in the name are
+// replaced as they are by New. Each call returns a new writer, which can be
+// closed on its own, and which is closed when the writer is closed.
+func (c *CloudWatchWriter) Stream(name string) (*CloudWatchWriter, error) {
+	c.streamsMu.Lock()
+	defer c.streamsMu.Unlock()
+
+	if c.isClosing() {
+		return nil, ErrClosed
+	}
+	opts := append(c.opts[:len(c.opts):len(c.opts)], WithManualPump(), withParent(c))
+	stream, err := NewWithClient(c.baseClient, c.batchInterval, aws.ToString(c.logGroupName), name, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("stream %s: %w", name, err)
+	}
+	c.streams = append(c.streams, stream)
+	return stream, nil
+}
+
+// withParent makes the writer a stream of the parent, see Stream, leaving
+// the parent's spool and expvar publishing to the parent.
+func withParent(parent *CloudWatchWriter) Option {
+	return func(c *CloudWatchWriter) {
+		c.parent = parent
+		c.senders = parent.senders
+		c.manualStart = false
+		c.spoolDir = ""
+		c.expvarName = ""
+	}
+}
+
+// getStreams returns the writers returned by Stream which are still open.
+func (c *CloudWatchWriter) getStreams() []*CloudWatchWriter {
+	c.streamsMu.Lock()
+	defer c.streamsMu.Unlock()
+
+	return append([]*CloudWatchWriter(nil), c.streams...)
+}
+
+// removeStream forgets a stream which has been closed.
+func (c *CloudWatchWriter) removeStream(stream *CloudWatchWriter) {
+	c.streamsMu.Lock()
+	defer c.streamsMu.Unlock()
+
+	for i, s := range c.streams {
+		if s == stream {
+			c.streams = append(c.streams[:i], c.streams[i+1:]...)
+			return
+		}
+	}
+}
+
+// pumpStreams sends the queued logs of the writer's streams, along with the
+// writer's own, without waiting for the senders, see startPump.
+func (c *CloudWatchWriter) pumpStreams() {
+	for _, stream := range c.getStreams() {
+		stream.startPump()
+	}
+}
+
+// waitForSends waits for the batches of the writer and its streams which are
+// being sent, see WithSenders.
+func (c *CloudWatchWriter) waitForSends() {
+	c.pump.wait()
+	for _, stream := range c.getStreams() {
+		stream.waitForSends()
+	}
+}
+
+// closeStreams closes the writer's streams at the same time, returning the
+// first error.
+func (c *CloudWatchWriter) closeStreams(ctx context.Context) error {
+	streams := c.getStreams()
+	for i, err := range closeAll(ctx, streams) {
+		if err != nil {
+			return fmt.Errorf("stream %s: %w", aws.ToString(streams[i].getLogStreamName()), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cloudwatchwriter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestStream(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Second, "logGroup", "app",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithStaticFields(map[string]interface{}{"service": "api"}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	audit, err := cloudWatchWriter.Stream("app-audit")
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	_, err = cloudWatchWriter.Write([]byte(`{"message":"app"}`))
+	assert.NoError(t, err)
+	_, err = audit.Write([]byte(`{"message":"audit"}`))
+	assert.NoError(t, err)
+
+	// The writer's timer sends the logs of both log streams, with its options
+	assert.NoError(t, clock.WaitForTimers(1, time.Second))
+	assert.Equal(t, 1, clock.Timers())
+	clock.Advance(time.Second)
+	_, err = client.WaitForEvents("logGroup", "app-audit", 1, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"service":"api","message":"audit"}`}, client.Messages("logGroup", "app-audit"))
+	assert.Equal(t, []string{`{"service":"api","message":"app"}`}, client.Messages("logGroup", "app"))
+
+	// Flushing the writer flushes its streams
+	_, err = audit.Write([]byte(`{"message":"flushed"}`))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Len(t, client.Messages("logGroup", "app-audit"), 2)
+
+	// Closing the writer closes its streams, sending their logs
+	_, err = audit.Write([]byte(`{"message":"closed"}`))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.CloseWithContext(context.Background()))
+	assert.Len(t, client.Messages("logGroup", "app-audit"), 3)
+	_, err = cloudWatchWriter.Stream("app-other")
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrClosed))
+}
+
+func TestStreamClosedOnItsOwn(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Hour, "logGroup", "app")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	audit, err := cloudWatchWriter.Stream("app-audit")
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	_, err = audit.Write([]byte("audit"))
+	assert.NoError(t, err)
+	audit.Close()
+	assert.Equal(t, []string{"audit"}, client.Messages("logGroup", "app-audit"))
+
+	_, err = cloudWatchWriter.Write([]byte("app"))
+	assert.NoError(t, err)
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, []string{"app"}, client.Messages("logGroup", "app"))
+}
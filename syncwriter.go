@@ -0,0 +1,156 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/rs/zerolog"
+)
+
+// ErrClosed is returned by SyncWriter.Write once the writer has been closed.
+var ErrClosed = errors.New("cloudwatchwriter: writer is closed")
+
+// SyncWriter sends each log to CloudWatch as it is written, without a queue or
+// a goroutine sending the logs in the background, so that Write returns the
+// error from PutLogEvents itself. This suits programs which write a few logs
+// and exit, such as command line tools and cron jobs, but each Write takes a
+// request to CloudWatch, and only 5 requests per second can be made to a log
+// stream. It is safe for concurrent use, the logs are sent one at a time.
+type SyncWriter struct {
+	mu     sync.Mutex
+	writer *CloudWatchWriter
+	closed bool
+}
+
+// NewSync returns a SyncWriter sending the logs to the log stream, or an
+// error. It accepts the same options as New, apart from those about the queue
+// and the batch interval, which have no effect, and WithSpool, which makes it
+// return an error.
+func NewSync(cfg aws.Config, logGroupName, logStreamName string, opts ...Option) (*SyncWriter, error) {
+	return NewSyncWithClient(cloudwatchlogs.NewFromConfig(cfg), logGroupName, logStreamName, opts...)
+}
+
+// NewSyncWithClient is like NewSync, with the CloudWatch Logs client to use.
+func NewSyncWithClient(client CloudWatchLogsClient, logGroupName, logStreamName string, opts ...Option) (*SyncWriter, error) {
+	writer, err := newWriter(client, defaultBatchInterval, logGroupName, logStreamName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if writer.spoolDir != "" {
+		return nil, errors.New("a synchronous writer can't use a spool")
+	}
+	if writer.expvarName != "" {
+		writer.publishExpvar()
+	}
+	s := &SyncWriter{writer: writer}
+	writer.summaryWriter = s.writeSummary
+	if writer.aggregation.window > 0 {
+		go writer.aggregate()
+	}
+	return s, nil
+}
+
+// Write implements the io.Writer interface. It sends the log to CloudWatch,
+// retrying according to the retry policy, and returns the error from the last
+// attempt if it couldn't be sent, unless WithWriteNeverFails is used. A log larger than CloudWatch accepts is
+// dealt with by the oversize policy, and may be sent as several log events.
+func (s *SyncWriter) Write(log []byte) (int, error) {
+	if s.writer.splitLines {
+		return writeLines(log, func(line []byte) error {
+			_, err := s.write(s.writer.levelOf(line), line)
+			return err
+		})
+	}
+	return s.write(s.writer.levelOf(log), log)
+}
+
+// write writes a log at the level, unless the writer screens it out, see
+// CloudWatchWriter.screen.
+func (s *SyncWriter) write(level zerolog.Level, log []byte) (int, error) {
+	c := s.writer
+	if ok, err := c.screen(context.Background(), level, log, ""); !ok {
+		return c.writeResult(log, err)
+	}
+	return c.writeResult(log, s.send(log, ""))
+}
+
+// writeSummary sends a log standing for several others straight away, see
+// CloudWatchWriter.writeSummary.
+func (s *SyncWriter) writeSummary(_ context.Context, log string) error {
+	return s.send(nil, log)
+}
+
+// send sends the log events for the log, or the text if it isn't empty, see
+// CloudWatchWriter.logEvents.
+func (s *SyncWriter) send(log []byte, text string) error {
+	c := s.writer
+	buf, err := c.logEvents(log, text)
+	if err != nil {
+		return err
+	}
+	defer buf.release()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	// The log may be split into more events than fit in one request
+	var current batch
+	for _, event := range buf.events {
+		if !current.fits(event) || current.full() {
+			if err = c.sendBatch(current.take()); err != nil {
+				return err
+			}
+		}
+		current.add(event)
+	}
+	return c.sendBatch(current.take())
+}
+
+// WriteLevel implements the zerolog.LevelWriter interface, discarding logs
+// below the writer's minimum level, see WithMinLevel.
+func (s *SyncWriter) WriteLevel(level zerolog.Level, log []byte) (int, error) {
+	if !s.writer.Enabled(level) {
+		return len(log), nil
+	}
+	if s.writer.splitLines {
+		return writeLines(log, func(line []byte) error {
+			_, err := s.write(level, line)
+			return err
+		})
+	}
+	return s.write(level, log)
+}
+
+// Enabled reports whether logs at the given level are sent by WriteLevel,
+// rather than discarded.
+func (s *SyncWriter) Enabled(level zerolog.Level) bool {
+	return s.writer.Enabled(level)
+}
+
+// Stats returns the writer's counters.
+func (s *SyncWriter) Stats() Stats {
+	return s.writer.Stats()
+}
+
+// Close sends the logs counted rather than sent, see WithDuplicateSuppression
+// and WithAggregation, and waits for a Write in progress to finish, then makes
+// any later Write return ErrClosed. There is nothing else to send, as every
+// log has been sent by the time Write returns.
+func (s *SyncWriter) Close() error {
+	s.writer.endRepeats()
+	s.writer.endAggregation()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.writer.cancel()
+	return nil
+}
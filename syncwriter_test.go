@@ -0,0 +1,139 @@
+package cloudwatchwriter_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestSyncWriter(t *testing.T) {
+	client := &mockClient{}
+	syncWriter, err := cloudwatchwriter.NewSyncWithClient(client, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewSyncWithClient: %v", err)
+	}
+
+	// The log has been sent by the time Write returns
+	n, err := syncWriter.Write([]byte("hello world"))
+	assert.NoError(t, err)
+	assert.Equal(t, len("hello world"), n)
+	assert.Equal(t, 1, client.numLogs())
+	assert.Equal(t, 1, client.numPutLogEventsCalls())
+
+	assert.NoError(t, syncWriter.Close())
+	_, err = syncWriter.Write([]byte("too late"))
+	assert.True(t, errors.Is(err, cloudwatchwriter.ErrClosed))
+	assert.Equal(t, 1, client.numPutLogEventsCalls())
+}
+
+func TestSyncWriterError(t *testing.T) {
+	client := &mockClient{
+		putLogEventsErrors: []error{serverError{}, serverError{}},
+	}
+	syncWriter, err := cloudwatchwriter.NewSyncWithClient(client, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 2}),
+	)
+	if err != nil {
+		t.Fatalf("NewSyncWithClient: %v", err)
+	}
+	defer syncWriter.Close()
+
+	// The error from PutLogEvents is returned by the same Write
+	_, err = syncWriter.Write([]byte("hello world"))
+	assert.Equal(t, serverError{}, err)
+	assert.Equal(t, 0, client.numLogs())
+
+	_, err = syncWriter.Write([]byte("hello again"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, client.numLogs())
+}
+
+func TestSyncWriterWriteNeverFails(t *testing.T) {
+	client := &mockClient{
+		putLogEventsShouldError: true,
+	}
+	syncWriter, err := cloudwatchwriter.NewSyncWithClient(client, "logGroup", "logStream",
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{MaxAttempts: 1}),
+		cloudwatchwriter.WithWriteNeverFails(),
+	)
+	if err != nil {
+		t.Fatalf("NewSyncWithClient: %v", err)
+	}
+	defer syncWriter.Close()
+
+	n, err := syncWriter.Write([]byte("hello world"))
+	assert.NoError(t, err)
+	assert.Equal(t, len("hello world"), n)
+}
+
+func TestSyncWriterDuplicateSuppression(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	syncWriter, err := cloudwatchwriter.NewSyncWithClient(client, "logGroup", "logStream",
+		cloudwatchwriter.WithDuplicateSuppression(time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("NewSyncWithClient: %v", err)
+	}
+
+	for _, log := range []string{"a", "a", "a", "b", "b"} {
+		_, err = syncWriter.Write([]byte(log))
+		assert.NoError(t, err)
+	}
+	// The copies of the last log are sent by Close
+	assert.NoError(t, syncWriter.Close())
+
+	assert.Equal(t, []string{
+		"a",
+		`{"repeated":2,"message":"a"}`,
+		"b",
+		`{"repeated":1,"message":"b"}`,
+	}, client.Messages("logGroup", "logStream"))
+}
+
+func TestSyncWriterSplit(t *testing.T) {
+	client := &mockClient{}
+	syncWriter, err := cloudwatchwriter.NewSyncWithClient(client, "logGroup", "logStream",
+		cloudwatchwriter.WithOversizePolicy(cloudwatchwriter.Split),
+	)
+	if err != nil {
+		t.Fatalf("NewSyncWithClient: %v", err)
+	}
+	defer syncWriter.Close()
+
+	// Too large for one batch, so it is sent in two
+	_, err = syncWriter.Write([]byte(strings.Repeat("a", 1100*1024)))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, client.numLogs())
+	assert.Equal(t, 2, client.numPutLogEventsCalls())
+}
+
+func TestSyncWriterSplitBatches(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	syncWriter, err := cloudwatchwriter.NewSyncWithClient(client, "logGroup", "logStream",
+		cloudwatchwriter.WithOversizePolicy(cloudwatchwriter.Split),
+	)
+	if err != nil {
+		t.Fatalf("NewSyncWithClient: %v", err)
+	}
+	defer syncWriter.Close()
+
+	// Split into more events than one request to CloudWatch takes, which the
+	// client would reject
+	log := strings.Repeat("a", 3*1024*1024)
+	_, err = syncWriter.Write([]byte(log))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, client.PutLogEventsCalls())
+	assert.Equal(t, log, strings.Join(client.Messages("logGroup", "logStream"), ""))
+}
+
+func TestNewSyncWithClientSpool(t *testing.T) {
+	_, err := cloudwatchwriter.NewSyncWithClient(&mockClient{}, "logGroup", "logStream",
+		cloudwatchwriter.WithSpool(t.TempDir()),
+	)
+	assert.Error(t, err)
+}
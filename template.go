@@ -0,0 +1,85 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+)
+
+// instanceIDTimeout is how long to wait for the EC2 instance metadata service
+// before falling back to the host name for {instance_id}.
+const instanceIDTimeout = 2 * time.Second
+
+// expandName replaces the placeholders in a log group or log stream name:
+// {hostname} with the host name, {pid} with the process ID, {date} with the
+// date of t in UTC, as 2006-01-02, {instance_id} with the EC2 instance ID,
+// and {ecs_cluster}, {ecs_task_id} and {ecs_container} with the ECS task's.
+// Anything else in braces is left as it is.
+func (c *CloudWatchWriter) expandName(name string, t time.Time) string {
+	if !strings.Contains(name, "{") {
+		return name
+	}
+
+	replacements := []string{
+		"{hostname}", hostname(),
+		"{pid}", strconv.Itoa(os.Getpid()),
+		"{date}", t.UTC().Format("2006-01-02"),
+	}
+	if strings.Contains(name, "{instance_id}") {
+		replacements = append(replacements, "{instance_id}", c.getInstanceID())
+	}
+	if strings.Contains(name, "{ecs_") {
+		replacements = append(replacements, c.ecsNameReplacements()...)
+	}
+	return strings.NewReplacer(replacements...).Replace(name)
+}
+
+func hostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+// getInstanceID returns the ID of the EC2 instance the program is running on,
+// looked up the first time with IMDSv2, or the host name if it can't be, such
+// as when not running on EC2.
+func (c *CloudWatchWriter) getInstanceID() string {
+	c.instanceIDOnce.Do(func() {
+		instanceID, err := c.lookupInstanceID()
+		if err != nil {
+			c.logf("using the host name instead of the EC2 instance ID: %v", err)
+			instanceID = hostname()
+		}
+		c.instanceID = instanceID
+	})
+	return c.instanceID
+}
+
+func (c *CloudWatchWriter) lookupInstanceID() (string, error) {
+	client := c.imdsClient
+	if client == nil {
+		client = imds.New(imds.Options{EnableFallback: aws.FalseTernary})
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, instanceIDTimeout)
+	defer cancel()
+	output, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: "instance-id"})
+	if err != nil {
+		return "", err
+	}
+	defer output.Content.Close()
+
+	instanceID, err := io.ReadAll(output.Content)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(instanceID)), nil
+}
@@ -0,0 +1,91 @@
+package cloudwatchwriter_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterNameTemplate(t *testing.T) {
+	client := cloudwatchwritertest.NewClient()
+	now := time.Now()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "group-{date}", "{hostname}-{pid}-{date}-{other}",
+		cloudwatchwriter.WithClock(cloudwatchwritertest.NewClock(now)),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+	cloudWatchWriter.Close()
+
+	hostname, err := os.Hostname()
+	assert.NoError(t, err)
+	date := now.UTC().Format("2006-01-02")
+	logGroupName := "group-" + date
+	logStreamName := fmt.Sprintf("%s-%d-%s-{other}", hostname, os.Getpid(), date)
+	assert.Equal(t, []string{"log"}, client.Messages(logGroupName, logStreamName))
+}
+
+// newIMDSServer returns an instance metadata service which only answers with
+// the instance ID to requests with an IMDSv2 token.
+func newIMDSServer(t *testing.T, instanceID string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Header().Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+			_, _ = w.Write([]byte("token"))
+		case r.URL.Path == "/latest/meta-data/instance-id" && r.Header.Get("X-Aws-Ec2-Metadata-Token") == "token":
+			_, _ = w.Write([]byte(instanceID))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCloudWatchWriterInstanceIDTemplate(t *testing.T) {
+	server := newIMDSServer(t, "i-0123456789abcdef0")
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "app-{instance_id}",
+		cloudwatchwriter.WithEC2Metadata(imds.New(imds.Options{Endpoint: server.URL})),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+	cloudWatchWriter.Close()
+	assert.Equal(t, []string{"log"}, client.Messages("logGroup", "app-i-0123456789abcdef0"))
+}
+
+func TestCloudWatchWriterInstanceIDFallback(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	client := cloudwatchwritertest.NewClient()
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "app-{instance_id}",
+		cloudwatchwriter.WithEC2Metadata(imds.New(imds.Options{Endpoint: server.URL})),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	// Not on EC2, so the host name is used instead
+	_, err = cloudWatchWriter.Write([]byte("log"))
+	assert.NoError(t, err)
+	cloudWatchWriter.Close()
+	hostname, err := os.Hostname()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"log"}, client.Messages("logGroup", "app-"+hostname))
+}
@@ -0,0 +1,54 @@
+package cloudwatchwriter
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// maxThrottleFactor is the most that the batch interval is multiplied by
+// while CloudWatch is throttling the writer.
+const maxThrottleFactor = 16
+
+func isThrottle(err error) bool {
+	return retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary
+}
+
+// throttled doubles the effective batch interval, so that fewer requests are
+// made to CloudWatch, after a request has been throttled.
+func (c *CloudWatchWriter) throttled() {
+	c.Lock()
+	c.stats.Throttles++
+	if c.throttleFactor < maxThrottleFactor {
+		c.throttleFactor *= 2
+	}
+	interval := c.batchInterval * time.Duration(c.throttleFactor)
+	c.Unlock()
+
+	c.logf("throttled by CloudWatch, sending batches every %v", interval)
+}
+
+// unthrottled halves the effective batch interval, back down to the batch
+// interval, after a request has succeeded.
+func (c *CloudWatchWriter) unthrottled() {
+	c.Lock()
+	if c.throttleFactor == 1 {
+		c.Unlock()
+		return
+	}
+	c.throttleFactor /= 2
+	interval := c.batchInterval * time.Duration(c.throttleFactor)
+	c.Unlock()
+
+	c.logf("request succeeded after throttling, sending batches every %v", interval)
+}
+
+// getEffectiveBatchInterval returns the batch interval, increased while
+// CloudWatch is throttling the writer.
+func (c *CloudWatchWriter) getEffectiveBatchInterval() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.batchInterval * time.Duration(c.throttleFactor)
+}
@@ -0,0 +1,74 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterThrottled(t *testing.T) {
+	throttlingException := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	client := &mockClient{
+		putLogEventsErrors: []error{throttlingException, throttlingException, throttlingException},
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		// Throttled attempts aren't limited by the maximum attempts
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{
+			MaxAttempts:    1,
+			InitialBackoff: time.Millisecond,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 1, client.numLogs())
+	assert.Equal(t, 4, client.numPutLogEventsCalls())
+
+	// The batch interval has gone up 8 times, then down by half after the
+	// successful request
+	stats := cloudWatchWriter.Stats()
+	assert.Equal(t, int64(3), stats.Throttles)
+	assert.True(t, stats.Throttled)
+	assert.Equal(t, 800*time.Millisecond, stats.EffectiveBatchInterval)
+
+	// And back to normal after a few more successful requests
+	for i := 0; i < 2; i++ {
+		helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+		assert.NoError(t, cloudWatchWriter.Flush())
+	}
+	stats = cloudWatchWriter.Stats()
+	assert.False(t, stats.Throttled)
+	assert.Equal(t, 200*time.Millisecond, stats.EffectiveBatchInterval)
+}
+
+func TestCloudWatchWriterThrottledThenError(t *testing.T) {
+	throttlingException := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	client := &mockClient{
+		putLogEventsErrors: []error{throttlingException, throttlingException, serverError{}},
+	}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		// The throttled attempts leave the second attempt for the 5xx
+		cloudwatchwriter.WithRetryPolicy(cloudwatchwriter.RetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	helperWriteLogs(t, cloudWatchWriter, exampleLog{Message: "Test message"})
+	assert.NoError(t, cloudWatchWriter.Flush())
+	assert.Equal(t, 1, client.numLogs())
+	assert.Equal(t, 4, client.numPutLogEventsCalls())
+}
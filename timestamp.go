@@ -0,0 +1,64 @@
+package cloudwatchwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// logTimestamp returns the time in the timestamp field of a log which is a
+// JSON object, see WithTimestampField, ok is false if the log doesn't have
+// one which can be parsed.
+func (c *CloudWatchWriter) logTimestamp(message string) (t time.Time, ok bool) {
+	trimmed := strings.TrimSpace(message)
+	if !strings.HasPrefix(trimmed, "{") {
+		return time.Time{}, false
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal([]byte(trimmed), &fields) != nil {
+		return time.Time{}, false
+	}
+	value, ok := fields[c.timestampField]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if bytes.HasPrefix(value, []byte(`"`)) {
+		var s string
+		if json.Unmarshal(value, &s) != nil {
+			return time.Time{}, false
+		}
+		layout := c.timestampLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		t, err := time.Parse(layout, s)
+		return t, err == nil
+	}
+
+	var n json.Number
+	if json.Unmarshal(value, &n) != nil {
+		return time.Time{}, false
+	}
+	if i, err := n.Int64(); err == nil {
+		switch c.timestampLayout {
+		case zerolog.TimeFormatUnixMs:
+			return time.UnixMilli(i), true
+		case zerolog.TimeFormatUnixMicro:
+			return time.UnixMicro(i), true
+		case zerolog.TimeFormatUnixNano:
+			return time.Unix(0, i), true
+		default:
+			return time.Unix(i, 0), true
+		}
+	}
+	// Seconds with a fraction
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(f*float64(time.Second))), true
+}
@@ -0,0 +1,52 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterTimestampField(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	logged := time.Date(2024, 5, 1, 11, 58, 30, 0, time.UTC)
+
+	for _, test := range []struct {
+		name   string
+		layout string
+		log    string
+		want   time.Time
+	}{
+		{"RFC3339", "", `{"time":"2024-05-01T11:58:30Z","message":"hello"}`, logged},
+		{"layout", time.DateTime, `{"time":"2024-05-01 11:58:30"}`, logged},
+		{"unix", zerolog.TimeFormatUnix, `{"time":1714564710}`, logged},
+		{"unix ms", zerolog.TimeFormatUnixMs, `{"time":1714564710000}`, logged},
+		{"missing", "", `{"message":"hello"}`, now},
+		{"invalid", "", `{"time":"yesterday"}`, now},
+		{"not JSON", "", `time=2024-05-01T11:58:30Z`, now},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client := &mockClient{}
+			cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+				cloudwatchwriter.WithClock(cloudwatchwritertest.NewClock(now)),
+				cloudwatchwriter.WithManualPump(),
+				cloudwatchwriter.WithTimestampField("time", test.layout),
+			)
+			if err != nil {
+				t.Fatalf("NewWithClient: %v", err)
+			}
+
+			_, err = cloudWatchWriter.Write([]byte(test.log))
+			assert.NoError(t, err)
+			cloudWatchWriter.Close()
+
+			events := client.getLogEvents()
+			if assert.Len(t, events, 1) {
+				assert.Equal(t, test.want.UnixMilli(), *events[0].Timestamp)
+			}
+		})
+	}
+}
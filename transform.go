@@ -0,0 +1,16 @@
+package cloudwatchwriter
+
+// transform passes the log through the transformers of WithTransformer, in
+// order, reporting false, having counted it, if one of them discards it.
+func (c *CloudWatchWriter) transform(log LogEvent) (LogEvent, bool) {
+	for _, transformer := range c.transformers {
+		var keep bool
+		if log, keep = transformer(log); !keep {
+			c.Lock()
+			c.stats.FilteredOut++
+			c.Unlock()
+			return log, false
+		}
+	}
+	return log, true
+}
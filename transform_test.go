@@ -0,0 +1,49 @@
+package cloudwatchwriter_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+	"github.com/tracmo/cloudwatchwriter/cloudwatchwritertest"
+)
+
+func TestCloudWatchWriterTransformer(t *testing.T) {
+	client := &mockClient{}
+	clock := cloudwatchwritertest.NewClock(time.Now())
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithClock(clock),
+		cloudwatchwriter.WithStaticFields(map[string]interface{}{"service": "orders"}),
+		cloudwatchwriter.WithTransformer(func(log cloudwatchwriter.LogEvent) (cloudwatchwriter.LogEvent, bool) {
+			log.Message = strings.ReplaceAll(log.Message, "secret", "****")
+			return log, true
+		}),
+		cloudwatchwriter.WithTransformer(func(log cloudwatchwriter.LogEvent) (cloudwatchwriter.LogEvent, bool) {
+			// Sees the message changed by the first transformer
+			return log, !strings.Contains(log.Message, "drop")
+		}),
+		cloudwatchwriter.WithTransformer(func(log cloudwatchwriter.LogEvent) (cloudwatchwriter.LogEvent, bool) {
+			log.Timestamp = log.Timestamp.Add(-time.Hour)
+			return log, true
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	logger := zerolog.New(cloudWatchWriter)
+	logger.Info().Msg("the secret is out")
+	logger.Info().Msg("drop this")
+	assert.NoError(t, cloudWatchWriter.Flush())
+
+	events := client.getLogEvents()
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, `{"service":"orders","level":"info","message":"the **** is out"}`+"\n", *events[0].Message)
+		assert.Equal(t, clock.Now().Add(-time.Hour).UnixMilli(), *events[0].Timestamp)
+	}
+	assert.Equal(t, int64(1), cloudWatchWriter.Stats().FilteredOut)
+}
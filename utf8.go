@@ -0,0 +1,43 @@
+package cloudwatchwriter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// InvalidUTF8Policy decides what happens to a log which isn't valid UTF-8,
+// which CloudWatch rejects along with the rest of its batch, see
+// WithInvalidUTF8Policy.
+type InvalidUTF8Policy int
+
+const (
+	// KeepInvalidUTF8 sends the log as it is.
+	KeepInvalidUTF8 InvalidUTF8Policy = iota
+	// ReplaceInvalidUTF8 replaces each invalid sequence of bytes with the
+	// Unicode replacement character, U+FFFD.
+	ReplaceInvalidUTF8
+	// Base64InvalidUTF8 sends the log encoded with base64, as the "base64"
+	// field of a JSON object, so that binary logs can be decoded again.
+	Base64InvalidUTF8
+)
+
+// base64Field is the field of the JSON object which a log is sent as with the
+// Base64InvalidUTF8 policy.
+const base64Field = "base64"
+
+// sanitizeUTF8 applies the policy to a message, if it isn't valid UTF-8.
+func sanitizeUTF8(message string, policy InvalidUTF8Policy) string {
+	if policy == KeepInvalidUTF8 || utf8.ValidString(message) {
+		return message
+	}
+
+	switch policy {
+	case ReplaceInvalidUTF8:
+		return strings.ToValidUTF8(message, string(utf8.RuneError))
+	default:
+		encoded, _ := json.Marshal(map[string]string{base64Field: base64.StdEncoding.EncodeToString([]byte(message))})
+		return string(encoded)
+	}
+}
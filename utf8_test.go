@@ -0,0 +1,45 @@
+package cloudwatchwriter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterInvalidUTF8Policy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  cloudwatchwriter.InvalidUTF8Policy
+		log     string
+		message string
+	}{
+		{"keep", cloudwatchwriter.KeepInvalidUTF8, "bad \xff log", "bad \xff log"},
+		{"replace", cloudwatchwriter.ReplaceInvalidUTF8, "bad \xff\xfe log", "bad � log"},
+		{"replace valid", cloudwatchwriter.ReplaceInvalidUTF8, "good é log", "good é log"},
+		{"base64", cloudwatchwriter.Base64InvalidUTF8, "\x00\xff", `{"base64":"AP8="}`},
+		{"base64 valid", cloudwatchwriter.Base64InvalidUTF8, "good log", "good log"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &mockClient{}
+			cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+				cloudwatchwriter.WithInvalidUTF8Policy(test.policy),
+			)
+			if err != nil {
+				t.Fatalf("NewWithClient: %v", err)
+			}
+			defer cloudWatchWriter.Close()
+
+			_, err = cloudWatchWriter.Write([]byte(test.log))
+			assert.NoError(t, err)
+			assert.NoError(t, cloudWatchWriter.Flush())
+
+			events := client.getLogEvents()
+			if assert.Len(t, events, 1) {
+				assert.Equal(t, test.message, *events[0].Message)
+			}
+		})
+	}
+}
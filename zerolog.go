@@ -0,0 +1,126 @@
+package cloudwatchwriter
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rs/zerolog"
+)
+
+// WriteLevel implements the zerolog.LevelWriter interface, so that logs below
+// the writer's minimum level, see WithMinLevel, are discarded before they are
+// queued.
+func (c *CloudWatchWriter) WriteLevel(level zerolog.Level, log []byte) (int, error) {
+	if !c.Enabled(level) {
+		return len(log), nil
+	}
+	if c.splitLines {
+		return writeLines(log, func(line []byte) error {
+			_, err := c.writeLevel(context.Background(), level, line, "", nil)
+			return err
+		})
+	}
+	return c.writeLevel(context.Background(), level, log, "", nil)
+}
+
+// Enabled reports whether logs at the given level are sent by WriteLevel,
+// rather than discarded.
+func (c *CloudWatchWriter) Enabled(level zerolog.Level) bool {
+	return level >= c.minLevel
+}
+
+// flushAtLevel sends the queued logs without waiting for the batch interval
+// if the level is at or above the one set by WithFlushLevel. The queueMonitor
+// is only asked to send them, except for fatal and panic logs, which it waits
+// for, whatever the flush level, as zerolog exits or panics as soon as they
+// have been written. A writer which is pumped manually sends them on the
+// calling goroutine.
+func (c *CloudWatchWriter) flushAtLevel(level zerolog.Level) {
+	fatal := isFatal(level)
+	if !fatal && (!c.flushOnLevel || level == zerolog.NoLevel || level < c.flushLevel) {
+		return
+	}
+
+	switch {
+	case c.manualPump:
+		c.pumpQueued()
+	case fatal:
+		_ = c.flush()
+	default:
+		select {
+		case c.sendNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// isFatal reports whether zerolog exits or panics after writing a log at the
+// level.
+func isFatal(level zerolog.Level) bool {
+	return level == zerolog.FatalLevel || level == zerolog.PanicLevel
+}
+
+// FlushHook returns a zerolog.Hook making sure that the fatal and panic logs
+// of the logger it is added to are sent before zerolog exits or panics, for
+// when the writer is written the logs without their level, such as through
+// io.MultiWriter:
+//
+//	logger := zerolog.New(io.MultiWriter(os.Stdout, cloudWatchWriter)).Hook(cloudWatchWriter.FlushHook())
+//
+// A writer given the level with WriteLevel, such as through
+// zerolog.MultiLevelWriter, doesn't need it. The hook tells the writer that a
+// fatal or panic log is about to be written, so that it reads the
+// zerolog.LevelFieldName field of the logs until it has been, then sends it
+// before Write returns, with the logs queued before it.
+func (c *CloudWatchWriter) FlushHook() zerolog.Hook {
+	return zerolog.HookFunc(func(e *zerolog.Event, level zerolog.Level, message string) {
+		if !isFatal(level) || !e.Enabled() {
+			return
+		}
+		c.Lock()
+		c.fatalsPending++
+		c.Unlock()
+	})
+}
+
+// fatalLevel returns the level of a log written without one, read from its
+// zerolog.LevelFieldName field while FlushHook has seen a fatal or panic log
+// which hasn't been written yet, and counts it as written if it is one.
+func (c *CloudWatchWriter) fatalLevel(level zerolog.Level, log []byte) zerolog.Level {
+	c.RLock()
+	pending := c.fatalsPending > 0
+	c.RUnlock()
+	if !pending {
+		return level
+	}
+
+	if level == zerolog.NoLevel {
+		level = logLevel(log)
+	}
+	if isFatal(level) {
+		c.Lock()
+		if c.fatalsPending > 0 {
+			c.fatalsPending--
+		}
+		c.Unlock()
+	}
+	return level
+}
+
+// logLevel returns the level of a JSON log, or zerolog.NoLevel if it hasn't
+// got one.
+func logLevel(log []byte) zerolog.Level {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(log, &fields); err != nil {
+		return zerolog.NoLevel
+	}
+	var name string
+	if err := json.Unmarshal(fields[zerolog.LevelFieldName], &name); err != nil {
+		return zerolog.NoLevel
+	}
+	level, err := zerolog.ParseLevel(name)
+	if err != nil {
+		return zerolog.NoLevel
+	}
+	return level
+}
@@ -0,0 +1,180 @@
+package cloudwatchwriter_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/tracmo/cloudwatchwriter"
+)
+
+func TestCloudWatchWriterMinLevel(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithMinLevel(zerolog.WarnLevel),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	logger := zerolog.New(cloudWatchWriter)
+	logger.Debug().Msg("debug")
+	logger.Info().Msg("info")
+	logger.Warn().Msg("warn")
+	logger.Error().Msg("error")
+	logger.Log().Msg("no level")
+
+	// Logs written directly, without a level, are kept too
+	_, err = cloudWatchWriter.Write([]byte("hello world"))
+	assert.NoError(t, err)
+
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 4, len(logs)) {
+		assert.Equal(t, `{"level":"warn","message":"warn"}`+"\n", *logs[0].Message)
+		assert.Equal(t, `{"level":"error","message":"error"}`+"\n", *logs[1].Message)
+		assert.Equal(t, `{"message":"no level"}`+"\n", *logs[2].Message)
+		assert.Equal(t, "hello world", *logs[3].Message)
+	}
+}
+
+func TestCloudWatchWriterMinLevelDefault(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	// Trace logs are kept without WithMinLevel
+	assert.True(t, cloudWatchWriter.Enabled(zerolog.TraceLevel))
+	logger := zerolog.New(cloudWatchWriter).Level(zerolog.TraceLevel)
+	logger.Trace().Msg("trace")
+	logger.Debug().Msg("debug")
+
+	cloudWatchWriter.Close()
+
+	logs := client.getLogEvents()
+	if assert.Equal(t, 2, len(logs)) {
+		assert.Equal(t, `{"level":"trace","message":"trace"}`+"\n", *logs[0].Message)
+		assert.Equal(t, `{"level":"debug","message":"debug"}`+"\n", *logs[1].Message)
+	}
+}
+
+func TestCloudWatchWriterMinLevelMultiLevelWriter(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, 200*time.Millisecond, "logGroup", "logStream",
+		cloudwatchwriter.WithMinLevel(zerolog.ErrorLevel),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+
+	logger := zerolog.New(zerolog.MultiLevelWriter(cloudWatchWriter))
+	logger.Info().Msg("info")
+	logger.Error().Msg("error")
+	cloudWatchWriter.Close()
+
+	assert.Equal(t, 1, client.numLogs())
+}
+
+func TestCloudWatchWriterFlushLevel(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Hour, "logGroup", "logStream",
+		cloudwatchwriter.WithFlushLevel(zerolog.ErrorLevel),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// An error sends the info log queued before it too, without waiting for
+	// the batch interval
+	logger := zerolog.New(cloudWatchWriter)
+	logger.Info().Msg("info")
+	logger.Error().Msg("error")
+	assert.NoError(t, client.waitForLogs(2, time.Second))
+
+	// Write reads the level field, and waits for a fatal log to be sent
+	_, err = cloudWatchWriter.Write([]byte(`{"level":"fatal","message":"fatal"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, client.numLogs())
+
+	_, err = cloudWatchWriter.WriteLevel(zerolog.PanicLevel, []byte("panic"))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, client.numLogs())
+
+	logger.Warn().Msg("warn")
+	logger.Log().Msg("no level")
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 4, client.numLogs())
+}
+
+func TestCloudWatchWriterFlushLevelManualPump(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Hour, "logGroup", "logStream",
+		cloudwatchwriter.WithFlushLevel(zerolog.ErrorLevel),
+		cloudwatchwriter.WithManualPump(),
+	)
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	logger := zerolog.New(cloudWatchWriter)
+	logger.Info().Msg("info")
+	assert.Equal(t, 0, client.numLogs())
+	logger.Error().Msg("error")
+	assert.Equal(t, 2, client.numLogs())
+}
+
+func TestCloudWatchWriterFatalLogsAlwaysSent(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Hour, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	logger := zerolog.New(cloudWatchWriter)
+	logger.Info().Msg("info")
+	assert.Panics(t, func() {
+		logger.Panic().Msg("panic")
+	})
+	assert.Equal(t, 2, client.numLogs())
+}
+
+func TestCloudWatchWriterFlushHook(t *testing.T) {
+	client := &mockClient{}
+
+	cloudWatchWriter, err := cloudwatchwriter.NewWithClient(client, time.Hour, "logGroup", "logStream")
+	if err != nil {
+		t.Fatalf("NewWithClient: %v", err)
+	}
+	defer cloudWatchWriter.Close()
+
+	// io.MultiWriter only calls Write, so the writer doesn't get the level
+	var console bytes.Buffer
+	logger := zerolog.New(io.MultiWriter(&console, cloudWatchWriter)).Hook(cloudWatchWriter.FlushHook())
+	logger.Info().Msg("info")
+	logger.Error().Msg("error")
+	assert.Equal(t, 0, client.numLogs())
+
+	assert.Panics(t, func() {
+		logger.Panic().Msg("panic")
+	})
+	assert.Equal(t, 3, client.numLogs())
+
+	logger.Info().Msg("info")
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 3, client.numLogs())
+}